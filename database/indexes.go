@@ -0,0 +1,119 @@
+package database
+
+import (
+	"context"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Índices requeridos por cada colección
+var collectionIndexes = map[string][]mongo.IndexModel{
+	"users": {
+		// Listado paginado con los ordenamientos permitidos
+		{Keys: bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "updated_at", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "email", Value: 1}, {Key: "_id", Value: 1}}},
+		// Un usuario por correo electrónico. Los correos se guardan normalizados (ver utils.NormalizeEmail)
+		{Keys: bson.D{{Key: "email", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "first_name", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "last_name", Value: 1}, {Key: "_id", Value: 1}}},
+		// Filtros por estado y tipo
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "type", Value: 1}, {Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}},
+		// Listado y eliminación definitiva de usuarios eliminados
+		{Keys: bson.D{{Key: "deleted_at", Value: 1}}},
+		// Búsqueda por prefijo sobre los términos normalizados
+		{Keys: bson.D{{Key: "search_terms", Value: 1}}},
+		// Filtros por atributos personalizados
+		{Keys: bson.D{{Key: "custom.$**", Value: 1}}},
+		// Ingreso con el teléfono. La unicidad es configurable (PHONE_UNIQUE) y la verifica el servicio
+		{Keys: bson.D{{Key: "phone", Value: 1}}, Options: options.Index().SetSparse(true)},
+		// Vencimiento de cuentas y avisos previos
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetSparse(true)},
+	},
+	"custom_attribute_schemas": {
+		{Keys: bson.D{{Key: "version", Value: 1}}, Options: options.Index().SetUnique(true)},
+	},
+	"user_status_changes": {
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "changed_at", Value: -1}}},
+	},
+	// Una copia por versión de cada usuario (ver recordUserVersion)
+	"user_versions": {
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "version", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "changed_by", Value: 1}}},
+		{Keys: bson.D{{Key: "snapshot.deleted_by", Value: 1}}, Options: options.Index().SetSparse(true)},
+	},
+	// Intentos de ingreso de cada usuario, del más reciente al más antiguo
+	"login_events": {
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "occurred_at", Value: -1}, {Key: "_id", Value: -1}}},
+	},
+	// Listado de GET /api/admin/audit, con sus filtros más comunes
+	"audit_events": {
+		{Keys: bson.D{{Key: "occurred_at", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "actor", Value: 1}, {Key: "occurred_at", Value: -1}}},
+		{Keys: bson.D{{Key: "target.type", Value: 1}, {Key: "target.id", Value: 1}, {Key: "occurred_at", Value: -1}}},
+		{Keys: bson.D{{Key: "target.email", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "action", Value: 1}, {Key: "occurred_at", Value: -1}}},
+		{Keys: bson.D{{Key: "request_id", Value: 1}}},
+	},
+	"email_changes": {
+		{Keys: bson.D{{Key: "confirm_token_hash", Value: 1}}},
+		{Keys: bson.D{{Key: "cancel_token_hash", Value: 1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "status", Value: 1}}},
+	},
+	"invitations": {
+		{Keys: bson.D{{Key: "token_hash", Value: 1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+	},
+	"phone_codes": {
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "purpose", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "phone", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "challenge_hash", Value: 1}}, Options: options.Index().SetSparse(true)},
+		// Los códigos se conservan un día para los límites de envío y luego se eliminan
+		{Keys: bson.D{{Key: "created_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(24 * 60 * 60)},
+	},
+	"user_erasures": {
+		{Keys: bson.D{{Key: "user_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+	},
+	"user_imports": {
+		{Keys: bson.D{{Key: "created_at", Value: -1}}},
+	},
+	"user_bulk_operations": {
+		{Keys: bson.D{{Key: "created_at", Value: -1}}},
+	},
+	"user_exports": {
+		{Keys: bson.D{{Key: "created_at", Value: 1}}},
+	},
+	"sessions": {
+		{Keys: bson.D{{Key: "email", Value: 1}}},
+	},
+}
+
+/** Crea los índices de las colecciones si no existen
+ *
+ * @param ctx context.Context El contexto de la base de datos
+ * @param db *mongo.Database La base de datos
+ * @return error El error al crear los índices
+ */
+func EnsureIndexes(ctx context.Context, db *mongo.Database) error {
+	for collection, indexes := range collectionIndexes {
+		for _, index := range indexes {
+			_, err := db.Collection(collection).Indexes().CreateOne(ctx, index)
+			// Un índice único no se puede crear mientras haya valores repetidos. No se impide que el servicio
+			// inicie: se informa y se vuelve a intentar en el próximo inicio
+			if mongo.IsDuplicateKeyError(err) {
+				log.Printf("No se pudo crear un índice único de %s porque hay valores repetidos: %s", collection, err)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
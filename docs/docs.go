@@ -20,63 +20,155 @@ const docTemplate_swagger = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/admin/users": {
+        "/admin/audit": {
             "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
+                "description": "Cada solicitud que modifica datos queda registrada con quién la hizo, sobre qué recurso, desde dónde y con qué resultado. Si el recurso es un usuario, se incluyen los campos que cambiaron, sin los valores sensibles.",
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Obtiene todos los usuarios",
-                "operationId": "get-users",
+                "summary": "Obtiene una página de eventos de auditoría",
+                "operationId": "get-audit-events",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Cantidad de eventos por página (máximo 200)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor de la página (next_cursor)",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Correo electrónico de quien hizo la acción",
+                        "name": "actor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Método y ruta del endpoint, por ejemplo PATCH /api/admin/users/:id",
+                        "name": "action",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Tipo de recurso, por ejemplo users o invitations",
+                        "name": "target_type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ID del recurso",
+                        "name": "target_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ID de la solicitud (cabecera X-Request-ID)",
+                        "name": "request_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Fecha mínima (RFC 3339)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Fecha máxima (RFC 3339)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Sólo las solicitudes rechazadas",
+                        "name": "failed",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/services.GetUsersResponse"
+                            "$ref": "#/definitions/services.GetAuditEventsResponse"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/services.GetUsersResponse"
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/custom-attributes/schema": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Obtiene la versión vigente del esquema de atributos personalizados",
+                "operationId": "get-custom-schema",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.CustomAttributeSchema"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
                         }
                     }
                 }
             },
-            "post": {
+            "put": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
+                "description": "Los usuarios existentes se validan en segundo plano con la nueva versión; el resultado se consulta en\n/admin/custom-attributes/schema/versions/{version}. Las propiedades con \"x-searchable\": true se incluyen en la búsqueda de usuarios.",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Crea un usuario",
-                "operationId": "create-user",
+                "summary": "Define una nueva versión del esquema de atributos personalizados",
+                "operationId": "save-custom-schema",
                 "parameters": [
                     {
-                        "description": "Datos del usuario",
-                        "name": "CreateUserRequest",
+                        "description": "Esquema y claims",
+                        "name": "SaveCustomSchemaRequest",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/services.CreateUserRequest"
+                            "$ref": "#/definitions/services.SaveCustomSchemaRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "201": {
+                        "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/gin.H"
+                            "$ref": "#/definitions/models.CustomAttributeSchema"
                         }
                     },
                     "400": {
@@ -88,7 +180,7 @@ const docTemplate_swagger = `{
                 }
             }
         },
-        "/admin/users/email/{email}": {
+        "/admin/custom-attributes/schema/versions": {
             "get": {
                 "security": [
                     {
@@ -98,34 +190,19 @@ const docTemplate_swagger = `{
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Obtiene un usuario por su correo electrónico",
-                "operationId": "get-user-by-email",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "Correo electrónico del usuario",
-                        "name": "email",
-                        "in": "path",
-                        "required": true
-                    }
-                ],
+                "summary": "Obtiene las versiones del esquema de atributos personalizados",
+                "operationId": "get-custom-schema-versions",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/gin.H"
-                        }
-                    },
-                    "400": {
-                        "description": "Bad Request",
-                        "schema": {
-                            "$ref": "#/definitions/gin.H"
+                            "$ref": "#/definitions/services.GetCustomSchemasResponse"
                         }
                     }
                 }
             }
         },
-        "/admin/users/{id}": {
+        "/admin/custom-attributes/schema/versions/{version}": {
             "get": {
                 "security": [
                     {
@@ -135,13 +212,13 @@ const docTemplate_swagger = `{
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Obtiene un usuario",
-                "operationId": "get-user",
+                "summary": "Obtiene una versión del esquema con el reporte de usuarios que no la cumplen",
+                "operationId": "get-custom-schema-version",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "ID del usuario",
-                        "name": "id",
+                        "type": "integer",
+                        "description": "Versión del esquema",
+                        "name": "version",
                         "in": "path",
                         "required": true
                     }
@@ -150,54 +227,71 @@ const docTemplate_swagger = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/gin.H"
+                            "$ref": "#/definitions/models.CustomAttributeSchema"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
                             "$ref": "#/definitions/gin.H"
                         }
                     }
                 }
+            }
+        },
+        "/admin/invitations": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Incluye las invitaciones vencidas, que se pueden reenviar.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Obtiene las invitaciones pendientes",
+                "operationId": "get-invitations",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.GetInvitationsResponse"
+                        }
+                    }
+                }
             },
-            "put": {
+            "post": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
+                "description": "Crea el usuario en el estado invited, sin contraseña, y le envía por correo un enlace de un solo uso para aceptar la invitación y elegir su contraseña.",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Actualiza un usuario",
-                "operationId": "update-user",
+                "summary": "Invita a un usuario",
+                "operationId": "create-invitation",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "ID del usuario",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "description": "Datos del usuario",
-                        "name": "UpdateUserRequest",
+                        "description": "Correo electrónico y datos iniciales del usuario",
+                        "name": "CreateInvitationRequest",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/services.UpdateUserRequest"
+                            "$ref": "#/definitions/services.CreateInvitationRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "201": {
+                        "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/gin.H"
+                            "$ref": "#/definitions/services.InvitationResponse"
                         }
                     },
                     "400": {
@@ -205,24 +299,33 @@ const docTemplate_swagger = `{
                         "schema": {
                             "$ref": "#/definitions/gin.H"
                         }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
                     }
                 }
-            },
+            }
+        },
+        "/admin/invitations/{id}": {
             "delete": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
+                "description": "El enlace deja de ser válido y el usuario invitado se elimina.",
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Elimina un usuario",
-                "operationId": "delete-user",
+                "summary": "Revoca una invitación pendiente",
+                "operationId": "revoke-invitation",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "ID del usuario",
+                        "description": "ID de la invitación",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -235,8 +338,8 @@ const docTemplate_swagger = `{
                             "$ref": "#/definitions/gin.H"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
                             "$ref": "#/definitions/gin.H"
                         }
@@ -244,48 +347,37 @@ const docTemplate_swagger = `{
                 }
             }
         },
-        "/admin/users/{id}/password": {
-            "put": {
+        "/admin/invitations/{id}/resend": {
+            "post": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Envía un enlace nuevo; el anterior deja de ser válido y el vencimiento se cuenta desde el reenvío.",
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Cambia la contraseña de un usuario",
-                "operationId": "change-password",
+                "summary": "Reenvía una invitación pendiente",
+                "operationId": "resend-invitation",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "ID del usuario",
+                        "description": "ID de la invitación",
                         "name": "id",
                         "in": "path",
                         "required": true
-                    },
-                    {
-                        "description": "Datos del usuario",
-                        "name": "ChangePasswordRequest",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/services.ChangePasswordRequest"
-                        }
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/gin.H"
+                            "$ref": "#/definitions/services.InvitationResponse"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
                             "$ref": "#/definitions/gin.H"
                         }
@@ -293,69 +385,59 @@ const docTemplate_swagger = `{
                 }
             }
         },
-        "/admin/users/{id}/set-super-admin": {
-            "post": {
+        "/admin/metadata": {
+            "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
+                "description": "Devuelve las rutas de app_metadata que se incluyen en los claims de los tokens.",
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Configura un usuario como super administrador",
-                "operationId": "set-super-admin",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "ID del usuario",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    }
-                ],
+                "summary": "Obtiene la configuración de los metadatos",
+                "operationId": "get-metadata-config",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/gin.H"
-                        }
-                    },
-                    "400": {
-                        "description": "Bad Request",
-                        "schema": {
-                            "$ref": "#/definitions/gin.H"
+                            "$ref": "#/definitions/services.MetadataConfigResponse"
                         }
                     }
                 }
-            }
-        },
-        "/admin/users/{id}/unset-super-admin": {
-            "post": {
+            },
+            "put": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
+                "description": "Define las rutas de app_metadata (separadas por puntos) que se incluyen en los claims de los tokens y el nombre de cada claim. Los tokens ya emitidos no cambian.",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Configura un super administrador como usuario",
-                "operationId": "unset-super-admin",
+                "summary": "Reemplaza la configuración de los metadatos",
+                "operationId": "update-metadata-config",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "ID del usuario",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "Rutas y claims",
+                        "name": "UpdateMetadataConfigRequest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/services.UpdateMetadataConfigRequest"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/gin.H"
+                            "$ref": "#/definitions/services.MetadataConfigResponse"
                         }
                     },
                     "400": {
@@ -367,176 +449,5412 @@ const docTemplate_swagger = `{
                 }
             }
         },
-        "/login": {
-            "post": {
+        "/admin/settings": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Devuelve las preferencias definidas en el servidor (tipo, valor predeterminado y valores permitidos), los valores predeterminados por tipo de usuario y las preferencias que se incluyen en los tokens.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Obtiene las preferencias disponibles y su configuración",
+                "operationId": "get-settings-config",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.SettingsConfigResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Define los valores predeterminados por tipo de usuario y las preferencias que se incluyen en el payload de los tokens. Los tokens ya emitidos no cambian.",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Ingresa un usuario",
-                "operationId": "login-user",
+                "summary": "Reemplaza la configuración de las preferencias",
+                "operationId": "update-settings-config",
                 "parameters": [
                     {
-                        "description": "Datos del usuario",
-                        "name": "loginUserRequest",
+                        "description": "Configuración de las preferencias",
+                        "name": "UpdateSettingsConfigRequest",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/handlers.loginUserRequest"
+                            "$ref": "#/definitions/services.UpdateSettingsConfigRequest"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Respuesta del login",
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/handlers.loginUserResponse"
+                            "$ref": "#/definitions/services.SettingsConfigResponse"
                         }
                     },
                     "400": {
-                        "description": "Error en la solicitud",
+                        "description": "Bad Request",
                         "schema": {
                             "$ref": "#/definitions/gin.H"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "gin.H": {
-            "type": "object",
-            "additionalProperties": true
         },
-        "handlers.loginUserRequest": {
-            "type": "object",
-            "required": [
-                "email",
-                "password"
-            ],
-            "properties": {
-                "email": {
-                    "type": "string"
-                },
-                "password": {
+        "/admin/users": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Obtiene una página de usuarios",
+                "operationId": "get-users",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Cantidad de usuarios por página (máximo 200)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor de la página (next_cursor o prev_cursor)",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "default": "-created_at",
+                        "description": "Campos de ordenamiento separados por coma, con - para orden descendente",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Estados",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Tipos de usuario",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Fecha de creación mínima (RFC 3339)",
+                        "name": "created_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Fecha de creación máxima (RFC 3339)",
+                        "name": "created_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Dominio del correo electrónico",
+                        "name": "email_domain",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Atributos personalizados declarados en el esquema, como custom[department]=ventas",
+                        "name": "custom",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Incluir el total de usuarios que cumplen los filtros",
+                        "name": "include_total",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.GetUsersResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Crea un usuario",
+                "operationId": "create-user",
+                "parameters": [
+                    {
+                        "description": "Datos del usuario",
+                        "name": "CreateUserRequest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/services.CreateUserRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/bulk": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Las acciones posibles son suspend, activate, delete, revoke_sessions y force_password_reset.\nLos usuarios se indican con user_ids o con filter. confirm debe coincidir con la cantidad de usuarios afectados;\nsi no coincide la respuesta indica la cantidad esperada. La operación se procesa en segundo plano y se consulta en /admin/users/bulk/{id}.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Aplica una acción a varios usuarios",
+                "operationId": "bulk-users",
+                "parameters": [
+                    {
+                        "description": "Acción y usuarios afectados",
+                        "name": "BulkUsersRequest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/services.BulkUsersRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Operación iniciada",
+                        "schema": {
+                            "$ref": "#/definitions/models.UserBulkOperation"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/bulk/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Obtiene el avance y el resultado por usuario de una operación masiva",
+                "operationId": "get-bulk-operation",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID de la operación",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.UserBulkOperation"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/deleted": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Obtiene una página de usuarios eliminados",
+                "operationId": "get-deleted-users",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Cantidad de usuarios por página (máximo 200)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor de la página (next_cursor o prev_cursor)",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "default": "-created_at",
+                        "description": "Campos de ordenamiento separados por coma, con - para orden descendente",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Estados",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Tipos de usuario",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Fecha de creación mínima (RFC 3339)",
+                        "name": "created_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Fecha de creación máxima (RFC 3339)",
+                        "name": "created_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Dominio del correo electrónico",
+                        "name": "email_domain",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Atributos personalizados declarados en el esquema, como custom[department]=ventas",
+                        "name": "custom",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Incluir el total de usuarios que cumplen los filtros",
+                        "name": "include_total",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.GetUsersResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/email-collisions": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Los detecta la migración que normaliza los correos al iniciar el servicio. Sólo el usuario marcado como owner tiene la dirección normalizada; los demás deben cambiar su correo o ser borrados.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Obtiene los correos electrónicos compartidos por varios usuarios",
+                "operationId": "get-email-collisions",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.GetEmailCollisionsResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/email/{email}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Obtiene un usuario por su correo electrónico",
+                "operationId": "get-user-by-email",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Correo electrónico del usuario",
+                        "name": "email",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/export": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "La respuesta se escribe a medida que se leen los usuarios de la base de datos. Nunca incluye la contraseña.",
+                "produces": [
+                    "text/csv",
+                    "application/x-ndjson",
+                    "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+                ],
+                "summary": "Exporta los usuarios en CSV, NDJSON o XLSX",
+                "operationId": "export-users",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Formato: csv, ndjson o xlsx",
+                        "name": "format",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Columnas separadas por coma",
+                        "name": "columns",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "default": "-created_at",
+                        "description": "Campos de ordenamiento separados por coma, con - para orden descendente",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Estados",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Tipos de usuario",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Fecha de creación mínima (RFC 3339)",
+                        "name": "created_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Fecha de creación máxima (RFC 3339)",
+                        "name": "created_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Dominio del correo electrónico",
+                        "name": "email_domain",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Atributos personalizados declarados en el esquema, como custom[department]=ventas",
+                        "name": "custom",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/exports": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Acepta los mismos parámetros que /admin/users/export. El archivo se descarga desde el download_url de la exportación una vez completada.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Inicia una exportación de usuarios en segundo plano",
+                "operationId": "start-user-export",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Formato: csv, ndjson o xlsx",
+                        "name": "format",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Columnas separadas por coma",
+                        "name": "columns",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "default": "-created_at",
+                        "description": "Campos de ordenamiento separados por coma, con - para orden descendente",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Estados",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Tipos de usuario",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Fecha de creación mínima (RFC 3339)",
+                        "name": "created_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Fecha de creación máxima (RFC 3339)",
+                        "name": "created_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Dominio del correo electrónico",
+                        "name": "email_domain",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Atributos personalizados declarados en el esquema, como custom[department]=ventas",
+                        "name": "custom",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.userExportResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/exports/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Obtiene el estado de una exportación de usuarios",
+                "operationId": "get-user-export",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID de la exportación",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.userExportResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/exports/{id}/download": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "produces": [
+                    "text/csv",
+                    "application/x-ndjson",
+                    "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+                ],
+                "summary": "Descarga el archivo de una exportación de usuarios",
+                "operationId": "download-user-export",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID de la exportación",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/import": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Con dry_run=true se validan todas las filas y se devuelve el resultado sin guardar usuarios.\nEn caso contrario la importación se procesa en segundo plano y se consulta en /admin/users/imports/{id}.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Importa usuarios desde un archivo CSV o NDJSON",
+                "operationId": "import-users",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "Archivo CSV (con encabezado) o NDJSON",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Formato del archivo (csv o ndjson). Por defecto se deduce de la extensión",
+                        "name": "format",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Objeto JSON que asigna columnas del archivo a campos del usuario, por ejemplo {\\",
+                        "name": "mapping",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Validar sin guardar",
+                        "name": "dry_run",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Qué hacer si el correo ya existe: skip (por defecto) o update. Al actualizar, la contraseña sólo cambia si la fila tiene reset_password=true",
+                        "name": "on_duplicate",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Resultado de la prueba",
+                        "schema": {
+                            "$ref": "#/definitions/models.UserImport"
+                        }
+                    },
+                    "202": {
+                        "description": "Importación iniciada",
+                        "schema": {
+                            "$ref": "#/definitions/models.UserImport"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/imports": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Obtiene las últimas importaciones de usuarios",
+                "operationId": "get-user-imports",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.GetImportsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/imports/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Obtiene el avance y los errores de una importación de usuarios",
+                "operationId": "get-user-import",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID de la importación",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.UserImport"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/search": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "La búsqueda no distingue mayúsculas ni acentos y cada palabra coincide por prefijo",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Busca usuarios por nombre, apellido o correo electrónico",
+                "operationId": "search-users",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Texto a buscar",
+                        "name": "q",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Cantidad máxima de resultados (máximo 100)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.SearchUsersResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Obtiene un usuario",
+                "operationId": "get-user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID del usuario",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag de la versión que ya tiene el cliente",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.GetUserResponse"
+                        }
+                    },
+                    "304": {
+                        "description": "El usuario no cambió"
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Los campos vacíos no se modifican. Para eliminar un campo o modificar sólo algunos usar PATCH. El correo electrónico se cambia con /admin/users/{id}/email-change.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Actualiza un usuario",
+                "operationId": "update-user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID del usuario",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Datos del usuario",
+                        "name": "UpdateUserRequest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/services.UpdateUserRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag del usuario obtenido en GET",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.UpdateUserResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "428": {
+                        "description": "Precondition Required",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Elimina un usuario",
+                "operationId": "delete-user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID del usuario",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Motivo de la eliminación, que queda en el historial de estados",
+                        "name": "reason",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "El cuerpo es un documento JSON Merge Patch (RFC 7396): sólo se modifican los campos presentes y null elimina el campo.\nCon el parámetro fields (máscara de campos) sólo se modifican los campos indicados; los que no estén en el cuerpo se eliminan.\nEl correo electrónico y el estado no se pueden cambiar por esta vía.",
+                "consumes": [
+                    "application/json",
+                    "application/merge-patch+json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Modifica parcialmente un usuario",
+                "operationId": "patch-user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID del usuario",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Campos a modificar separados por coma",
+                        "name": "fields",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Campos a modificar, por ejemplo {\\",
+                        "name": "patch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag del usuario obtenido en GET",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.UpdateUserResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "428": {
+                        "description": "Precondition Required",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/{id}/activate": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Permitido desde invited, pending_verification, suspended, locked y deactivated",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Activa un usuario",
+                "operationId": "activate-user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID del usuario",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Motivo",
+                        "name": "ChangeUserStatusRequest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/services.ChangeUserStatusRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.UpdateUserResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/{id}/app-metadata": {
+            "patch": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Modifica app_metadata, que el usuario no puede modificar. Sigue JSON Merge Patch: los objetos se combinan en profundidad y null elimina la clave.",
+                "consumes": [
+                    "application/json",
+                    "application/merge-patch+json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Modifica los metadatos de la aplicación de un usuario",
+                "operationId": "patch-user-app-metadata",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID del usuario",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Cambios en app_metadata, por ejemplo {\\",
+                        "name": "patch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.UserMetadataResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/{id}/avatar": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Acepta imágenes JPEG, PNG o GIF. Se eliminan los metadatos (EXIF) y se generan miniaturas de 64, 128 y 256 píxeles.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Sube la imagen de perfil de un usuario",
+                "operationId": "set-user-avatar",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID del usuario",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "file",
+                        "description": "Imagen JPEG, PNG o GIF",
+                        "name": "image",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.UpdateUserResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Elimina la imagen de perfil de un usuario",
+                "operationId": "delete-user-avatar",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID del usuario",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.UpdateUserResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/{id}/data-export": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Archivo ZIP con el perfil, la imagen de perfil, las sesiones, el historial de estados, las operaciones masivas que lo incluyeron y las acciones que hizo como administrador. Incluye a los usuarios eliminados.",
+                "produces": [
+                    "application/zip"
+                ],
+                "summary": "Exporta todos los datos de un usuario",
+                "operationId": "export-user-data",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID del usuario",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/{id}/deactivate": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Permitido desde cualquier estado salvo deleted. Bloquea las sesiones del usuario",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Desactiva un usuario",
+                "operationId": "deactivate-user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID del usuario",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Motivo",
+                        "name": "ChangeUserStatusRequest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/services.ChangeUserStatusRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.UpdateUserResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/{id}/email-change": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Obtiene el cambio de correo electrónico pendiente de un usuario",
+                "operationId": "get-user-email-change",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID del usuario",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.EmailChangeResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "El correo no cambia hasta que se confirma con el enlace enviado a la dirección nueva. La dirección actual recibe un aviso con un enlace para cancelar el cambio.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Inicia el cambio de correo electrónico de un usuario",
+                "operationId": "request-user-email-change",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID del usuario",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Correo electrónico nuevo",
+                        "name": "RequestEmailChangeRequest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/services.RequestEmailChangeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.EmailChangeResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Cancela el cambio de correo electrónico pendiente de un usuario",
+                "operationId": "cancel-user-email-change",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID del usuario",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/{id}/erase": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Los datos se anonimizan: el usuario conserva su id y su correo electrónico se reemplaza por un seudónimo en todas las colecciones. Se eliminan sus sesiones y su imagen de perfil. El borrado no se puede deshacer.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Borra los datos personales de un usuario",
+                "operationId": "erase-user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID del usuario",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Motivo y confirmación (correo electrónico del usuario)",
+                        "name": "EraseUserRequest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/services.EraseUserRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.EraseUserResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "410": {
+                        "description": "Gone",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/{id}/expiration": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Desde la fecha indicada el usuario no puede ingresar y sus tokens dejan de ser válidos. Si la cuenta ya había vencido, el usuario vuelve al estado que tenía antes de vencer.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Define o extiende el vencimiento de la cuenta de un usuario",
+                "operationId": "set-user-expiration",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID del usuario",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Fecha de vencimiento",
+                        "name": "SetExpirationRequest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/services.SetExpirationRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.UpdateUserResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Si la cuenta ya había vencido, el usuario vuelve al estado que tenía antes de vencer.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Quita el vencimiento de la cuenta de un usuario",
+                "operationId": "clear-user-expiration",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID del usuario",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.UpdateUserResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/{id}/history": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Cada modificación del usuario guarda una versión. Para cada versión, de la más reciente a la más antigua, se indican la acción, su autor y los campos que cambiaron respecto de la versión anterior registrada.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Obtiene el historial de versiones de un usuario",
+                "operationId": "get-user-history",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID del usuario",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.GetUserHistoryResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/{id}/history/{version}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Devuelve el usuario tal como quedó en esa versión.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Obtiene una versión de un usuario",
+                "operationId": "get-user-version",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID del usuario",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Número de versión",
+                        "name": "version",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.GetUserVersionResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/{id}/history/{version}/restore": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Restaura el nombre, el apellido, el tipo, los atributos personalizados, las preferencias y los metadatos. El correo electrónico, la contraseña, el estado, el teléfono, la imagen de perfil y el vencimiento no cambian. Si cambia el tipo se bloquean las sesiones del usuario. La restauración crea una versión nueva.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Restaura un usuario a una versión anterior",
+                "operationId": "restore-user-version",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID del usuario",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Número de versión a restaurar",
+                        "name": "version",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag del usuario obtenido en GET",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.UpdateUserResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "428": {
+                        "description": "Precondition Required",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/{id}/lock": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Permitido desde active. Bloquea las sesiones del usuario",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Bloquea un usuario",
+                "operationId": "lock-user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID del usuario",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Motivo",
+                        "name": "ChangeUserStatusRequest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/services.ChangeUserStatusRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.UpdateUserResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/{id}/logins": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Incluye los intentos fallidos: contraseña incorrecta (wrong_password), usuario que no puede ingresar por su estado (locked), alcances no permitidos (invalid_scope), código por SMS enviado (mfa_required) o incorrecto (mfa_failed). Cada intento tiene la IP, el user agent y el navegador, el sistema operativo y el tipo de dispositivo obtenidos de él.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Obtiene los intentos de ingreso de un usuario",
+                "operationId": "get-user-logins",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID del usuario",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Cantidad de intentos por página (máximo 200)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor de la página (next_cursor)",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Resultados",
+                        "name": "result",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.GetLoginEventsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/{id}/metadata": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Obtiene los metadatos de un usuario",
+                "operationId": "get-user-metadata",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID del usuario",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.UserMetadataResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/{id}/password": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Cambia la contraseña de un usuario",
+                "operationId": "change-password",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID del usuario",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Datos del usuario",
+                        "name": "ChangePasswordRequest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/services.ChangePasswordRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/{id}/phone": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "También desactiva el segundo factor por SMS. Sirve para recuperar el acceso de un usuario que perdió su teléfono.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Elimina el teléfono de un usuario",
+                "operationId": "remove-user-phone",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID del usuario",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.UpdateUserResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/{id}/require-verification": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Permitido desde invited",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Pasa un usuario invitado a pendiente de verificación",
+                "operationId": "require-user-verification",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID del usuario",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Motivo",
+                        "name": "ChangeUserStatusRequest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/services.ChangeUserStatusRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.UpdateUserResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/{id}/restore": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "El usuario vuelve al estado que tenía antes de ser eliminado",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Restaura un usuario eliminado",
+                "operationId": "restore-user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID del usuario",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "410": {
+                        "description": "Gone",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/{id}/set-super-admin": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Configura un usuario como super administrador",
+                "operationId": "set-super-admin",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID del usuario",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/{id}/status-history": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Obtiene el historial de estados de un usuario",
+                "operationId": "get-user-status-history",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID del usuario",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.GetStatusHistoryResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/{id}/suspend": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Permitido desde active y locked. Bloquea las sesiones del usuario",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Suspende un usuario",
+                "operationId": "suspend-user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID del usuario",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Motivo",
+                        "name": "ChangeUserStatusRequest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/services.ChangeUserStatusRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.UpdateUserResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/{id}/unset-super-admin": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Configura un super administrador como usuario",
+                "operationId": "unset-super-admin",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID del usuario",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/{id}/user-metadata": {
+            "patch": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Modifica user_metadata en nombre del usuario. Sigue JSON Merge Patch: los objetos se combinan en profundidad y null elimina la clave.",
+                "consumes": [
+                    "application/json",
+                    "application/merge-patch+json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Modifica los metadatos propios de un usuario",
+                "operationId": "patch-user-user-metadata",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID del usuario",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Cambios en user_metadata",
+                        "name": "patch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.UserMetadataResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/authz/check": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Evalúa si un sujeto puede realizar una acción sobre un recurso",
+                "operationId": "authz-check",
+                "parameters": [
+                    {
+                        "description": "Sujeto, acción y recurso",
+                        "name": "AuthzCheckRequest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/services.AuthzCheckRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.AuthzCheckResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "403": {
+                        "description": "Sólo los administradores pueden evaluar a otros usuarios por su id",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/authz/check/batch": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Evalúa varias comprobaciones de autorización",
+                "operationId": "authz-check-batch",
+                "parameters": [
+                    {
+                        "description": "Comprobaciones a evaluar",
+                        "name": "AuthzBatchRequest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/services.AuthzBatchRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.AuthzBatchResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "403": {
+                        "description": "Sólo los administradores pueden evaluar a otros usuarios por su id",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/avatars/{id}": {
+            "get": {
+                "description": "Es la URL que se guarda en profile_image y no cambia al subir otra imagen. No requiere autenticación.",
+                "produces": [
+                    "image/jpeg",
+                    "image/png"
+                ],
+                "summary": "Obtiene la imagen de perfil de un usuario",
+                "operationId": "get-avatar",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID del usuario",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Lado de la miniatura cuadrada (64, 128 o 256). Sin este parámetro se obtiene la imagen completa",
+                        "name": "size",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "304": {
+                        "description": "La imagen no cambió (If-None-Match)"
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/email-change/cancel": {
+            "post": {
+                "description": "Recibe el token del enlace enviado a la dirección anterior. No requiere autenticación. Si el cambio ya se confirmó y el enlace no venció, se vuelve a la dirección anterior y se bloquean todas las sesiones del usuario.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Cancela un cambio de correo electrónico",
+                "operationId": "cancel-email-change",
+                "parameters": [
+                    {
+                        "description": "Token del enlace",
+                        "name": "EmailChangeTokenRequest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/services.EmailChangeTokenRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/email-change/confirm": {
+            "post": {
+                "description": "Recibe el token del enlace enviado a la dirección nueva. No requiere autenticación. Las sesiones del usuario siguen siendo válidas.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Confirma un cambio de correo electrónico",
+                "operationId": "confirm-email-change",
+                "parameters": [
+                    {
+                        "description": "Token del enlace",
+                        "name": "EmailChangeTokenRequest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/services.EmailChangeTokenRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.UpdateUserResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/invitations/accept": {
+            "post": {
+                "description": "Recibe el token del enlace enviado por correo. No requiere autenticación. El usuario elige su contraseña, puede corregir su nombre y pasa al estado active; después ingresa con POST /login.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Acepta una invitación",
+                "operationId": "accept-invitation",
+                "parameters": [
+                    {
+                        "description": "Token del enlace, contraseña y datos del perfil",
+                        "name": "AcceptInvitationRequest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/services.AcceptInvitationRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.UpdateUserResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/login": {
+            "post": {
+                "description": "Se puede ingresar con el correo electrónico o con el teléfono verificado. Si el usuario tiene activo el segundo factor por SMS, se envía un código al teléfono y se responde 202 con el token para completar el ingreso en POST /login/mfa.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Ingresa un usuario",
+                "operationId": "login-user",
+                "parameters": [
+                    {
+                        "description": "Datos del usuario",
+                        "name": "loginUserRequest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.loginUserRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Respuesta del login",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.loginUserResponse"
+                        }
+                    },
+                    "202": {
+                        "description": "Se requiere el código enviado por SMS",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.loginMFAResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Error en la solicitud",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "403": {
+                        "description": "El usuario no está activo",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "409": {
+                        "description": "El teléfono está asociado a más de un usuario",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "429": {
+                        "description": "Se enviaron demasiados códigos",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/login/mfa": {
+            "post": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Completa el ingreso de un usuario con el segundo factor por SMS",
+                "operationId": "login-user-mfa",
+                "parameters": [
+                    {
+                        "description": "Token de POST /login y código recibido",
+                        "name": "loginMFARequest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.loginMFARequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Respuesta del login",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.loginUserResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Error en la solicitud",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "401": {
+                        "description": "El código no es correcto o venció",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "403": {
+                        "description": "El usuario no está activo",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/me": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Obtiene el usuario de la sesión",
+                "operationId": "get-me",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.GetUserResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Igual que PATCH /admin/users/{id} (JSON Merge Patch y máscara de campos), pero sólo se pueden modificar first_name y last_name.",
+                "consumes": [
+                    "application/json",
+                    "application/merge-patch+json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Modifica el perfil del usuario de la sesión",
+                "operationId": "patch-me",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Campos a modificar separados por coma",
+                        "name": "fields",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Campos a modificar, por ejemplo {\\",
+                        "name": "patch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag obtenido en GET /me",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.UpdateUserResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/me/activity": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Permite al usuario detectar ingresos que no reconoce. Incluye los intentos fallidos.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Obtiene los intentos de ingreso a la cuenta del usuario de la sesión",
+                "operationId": "get-my-activity",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Cantidad de intentos por página (máximo 200)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor de la página (next_cursor)",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Resultados",
+                        "name": "result",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.GetLoginEventsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/me/avatar": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Acepta imágenes JPEG, PNG o GIF. Se eliminan los metadatos (EXIF) y se generan miniaturas de 64, 128 y 256 píxeles.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Sube la imagen de perfil del usuario de la sesión",
+                "operationId": "set-my-avatar",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "Imagen JPEG, PNG o GIF",
+                        "name": "image",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.UpdateUserResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Elimina la imagen de perfil del usuario de la sesión",
+                "operationId": "delete-my-avatar",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.UpdateUserResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/me/data-export": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Igual que GET /admin/users/{id}/data-export.",
+                "produces": [
+                    "application/zip"
+                ],
+                "summary": "Exporta todos los datos del usuario de la sesión",
+                "operationId": "export-my-data",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    }
+                }
+            }
+        },
+        "/me/email-change": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Obtiene el cambio de correo electrónico pendiente del usuario de la sesión",
+                "operationId": "get-my-email-change",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.EmailChangeResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Requiere la contraseña actual. El correo no cambia hasta que se confirma con el enlace enviado a la dirección nueva.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Inicia el cambio de correo electrónico del usuario de la sesión",
+                "operationId": "request-my-email-change",
+                "parameters": [
+                    {
+                        "description": "Correo electrónico nuevo y contraseña actual",
+                        "name": "RequestOwnEmailChangeRequest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/services.RequestOwnEmailChangeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.EmailChangeResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Cancela el cambio de correo electrónico pendiente del usuario de la sesión",
+                "operationId": "cancel-my-email-change",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/me/metadata": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Incluye app_metadata, que sólo pueden modificar los administradores, y user_metadata, que modifica el propio usuario.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Obtiene los metadatos del usuario de la sesión",
+                "operationId": "get-my-metadata",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.UserMetadataResponse"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Modifica sólo user_metadata. Sigue JSON Merge Patch: los objetos se combinan en profundidad y null elimina la clave.",
+                "consumes": [
+                    "application/json",
+                    "application/merge-patch+json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Modifica los metadatos propios del usuario de la sesión",
+                "operationId": "patch-my-metadata",
+                "parameters": [
+                    {
+                        "description": "Cambios en user_metadata, por ejemplo {\\",
+                        "name": "patch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.UserMetadataResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/me/password": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Requiere la contraseña actual. Las demás sesiones del usuario se revocan.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Cambia la contraseña del usuario de la sesión",
+                "operationId": "change-my-password",
+                "parameters": [
+                    {
+                        "description": "Contraseña actual y nueva",
+                        "name": "ChangeOwnPasswordRequest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/services.ChangeOwnPasswordRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/me/phone": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Requiere la contraseña actual. Envía un código por SMS al teléfono, que debe incluir el código de país (formato E.164). El teléfono del usuario no cambia hasta que se confirma el código.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Inicia la verificación de un teléfono del usuario de la sesión",
+                "operationId": "start-my-phone-verification",
+                "parameters": [
+                    {
+                        "description": "Teléfono y contraseña actual",
+                        "name": "StartPhoneVerificationRequest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/services.StartPhoneVerificationRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.PhoneVerificationResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Requiere la contraseña actual. También desactiva el segundo factor por SMS.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Elimina el teléfono del usuario de la sesión",
+                "operationId": "remove-my-phone",
+                "parameters": [
+                    {
+                        "description": "Contraseña actual",
+                        "name": "RemoveOwnPhoneRequest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/services.RemoveOwnPhoneRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.UpdateUserResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/me/phone/mfa": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Requiere la contraseña actual. Para activarlo el usuario debe tener un teléfono verificado. Con el segundo factor activo, POST /login responde 202 y el ingreso se completa en POST /login/mfa.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Activa o desactiva el segundo factor por SMS del usuario de la sesión",
+                "operationId": "set-my-phone-mfa",
+                "parameters": [
+                    {
+                        "description": "Si se activa y contraseña actual",
+                        "name": "SetPhoneMFARequest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/services.SetPhoneMFARequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.UpdateUserResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/me/phone/verify": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Recibe el código enviado por SMS. El teléfono reemplaza al anterior del usuario.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Confirma la verificación del teléfono del usuario de la sesión",
+                "operationId": "confirm-my-phone-verification",
+                "parameters": [
+                    {
+                        "description": "Código recibido",
+                        "name": "ConfirmPhoneVerificationRequest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/services.ConfirmPhoneVerificationRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.UpdateUserResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/me/sessions": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Obtiene las sesiones vigentes del usuario de la sesión",
+                "operationId": "get-my-sessions",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.GetSessionsResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/me/sessions/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Los tokens de la sesión dejan de ser válidos. Revocar la sesión actual equivale a cerrar sesión.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Revoca una sesión del usuario de la sesión",
+                "operationId": "revoke-my-session",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID de la sesión",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/me/settings": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Cada preferencia toma el valor elegido por el usuario, el predeterminado para su tipo de usuario o el definido en el servidor, en ese orden.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Obtiene las preferencias del usuario de la sesión",
+                "operationId": "get-my-settings",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.UserSettingsResponse"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Sigue JSON Merge Patch: sólo cambian las preferencias enviadas y null vuelve al valor predeterminado. Las preferencias disponibles se listan en GET /admin/settings.",
+                "consumes": [
+                    "application/json",
+                    "application/merge-patch+json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Modifica las preferencias del usuario de la sesión",
+                "operationId": "patch-my-settings",
+                "parameters": [
+                    {
+                        "description": "Preferencias a modificar, por ejemplo {\\",
+                        "name": "patch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.UserSettingsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        },
+        "/token": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Crea un token de acceso con un subconjunto de los alcances del token actual",
+                "operationId": "create-scoped-token",
+                "parameters": [
+                    {
+                        "description": "Alcances solicitados",
+                        "name": "createTokenRequest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.createTokenRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Token de acceso",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.createTokenResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Error en la solicitud",
+                        "schema": {
+                            "$ref": "#/definitions/gin.H"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "gin.H": {
+            "type": "object",
+            "additionalProperties": true
+        },
+        "handlers.createTokenRequest": {
+            "type": "object",
+            "required": [
+                "scopes"
+            ],
+            "properties": {
+                "scopes": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "handlers.createTokenResponse": {
+            "type": "object",
+            "properties": {
+                "access_token": {
+                    "type": "string"
+                },
+                "access_token_expires_at": {
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "handlers.loginMFARequest": {
+            "type": "object",
+            "required": [
+                "code",
+                "mfa_token"
+            ],
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "mfa_token": {
+                    "description": "Token recibido en la respuesta de POST /login",
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "handlers.loginMFAResponse": {
+            "type": "object",
+            "properties": {
+                "expires_at": {
+                    "type": "string"
+                },
+                "mfa_required": {
+                    "type": "boolean"
+                },
+                "mfa_token": {
+                    "type": "string"
+                },
+                "phone": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.loginUserRequest": {
+            "type": "object",
+            "required": [
+                "password"
+            ],
+            "properties": {
+                "email": {
+                    "type": "string"
+                },
+                "password": {
                     "type": "string",
                     "minLength": 6
+                },
+                "phone": {
+                    "description": "Teléfono verificado, en lugar del correo electrónico",
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "handlers.loginUserResponse": {
+            "type": "object",
+            "properties": {
+                "access_token": {
+                    "type": "string"
+                },
+                "access_token_expires_at": {
+                    "type": "string"
+                },
+                "refresh_token": {
+                    "type": "string"
+                },
+                "refresh_token_expires_at": {
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "session_id": {
+                    "type": "string"
+                },
+                "user": {
+                    "$ref": "#/definitions/handlers.userResponse"
+                }
+            }
+        },
+        "handlers.userExportResponse": {
+            "type": "object",
+            "properties": {
+                "_id": {
+                    "type": "string"
+                },
+                "columns": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "created_by": {
+                    "type": "string"
+                },
+                "download_url": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "finished_at": {
+                    "type": "string"
+                },
+                "format": {
+                    "type": "string"
+                },
+                "rows": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.userResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "first_name": {
+                    "type": "string"
+                },
+                "last_name": {
+                    "type": "string"
+                },
+                "password_changed_at": {
+                    "type": "string"
+                },
+                "password_reset_required": {
+                    "description": "Si es verdadero, el usuario debe cambiar su contraseña",
+                    "type": "boolean"
+                },
+                "profile_image": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.AuditChange": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "type": "string"
+                },
+                "from": {},
+                "to": {}
+            }
+        },
+        "models.AuditEvent": {
+            "type": "object",
+            "properties": {
+                "_id": {
+                    "type": "string"
+                },
+                "action": {
+                    "description": "Método y ruta del endpoint, por ejemplo \"PATCH /api/admin/users/:id\"",
+                    "type": "string"
+                },
+                "actor": {
+                    "description": "Correo electrónico del usuario de la sesión. Vacío en las solicitudes sin sesión, como el login",
+                    "type": "string"
+                },
+                "actor_type": {
+                    "type": "string"
+                },
+                "changes": {
+                    "description": "Campos del usuario afectado que cambiaron. Los valores sensibles se reemplazan por AuditRedacted",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.AuditChange"
+                    }
+                },
+                "client_ip": {
+                    "type": "string"
+                },
+                "occurred_at": {
+                    "type": "string"
+                },
+                "request_id": {
+                    "type": "string"
+                },
+                "status": {
+                    "description": "Código de estado HTTP de la respuesta",
+                    "type": "integer"
+                },
+                "target": {
+                    "$ref": "#/definitions/models.AuditTarget"
+                },
+                "user_agent": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.AuditTarget": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "type": {
+                    "description": "Recurso de la ruta, por ejemplo users, invitations o custom-attributes",
+                    "type": "string"
+                }
+            }
+        },
+        "models.CustomAttributeInvalidUser": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string"
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.CustomAttributeReport": {
+            "type": "object",
+            "properties": {
+                "checked_users": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "finished_at": {
+                    "type": "string"
+                },
+                "invalid_count": {
+                    "type": "integer"
+                },
+                "invalid_users": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.CustomAttributeInvalidUser"
+                    }
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.CustomAttributeSchema": {
+            "type": "object",
+            "properties": {
+                "_id": {
+                    "type": "string"
+                },
+                "claims": {
+                    "description": "Atributo =\u003e nombre del claim en el token",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "created_by": {
+                    "type": "string"
+                },
+                "report": {
+                    "$ref": "#/definitions/models.CustomAttributeReport"
+                },
+                "schema": {
+                    "type": "object"
+                },
+                "version": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.EmailChange": {
+            "type": "object",
+            "properties": {
+                "_id": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "finished_at": {
+                    "type": "string"
+                },
+                "new_email": {
+                    "type": "string"
+                },
+                "old_email": {
+                    "type": "string"
+                },
+                "requested_by": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.EmailCollision": {
+            "type": "object",
+            "properties": {
+                "_id": {
+                    "type": "string"
+                },
+                "detected_at": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "users": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.EmailCollisionUser"
+                    }
+                }
+            }
+        },
+        "models.EmailCollisionUser": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "deleted_at": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "owner": {
+                    "description": "Si es verdadero, el usuario tiene la dirección normalizada y puede seguir ingresando con ella",
+                    "type": "boolean"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.Invitation": {
+            "type": "object",
+            "properties": {
+                "_id": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "finished_at": {
+                    "type": "string"
+                },
+                "invited_by": {
+                    "type": "string"
+                },
+                "resend_count": {
+                    "description": "Cantidad de veces que se reenvió. Cada reenvío invalida el enlace anterior",
+                    "type": "integer"
+                },
+                "revoked_by": {
+                    "type": "string"
+                },
+                "sent_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.LoginDevice": {
+            "type": "object",
+            "properties": {
+                "browser": {
+                    "type": "string"
+                },
+                "os": {
+                    "type": "string"
+                },
+                "type": {
+                    "description": "desktop, mobile, tablet o bot",
+                    "type": "string"
+                }
+            }
+        },
+        "models.LoginEvent": {
+            "type": "object",
+            "properties": {
+                "_id": {
+                    "type": "string"
+                },
+                "client_ip": {
+                    "type": "string"
+                },
+                "device": {
+                    "$ref": "#/definitions/models.LoginDevice"
+                },
+                "method": {
+                    "description": "Paso del ingreso: email o phone (la contraseña) o mfa (el código enviado por SMS)",
+                    "type": "string"
+                },
+                "occurred_at": {
+                    "type": "string"
+                },
+                "reason": {
+                    "description": "Motivo del rechazo, si el intento no fue exitoso",
+                    "type": "string"
+                },
+                "result": {
+                    "description": "Resultado del intento (ver services/login_events.go)",
+                    "type": "string"
+                },
+                "session_id": {
+                    "description": "Sesión creada por el ingreso exitoso",
+                    "type": "string"
+                },
+                "user_agent": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.MetadataClaim": {
+            "type": "object",
+            "required": [
+                "claim",
+                "path"
+            ],
+            "properties": {
+                "claim": {
+                    "description": "Nombre del claim en el token",
+                    "type": "string"
+                },
+                "path": {
+                    "description": "Ruta separada por puntos, por ejemplo billing.plan",
+                    "type": "string"
+                }
+            }
+        },
+        "models.MetadataConfig": {
+            "type": "object",
+            "properties": {
+                "claims": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.MetadataClaim"
+                    }
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "updated_by": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.SettingsConfig": {
+            "type": "object",
+            "properties": {
+                "token_keys": {
+                    "description": "Preferencias que se incluyen en el payload de los tokens",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "type_defaults": {
+                    "description": "Tipo de usuario =\u003e preferencia =\u003e valor predeterminado, que reemplaza al definido en el servidor",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "object",
+                        "additionalProperties": true
+                    }
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "updated_by": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.User": {
+            "type": "object",
+            "properties": {
+                "_id": {
+                    "type": "string"
+                },
+                "app_metadata": {
+                    "description": "Metadatos libres que sólo modifican los administradores, por ejemplo permisos contratados o ids de facturación",
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "avatar": {
+                    "description": "Imagen de perfil subida al almacenamiento. Se sirve en la URL de ProfileImage",
+                    "$ref": "#/definitions/models.UserAvatar"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "custom": {
+                    "description": "Atributos personalizados, validados con el esquema definido por los administradores",
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "deleted_at": {
+                    "type": "string"
+                },
+                "deleted_by": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "erased_at": {
+                    "description": "Fecha en que se borraron los datos personales del usuario. El borrado no se puede deshacer",
+                    "type": "string"
+                },
+                "expiration_reminded_at": {
+                    "description": "Fecha en que se avisó al usuario que su cuenta está por vencer",
+                    "type": "string"
+                },
+                "expires_at": {
+                    "description": "Fecha en que vence la cuenta. Desde entonces el usuario no puede ingresar y pasa al estado expired",
+                    "type": "string"
+                },
+                "first_name": {
+                    "type": "string"
+                },
+                "last_login_at": {
+                    "description": "Fecha del último ingreso y cantidad de ingresos. Los intentos se registran en login_events",
+                    "type": "string"
+                },
+                "last_name": {
+                    "type": "string"
+                },
+                "login_count": {
+                    "type": "integer"
+                },
+                "password": {
+                    "type": "string"
+                },
+                "password_changed_at": {
+                    "type": "string"
+                },
+                "password_reset_required": {
+                    "description": "Si es verdadero, el usuario debe cambiar su contraseña antes de seguir usando la aplicación",
+                    "type": "boolean"
+                },
+                "phone": {
+                    "description": "Teléfono verificado en formato E.164 (ver utils.NormalizePhone). Se puede usar para ingresar",
+                    "type": "string"
+                },
+                "phone_mfa": {
+                    "description": "Si es verdadero, el ingreso requiere además un código enviado por SMS al teléfono",
+                    "type": "boolean"
+                },
+                "phone_verified_at": {
+                    "type": "string"
+                },
+                "profile_image": {
+                    "type": "string"
+                },
+                "settings": {
+                    "description": "Preferencias elegidas por el usuario. Las demás toman el valor predeterminado (ver services/user_settings_service.go)",
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "status": {
+                    "type": "string"
+                },
+                "status_changed_at": {
+                    "type": "string"
+                },
+                "status_reason": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "user_metadata": {
+                    "description": "Metadatos libres que modifica el propio usuario, por ejemplo el estado de la interfaz",
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "version": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.UserAvatar": {
+            "type": "object",
+            "properties": {
+                "content_type": {
+                    "type": "string"
+                },
+                "id": {
+                    "description": "Identifica la versión de la imagen. Cambia con cada imagen subida",
+                    "type": "string"
+                },
+                "sizes": {
+                    "description": "Lados de las miniaturas cuadradas disponibles, en píxeles",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.UserBulkOperation": {
+            "type": "object",
+            "properties": {
+                "_id": {
+                    "type": "string"
+                },
+                "action": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "created_by": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "finished_at": {
+                    "type": "string"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.UserBulkOperationItem"
+                    }
+                },
+                "processed": {
+                    "type": "integer"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "skipped": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "succeeded": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.UserBulkOperationItem": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.UserErasure": {
+            "type": "object",
+            "properties": {
+                "_id": {
+                    "type": "string"
+                },
+                "erased_at": {
+                    "type": "string"
+                },
+                "erased_by": {
+                    "type": "string"
+                },
+                "pseudonym": {
+                    "description": "Correo electrónico con el que se reemplazó el del usuario en todas las colecciones",
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.UserImport": {
+            "type": "object",
+            "properties": {
+                "_id": {
+                    "type": "string"
+                },
+                "created": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "created_by": {
+                    "type": "string"
+                },
+                "dry_run": {
+                    "type": "boolean"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "file_name": {
+                    "type": "string"
+                },
+                "finished_at": {
+                    "type": "string"
+                },
+                "format": {
+                    "type": "string"
+                },
+                "on_duplicate": {
+                    "type": "string"
+                },
+                "processed_rows": {
+                    "type": "integer"
+                },
+                "row_errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.UserImportRowError"
+                    }
+                },
+                "skipped": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "total_rows": {
+                    "type": "integer"
+                },
+                "updated": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.UserImportRowError": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "row": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.UserStatusChange": {
+            "type": "object",
+            "properties": {
+                "_id": {
+                    "type": "string"
+                },
+                "changed_at": {
+                    "type": "string"
+                },
+                "changed_by": {
+                    "type": "string"
+                },
+                "from": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "to": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.UserVersion": {
+            "type": "object",
+            "properties": {
+                "_id": {
+                    "type": "string"
+                },
+                "action": {
+                    "type": "string"
+                },
+                "changed_at": {
+                    "type": "string"
+                },
+                "changed_by": {
+                    "type": "string"
+                },
+                "snapshot": {
+                    "description": "El usuario en esta versión, sin la contraseña",
+                    "$ref": "#/definitions/models.User"
+                },
+                "user_id": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "integer"
+                }
+            }
+        },
+        "services.AcceptInvitationRequest": {
+            "type": "object",
+            "required": [
+                "password",
+                "token"
+            ],
+            "properties": {
+                "first_name": {
+                    "description": "Si se indican, reemplazan el nombre y el apellido cargados por el administrador",
+                    "type": "string"
+                },
+                "last_name": {
+                    "type": "string"
+                },
+                "password": {
+                    "type": "string"
+                },
+                "token": {
+                    "description": "Token recibido en el enlace del correo",
+                    "type": "string"
+                }
+            }
+        },
+        "services.AuthzBatchRequest": {
+            "type": "object",
+            "required": [
+                "checks"
+            ],
+            "properties": {
+                "checks": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "$ref": "#/definitions/services.AuthzCheckRequest"
+                    }
+                }
+            }
+        },
+        "services.AuthzBatchResponse": {
+            "type": "object",
+            "properties": {
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.AuthzCheckResponse"
+                    }
+                },
+                "ttl": {
+                    "description": "Tiempo en segundos durante el cual todas las decisiones pueden ser cacheadas",
+                    "type": "integer"
+                }
+            }
+        },
+        "services.AuthzCheckRequest": {
+            "type": "object",
+            "required": [
+                "action",
+                "resource",
+                "subject"
+            ],
+            "properties": {
+                "action": {
+                    "type": "string"
+                },
+                "resource": {
+                    "type": "string"
+                },
+                "subject": {
+                    "$ref": "#/definitions/services.AuthzSubject"
+                }
+            }
+        },
+        "services.AuthzCheckResponse": {
+            "type": "object",
+            "properties": {
+                "allowed": {
+                    "type": "boolean"
+                },
+                "decision": {
+                    "type": "string"
+                },
+                "reasons": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "ttl": {
+                    "description": "Tiempo en segundos durante el cual la decisión puede ser cacheada",
+                    "type": "integer"
+                },
+                "user_id": {
+                    "type": "string"
+                },
+                "user_type": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.AuthzSubject": {
+            "type": "object",
+            "properties": {
+                "token": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.BulkUsersFilter": {
+            "type": "object",
+            "properties": {
+                "created_from": {
+                    "type": "string"
+                },
+                "created_to": {
+                    "type": "string"
+                },
+                "custom": {
+                    "description": "Atributo personalizado =\u003e valor",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "email_domain": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "type": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "services.BulkUsersRequest": {
+            "type": "object",
+            "required": [
+                "action"
+            ],
+            "properties": {
+                "action": {
+                    "description": "suspend, activate, delete, revoke_sessions o force_password_reset",
+                    "type": "string"
+                },
+                "confirm": {
+                    "description": "Cantidad de usuarios afectados. Debe coincidir con la cantidad de usuarios encontrados",
+                    "type": "integer"
+                },
+                "filter": {
+                    "description": "Filtro de los usuarios. No se puede usar junto con user_ids",
+                    "$ref": "#/definitions/services.BulkUsersFilter"
+                },
+                "reason": {
+                    "description": "Motivo del cambio de estado. Requerido para suspend, activate y delete",
+                    "type": "string"
+                },
+                "user_ids": {
+                    "description": "Ids de los usuarios. No se puede usar junto con filter",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "services.ChangeOwnPasswordRequest": {
+            "type": "object",
+            "required": [
+                "current_password"
+            ],
+            "properties": {
+                "current_password": {
+                    "type": "string"
+                },
+                "password": {
+                    "type": "string"
+                },
+                "password_confirmation": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.ChangePasswordRequest": {
+            "type": "object",
+            "properties": {
+                "password": {
+                    "type": "string"
+                },
+                "password_confirmation": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.ChangeUserStatusRequest": {
+            "type": "object",
+            "required": [
+                "reason"
+            ],
+            "properties": {
+                "reason": {
+                    "description": "Motivo del cambio de estado, que queda registrado en el historial",
+                    "type": "string"
+                }
+            }
+        },
+        "services.ConfirmPhoneVerificationRequest": {
+            "type": "object",
+            "required": [
+                "code"
+            ],
+            "properties": {
+                "code": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.CreateInvitationRequest": {
+            "type": "object",
+            "required": [
+                "email"
+            ],
+            "properties": {
+                "email": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "description": "Fecha en que vence la cuenta, para usuarios temporales",
+                    "type": "string"
+                },
+                "first_name": {
+                    "type": "string"
+                },
+                "last_name": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.CreateUserRequest": {
+            "type": "object",
+            "properties": {
+                "custom": {
+                    "description": "Atributos personalizados, según el esquema definido por los administradores",
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "email": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "description": "Fecha en que vence la cuenta, para usuarios temporales",
+                    "type": "string"
+                },
+                "first_name": {
+                    "type": "string"
+                },
+                "last_name": {
+                    "type": "string"
+                },
+                "password": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.EmailChangeResponse": {
+            "type": "object",
+            "properties": {
+                "email_change": {
+                    "$ref": "#/definitions/models.EmailChange"
+                }
+            }
+        },
+        "services.EmailChangeTokenRequest": {
+            "type": "object",
+            "required": [
+                "token"
+            ],
+            "properties": {
+                "token": {
+                    "description": "Token recibido en el enlace del correo",
+                    "type": "string"
+                }
+            }
+        },
+        "services.EraseUserRequest": {
+            "type": "object",
+            "required": [
+                "confirm",
+                "reason"
+            ],
+            "properties": {
+                "confirm": {
+                    "description": "Correo electrónico del usuario, para confirmar que se borra el usuario correcto",
+                    "type": "string"
+                },
+                "reason": {
+                    "description": "Motivo del borrado, por ejemplo el número de la solicitud del titular de los datos",
+                    "type": "string"
+                }
+            }
+        },
+        "services.EraseUserResponse": {
+            "type": "object",
+            "properties": {
+                "erasure": {
+                    "$ref": "#/definitions/models.UserErasure"
+                }
+            }
+        },
+        "services.GetAuditEventsResponse": {
+            "type": "object",
+            "properties": {
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.AuditEvent"
+                    }
+                },
+                "next_cursor": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.GetCustomSchemasResponse": {
+            "type": "object",
+            "properties": {
+                "schemas": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.CustomAttributeSchema"
+                    }
+                }
+            }
+        },
+        "services.GetEmailCollisionsResponse": {
+            "type": "object",
+            "properties": {
+                "collisions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.EmailCollision"
+                    }
+                }
+            }
+        },
+        "services.GetImportsResponse": {
+            "type": "object",
+            "properties": {
+                "imports": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.UserImport"
+                    }
+                }
+            }
+        },
+        "services.GetInvitationsResponse": {
+            "type": "object",
+            "properties": {
+                "invitations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Invitation"
+                    }
+                }
+            }
+        },
+        "services.GetLoginEventsResponse": {
+            "type": "object",
+            "properties": {
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.LoginEvent"
+                    }
+                },
+                "next_cursor": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.GetSessionsResponse": {
+            "type": "object",
+            "properties": {
+                "sessions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.UserSession"
+                    }
                 }
             }
         },
-        "handlers.loginUserResponse": {
+        "services.GetStatusHistoryResponse": {
             "type": "object",
             "properties": {
-                "access_token": {
+                "changes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.UserStatusChange"
+                    }
+                }
+            }
+        },
+        "services.GetUserHistoryResponse": {
+            "type": "object",
+            "properties": {
+                "versions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.UserHistoryEntry"
+                    }
+                }
+            }
+        },
+        "services.GetUserResponse": {
+            "type": "object",
+            "properties": {
+                "user": {
+                    "$ref": "#/definitions/models.User"
+                }
+            }
+        },
+        "services.GetUserVersionResponse": {
+            "type": "object",
+            "properties": {
+                "version": {
+                    "$ref": "#/definitions/models.UserVersion"
+                }
+            }
+        },
+        "services.GetUsersResponse": {
+            "type": "object",
+            "properties": {
+                "next_cursor": {
                     "type": "string"
                 },
-                "access_token_expires_at": {
+                "prev_cursor": {
                     "type": "string"
                 },
-                "refresh_token": {
-                    "type": "string"
+                "total": {
+                    "type": "integer"
                 },
-                "refresh_token_expires_at": {
+                "users": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.User"
+                    }
+                }
+            }
+        },
+        "services.InvitationResponse": {
+            "type": "object",
+            "properties": {
+                "invitation": {
+                    "$ref": "#/definitions/models.Invitation"
+                }
+            }
+        },
+        "services.MetadataConfigResponse": {
+            "type": "object",
+            "properties": {
+                "config": {
+                    "$ref": "#/definitions/models.MetadataConfig"
+                }
+            }
+        },
+        "services.PhoneVerificationResponse": {
+            "type": "object",
+            "properties": {
+                "expires_at": {
                     "type": "string"
                 },
-                "session_id": {
+                "phone": {
+                    "description": "Teléfono al que se envió el código, oculto salvo los últimos dígitos",
                     "type": "string"
-                },
-                "user": {
-                    "$ref": "#/definitions/handlers.userResponse"
                 }
             }
         },
-        "handlers.userResponse": {
+        "services.RemoveOwnPhoneRequest": {
             "type": "object",
+            "required": [
+                "current_password"
+            ],
             "properties": {
-                "created_at": {
+                "current_password": {
                     "type": "string"
-                },
+                }
+            }
+        },
+        "services.RequestEmailChangeRequest": {
+            "type": "object",
+            "required": [
+                "email"
+            ],
+            "properties": {
                 "email": {
                     "type": "string"
-                },
-                "first_name": {
-                    "type": "string"
-                },
-                "last_name": {
+                }
+            }
+        },
+        "services.RequestOwnEmailChangeRequest": {
+            "type": "object",
+            "required": [
+                "current_password",
+                "email"
+            ],
+            "properties": {
+                "current_password": {
                     "type": "string"
                 },
-                "password_changed_at": {
+                "email": {
                     "type": "string"
+                }
+            }
+        },
+        "services.SaveCustomSchemaRequest": {
+            "type": "object",
+            "required": [
+                "schema"
+            ],
+            "properties": {
+                "claims": {
+                    "description": "Atributo =\u003e nombre del claim con el que se incluye en los tokens",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
                 },
-                "profile_image": {
-                    "type": "string"
+                "schema": {
+                    "description": "Esquema JSON (draft 7) de los atributos personalizados. La raíz debe ser de tipo object",
+                    "type": "object"
                 }
             }
         },
-        "models.User": {
+        "services.SearchUserResult": {
             "type": "object",
             "properties": {
                 "_id": {
                     "type": "string"
                 },
+                "app_metadata": {
+                    "description": "Metadatos libres que sólo modifican los administradores, por ejemplo permisos contratados o ids de facturación",
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "avatar": {
+                    "description": "Imagen de perfil subida al almacenamiento. Se sirve en la URL de ProfileImage",
+                    "$ref": "#/definitions/models.UserAvatar"
+                },
                 "created_at": {
                     "type": "string"
                 },
+                "custom": {
+                    "description": "Atributos personalizados, validados con el esquema definido por los administradores",
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "deleted_at": {
+                    "type": "string"
+                },
+                "deleted_by": {
+                    "type": "string"
+                },
                 "email": {
                     "type": "string"
                 },
+                "erased_at": {
+                    "description": "Fecha en que se borraron los datos personales del usuario. El borrado no se puede deshacer",
+                    "type": "string"
+                },
+                "expiration_reminded_at": {
+                    "description": "Fecha en que se avisó al usuario que su cuenta está por vencer",
+                    "type": "string"
+                },
+                "expires_at": {
+                    "description": "Fecha en que vence la cuenta. Desde entonces el usuario no puede ingresar y pasa al estado expired",
+                    "type": "string"
+                },
                 "first_name": {
                     "type": "string"
                 },
+                "last_login_at": {
+                    "description": "Fecha del último ingreso y cantidad de ingresos. Los intentos se registran en login_events",
+                    "type": "string"
+                },
                 "last_name": {
                     "type": "string"
                 },
+                "login_count": {
+                    "type": "integer"
+                },
                 "password": {
                     "type": "string"
                 },
                 "password_changed_at": {
                     "type": "string"
                 },
+                "password_reset_required": {
+                    "description": "Si es verdadero, el usuario debe cambiar su contraseña antes de seguir usando la aplicación",
+                    "type": "boolean"
+                },
+                "phone": {
+                    "description": "Teléfono verificado en formato E.164 (ver utils.NormalizePhone). Se puede usar para ingresar",
+                    "type": "string"
+                },
+                "phone_mfa": {
+                    "description": "Si es verdadero, el ingreso requiere además un código enviado por SMS al teléfono",
+                    "type": "boolean"
+                },
+                "phone_verified_at": {
+                    "type": "string"
+                },
                 "profile_image": {
                     "type": "string"
                 },
+                "score": {
+                    "description": "Relevancia del resultado: mayor cuanto más palabras coinciden en forma exacta",
+                    "type": "number"
+                },
+                "settings": {
+                    "description": "Preferencias elegidas por el usuario. Las demás toman el valor predeterminado (ver services/user_settings_service.go)",
+                    "type": "object",
+                    "additionalProperties": true
+                },
                 "status": {
                     "type": "string"
                 },
+                "status_changed_at": {
+                    "type": "string"
+                },
+                "status_reason": {
+                    "type": "string"
+                },
                 "type": {
                     "type": "string"
                 },
                 "updated_at": {
                     "type": "string"
+                },
+                "user_metadata": {
+                    "description": "Metadatos libres que modifica el propio usuario, por ejemplo el estado de la interfaz",
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "version": {
+                    "type": "integer"
+                }
+            }
+        },
+        "services.SearchUsersResponse": {
+            "type": "object",
+            "properties": {
+                "users": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.SearchUserResult"
+                    }
+                }
+            }
+        },
+        "services.SetExpirationRequest": {
+            "type": "object",
+            "required": [
+                "expires_at"
+            ],
+            "properties": {
+                "expires_at": {
+                    "description": "Fecha en que vence la cuenta. Debe ser futura",
+                    "type": "string"
                 }
             }
         },
-        "services.ChangePasswordRequest": {
+        "services.SetPhoneMFARequest": {
             "type": "object",
+            "required": [
+                "current_password",
+                "enabled"
+            ],
             "properties": {
-                "password": {
+                "current_password": {
                     "type": "string"
                 },
-                "password_confirmation": {
+                "enabled": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "services.SettingDefinition": {
+            "type": "object",
+            "properties": {
+                "default": {},
+                "description": {
+                    "type": "string"
+                },
+                "key": {
+                    "type": "string"
+                },
+                "type": {
                     "type": "string"
+                },
+                "values": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
                 }
             }
         },
-        "services.CreateUserRequest": {
+        "services.SettingsConfigResponse": {
             "type": "object",
             "properties": {
-                "email": {
+                "config": {
+                    "$ref": "#/definitions/models.SettingsConfig"
+                },
+                "definitions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.SettingDefinition"
+                    }
+                }
+            }
+        },
+        "services.StartPhoneVerificationRequest": {
+            "type": "object",
+            "required": [
+                "current_password",
+                "phone"
+            ],
+            "properties": {
+                "current_password": {
                     "type": "string"
                 },
-                "first_name": {
+                "phone": {
                     "type": "string"
+                }
+            }
+        },
+        "services.UpdateMetadataConfigRequest": {
+            "type": "object",
+            "properties": {
+                "claims": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.MetadataClaim"
+                    }
+                }
+            }
+        },
+        "services.UpdateSettingsConfigRequest": {
+            "type": "object",
+            "properties": {
+                "token_keys": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
                 },
-                "last_name": {
+                "type_defaults": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "object",
+                        "additionalProperties": true
+                    }
+                }
+            }
+        },
+        "services.UpdateUserRequest": {
+            "type": "object",
+            "properties": {
+                "custom": {
+                    "description": "Atributos personalizados a modificar. Se combinan con los actuales como en JSON Merge Patch",
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "email": {
                     "type": "string"
                 },
-                "password": {
+                "first_name": {
                     "type": "string"
                 },
-                "profile_image": {
+                "last_name": {
                     "type": "string"
                 },
                 "status": {
@@ -547,39 +5865,103 @@ const docTemplate_swagger = `{
                 }
             }
         },
-        "services.GetUsersResponse": {
+        "services.UpdateUserResponse": {
             "type": "object",
             "properties": {
-                "users": {
+                "user": {
+                    "$ref": "#/definitions/models.User"
+                }
+            }
+        },
+        "services.UserFieldChange": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "type": "string"
+                },
+                "from": {},
+                "to": {}
+            }
+        },
+        "services.UserHistoryEntry": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string"
+                },
+                "changed_at": {
+                    "type": "string"
+                },
+                "changed_by": {
+                    "type": "string"
+                },
+                "changes": {
+                    "description": "Campos que cambiaron respecto de la versión anterior registrada",
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/models.User"
+                        "$ref": "#/definitions/services.UserFieldChange"
                     }
+                },
+                "version": {
+                    "type": "integer"
                 }
             }
         },
-        "services.UpdateUserRequest": {
+        "services.UserMetadataResponse": {
             "type": "object",
             "properties": {
-                "email": {
-                    "type": "string"
+                "app_metadata": {
+                    "description": "Metadatos que sólo modifican los administradores",
+                    "type": "object",
+                    "additionalProperties": true
                 },
-                "first_name": {
+                "user_metadata": {
+                    "description": "Metadatos que modifica el propio usuario",
+                    "type": "object",
+                    "additionalProperties": true
+                }
+            }
+        },
+        "services.UserSession": {
+            "type": "object",
+            "properties": {
+                "_id": {
                     "type": "string"
                 },
-                "last_name": {
+                "client_ip": {
                     "type": "string"
                 },
-                "profile_image": {
+                "created_at": {
                     "type": "string"
                 },
-                "status": {
+                "current": {
+                    "description": "Indica si es la sesión con la que se hizo la consulta",
+                    "type": "boolean"
+                },
+                "expires_at": {
                     "type": "string"
                 },
-                "type": {
+                "user_agent": {
                     "type": "string"
                 }
             }
+        },
+        "services.UserSettingsResponse": {
+            "type": "object",
+            "properties": {
+                "overridden": {
+                    "description": "Preferencias elegidas por el usuario",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "settings": {
+                    "description": "Valor de cada preferencia, elegido por el usuario o predeterminado",
+                    "type": "object",
+                    "additionalProperties": true
+                }
+            }
         }
     },
     "securityDefinitions": {
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maramal/user-service/middlewares"
+	"github.com/maramal/user-service/services"
+	"github.com/maramal/user-service/utils"
+)
+
+// @Summary	Obtiene una página de eventos de auditoría
+// @Description Cada solicitud que modifica datos queda registrada con quién la hizo, sobre qué recurso, desde dónde y con qué resultado. Si el recurso es un usuario, se incluyen los campos que cambiaron, sin los valores sensibles.
+// @ID 		get-audit-events
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	limit 		query int 		false "Cantidad de eventos por página (máximo 200)"
+// @Param 	cursor 		query string 	false "Cursor de la página (next_cursor)"
+// @Param 	actor 		query string 	false "Correo electrónico de quien hizo la acción"
+// @Param 	action 		query string 	false "Método y ruta del endpoint, por ejemplo PATCH /api/admin/users/:id"
+// @Param 	target_type query string 	false "Tipo de recurso, por ejemplo users o invitations"
+// @Param 	target_id 	query string 	false "ID del recurso"
+// @Param 	request_id 	query string 	false "ID de la solicitud (cabecera X-Request-ID)"
+// @Param 	from 		query string 	false "Fecha mínima (RFC 3339)"
+// @Param 	to 			query string 	false "Fecha máxima (RFC 3339)"
+// @Param 	failed 		query bool 		false "Sólo las solicitudes rechazadas"
+// @Success 200 {object} services.GetAuditEventsResponse
+// @Failure 400 {object} gin.H
+// @Router 	/admin/audit [get]
+func handleGetAuditEvents(service services.IAuditService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req services.GetAuditEventsRequest
+		if err := ctx.ShouldBindQuery(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(err))
+			return
+		}
+
+		events, err := service.GetEvents(req)
+		if err != nil {
+			ctx.JSON(userErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(events))
+	}
+}
+
+/** Crea un nuevo grupo de endpoints de auditoría
+ *
+ * @param group *gin.RouterGroup "El grupo de endpoints padre"
+ * @param service services.IAuditService "El servicio de auditoría"
+ * @return *gin.RouterGroup "El grupo de endpoints creado"
+ */
+func newAuditHandler(group gin.IRoutes, service services.IAuditService) *gin.IRoutes {
+	group.GET("", middlewares.RequireScopes("audit:read"), handleGetAuditEvents(service))
+
+	return &group
+}
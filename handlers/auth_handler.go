@@ -1,12 +1,16 @@
 package handlers
 
 import (
+	"errors"
+	"log"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/maramal/user-service/middlewares"
 	"github.com/maramal/user-service/models"
 	"github.com/maramal/user-service/services"
+	"github.com/maramal/user-service/token"
 	"github.com/maramal/user-service/utils"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
@@ -18,11 +22,29 @@ type userResponse struct {
 	ProfileImage      string    `json:"profile_image"`
 	PasswordChangedAt time.Time `json:"password_changed_at"`
 	CreatedAt         time.Time `json:"created_at"`
+	// Si es verdadero, el usuario debe cambiar su contraseña
+	PasswordResetRequired bool `json:"password_reset_required"`
 }
 
 type loginUserRequest struct {
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=6"`
+	Email string `json:"email" binding:"required_without=Phone,omitempty,email"`
+	// Teléfono verificado, en lugar del correo electrónico
+	Phone    string   `json:"phone" binding:"required_without=Email"`
+	Password string   `json:"password" binding:"required,min=6"`
+	Scopes   []string `json:"scopes"`
+}
+
+// Respuesta del login cuando el usuario tiene activo el segundo factor por SMS
+type loginMFAResponse struct {
+	MFARequired bool `json:"mfa_required"`
+	services.PhoneLoginChallenge
+}
+
+type loginMFARequest struct {
+	// Token recibido en la respuesta de POST /login
+	MFAToken string   `json:"mfa_token" binding:"required"`
+	Code     string   `json:"code" binding:"required"`
+	Scopes   []string `json:"scopes"`
 }
 
 type loginUserResponse struct {
@@ -31,9 +53,20 @@ type loginUserResponse struct {
 	AccessTokenExpiresAt  time.Time          `json:"access_token_expires_at"`
 	RefreshToken          string             `json:"refresh_token"`
 	RefreshTokenExpiresAt time.Time          `json:"refresh_token_expires_at"`
+	Scopes                []string           `json:"scopes"`
 	User                  userResponse       `json:"user"`
 }
 
+type createTokenRequest struct {
+	Scopes []string `json:"scopes" binding:"required,min=1"`
+}
+
+type createTokenResponse struct {
+	AccessToken          string    `json:"access_token"`
+	AccessTokenExpiresAt time.Time `json:"access_token_expires_at"`
+	Scopes               []string  `json:"scopes"`
+}
+
 func newUserResponse(user models.User) userResponse {
 	return userResponse{
 		Email:        user.Email,
@@ -41,18 +74,25 @@ func newUserResponse(user models.User) userResponse {
 		LastName:     user.LastName,
 		ProfileImage: user.ProfileImage,
 		CreatedAt:    user.CreatedAt,
+
+		PasswordResetRequired: user.PasswordResetRequired,
 	}
 }
 
 // @Summary Ingresa un usuario
+// @Description Se puede ingresar con el correo electrónico o con el teléfono verificado. Si el usuario tiene activo el segundo factor por SMS, se envía un código al teléfono y se responde 202 con el token para completar el ingreso en POST /login/mfa.
 // @ID 		login-user
 // @Accept 	json
 // @Produce	json
 // @Param   loginUserRequest body loginUserRequest true 	"Datos del usuario"
 // @Success 200 {object} loginUserResponse "Respuesta del login"
+// @Success 202 {object} loginMFAResponse "Se requiere el código enviado por SMS"
 // @Failure 400 {object} gin.H	"Error en la solicitud"
+// @Failure 403 {object} gin.H	"El usuario no está activo"
+// @Failure 409 {object} gin.H	"El teléfono está asociado a más de un usuario"
+// @Failure 429 {object} gin.H	"Se enviaron demasiados códigos"
 // @Router 	/login [post]
-func (server *Server) handleLoginUser(userService services.IUserService, AuthService services.IAuthService) gin.HandlerFunc {
+func (server *Server) handleLoginUser(userService services.IUserService, AuthService services.IAuthService, customAttributeService services.ICustomAttributeService, phoneService services.IPhoneService, settingsService services.IUserSettingsService, metadataService services.IUserMetadataService) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		var req loginUserRequest
 		if err := ctx.ShouldBindJSON(&req); err != nil {
@@ -60,75 +100,285 @@ func (server *Server) handleLoginUser(userService services.IUserService, AuthSer
 			return
 		}
 
-		resp, err := userService.GetUserByEmail(req.Email)
+		var user models.User
+		method := services.LoginMethodEmail
+		if req.Email != "" {
+			resp, err := userService.GetUserByEmail(req.Email)
+			if err != nil {
+				ctx.JSON(http.StatusNotFound, utils.ErrorResponse(err))
+				return
+			}
+			user = resp.User
+		} else {
+			var err error
+			if user, err = phoneService.GetUserByPhone(req.Phone); err != nil {
+				ctx.JSON(phoneErrorStatus(err), utils.ErrorResponse(err))
+				return
+			}
+			method = services.LoginMethodPhone
+		}
+		middlewares.SetAuditTarget(ctx, models.AuditTarget{Type: services.AuditTargetUsers, ID: user.ID.Hex(), Email: user.Email})
 
+		err := utils.CheckPassword(req.Password, user.Password)
 		if err != nil {
-			ctx.JSON(http.StatusNotFound, utils.ErrorResponse(err))
+			recordLogin(ctx, AuthService, services.RecordLoginParams{UserID: user.ID, Method: method, Result: services.LoginResultWrongPassword})
+			ctx.JSON(http.StatusUnauthorized, utils.ErrorResponse(err))
 			return
 		}
 
-		user := resp.User
+		if err = services.CheckUserCanLogin(user); err != nil {
+			recordLogin(ctx, AuthService, services.RecordLoginParams{UserID: user.ID, Method: method, Result: services.LoginResultLocked, Reason: err.Error()})
+			ctx.JSON(http.StatusForbidden, utils.ErrorResponse(err))
+			return
+		}
 
-		err = utils.CheckPassword(req.Password, user.Password)
-		if err != nil {
-			ctx.JSON(http.StatusUnauthorized, utils.ErrorResponse(err))
+		if _, err = services.ResolveScopes(user.Type, req.Scopes); err != nil {
+			recordLogin(ctx, AuthService, services.RecordLoginParams{UserID: user.ID, Method: method, Result: services.LoginResultInvalidScope, Reason: err.Error()})
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(err))
 			return
 		}
 
-		accessToken, accessPayload, err := server.TokenMaker.CreateToken(
-			user.FirstName,
-			user.LastName,
-			user.Email,
-			user.Type,
-			user.ProfileImage,
-			server.Config.AccessTokenDuration,
-		)
-		if err != nil {
-			ctx.JSON(http.StatusInternalServerError, utils.ErrorResponse(err))
+		if user.PhoneMFA {
+			challenge, err := phoneService.StartLoginChallenge(user)
+			if err != nil {
+				ctx.JSON(phoneErrorStatus(err), utils.ErrorResponse(err))
+				return
+			}
+
+			recordLogin(ctx, AuthService, services.RecordLoginParams{UserID: user.ID, Method: method, Result: services.LoginResultMFARequired})
+			ctx.JSON(http.StatusAccepted, loginMFAResponse{MFARequired: true, PhoneLoginChallenge: challenge})
 			return
 		}
 
-		refreshToken, refreshPayload, err := server.TokenMaker.CreateToken(
-			user.FirstName,
-			user.LastName,
-			user.Email,
-			user.Type,
-			user.ProfileImage,
-			server.Config.RefreshTokenDuration,
-		)
+		server.createLoginSession(ctx, user, method, req.Scopes, AuthService, customAttributeService, settingsService, metadataService)
+	}
+}
+
+// @Summary Completa el ingreso de un usuario con el segundo factor por SMS
+// @ID 		login-user-mfa
+// @Accept 	json
+// @Produce	json
+// @Param   loginMFARequest body loginMFARequest true 	"Token de POST /login y código recibido"
+// @Success 200 {object} loginUserResponse "Respuesta del login"
+// @Failure 400 {object} gin.H	"Error en la solicitud"
+// @Failure 401 {object} gin.H	"El código no es correcto o venció"
+// @Failure 403 {object} gin.H	"El usuario no está activo"
+// @Router 	/login/mfa [post]
+func (server *Server) handleLoginUserMFA(authService services.IAuthService, customAttributeService services.ICustomAttributeService, phoneService services.IPhoneService, settingsService services.IUserSettingsService, metadataService services.IUserMetadataService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req loginMFARequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(err))
+			return
+		}
+
+		user, err := phoneService.VerifyLoginChallenge(req.MFAToken, req.Code)
+		if errors.Is(err, services.ErrPhoneCodeInvalid) {
+			if !user.ID.IsZero() {
+				middlewares.SetAuditTarget(ctx, models.AuditTarget{Type: services.AuditTargetUsers, ID: user.ID.Hex()})
+				recordLogin(ctx, authService, services.RecordLoginParams{UserID: user.ID, Method: services.LoginMethodMFA, Result: services.LoginResultMFAFailed, Reason: err.Error()})
+			}
+			ctx.JSON(http.StatusUnauthorized, utils.ErrorResponse(err))
+			return
+		}
 		if err != nil {
 			ctx.JSON(http.StatusInternalServerError, utils.ErrorResponse(err))
 			return
 		}
+		middlewares.SetAuditTarget(ctx, models.AuditTarget{Type: services.AuditTargetUsers, ID: user.ID.Hex(), Email: user.Email})
+
+		// El estado pudo cambiar mientras se esperaba el código
+		if err = services.CheckUserCanLogin(user); err != nil {
+			recordLogin(ctx, authService, services.RecordLoginParams{UserID: user.ID, Method: services.LoginMethodMFA, Result: services.LoginResultLocked, Reason: err.Error()})
+			ctx.JSON(http.StatusForbidden, utils.ErrorResponse(err))
+			return
+		}
+
+		server.createLoginSession(ctx, user, services.LoginMethodMFA, req.Scopes, authService, customAttributeService, settingsService, metadataService)
+	}
+}
+
+// Crea la sesión y los tokens de un usuario que completó el ingreso con el paso method y responde con ellos
+func (server *Server) createLoginSession(ctx *gin.Context, user models.User, method string, requestedScopes []string, authService services.IAuthService, customAttributeService services.ICustomAttributeService, settingsService services.IUserSettingsService, metadataService services.IUserMetadataService) {
+	scopes, err := services.ResolveScopes(user.Type, requestedScopes)
+	if err != nil {
+		recordLogin(ctx, authService, services.RecordLoginParams{UserID: user.ID, Method: method, Result: services.LoginResultInvalidScope, Reason: err.Error()})
+		ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(err))
+		return
+	}
+
+	claims, err := customAttributeService.TokenClaims(user)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.ErrorResponse(err))
+		return
+	}
+
+	metadataClaims, err := metadataService.TokenClaims(user)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.ErrorResponse(err))
+		return
+	}
+	// Si un claim se repite, prevalece el del atributo personalizado
+	for claim, value := range metadataClaims {
+		if _, ok := claims[claim]; ok {
+			continue
+		}
+		if claims == nil {
+			claims = map[string]interface{}{}
+		}
+		claims[claim] = value
+	}
+
+	settings, err := settingsService.TokenSettings(user)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.ErrorResponse(err))
+		return
+	}
+
+	sessionID := primitive.NewObjectID()
+
+	payloadParams := token.PayloadParams{
+		SessionID:    sessionID.Hex(),
+		FirstName:    user.FirstName,
+		LastName:     user.LastName,
+		Email:        user.Email,
+		UserType:     user.Type,
+		ProfileImage: user.ProfileImage,
+		Scopes:       scopes,
+		Claims:       claims,
+		Settings:     settings,
+	}
+
+	accessToken, accessPayload, err := server.TokenMaker.CreateToken(
+		payloadParams,
+		server.Config.AccessTokenDuration,
+	)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.ErrorResponse(err))
+		return
+	}
+
+	refreshToken, refreshPayload, err := server.TokenMaker.CreateToken(
+		payloadParams,
+		server.Config.RefreshTokenDuration,
+	)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.ErrorResponse(err))
+		return
+	}
+
+	session, err := authService.CreateSession(services.CreateSessionParams{
+		ID:           sessionID,
+		Email:        user.Email,
+		RefreshToken: refreshToken,
+		UserAgent:    ctx.Request.UserAgent(),
+		ClientIp:     ctx.ClientIP(),
+		IsBlocked:    false,
+		ExpiresAt:    refreshPayload.ExpiredAt,
+
+		UserExpiresAt:         user.ExpiresAt,
+		PasswordResetRequired: user.PasswordResetRequired,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.ErrorResponse(err))
+		return
+	}
+	recordLogin(ctx, authService, services.RecordLoginParams{UserID: user.ID, Method: method, Result: services.LoginResultSuccess, SessionID: session.ID})
+
+	response := loginUserResponse{
+		SessionID:             session.ID,
+		AccessToken:           accessToken,
+		AccessTokenExpiresAt:  accessPayload.ExpiredAt,
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: refreshPayload.ExpiredAt,
+		Scopes:                scopes,
+		User:                  newUserResponse(user),
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// Registra un intento de ingreso con el origen de la solicitud. Si no se puede registrar, el ingreso sigue igual
+func recordLogin(ctx *gin.Context, authService services.IAuthService, params services.RecordLoginParams) {
+	params.ClientIP, params.UserAgent = ctx.ClientIP(), ctx.Request.UserAgent()
+	if err := authService.RecordLogin(params); err != nil {
+		log.Printf("Error al registrar el ingreso del usuario %s: %s", params.UserID.Hex(), err)
+	}
+}
+
+// @Summary Crea un token de acceso con un subconjunto de los alcances del token actual
+// @ID 		create-scoped-token
+// @Accept 	json
+// @Produce	json
+// @Security ApiKeyAuth
+// @Param   createTokenRequest body createTokenRequest true "Alcances solicitados"
+// @Success 200 {object} createTokenResponse "Token de acceso"
+// @Failure 400 {object} gin.H	"Error en la solicitud"
+// @Router 	/token [post]
+func (server *Server) handleCreateScopedToken() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req createTokenRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(err))
+			return
+		}
 
-		session, err := AuthService.CreateSession(services.CreateSessionParams{
-			Email:        user.Email,
-			RefreshToken: refreshToken,
-			UserAgent:    ctx.Request.UserAgent(),
-			ClientIp:     ctx.ClientIP(),
-			IsBlocked:    false,
-			ExpiresAt:    refreshPayload.ExpiredAt,
-		})
+		payload, ok := middlewares.GetAuthorizationPayload(ctx)
+		if !ok {
+			ctx.JSON(http.StatusUnauthorized, utils.ErrorResponse(errors.New("sesion no iniciada")))
+			return
+		}
+
+		if !payload.HasScopes(req.Scopes...) {
+			err := errors.New("sólo se pueden solicitar alcances incluidos en el token actual")
+			ctx.JSON(http.StatusForbidden, utils.ErrorResponse(err))
+			return
+		}
+
+		// El nuevo token no puede vivir más que el token con el que se solicitó
+		duration := server.Config.AccessTokenDuration
+		if remaining := time.Until(payload.ExpiredAt); remaining < duration {
+			duration = remaining
+		}
+
+		accessToken, accessPayload, err := server.TokenMaker.CreateToken(
+			token.PayloadParams{
+				SessionID:    payload.SessionID,
+				FirstName:    payload.FirstName,
+				LastName:     payload.LastName,
+				Email:        payload.Email,
+				UserType:     payload.UserType,
+				ProfileImage: payload.ProfileImage,
+				Scopes:       req.Scopes,
+				Claims:       payload.Claims,
+				Settings:     payload.Settings,
+			},
+			duration,
+		)
 		if err != nil {
 			ctx.JSON(http.StatusInternalServerError, utils.ErrorResponse(err))
 			return
 		}
 
-		response := loginUserResponse{
-			SessionID:             session.ID,
-			AccessToken:           accessToken,
-			AccessTokenExpiresAt:  accessPayload.ExpiredAt,
-			RefreshToken:          refreshToken,
-			RefreshTokenExpiresAt: refreshPayload.ExpiredAt,
-			User:                  newUserResponse(user),
+		response := createTokenResponse{
+			AccessToken:          accessToken,
+			AccessTokenExpiresAt: accessPayload.ExpiredAt,
+			Scopes:               accessPayload.Scopes,
 		}
 
 		ctx.JSON(http.StatusOK, response)
 	}
 }
 
-func newAuthHandler(group *gin.RouterGroup, userService services.IUserService, authService services.IAuthService, server *Server) *gin.RouterGroup {
-	group.POST("/login", server.handleLoginUser(userService, authService))
+func newAuthHandler(group *gin.RouterGroup, userService services.IUserService, authService services.IAuthService, customAttributeService services.ICustomAttributeService, phoneService services.IPhoneService, settingsService services.IUserSettingsService, metadataService services.IUserMetadataService, server *Server) *gin.RouterGroup {
+	group.POST("/login", server.handleLoginUser(userService, authService, customAttributeService, phoneService, settingsService, metadataService))
+	group.POST("/login/mfa", server.handleLoginUserMFA(authService, customAttributeService, phoneService, settingsService, metadataService))
+
+	return group
+}
+
+func newTokenHandler(group *gin.RouterGroup, server *Server) *gin.RouterGroup {
+	group.POST("", server.handleCreateScopedToken())
 
 	return group
 }
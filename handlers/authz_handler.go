@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maramal/user-service/middlewares"
+	"github.com/maramal/user-service/services"
+	"github.com/maramal/user-service/utils"
+)
+
+// @Summary Evalúa si un sujeto puede realizar una acción sobre un recurso
+// @ID 		authz-check
+// @Accept 	json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param   AuthzCheckRequest body services.AuthzCheckRequest true "Sujeto, acción y recurso"
+// @Success 200 {object} services.AuthzCheckResponse
+// @Failure 400 {object} gin.H
+// @Failure 403 {object} gin.H "Sólo los administradores pueden evaluar a otros usuarios por su id"
+// @Router 	/authz/check [post]
+func handleAuthzCheck(service services.IAuthzService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req services.AuthzCheckRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(err))
+			return
+		}
+
+		result, err := service.Check(req, authzCaller(ctx))
+		if err != nil {
+			ctx.JSON(authzErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		setCacheHeaders(ctx, result.TTL)
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(result))
+	}
+}
+
+// @Summary Evalúa varias comprobaciones de autorización
+// @ID 		authz-check-batch
+// @Accept 	json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param   AuthzBatchRequest body services.AuthzBatchRequest true "Comprobaciones a evaluar"
+// @Success 200 {object} services.AuthzBatchResponse
+// @Failure 400 {object} gin.H
+// @Failure 403 {object} gin.H "Sólo los administradores pueden evaluar a otros usuarios por su id"
+// @Router 	/authz/check/batch [post]
+func handleAuthzCheckBatch(service services.IAuthzService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req services.AuthzBatchRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(err))
+			return
+		}
+
+		result, err := service.CheckBatch(req, authzCaller(ctx))
+		if err != nil {
+			ctx.JSON(authzErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		setCacheHeaders(ctx, result.TTL)
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(result))
+	}
+}
+
+func authzErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidAuthzSubject), errors.Is(err, services.ErrAuthzBatchTooLarge):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrAuthzSubjectForbidden):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Obtiene el usuario de la sesión que consulta la autorización
+func authzCaller(ctx *gin.Context) services.AuthzCaller {
+	payload, ok := middlewares.GetAuthorizationPayload(ctx)
+	if !ok {
+		return services.AuthzCaller{}
+	}
+
+	return services.AuthzCaller{Email: payload.Email, UserType: payload.UserType}
+}
+
+// Agrega las cabeceras que indican durante cuánto tiempo se puede cachear la respuesta
+func setCacheHeaders(ctx *gin.Context, ttl int) {
+	if ttl <= 0 {
+		ctx.Header("Cache-Control", "no-store")
+		return
+	}
+
+	ctx.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", ttl))
+	ctx.Header("Expires", time.Now().Add(time.Duration(ttl)*time.Second).UTC().Format(http.TimeFormat))
+}
+
+/** Crea un nuevo grupo de endpoints de autorización
+ *
+ * @param group *gin.RouterGroup "El grupo de endpoints padre"
+ * @param service services.IAuthzService "El servicio de autorización"
+ * @return *gin.RouterGroup "El grupo de endpoints creado"
+ */
+func newAuthzHandler(group gin.IRoutes, authzService services.IAuthzService) *gin.IRoutes {
+	group.POST("/check", middlewares.RequireScopes("authz:check"), handleAuthzCheck(authzService))
+	group.POST("/check/batch", middlewares.RequireScopes("authz:check"), handleAuthzCheckBatch(authzService))
+
+	return &group
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maramal/user-service/middlewares"
+	"github.com/maramal/user-service/services"
+	"github.com/maramal/user-service/utils"
+)
+
+// Tiempo durante el cual los clientes pueden cachear una imagen de perfil sin volver a consultarla
+const avatarCacheMaxAge = 300
+
+// @Summary Obtiene la imagen de perfil de un usuario
+// @Description Es la URL que se guarda en profile_image y no cambia al subir otra imagen. No requiere autenticación.
+// @ID 		get-avatar
+// @Produce image/jpeg
+// @Produce image/png
+// @Param 	id 		path 	string 	true 	"ID del usuario"
+// @Param 	size 	query 	int 	false 	"Lado de la miniatura cuadrada (64, 128 o 256). Sin este parámetro se obtiene la imagen completa"
+// @Success 200 {file} binary
+// @Success 304 "La imagen no cambió (If-None-Match)"
+// @Failure 404 {object} gin.H
+// @Router 	/avatars/{id} [get]
+func handleGetAvatar(service services.IAvatarService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		size := 0
+		if value := ctx.Query("size"); value != "" {
+			var err error
+			if size, err = strconv.Atoi(value); err != nil {
+				ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(errors.New("el tamaño debe ser un número")))
+				return
+			}
+		}
+
+		avatar, err := service.GetAvatar(ctx.Param("id"), size)
+		if err != nil {
+			ctx.JSON(avatarErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		etag := `"` + avatar.Version + `"`
+		ctx.Header("ETag", etag)
+		ctx.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", avatarCacheMaxAge))
+
+		if etagMatches(ctx.GetHeader("If-None-Match"), etag) {
+			ctx.Status(http.StatusNotModified)
+			return
+		}
+
+		ctx.Data(http.StatusOK, avatar.ContentType, avatar.Data)
+	}
+}
+
+// @Summary Sube la imagen de perfil de un usuario
+// @Description Acepta imágenes JPEG, PNG o GIF. Se eliminan los metadatos (EXIF) y se generan miniaturas de 64, 128 y 256 píxeles.
+// @ID 		set-user-avatar
+// @Accept 	multipart/form-data
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	id 		path 		string 	true "ID del usuario"
+// @Param 	image 	formData 	file 	true "Imagen JPEG, PNG o GIF"
+// @Success 200 {object} services.UpdateUserResponse
+// @Failure 400 {object} gin.H
+// @Failure 413 {object} gin.H
+// @Router 	/admin/users/{id}/avatar [put]
+func handleSetUserAvatar(service services.IAvatarService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		setAvatar(ctx, service, ctx.Param("id"))
+	}
+}
+
+// @Summary Elimina la imagen de perfil de un usuario
+// @ID 		delete-user-avatar
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	id path string true "ID del usuario"
+// @Success 200 {object} services.UpdateUserResponse
+// @Failure 404 {object} gin.H
+// @Router 	/admin/users/{id}/avatar [delete]
+func handleDeleteUserAvatar(service services.IAvatarService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		deleteAvatar(ctx, service, ctx.Param("id"))
+	}
+}
+
+// Lee la imagen del formulario y la guarda como imagen de perfil del usuario
+func setAvatar(ctx *gin.Context, service services.IAvatarService, userId string) {
+	fileHeader, err := ctx.FormFile("image")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(errors.New("la imagen es requerida")))
+		return
+	}
+
+	if fileHeader.Size > int64(service.MaxSize()) {
+		err := fmt.Errorf("%w: el máximo es %d bytes", services.ErrAvatarTooLarge, service.MaxSize())
+		ctx.JSON(http.StatusRequestEntityTooLarge, utils.ErrorResponse(err))
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(err))
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(err))
+		return
+	}
+
+	user, err := service.SetAvatar(userId, data, fileHeader.Header.Get("Content-Type"), sessionEmail(ctx))
+	if err != nil {
+		ctx.JSON(avatarErrorStatus(err), utils.ErrorResponse(err))
+		return
+	}
+
+	ctx.Header("ETag", userETag(user.User))
+	ctx.JSON(http.StatusOK, utils.SuccessResponse(user))
+}
+
+func deleteAvatar(ctx *gin.Context, service services.IAvatarService, userId string) {
+	user, err := service.DeleteAvatar(userId, sessionEmail(ctx))
+	if err != nil {
+		ctx.JSON(avatarErrorStatus(err), utils.ErrorResponse(err))
+		return
+	}
+
+	ctx.Header("ETag", userETag(user.User))
+	ctx.JSON(http.StatusOK, utils.SuccessResponse(user))
+}
+
+func avatarErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidAvatar):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrAvatarTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, services.ErrAvatarNotFound), errors.Is(err, services.ErrUserNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+/** Crea el endpoint público de imágenes de perfil
+ *
+ * @param group *gin.RouterGroup "El grupo de endpoints padre"
+ * @param avatarService services.IAvatarService "El servicio de imágenes de perfil"
+ * @return *gin.RouterGroup "El grupo de endpoints creado"
+ */
+func newAvatarHandler(group gin.IRoutes, avatarService services.IAvatarService) *gin.IRoutes {
+	group.GET("/:id", handleGetAvatar(avatarService))
+
+	return &group
+}
+
+/** Crea los endpoints de administración de imágenes de perfil
+ *
+ * @param group *gin.RouterGroup "El grupo de endpoints de usuarios"
+ * @param avatarService services.IAvatarService "El servicio de imágenes de perfil"
+ * @return *gin.RouterGroup "El grupo de endpoints creado"
+ */
+func newUserAvatarHandler(group gin.IRoutes, avatarService services.IAvatarService) *gin.IRoutes {
+	group.PUT("/:id/avatar", middlewares.RequireScopes("users:update"), handleSetUserAvatar(avatarService))
+	group.DELETE("/:id/avatar", middlewares.RequireScopes("users:update"), handleDeleteUserAvatar(avatarService))
+
+	return &group
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maramal/user-service/middlewares"
+	"github.com/maramal/user-service/services"
+	"github.com/maramal/user-service/utils"
+)
+
+// @Summary Obtiene la versión vigente del esquema de atributos personalizados
+// @ID 		get-custom-schema
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} models.CustomAttributeSchema
+// @Failure 404 {object} gin.H
+// @Router 	/admin/custom-attributes/schema [get]
+func handleGetCustomSchema(service services.ICustomAttributeService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		schema, err := service.GetSchema()
+		if err != nil {
+			ctx.JSON(customAttributeErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(schema))
+	}
+}
+
+// @Summary Define una nueva versión del esquema de atributos personalizados
+// @Description Los usuarios existentes se validan en segundo plano con la nueva versión; el resultado se consulta en
+// @Description /admin/custom-attributes/schema/versions/{version}. Las propiedades con "x-searchable": true se incluyen en la búsqueda de usuarios.
+// @ID 		save-custom-schema
+// @Accept 	json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	SaveCustomSchemaRequest body services.SaveCustomSchemaRequest true "Esquema y claims"
+// @Success 201 {object} models.CustomAttributeSchema
+// @Failure 400 {object} gin.H
+// @Router 	/admin/custom-attributes/schema [put]
+func handleSaveCustomSchema(service services.ICustomAttributeService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req services.SaveCustomSchemaRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(err))
+			return
+		}
+
+		payload, ok := middlewares.GetAuthorizationPayload(ctx)
+		if !ok {
+			ctx.JSON(http.StatusUnauthorized, utils.ErrorResponse(errors.New("sesion no iniciada")))
+			return
+		}
+
+		schema, err := service.SaveSchema(req, payload.Email)
+		if err != nil {
+			ctx.JSON(customAttributeErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusCreated, utils.SuccessResponse(schema))
+	}
+}
+
+// @Summary Obtiene las versiones del esquema de atributos personalizados
+// @ID 		get-custom-schema-versions
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} services.GetCustomSchemasResponse
+// @Router 	/admin/custom-attributes/schema/versions [get]
+func handleGetCustomSchemaVersions(service services.ICustomAttributeService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		schemas, err := service.GetSchemaVersions()
+		if err != nil {
+			ctx.JSON(customAttributeErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(schemas))
+	}
+}
+
+// @Summary Obtiene una versión del esquema con el reporte de usuarios que no la cumplen
+// @ID 		get-custom-schema-version
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	version path int true "Versión del esquema"
+// @Success 200 {object} models.CustomAttributeSchema
+// @Failure 404 {object} gin.H
+// @Router 	/admin/custom-attributes/schema/versions/{version} [get]
+func handleGetCustomSchemaVersion(service services.ICustomAttributeService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		version, err := strconv.Atoi(ctx.Param("version"))
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(errors.New("la versión debe ser un número")))
+			return
+		}
+
+		schema, err := service.GetSchemaVersion(version)
+		if err != nil {
+			ctx.JSON(customAttributeErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(schema))
+	}
+}
+
+func customAttributeErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidCustomSchema):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrCustomSchemaNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+/** Crea un nuevo grupo de endpoints de atributos personalizados
+ *
+ * @param group *gin.RouterGroup "El grupo de endpoints padre"
+ * @param service services.ICustomAttributeService "El servicio de atributos personalizados"
+ * @return *gin.RouterGroup "El grupo de endpoints creado"
+ */
+func newCustomAttributeHandler(group gin.IRoutes, customAttributeService services.ICustomAttributeService) *gin.IRoutes {
+	group.GET("/schema", middlewares.RequireScopes("users:read"), handleGetCustomSchema(customAttributeService))
+	group.PUT("/schema", middlewares.RequireScopes("users:update"), handleSaveCustomSchema(customAttributeService))
+	group.GET("/schema/versions", middlewares.RequireScopes("users:read"), handleGetCustomSchemaVersions(customAttributeService))
+	group.GET("/schema/versions/:version", middlewares.RequireScopes("users:read"), handleGetCustomSchemaVersion(customAttributeService))
+
+	return &group
+}
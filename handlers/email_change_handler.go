@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maramal/user-service/middlewares"
+	"github.com/maramal/user-service/services"
+	"github.com/maramal/user-service/utils"
+)
+
+// @Summary Inicia el cambio de correo electrónico de un usuario
+// @Description El correo no cambia hasta que se confirma con el enlace enviado a la dirección nueva. La dirección actual recibe un aviso con un enlace para cancelar el cambio.
+// @ID 		request-user-email-change
+// @Accept 	json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	id 							path string 							true "ID del usuario"
+// @Param 	RequestEmailChangeRequest 	body services.RequestEmailChangeRequest true "Correo electrónico nuevo"
+// @Success 200 {object} services.EmailChangeResponse
+// @Failure 400 {object} gin.H
+// @Failure 409 {object} gin.H
+// @Router 	/admin/users/{id}/email-change [post]
+func handleRequestUserEmailChange(service services.IEmailChangeService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req services.RequestEmailChangeRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(err))
+			return
+		}
+
+		payload, ok := middlewares.GetAuthorizationPayload(ctx)
+		if !ok {
+			ctx.JSON(http.StatusUnauthorized, utils.ErrorResponse(errors.New("sesion no iniciada")))
+			return
+		}
+
+		change, err := service.RequestEmailChange(ctx.Param("id"), req.Email, payload.Email)
+		if err != nil {
+			ctx.JSON(emailChangeErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(change))
+	}
+}
+
+// @Summary Obtiene el cambio de correo electrónico pendiente de un usuario
+// @ID 		get-user-email-change
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	id path string true "ID del usuario"
+// @Success 200 {object} services.EmailChangeResponse
+// @Failure 404 {object} gin.H
+// @Router 	/admin/users/{id}/email-change [get]
+func handleGetUserEmailChange(service services.IEmailChangeService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		getPendingEmailChange(ctx, service, ctx.Param("id"))
+	}
+}
+
+// @Summary Cancela el cambio de correo electrónico pendiente de un usuario
+// @ID 		cancel-user-email-change
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	id path string true "ID del usuario"
+// @Success 200 {object} gin.H
+// @Failure 404 {object} gin.H
+// @Router 	/admin/users/{id}/email-change [delete]
+func handleCancelUserEmailChange(service services.IEmailChangeService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		cancelPendingEmailChange(ctx, service, ctx.Param("id"))
+	}
+}
+
+// @Summary Inicia el cambio de correo electrónico del usuario de la sesión
+// @Description Requiere la contraseña actual. El correo no cambia hasta que se confirma con el enlace enviado a la dirección nueva.
+// @ID 		request-my-email-change
+// @Accept 	json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	RequestOwnEmailChangeRequest body services.RequestOwnEmailChangeRequest true "Correo electrónico nuevo y contraseña actual"
+// @Success 200 {object} services.EmailChangeResponse
+// @Failure 400 {object} gin.H
+// @Failure 409 {object} gin.H
+// @Router 	/me/email-change [post]
+func handleRequestMyEmailChange(userService services.IUserService, service services.IEmailChangeService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req services.RequestOwnEmailChangeRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(err))
+			return
+		}
+
+		userId, ok := currentUserID(ctx, userService)
+		if !ok {
+			return
+		}
+
+		change, err := service.RequestOwnEmailChange(userId, req)
+		if err != nil {
+			ctx.JSON(emailChangeErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(change))
+	}
+}
+
+// @Summary Obtiene el cambio de correo electrónico pendiente del usuario de la sesión
+// @ID 		get-my-email-change
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} services.EmailChangeResponse
+// @Failure 404 {object} gin.H
+// @Router 	/me/email-change [get]
+func handleGetMyEmailChange(userService services.IUserService, service services.IEmailChangeService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userId, ok := currentUserID(ctx, userService)
+		if !ok {
+			return
+		}
+
+		getPendingEmailChange(ctx, service, userId)
+	}
+}
+
+// @Summary Cancela el cambio de correo electrónico pendiente del usuario de la sesión
+// @ID 		cancel-my-email-change
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} gin.H
+// @Failure 404 {object} gin.H
+// @Router 	/me/email-change [delete]
+func handleCancelMyEmailChange(userService services.IUserService, service services.IEmailChangeService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userId, ok := currentUserID(ctx, userService)
+		if !ok {
+			return
+		}
+
+		cancelPendingEmailChange(ctx, service, userId)
+	}
+}
+
+// @Summary Confirma un cambio de correo electrónico
+// @Description Recibe el token del enlace enviado a la dirección nueva. No requiere autenticación. Las sesiones del usuario siguen siendo válidas.
+// @ID 		confirm-email-change
+// @Accept 	json
+// @Produce json
+// @Param 	EmailChangeTokenRequest body services.EmailChangeTokenRequest true "Token del enlace"
+// @Success 200 {object} services.UpdateUserResponse
+// @Failure 404 {object} gin.H
+// @Failure 409 {object} gin.H
+// @Router 	/email-change/confirm [post]
+func handleConfirmEmailChange(service services.IEmailChangeService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req services.EmailChangeTokenRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(err))
+			return
+		}
+
+		user, err := service.ConfirmEmailChange(req.Token)
+		if err != nil {
+			ctx.JSON(emailChangeErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(user))
+	}
+}
+
+// @Summary Cancela un cambio de correo electrónico
+// @Description Recibe el token del enlace enviado a la dirección anterior. No requiere autenticación. Si el cambio ya se confirmó y el enlace no venció, se vuelve a la dirección anterior y se bloquean todas las sesiones del usuario.
+// @ID 		cancel-email-change
+// @Accept 	json
+// @Produce json
+// @Param 	EmailChangeTokenRequest body services.EmailChangeTokenRequest true "Token del enlace"
+// @Success 200 {object} gin.H
+// @Failure 404 {object} gin.H
+// @Failure 409 {object} gin.H
+// @Router 	/email-change/cancel [post]
+func handleCancelEmailChange(service services.IEmailChangeService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req services.EmailChangeTokenRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(err))
+			return
+		}
+
+		if err := service.CancelEmailChange(req.Token); err != nil {
+			ctx.JSON(emailChangeErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(nil))
+	}
+}
+
+func getPendingEmailChange(ctx *gin.Context, service services.IEmailChangeService, userId string) {
+	change, err := service.GetPendingEmailChange(userId)
+	if err != nil {
+		ctx.JSON(emailChangeErrorStatus(err), utils.ErrorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.SuccessResponse(change))
+}
+
+func cancelPendingEmailChange(ctx *gin.Context, service services.IEmailChangeService, userId string) {
+	if err := service.CancelPendingEmailChange(userId); err != nil {
+		ctx.JSON(emailChangeErrorStatus(err), utils.ErrorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.SuccessResponse(nil))
+}
+
+func emailChangeErrorStatus(err error) int {
+	if errors.Is(err, services.ErrEmailChangeNotFound) {
+		return http.StatusNotFound
+	}
+
+	return userErrorStatus(err)
+}
+
+/** Crea un nuevo grupo de endpoints de cambio de correo electrónico de los usuarios
+ *
+ * @param group *gin.RouterGroup "El grupo de endpoints padre"
+ * @param service services.IEmailChangeService "El servicio de cambios de correo electrónico"
+ * @return *gin.RouterGroup "El grupo de endpoints creado"
+ */
+func newUserEmailChangeHandler(group gin.IRoutes, service services.IEmailChangeService) *gin.IRoutes {
+	group.GET("/:id/email-change", middlewares.RequireScopes("users:read"), handleGetUserEmailChange(service))
+	group.POST("/:id/email-change", middlewares.RequireScopes("users:update"), handleRequestUserEmailChange(service))
+	group.DELETE("/:id/email-change", middlewares.RequireScopes("users:update"), handleCancelUserEmailChange(service))
+
+	return &group
+}
+
+/** Crea un nuevo grupo de endpoints públicos de confirmación y cancelación de cambios de correo electrónico
+ *
+ * @param group *gin.RouterGroup "El grupo de endpoints padre"
+ * @param service services.IEmailChangeService "El servicio de cambios de correo electrónico"
+ * @return *gin.RouterGroup "El grupo de endpoints creado"
+ */
+func newEmailChangeHandler(group gin.IRoutes, service services.IEmailChangeService) *gin.IRoutes {
+	group.POST("/confirm", handleConfirmEmailChange(service))
+	group.POST("/cancel", handleCancelEmailChange(service))
+
+	return &group
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maramal/user-service/models"
+)
+
+var errInvalidIfMatch = errors.New("la versión indicada en If-Match no corresponde al usuario")
+
+// Crea el ETag de un usuario a partir de su versión
+func userETag(user models.User) string {
+	return `"` + strconv.FormatInt(user.Version, 10) + `"`
+}
+
+/** Obtiene la versión esperada de la cabecera If-Match
+ *
+ * @param ctx *gin.Context "El contexto de la solicitud"
+ * @return *int64 "La versión, o nil si no se envió la cabecera o es *"
+ * @return error "El error si la cabecera no corresponde a una versión"
+ */
+func ifMatchVersion(ctx *gin.Context) (*int64, error) {
+	value := strings.TrimSpace(ctx.GetHeader("If-Match"))
+	if value == "" || value == "*" {
+		return nil, nil
+	}
+
+	version, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(value, "W/"), `"`), 10, 64)
+	if err != nil {
+		return nil, errInvalidIfMatch
+	}
+
+	return &version, nil
+}
+
+// Indica si alguno de los ETags de la cabecera If-None-Match coincide con etag
+func etagMatches(header string, etag string) bool {
+	for _, value := range strings.Split(header, ",") {
+		value = strings.TrimPrefix(strings.TrimSpace(value), "W/")
+		if value == "*" || value == etag {
+			return true
+		}
+	}
+
+	return false
+}
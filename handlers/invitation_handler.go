@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maramal/user-service/middlewares"
+	"github.com/maramal/user-service/models"
+	"github.com/maramal/user-service/services"
+	"github.com/maramal/user-service/utils"
+)
+
+// @Summary Invita a un usuario
+// @Description Crea el usuario en el estado invited, sin contraseña, y le envía por correo un enlace de un solo uso para aceptar la invitación y elegir su contraseña.
+// @ID 		create-invitation
+// @Accept 	json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	CreateInvitationRequest body services.CreateInvitationRequest true "Correo electrónico y datos iniciales del usuario"
+// @Success 201 {object} services.InvitationResponse
+// @Failure 400 {object} gin.H
+// @Failure 409 {object} gin.H
+// @Router 	/admin/invitations [post]
+func handleCreateInvitation(service services.IInvitationService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req services.CreateInvitationRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(err))
+			return
+		}
+
+		payload, ok := middlewares.GetAuthorizationPayload(ctx)
+		if !ok {
+			ctx.JSON(http.StatusUnauthorized, utils.ErrorResponse(errors.New("sesion no iniciada")))
+			return
+		}
+
+		invitation, err := service.CreateInvitation(req, payload.Email)
+		if err != nil {
+			ctx.JSON(invitationErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+		middlewares.SetAuditCreated(ctx, models.AuditTarget{Type: services.AuditTargetUsers, ID: invitation.Invitation.UserID.Hex()})
+
+		ctx.JSON(http.StatusCreated, utils.SuccessResponse(invitation))
+	}
+}
+
+// @Summary Obtiene las invitaciones pendientes
+// @Description Incluye las invitaciones vencidas, que se pueden reenviar.
+// @ID 		get-invitations
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} services.GetInvitationsResponse
+// @Router 	/admin/invitations [get]
+func handleGetInvitations(service services.IInvitationService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		invitations, err := service.GetPendingInvitations()
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(invitations))
+	}
+}
+
+// @Summary Reenvía una invitación pendiente
+// @Description Envía un enlace nuevo; el anterior deja de ser válido y el vencimiento se cuenta desde el reenvío.
+// @ID 		resend-invitation
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	id path string true "ID de la invitación"
+// @Success 200 {object} services.InvitationResponse
+// @Failure 404 {object} gin.H
+// @Router 	/admin/invitations/{id}/resend [post]
+func handleResendInvitation(service services.IInvitationService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		invitation, err := service.ResendInvitation(ctx.Param("id"))
+		if err != nil {
+			ctx.JSON(invitationErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(invitation))
+	}
+}
+
+// @Summary Revoca una invitación pendiente
+// @Description El enlace deja de ser válido y el usuario invitado se elimina.
+// @ID 		revoke-invitation
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	id path string true "ID de la invitación"
+// @Success 200 {object} gin.H
+// @Failure 404 {object} gin.H
+// @Router 	/admin/invitations/{id} [delete]
+func handleRevokeInvitation(service services.IInvitationService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		payload, ok := middlewares.GetAuthorizationPayload(ctx)
+		if !ok {
+			ctx.JSON(http.StatusUnauthorized, utils.ErrorResponse(errors.New("sesion no iniciada")))
+			return
+		}
+
+		if err := service.RevokeInvitation(ctx.Param("id"), payload.Email); err != nil {
+			ctx.JSON(invitationErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(nil))
+	}
+}
+
+// @Summary Acepta una invitación
+// @Description Recibe el token del enlace enviado por correo. No requiere autenticación. El usuario elige su contraseña, puede corregir su nombre y pasa al estado active; después ingresa con POST /login.
+// @ID 		accept-invitation
+// @Accept 	json
+// @Produce json
+// @Param 	AcceptInvitationRequest body services.AcceptInvitationRequest true "Token del enlace, contraseña y datos del perfil"
+// @Success 200 {object} services.UpdateUserResponse
+// @Failure 400 {object} gin.H
+// @Failure 404 {object} gin.H
+// @Router 	/invitations/accept [post]
+func handleAcceptInvitation(service services.IInvitationService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req services.AcceptInvitationRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(err))
+			return
+		}
+
+		user, err := service.AcceptInvitation(req)
+		if err != nil {
+			ctx.JSON(invitationErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+		middlewares.SetAuditTarget(ctx, models.AuditTarget{Type: services.AuditTargetUsers, ID: user.User.ID.Hex(), Email: user.User.Email})
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(user))
+	}
+}
+
+func invitationErrorStatus(err error) int {
+	if errors.Is(err, services.ErrInvitationNotFound) {
+		return http.StatusNotFound
+	}
+
+	return userErrorStatus(err)
+}
+
+/** Crea un nuevo grupo de endpoints de invitaciones de usuarios
+ *
+ * @param group *gin.RouterGroup "El grupo de endpoints padre"
+ * @param service services.IInvitationService "El servicio de invitaciones"
+ * @return *gin.RouterGroup "El grupo de endpoints creado"
+ */
+func newInvitationHandler(group gin.IRoutes, service services.IInvitationService) *gin.IRoutes {
+	group.GET("", middlewares.RequireScopes("users:read"), handleGetInvitations(service))
+	group.POST("", middlewares.RequireScopes("users:create"), handleCreateInvitation(service))
+	group.POST("/:id/resend", middlewares.RequireScopes("users:create"), handleResendInvitation(service))
+	group.DELETE("/:id", middlewares.RequireScopes("users:delete"), handleRevokeInvitation(service))
+
+	return &group
+}
+
+/** Crea un nuevo grupo de endpoints públicos de aceptación de invitaciones
+ *
+ * @param group *gin.RouterGroup "El grupo de endpoints padre"
+ * @param service services.IInvitationService "El servicio de invitaciones"
+ * @return *gin.RouterGroup "El grupo de endpoints creado"
+ */
+func newAcceptInvitationHandler(group gin.IRoutes, service services.IInvitationService) *gin.IRoutes {
+	group.POST("/accept", handleAcceptInvitation(service))
+
+	return &group
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/maramal/user-service/mailer"
+	"github.com/maramal/user-service/services"
+)
+
+const (
+	// Frecuencia con la que se ejecutan las tareas de mantenimiento
+	jobsInterval = time.Hour
+	// Tiempo durante el cual se pueden descargar las exportaciones en segundo plano
+	exportRetention = 24 * time.Hour
+)
+
+/** Ejecuta periódicamente las tareas de mantenimiento hasta que se cancela el contexto
+ *
+ * @param ctx context.Context "El contexto que detiene las tareas al cancelarse"
+ */
+func (server *Server) StartJobs(ctx context.Context) {
+	userService := services.NewUserService(server.Database)
+	userExportService := services.NewUserExportService(server.Database, server.Config.ExportsDir)
+
+	// La configuración del correo ya se validó al crear las rutas
+	mail, err := mailer.NewMailer(server.Config)
+	if err != nil {
+		log.Printf("Error al crear el servicio de correo de las tareas de mantenimiento: %s", err)
+		return
+	}
+	expirationService := services.NewUserExpirationService(server.Database, mail)
+
+	ticker := time.NewTicker(jobsInterval)
+	defer ticker.Stop()
+
+	for {
+		server.purgeDeletedUsers(userService)
+		server.purgeExports(userExportService)
+		server.expireUsers(expirationService)
+		server.sendExpirationReminders(expirationService)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Elimina definitivamente los usuarios cuyo período de retención terminó. Si no hay un período configurado no se elimina ninguno
+func (server *Server) purgeDeletedUsers(userService services.IUserService) {
+	if server.Config.DeletedUserRetention <= 0 {
+		return
+	}
+
+	deleted, err := userService.PurgeDeletedUsers(server.Config.DeletedUserRetention)
+	if err != nil {
+		log.Printf("Error al eliminar definitivamente los usuarios eliminados: %s", err)
+		return
+	}
+
+	if deleted > 0 {
+		log.Printf("Se eliminaron definitivamente %d usuarios", deleted)
+	}
+}
+
+// Pasa al estado expired a los usuarios cuya cuenta venció
+func (server *Server) expireUsers(expirationService services.IUserExpirationService) {
+	expired, err := expirationService.ExpireUsers()
+	if err != nil {
+		log.Printf("Error al vencer las cuentas de los usuarios: %s", err)
+		return
+	}
+
+	if expired > 0 {
+		log.Printf("Vencieron las cuentas de %d usuarios", expired)
+	}
+}
+
+// Avisa a los usuarios cuya cuenta está por vencer. Si no hay una anticipación configurada no se avisa
+func (server *Server) sendExpirationReminders(expirationService services.IUserExpirationService) {
+	if server.Config.ExpirationReminder <= 0 {
+		return
+	}
+
+	sent, err := expirationService.SendExpirationReminders(server.Config.ExpirationReminder)
+	if err != nil {
+		log.Printf("Error al avisar el vencimiento de las cuentas: %s", err)
+		return
+	}
+
+	if sent > 0 {
+		log.Printf("Se avisó el vencimiento de la cuenta a %d usuarios", sent)
+	}
+}
+
+// Elimina las exportaciones en segundo plano que ya no se pueden descargar
+func (server *Server) purgeExports(userExportService services.IUserExportService) {
+	deleted, err := userExportService.PurgeExports(exportRetention)
+	if err != nil {
+		log.Printf("Error al eliminar las exportaciones vencidas: %s", err)
+		return
+	}
+
+	if deleted > 0 {
+		log.Printf("Se eliminaron %d exportaciones vencidas", deleted)
+	}
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maramal/user-service/middlewares"
+	"github.com/maramal/user-service/services"
+	"github.com/maramal/user-service/utils"
+)
+
+// @Summary	Obtiene los intentos de ingreso de un usuario
+// @Description Incluye los intentos fallidos: contraseña incorrecta (wrong_password), usuario que no puede ingresar por su estado (locked), alcances no permitidos (invalid_scope), código por SMS enviado (mfa_required) o incorrecto (mfa_failed). Cada intento tiene la IP, el user agent y el navegador, el sistema operativo y el tipo de dispositivo obtenidos de él.
+// @ID 		get-user-logins
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	id 		path 	string 		true 	"ID del usuario"
+// @Param 	limit 	query 	int 		false 	"Cantidad de intentos por página (máximo 200)"
+// @Param 	cursor 	query 	string 		false 	"Cursor de la página (next_cursor)"
+// @Param 	result 	query 	[]string 	false 	"Resultados" collectionFormat(multi)
+// @Success 200 {object} services.GetLoginEventsResponse
+// @Failure 400 {object} gin.H
+// @Failure 404 {object} gin.H
+// @Router 	/admin/users/{id}/logins [get]
+func handleGetUserLogins(authService services.IAuthService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req services.GetLoginEventsRequest
+		if err := ctx.ShouldBindQuery(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(err))
+			return
+		}
+
+		events, err := authService.GetLoginEvents(ctx.Param("id"), req)
+		if err != nil {
+			ctx.JSON(userErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(events))
+	}
+}
+
+// @Summary	Obtiene los intentos de ingreso a la cuenta del usuario de la sesión
+// @Description Permite al usuario detectar ingresos que no reconoce. Incluye los intentos fallidos.
+// @ID 		get-my-activity
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	limit 	query 	int 		false 	"Cantidad de intentos por página (máximo 200)"
+// @Param 	cursor 	query 	string 		false 	"Cursor de la página (next_cursor)"
+// @Param 	result 	query 	[]string 	false 	"Resultados" collectionFormat(multi)
+// @Success 200 {object} services.GetLoginEventsResponse
+// @Failure 400 {object} gin.H
+// @Router 	/me/activity [get]
+func handleGetMyActivity(userService services.IUserService, authService services.IAuthService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req services.GetLoginEventsRequest
+		if err := ctx.ShouldBindQuery(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(err))
+			return
+		}
+
+		userId, ok := currentUserID(ctx, userService)
+		if !ok {
+			return
+		}
+
+		events, err := authService.GetLoginEvents(userId, req)
+		if err != nil {
+			ctx.JSON(userErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(events))
+	}
+}
+
+/** Crea un nuevo grupo de endpoints de intentos de ingreso de los usuarios
+ *
+ * @param group *gin.RouterGroup "El grupo de endpoints padre"
+ * @param authService services.IAuthService "El servicio de autenticación"
+ * @return *gin.RouterGroup "El grupo de endpoints creado"
+ */
+func newUserLoginHandler(group gin.IRoutes, authService services.IAuthService) *gin.IRoutes {
+	group.GET("/:id/logins", middlewares.RequireScopes("users:read"), handleGetUserLogins(authService))
+
+	return &group
+}
@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maramal/user-service/middlewares"
+	"github.com/maramal/user-service/services"
+	"github.com/maramal/user-service/utils"
+)
+
+// @Summary Obtiene el usuario de la sesión
+// @ID 		get-me
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} services.GetUserResponse
+// @Failure 401 {object} gin.H
+// @Router 	/me [get]
+func handleGetMe(userService services.IUserService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userId, ok := currentUserID(ctx, userService)
+		if !ok {
+			return
+		}
+
+		user, err := userService.GetUser(userId)
+		if err != nil {
+			ctx.JSON(userErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.Header("ETag", userETag(user.User))
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(user))
+	}
+}
+
+// @Summary Modifica el perfil del usuario de la sesión
+// @Description Igual que PATCH /admin/users/{id} (JSON Merge Patch y máscara de campos), pero sólo se pueden modificar first_name y last_name.
+// @ID 		patch-me
+// @Accept 	json
+// @Accept 	application/merge-patch+json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	fields 	query 	string 	false 	"Campos a modificar separados por coma"
+// @Param 	patch 	body 	object 	true 	"Campos a modificar, por ejemplo {\"first_name\":\"Juan\"}"
+// @Param 	If-Match header string 	false 	"ETag obtenido en GET /me"
+// @Success 200 {object} services.UpdateUserResponse
+// @Failure 400 {object} gin.H
+// @Failure 412 {object} gin.H
+// @Router 	/me [patch]
+func handlePatchMe(userService services.IUserService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		req, ok := bindPatchUserRequest(ctx)
+		if !ok {
+			return
+		}
+
+		userId, ok := currentUserID(ctx, userService)
+		if !ok {
+			return
+		}
+
+		user, err := userService.PatchProfile(userId, req)
+		if err != nil {
+			ctx.JSON(userErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.Header("ETag", userETag(user.User))
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(user))
+	}
+}
+
+// @Summary Cambia la contraseña del usuario de la sesión
+// @Description Requiere la contraseña actual. Las demás sesiones del usuario se revocan.
+// @ID 		change-my-password
+// @Accept 	json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	ChangeOwnPasswordRequest body services.ChangeOwnPasswordRequest true "Contraseña actual y nueva"
+// @Success 200 {object} gin.H
+// @Failure 400 {object} gin.H
+// @Router 	/me/password [post]
+func handleChangeMyPassword(userService services.IUserService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req services.ChangeOwnPasswordRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(err))
+			return
+		}
+
+		userId, ok := currentUserID(ctx, userService)
+		if !ok {
+			return
+		}
+
+		payload, _ := middlewares.GetAuthorizationPayload(ctx)
+		if err := userService.ChangeOwnPassword(userId, payload.SessionID, req); err != nil {
+			ctx.JSON(userErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(nil))
+	}
+}
+
+// @Summary Obtiene las sesiones vigentes del usuario de la sesión
+// @ID 		get-my-sessions
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} services.GetSessionsResponse
+// @Router 	/me/sessions [get]
+func handleGetMySessions(authService services.IAuthService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		payload, ok := middlewares.GetAuthorizationPayload(ctx)
+		if !ok {
+			ctx.JSON(http.StatusUnauthorized, utils.ErrorResponse(errors.New("sesion no iniciada")))
+			return
+		}
+
+		sessions, err := authService.GetUserSessions(payload.Email, payload.SessionID)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(sessions))
+	}
+}
+
+// @Summary Revoca una sesión del usuario de la sesión
+// @Description Los tokens de la sesión dejan de ser válidos. Revocar la sesión actual equivale a cerrar sesión.
+// @ID 		revoke-my-session
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	id path string true "ID de la sesión"
+// @Success 200 {object} gin.H
+// @Failure 404 {object} gin.H
+// @Router 	/me/sessions/{id} [delete]
+func handleRevokeMySession(authService services.IAuthService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		payload, ok := middlewares.GetAuthorizationPayload(ctx)
+		if !ok {
+			ctx.JSON(http.StatusUnauthorized, utils.ErrorResponse(errors.New("sesion no iniciada")))
+			return
+		}
+
+		err := authService.RevokeUserSession(payload.Email, ctx.Param("id"))
+		if errors.Is(err, services.ErrSessionNotFound) {
+			ctx.JSON(http.StatusNotFound, utils.ErrorResponse(err))
+			return
+		}
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(nil))
+	}
+}
+
+// @Summary Sube la imagen de perfil del usuario de la sesión
+// @Description Acepta imágenes JPEG, PNG o GIF. Se eliminan los metadatos (EXIF) y se generan miniaturas de 64, 128 y 256 píxeles.
+// @ID 		set-my-avatar
+// @Accept 	multipart/form-data
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	image formData file true "Imagen JPEG, PNG o GIF"
+// @Success 200 {object} services.UpdateUserResponse
+// @Failure 400 {object} gin.H
+// @Failure 413 {object} gin.H
+// @Router 	/me/avatar [put]
+func handleSetMyAvatar(userService services.IUserService, avatarService services.IAvatarService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userId, ok := currentUserID(ctx, userService)
+		if !ok {
+			return
+		}
+
+		setAvatar(ctx, avatarService, userId)
+	}
+}
+
+// @Summary Elimina la imagen de perfil del usuario de la sesión
+// @ID 		delete-my-avatar
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} services.UpdateUserResponse
+// @Failure 404 {object} gin.H
+// @Router 	/me/avatar [delete]
+func handleDeleteMyAvatar(userService services.IUserService, avatarService services.IAvatarService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userId, ok := currentUserID(ctx, userService)
+		if !ok {
+			return
+		}
+
+		deleteAvatar(ctx, avatarService, userId)
+	}
+}
+
+// @Summary Exporta todos los datos del usuario de la sesión
+// @Description Igual que GET /admin/users/{id}/data-export.
+// @ID 		export-my-data
+// @Produce application/zip
+// @Security ApiKeyAuth
+// @Success 200 {file} file
+// @Router 	/me/data-export [get]
+func handleExportMyData(userService services.IUserService, privacyService services.IUserPrivacyService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userId, ok := currentUserID(ctx, userService)
+		if !ok {
+			return
+		}
+
+		exportUserData(ctx, privacyService, userId)
+	}
+}
+
+// Obtiene el id del usuario de la sesión. Si no se puede obtener responde con el error y devuelve false
+func currentUserID(ctx *gin.Context, userService services.IUserService) (string, bool) {
+	payload, ok := middlewares.GetAuthorizationPayload(ctx)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, utils.ErrorResponse(errors.New("sesion no iniciada")))
+		return "", false
+	}
+
+	user, err := userService.GetUserByEmail(payload.Email)
+	if err != nil {
+		ctx.JSON(userErrorStatus(err), utils.ErrorResponse(err))
+		return "", false
+	}
+
+	return user.User.ID.Hex(), true
+}
+
+// Obtiene el correo electrónico del usuario de la sesión, para registrar quién hace un cambio
+func sessionEmail(ctx *gin.Context) string {
+	payload, ok := middlewares.GetAuthorizationPayload(ctx)
+	if !ok {
+		return ""
+	}
+
+	return payload.Email
+}
+
+/** Crea un nuevo grupo de endpoints del usuario de la sesión
+ *
+ * @param group *gin.RouterGroup "El grupo de endpoints padre"
+ * @param userService services.IUserService "El servicio de usuarios"
+ * @param authService services.IAuthService "El servicio de sesiones"
+ * @param avatarService services.IAvatarService "El servicio de imágenes de perfil"
+ * @param privacyService services.IUserPrivacyService "El servicio de protección de datos"
+ * @param emailChangeService services.IEmailChangeService "El servicio de cambios de correo electrónico"
+ * @param phoneService services.IPhoneService "El servicio de teléfonos"
+ * @param settingsService services.IUserSettingsService "El servicio de preferencias"
+ * @param metadataService services.IUserMetadataService "El servicio de metadatos"
+ * @return *gin.RouterGroup "El grupo de endpoints creado"
+ */
+func newMeHandler(group gin.IRoutes, userService services.IUserService, authService services.IAuthService, avatarService services.IAvatarService, privacyService services.IUserPrivacyService, emailChangeService services.IEmailChangeService, phoneService services.IPhoneService, settingsService services.IUserSettingsService, metadataService services.IUserMetadataService) *gin.IRoutes {
+	group.GET("", middlewares.RequireScopes("users:read"), handleGetMe(userService))
+	group.PATCH("", middlewares.RequireScopes("users:update"), handlePatchMe(userService))
+	group.POST("/password", middlewares.RequireScopes("users:update"), handleChangeMyPassword(userService))
+
+	group.GET("/settings", middlewares.RequireScopes("users:read"), handleGetMySettings(userService, settingsService))
+	group.PATCH("/settings", middlewares.RequireScopes("users:update"), handlePatchMySettings(userService, settingsService))
+
+	group.GET("/metadata", middlewares.RequireScopes("users:read"), handleGetMyMetadata(userService, metadataService))
+	group.PATCH("/metadata", middlewares.RequireScopes("users:update"), handlePatchMyMetadata(userService, metadataService))
+
+	group.GET("/email-change", middlewares.RequireScopes("users:read"), handleGetMyEmailChange(userService, emailChangeService))
+	group.POST("/email-change", middlewares.RequireScopes("users:update"), handleRequestMyEmailChange(userService, emailChangeService))
+	group.DELETE("/email-change", middlewares.RequireScopes("users:update"), handleCancelMyEmailChange(userService, emailChangeService))
+
+	group.POST("/phone", middlewares.RequireScopes("users:update"), handleStartMyPhoneVerification(userService, phoneService))
+	group.POST("/phone/verify", middlewares.RequireScopes("users:update"), handleConfirmMyPhoneVerification(userService, phoneService))
+	group.DELETE("/phone", middlewares.RequireScopes("users:update"), handleRemoveMyPhone(userService, phoneService))
+	group.PUT("/phone/mfa", middlewares.RequireScopes("users:update"), handleSetMyPhoneMFA(userService, phoneService))
+
+	group.GET("/sessions", middlewares.RequireScopes("users:read"), handleGetMySessions(authService))
+	group.GET("/activity", middlewares.RequireScopes("users:read"), handleGetMyActivity(userService, authService))
+	group.DELETE("/sessions/:id", middlewares.RequireScopes("users:update"), handleRevokeMySession(authService))
+
+	group.PUT("/avatar", middlewares.RequireScopes("users:update"), handleSetMyAvatar(userService, avatarService))
+	group.DELETE("/avatar", middlewares.RequireScopes("users:update"), handleDeleteMyAvatar(userService, avatarService))
+
+	group.GET("/data-export", middlewares.RequireScopes("users:read"), handleExportMyData(userService, privacyService))
+
+	return &group
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maramal/user-service/middlewares"
+	"github.com/maramal/user-service/services"
+	"github.com/maramal/user-service/utils"
+)
+
+// @Summary Inicia la verificación de un teléfono del usuario de la sesión
+// @Description Requiere la contraseña actual. Envía un código por SMS al teléfono, que debe incluir el código de país (formato E.164). El teléfono del usuario no cambia hasta que se confirma el código.
+// @ID 		start-my-phone-verification
+// @Accept 	json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	StartPhoneVerificationRequest body services.StartPhoneVerificationRequest true "Teléfono y contraseña actual"
+// @Success 200 {object} services.PhoneVerificationResponse
+// @Failure 400 {object} gin.H
+// @Failure 409 {object} gin.H
+// @Failure 429 {object} gin.H
+// @Router 	/me/phone [post]
+func handleStartMyPhoneVerification(userService services.IUserService, phoneService services.IPhoneService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req services.StartPhoneVerificationRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(err))
+			return
+		}
+
+		userId, ok := currentUserID(ctx, userService)
+		if !ok {
+			return
+		}
+
+		verification, err := phoneService.StartPhoneVerification(userId, req)
+		if err != nil {
+			ctx.JSON(phoneErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(verification))
+	}
+}
+
+// @Summary Confirma la verificación del teléfono del usuario de la sesión
+// @Description Recibe el código enviado por SMS. El teléfono reemplaza al anterior del usuario.
+// @ID 		confirm-my-phone-verification
+// @Accept 	json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	ConfirmPhoneVerificationRequest body services.ConfirmPhoneVerificationRequest true "Código recibido"
+// @Success 200 {object} services.UpdateUserResponse
+// @Failure 400 {object} gin.H
+// @Failure 409 {object} gin.H
+// @Router 	/me/phone/verify [post]
+func handleConfirmMyPhoneVerification(userService services.IUserService, phoneService services.IPhoneService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req services.ConfirmPhoneVerificationRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(err))
+			return
+		}
+
+		userId, ok := currentUserID(ctx, userService)
+		if !ok {
+			return
+		}
+
+		user, err := phoneService.ConfirmPhoneVerification(userId, req.Code)
+		if err != nil {
+			ctx.JSON(phoneErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(user))
+	}
+}
+
+// @Summary Elimina el teléfono del usuario de la sesión
+// @Description Requiere la contraseña actual. También desactiva el segundo factor por SMS.
+// @ID 		remove-my-phone
+// @Accept 	json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	RemoveOwnPhoneRequest body services.RemoveOwnPhoneRequest true "Contraseña actual"
+// @Success 200 {object} services.UpdateUserResponse
+// @Failure 400 {object} gin.H
+// @Failure 409 {object} gin.H
+// @Router 	/me/phone [delete]
+func handleRemoveMyPhone(userService services.IUserService, phoneService services.IPhoneService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req services.RemoveOwnPhoneRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(err))
+			return
+		}
+
+		userId, ok := currentUserID(ctx, userService)
+		if !ok {
+			return
+		}
+
+		user, err := phoneService.RemoveOwnPhone(userId, req)
+		if err != nil {
+			ctx.JSON(phoneErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(user))
+	}
+}
+
+// @Summary Activa o desactiva el segundo factor por SMS del usuario de la sesión
+// @Description Requiere la contraseña actual. Para activarlo el usuario debe tener un teléfono verificado. Con el segundo factor activo, POST /login responde 202 y el ingreso se completa en POST /login/mfa.
+// @ID 		set-my-phone-mfa
+// @Accept 	json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	SetPhoneMFARequest body services.SetPhoneMFARequest true "Si se activa y contraseña actual"
+// @Success 200 {object} services.UpdateUserResponse
+// @Failure 400 {object} gin.H
+// @Failure 409 {object} gin.H
+// @Router 	/me/phone/mfa [put]
+func handleSetMyPhoneMFA(userService services.IUserService, phoneService services.IPhoneService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req services.SetPhoneMFARequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(err))
+			return
+		}
+
+		userId, ok := currentUserID(ctx, userService)
+		if !ok {
+			return
+		}
+
+		user, err := phoneService.SetPhoneMFA(userId, req)
+		if err != nil {
+			ctx.JSON(phoneErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(user))
+	}
+}
+
+// @Summary Elimina el teléfono de un usuario
+// @Description También desactiva el segundo factor por SMS. Sirve para recuperar el acceso de un usuario que perdió su teléfono.
+// @ID 		remove-user-phone
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	id path string true "ID del usuario"
+// @Success 200 {object} services.UpdateUserResponse
+// @Failure 404 {object} gin.H
+// @Failure 409 {object} gin.H
+// @Router 	/admin/users/{id}/phone [delete]
+func handleRemoveUserPhone(phoneService services.IPhoneService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, err := phoneService.RemovePhone(ctx.Param("id"), sessionEmail(ctx))
+		if err != nil {
+			ctx.JSON(phoneErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(user))
+	}
+}
+
+func phoneErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrPhoneCodeThrottled):
+		return http.StatusTooManyRequests
+	case errors.Is(err, services.ErrPhoneTaken), errors.Is(err, services.ErrPhoneNotVerified), errors.Is(err, services.ErrPhoneAmbiguous):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrPhoneCodeInvalid), errors.Is(err, services.ErrPhoneCodeNotPending):
+		return http.StatusBadRequest
+	default:
+		return userErrorStatus(err)
+	}
+}
+
+/** Crea un nuevo grupo de endpoints de teléfonos de los usuarios
+ *
+ * @param group *gin.RouterGroup "El grupo de endpoints padre"
+ * @param phoneService services.IPhoneService "El servicio de teléfonos"
+ * @return *gin.RouterGroup "El grupo de endpoints creado"
+ */
+func newUserPhoneHandler(group gin.IRoutes, phoneService services.IPhoneService) *gin.IRoutes {
+	group.DELETE("/:id/phone", middlewares.RequireScopes("users:update"), handleRemoveUserPhone(phoneService))
+
+	return &group
+}
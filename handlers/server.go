@@ -7,8 +7,11 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/maramal/user-service/database"
 	_ "github.com/maramal/user-service/docs"
+	"github.com/maramal/user-service/mailer"
 	"github.com/maramal/user-service/middlewares"
 	"github.com/maramal/user-service/services"
+	"github.com/maramal/user-service/sms"
+	"github.com/maramal/user-service/storage"
 	"github.com/maramal/user-service/token"
 	"github.com/maramal/user-service/utils"
 	"github.com/newrelic/go-agent/v3/integrations/nrgin"
@@ -52,6 +55,14 @@ func NewServer(config utils.Config) (*Server, error) {
 		Database:   client.Database("users-dev"),
 	}
 
+	if err := database.EnsureIndexes(context.TODO(), server.Database); err != nil {
+		return nil, fmt.Errorf("Error al crear los índices de la base de datos: %s", utils.ErrorResponse(err))
+	}
+
+	if err := services.RunMigrations(server.Database); err != nil {
+		return nil, fmt.Errorf("Error al migrar los datos: %s", utils.ErrorResponse(err))
+	}
+
 	if config.APMAppName != "" && config.APMLicense != "" {
 		app, err := configAPM(config)
 		if err != nil {
@@ -61,12 +72,14 @@ func NewServer(config utils.Config) (*Server, error) {
 		server.APMApp = app
 	}
 
-	server.setupRouter()
+	if err := server.setupRouter(); err != nil {
+		return nil, fmt.Errorf("error al configurar el servidor: %w", err)
+	}
 
 	return server, nil
 }
 
-func (server *Server) setupRouter() {
+func (server *Server) setupRouter() error {
 	router := gin.New()
 
 	// Middlewares
@@ -76,6 +89,7 @@ func (server *Server) setupRouter() {
 
 	router.Use(
 		gin.Recovery(),
+		middlewares.RequestID(),
 		middlewares.Logger(),
 		gindump.Dump(),
 		middlewares.CorsConfig(),
@@ -86,28 +100,124 @@ func (server *Server) setupRouter() {
 
 	userService := services.NewUserService(server.Database)
 	authService := services.NewAuthService(server.Database)
+	userImportService := services.NewUserImportService(server.Database)
+	userExportService := services.NewUserExportService(server.Database, server.Config.ExportsDir)
+	userBulkService := services.NewUserBulkService(server.Database, server.Config.BulkMaxUsers)
+	customAttributeService := services.NewCustomAttributeService(server.Database)
+	settingsService := services.NewUserSettingsService(server.Database)
+	metadataService := services.NewUserMetadataService(server.Database, server.Config.MetadataMaxSize)
+	auditService := services.NewAuditService(server.Database)
+	authzService := services.NewAuthzService(server.Database, server.TokenMaker, server.Config.AuthzCacheTTL)
+
+	fileStorage, err := storage.NewStorage(server.Config)
+	if err != nil {
+		return err
+	}
+	avatarService := services.NewAvatarService(server.Database, fileStorage, server.Config.AvatarMaxSize)
+	privacyService := services.NewUserPrivacyService(server.Database, fileStorage)
+
+	mail, err := mailer.NewMailer(server.Config)
+	if err != nil {
+		return err
+	}
+	emailChangeService := services.NewEmailChangeService(server.Database, mail, server.Config.AppURL, server.Config.EmailChangeTTL)
+	invitationService := services.NewInvitationService(server.Database, mail, server.Config.AppURL, server.Config.InvitationTTL)
+	expirationService := services.NewUserExpirationService(server.Database, mail)
+
+	smsSender, err := sms.NewSender(server.Config)
+	if err != nil {
+		return err
+	}
+	phoneService := services.NewPhoneService(server.Database, smsSender, services.PhoneServiceConfig{
+		Unique:         server.Config.PhoneUnique,
+		CodeTTL:        server.Config.SMSCodeTTL,
+		ResendInterval: server.Config.SMSResendInterval,
+		MaxPerHour:     server.Config.SMSMaxPerHour,
+	})
 
 	// Rutas API
 	apiRouter := router.Group("/api")
 	adminRouter := apiRouter.Group("/admin")
 	authRouter := apiRouter.Group("/")
 
-	adminRouter.Use(middlewares.AuthMiddleware(server.TokenMaker)).Use(middlewares.AdminMiddleware())
-	authRouter.Use(middlewares.AuthMiddleware(server.TokenMaker))
+	// Registra las solicitudes que modifican datos o descargan datos personales. En las rutas de
+	// administración va antes de la autenticación, para registrar también los accesos rechazados
+	audit := middlewares.Audit(auditService)
+
+	adminRouter.Use(audit).Use(middlewares.AuthMiddleware(server.TokenMaker, authService)).Use(middlewares.AdminMiddleware())
+	authRouter.Use(middlewares.AuthMiddleware(server.TokenMaker, authService))
 
 	// Usuarios
 	userRoutes := adminRouter.Group("/users")
-	newUserHandler(userRoutes, userService)
+	newUserHandler(userRoutes, userService, server.Config.RequireIfMatch)
+	newUserImportHandler(userRoutes, userImportService)
+	newUserExportHandler(userRoutes, userExportService)
+	newUserBulkHandler(userRoutes, userBulkService)
+	newUserAvatarHandler(userRoutes, avatarService)
+	newUserPrivacyHandler(userRoutes, privacyService)
+	newUserEmailChangeHandler(userRoutes, emailChangeService)
+	newUserPhoneHandler(userRoutes, phoneService)
+	newUserExpirationHandler(userRoutes, expirationService)
+	newUserMetadataHandler(userRoutes, metadataService)
+	newUserLoginHandler(userRoutes, authService)
+
+	// Invitaciones
+	invitationRoutes := adminRouter.Group("/invitations")
+	newInvitationHandler(invitationRoutes, invitationService)
+
+	acceptInvitationRoutes := apiRouter.Group("/invitations", audit)
+	newAcceptInvitationHandler(acceptInvitationRoutes, invitationService)
+
+	// Usuario de la sesión
+	meRoutes := authRouter.Group("/me", audit)
+	newMeHandler(meRoutes, userService, authService, avatarService, privacyService, emailChangeService, phoneService, settingsService, metadataService)
+
+	// Confirmación de cambios de correo electrónico
+	emailChangeRoutes := apiRouter.Group("/email-change", audit)
+	newEmailChangeHandler(emailChangeRoutes, emailChangeService)
+
+	// Imágenes de perfil
+	avatarRoutes := apiRouter.Group("/avatars")
+	newAvatarHandler(avatarRoutes, avatarService)
+
+	// Atributos personalizados
+	customAttributeRoutes := adminRouter.Group("/custom-attributes")
+	newCustomAttributeHandler(customAttributeRoutes, customAttributeService)
+
+	// Preferencias de los usuarios
+	settingsRoutes := adminRouter.Group("/settings")
+	newSettingsConfigHandler(settingsRoutes, settingsService)
+
+	// Configuración de los metadatos de los usuarios
+	metadataRoutes := adminRouter.Group("/metadata")
+	newMetadataConfigHandler(metadataRoutes, metadataService)
+
+	// Auditoría
+	auditRoutes := adminRouter.Group("/audit")
+	newAuditHandler(auditRoutes, auditService)
+
+	// Autorización
+	authzRoutes := authRouter.Group("/authz")
+	newAuthzHandler(authzRoutes, authzService)
 
 	// Autenticación
+	loginRoutes := apiRouter.Group("/", audit)
 	newAuthHandler(
-		apiRouter,
+		loginRoutes,
 		userService,
 		authService,
+		customAttributeService,
+		phoneService,
+		settingsService,
+		metadataService,
 		server,
 	)
 
+	tokenRoutes := authRouter.Group("/token", audit)
+	newTokenHandler(tokenRoutes, server)
+
 	server.Router = router
+	return nil
 }
 
 func configAPM(config utils.Config) (*newrelic.Application, error) {
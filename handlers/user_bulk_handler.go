@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maramal/user-service/middlewares"
+	"github.com/maramal/user-service/services"
+	"github.com/maramal/user-service/utils"
+)
+
+// @Summary Aplica una acción a varios usuarios
+// @Description Las acciones posibles son suspend, activate, delete, revoke_sessions y force_password_reset.
+// @Description Los usuarios se indican con user_ids o con filter. confirm debe coincidir con la cantidad de usuarios afectados;
+// @Description si no coincide la respuesta indica la cantidad esperada. La operación se procesa en segundo plano y se consulta en /admin/users/bulk/{id}.
+// @ID 		bulk-users
+// @Accept 	json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	BulkUsersRequest body services.BulkUsersRequest true "Acción y usuarios afectados"
+// @Success 202 {object} models.UserBulkOperation "Operación iniciada"
+// @Failure 400 {object} gin.H
+// @Failure 403 {object} gin.H
+// @Router 	/admin/users/bulk [post]
+func handleBulkUsers(service services.IUserBulkService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req services.BulkUsersRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(err))
+			return
+		}
+
+		payload, ok := middlewares.GetAuthorizationPayload(ctx)
+		if !ok {
+			ctx.JSON(http.StatusUnauthorized, utils.ErrorResponse(errors.New("sesion no iniciada")))
+			return
+		}
+
+		if req.Action == services.BulkActionDelete && !payload.HasScopes("users:delete") {
+			err := errors.New("el token no incluye los alcances requeridos: users:delete")
+			ctx.JSON(http.StatusForbidden, utils.ErrorResponse(err))
+			return
+		}
+
+		operation, err := service.StartBulkOperation(req, payload.Email)
+		if err != nil {
+			ctx.JSON(userBulkErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusAccepted, utils.SuccessResponse(operation))
+	}
+}
+
+// @Summary Obtiene el avance y el resultado por usuario de una operación masiva
+// @ID 		get-bulk-operation
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	id path string true "ID de la operación"
+// @Success 200 {object} models.UserBulkOperation
+// @Failure 404 {object} gin.H
+// @Router 	/admin/users/bulk/{id} [get]
+func handleGetBulkOperation(service services.IUserBulkService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		operation, err := service.GetBulkOperation(ctx.Param("id"))
+		if err != nil {
+			ctx.JSON(userBulkErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(operation))
+	}
+}
+
+func userBulkErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidBulkOperation),
+		errors.Is(err, services.ErrInvalidUserData),
+		errors.Is(err, services.ErrBulkLimitExceeded),
+		errors.Is(err, services.ErrBulkConfirmationRequired):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrBulkOperationNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+/** Agrega los endpoints de operaciones masivas al grupo de usuarios
+ *
+ * @param group *gin.RouterGroup "El grupo de endpoints de usuarios"
+ * @param service services.IUserBulkService "El servicio de operaciones masivas"
+ * @return *gin.RouterGroup "El grupo de endpoints"
+ */
+func newUserBulkHandler(group gin.IRoutes, bulkService services.IUserBulkService) *gin.IRoutes {
+	group.POST("/bulk", middlewares.RequireScopes("users:update"), handleBulkUsers(bulkService))
+	group.GET("/bulk/:id", middlewares.RequireScopes("users:read"), handleGetBulkOperation(bulkService))
+
+	return &group
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maramal/user-service/middlewares"
+	"github.com/maramal/user-service/services"
+	"github.com/maramal/user-service/utils"
+)
+
+// @Summary Define o extiende el vencimiento de la cuenta de un usuario
+// @Description Desde la fecha indicada el usuario no puede ingresar y sus tokens dejan de ser válidos. Si la cuenta ya había vencido, el usuario vuelve al estado que tenía antes de vencer.
+// @ID 		set-user-expiration
+// @Accept 	json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	id path string true "ID del usuario"
+// @Param 	SetExpirationRequest body services.SetExpirationRequest true "Fecha de vencimiento"
+// @Success 200 {object} services.UpdateUserResponse
+// @Failure 400 {object} gin.H
+// @Failure 404 {object} gin.H
+// @Router 	/admin/users/{id}/expiration [put]
+func handleSetUserExpiration(service services.IUserExpirationService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req services.SetExpirationRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(err))
+			return
+		}
+
+		payload, ok := middlewares.GetAuthorizationPayload(ctx)
+		if !ok {
+			ctx.JSON(http.StatusUnauthorized, utils.ErrorResponse(errors.New("sesion no iniciada")))
+			return
+		}
+
+		user, err := service.SetExpiration(ctx.Param("id"), req, payload.Email)
+		if err != nil {
+			ctx.JSON(userErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(user))
+	}
+}
+
+// @Summary Quita el vencimiento de la cuenta de un usuario
+// @Description Si la cuenta ya había vencido, el usuario vuelve al estado que tenía antes de vencer.
+// @ID 		clear-user-expiration
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	id path string true "ID del usuario"
+// @Success 200 {object} services.UpdateUserResponse
+// @Failure 404 {object} gin.H
+// @Router 	/admin/users/{id}/expiration [delete]
+func handleClearUserExpiration(service services.IUserExpirationService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		payload, ok := middlewares.GetAuthorizationPayload(ctx)
+		if !ok {
+			ctx.JSON(http.StatusUnauthorized, utils.ErrorResponse(errors.New("sesion no iniciada")))
+			return
+		}
+
+		user, err := service.ClearExpiration(ctx.Param("id"), payload.Email)
+		if err != nil {
+			ctx.JSON(userErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(user))
+	}
+}
+
+/** Crea un nuevo grupo de endpoints de vencimiento de las cuentas de los usuarios
+ *
+ * @param group *gin.RouterGroup "El grupo de endpoints padre"
+ * @param service services.IUserExpirationService "El servicio de vencimiento de cuentas"
+ * @return *gin.RouterGroup "El grupo de endpoints creado"
+ */
+func newUserExpirationHandler(group gin.IRoutes, service services.IUserExpirationService) *gin.IRoutes {
+	group.PUT("/:id/expiration", middlewares.RequireScopes("users:update"), handleSetUserExpiration(service))
+	group.DELETE("/:id/expiration", middlewares.RequireScopes("users:update"), handleClearUserExpiration(service))
+
+	return &group
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maramal/user-service/middlewares"
+	"github.com/maramal/user-service/models"
+	"github.com/maramal/user-service/services"
+	"github.com/maramal/user-service/utils"
+)
+
+type userExportResponse struct {
+	models.UserExport
+	DownloadURL string `json:"download_url,omitempty"`
+}
+
+func newUserExportResponse(export models.UserExport) userExportResponse {
+	response := userExportResponse{UserExport: export}
+	if export.Status == services.ExportStatusCompleted {
+		response.DownloadURL = fmt.Sprintf("/api/admin/users/exports/%s/download", export.ID.Hex())
+	}
+
+	return response
+}
+
+// @Summary Exporta los usuarios en CSV, NDJSON o XLSX
+// @Description La respuesta se escribe a medida que se leen los usuarios de la base de datos. Nunca incluye la contraseña.
+// @ID 		export-users
+// @Produce text/csv,application/x-ndjson,application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Security ApiKeyAuth
+// @Param 	format 			query string 	true  "Formato: csv, ndjson o xlsx"
+// @Param 	columns 		query string 	false "Columnas separadas por coma"
+// @Param 	sort 			query string 	false "Campos de ordenamiento separados por coma, con - para orden descendente" default(-created_at)
+// @Param 	status 			query []string 	false "Estados" collectionFormat(multi)
+// @Param 	type 			query []string 	false "Tipos de usuario" collectionFormat(multi)
+// @Param 	created_from 	query string 	false "Fecha de creación mínima (RFC 3339)"
+// @Param 	created_to 		query string 	false "Fecha de creación máxima (RFC 3339)"
+// @Param 	email_domain 	query string 	false "Dominio del correo electrónico"
+// @Param 	custom 			query string 	false "Atributos personalizados declarados en el esquema, como custom[department]=ventas"
+// @Success 200 {file} file
+// @Failure 400 {object} gin.H
+// @Router 	/admin/users/export [get]
+func handleExportUsers(service services.IUserExportService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req services.ExportUsersRequest
+		if err := ctx.ShouldBindQuery(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(err))
+			return
+		}
+		req.Custom = ctx.QueryMap("custom")
+
+		contentType, fileName, err := services.ExportFileInfo(req.Format)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.Header("Content-Type", contentType)
+		ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+
+		if _, err := service.ExportUsers(req, ctx.Writer); err != nil {
+			// Si ya se enviaron filas no se puede cambiar la respuesta, sólo cortarla
+			if ctx.Writer.Written() {
+				ctx.Error(err)
+				return
+			}
+
+			ctx.Header("Content-Disposition", "")
+			ctx.JSON(userExportErrorStatus(err), utils.ErrorResponse(err))
+		}
+	}
+}
+
+// @Summary Inicia una exportación de usuarios en segundo plano
+// @Description Acepta los mismos parámetros que /admin/users/export. El archivo se descarga desde el download_url de la exportación una vez completada.
+// @ID 		start-user-export
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	format 			query string 	true  "Formato: csv, ndjson o xlsx"
+// @Param 	columns 		query string 	false "Columnas separadas por coma"
+// @Param 	sort 			query string 	false "Campos de ordenamiento separados por coma, con - para orden descendente" default(-created_at)
+// @Param 	status 			query []string 	false "Estados" collectionFormat(multi)
+// @Param 	type 			query []string 	false "Tipos de usuario" collectionFormat(multi)
+// @Param 	created_from 	query string 	false "Fecha de creación mínima (RFC 3339)"
+// @Param 	created_to 		query string 	false "Fecha de creación máxima (RFC 3339)"
+// @Param 	email_domain 	query string 	false "Dominio del correo electrónico"
+// @Param 	custom 			query string 	false "Atributos personalizados declarados en el esquema, como custom[department]=ventas"
+// @Success 202 {object} userExportResponse
+// @Failure 400 {object} gin.H
+// @Router 	/admin/users/exports [post]
+func handleStartExport(service services.IUserExportService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req services.ExportUsersRequest
+		if err := ctx.ShouldBindQuery(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(err))
+			return
+		}
+		req.Custom = ctx.QueryMap("custom")
+
+		payload, ok := middlewares.GetAuthorizationPayload(ctx)
+		if !ok {
+			ctx.JSON(http.StatusUnauthorized, utils.ErrorResponse(errors.New("sesion no iniciada")))
+			return
+		}
+
+		export, err := service.StartExport(req, payload.Email)
+		if err != nil {
+			ctx.JSON(userExportErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusAccepted, utils.SuccessResponse(newUserExportResponse(export)))
+	}
+}
+
+// @Summary Obtiene el estado de una exportación de usuarios
+// @ID 		get-user-export
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	id path string true "ID de la exportación"
+// @Success 200 {object} userExportResponse
+// @Failure 404 {object} gin.H
+// @Router 	/admin/users/exports/{id} [get]
+func handleGetExport(service services.IUserExportService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.Param("id")
+		if id == "" {
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(errors.New("el id es requerido")))
+			return
+		}
+
+		export, err := service.GetExport(id)
+		if err != nil {
+			ctx.JSON(userExportErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(newUserExportResponse(export)))
+	}
+}
+
+// @Summary Descarga el archivo de una exportación de usuarios
+// @ID 		download-user-export
+// @Produce text/csv,application/x-ndjson,application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Security ApiKeyAuth
+// @Param 	id path string true "ID de la exportación"
+// @Success 200 {file} file
+// @Failure 404 {object} gin.H
+// @Failure 409 {object} gin.H
+// @Router 	/admin/users/exports/{id}/download [get]
+func handleDownloadExport(service services.IUserExportService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.Param("id")
+		if id == "" {
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(errors.New("el id es requerido")))
+			return
+		}
+
+		// El id de la ruta es el de la exportación, no el de un usuario
+		middlewares.SetAuditTarget(ctx, models.AuditTarget{Type: "exports", ID: id})
+
+		export, err := service.GetExport(id)
+		if err != nil {
+			ctx.JSON(userExportErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		if export.Status != services.ExportStatusCompleted {
+			ctx.JSON(http.StatusConflict, utils.ErrorResponse(services.ErrExportNotReady))
+			return
+		}
+
+		contentType, fileName, _ := services.ExportFileInfo(export.Format)
+		ctx.Header("Content-Type", contentType)
+		ctx.FileAttachment(export.FilePath, fileName)
+	}
+}
+
+func userExportErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidExport), errors.Is(err, services.ErrInvalidSort), errors.Is(err, services.ErrInvalidUserData):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrExportNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+/** Agrega los endpoints de exportación al grupo de usuarios
+ *
+ * @param group *gin.RouterGroup "El grupo de endpoints de usuarios"
+ * @param service services.IUserExportService "El servicio de exportaciones"
+ * @return *gin.RouterGroup "El grupo de endpoints"
+ */
+func newUserExportHandler(group gin.IRoutes, exportService services.IUserExportService) *gin.IRoutes {
+	group.GET("/export", middlewares.RequireScopes("users:read"), handleExportUsers(exportService))
+	group.POST("/exports", middlewares.RequireScopes("users:read"), handleStartExport(exportService))
+	group.GET("/exports/:id", middlewares.RequireScopes("users:read"), handleGetExport(exportService))
+	group.GET("/exports/:id/download", middlewares.RequireScopes("users:read"), handleDownloadExport(exportService))
+
+	return &group
+}
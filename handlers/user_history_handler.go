@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maramal/user-service/services"
+	"github.com/maramal/user-service/utils"
+)
+
+var errInvalidUserVersion = errors.New("la versión debe ser un número entero")
+
+// @Summary Obtiene el historial de versiones de un usuario
+// @Description Cada modificación del usuario guarda una versión. Para cada versión, de la más reciente a la más antigua, se indican la acción, su autor y los campos que cambiaron respecto de la versión anterior registrada.
+// @ID 		get-user-history
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	id path string true "ID del usuario"
+// @Success 200 {object} services.GetUserHistoryResponse
+// @Failure 404 {object} gin.H
+// @Router 	/admin/users/{id}/history [get]
+func handleGetUserHistory(service services.IUserService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		history, err := service.GetUserHistory(ctx.Param("id"))
+		if err != nil {
+			ctx.JSON(userHistoryErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(history))
+	}
+}
+
+// @Summary Obtiene una versión de un usuario
+// @Description Devuelve el usuario tal como quedó en esa versión.
+// @ID 		get-user-version
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	id path string true "ID del usuario"
+// @Param 	version path int true "Número de versión"
+// @Success 200 {object} services.GetUserVersionResponse
+// @Failure 400 {object} gin.H
+// @Failure 404 {object} gin.H
+// @Router 	/admin/users/{id}/history/{version} [get]
+func handleGetUserVersion(service services.IUserService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		version, err := strconv.ParseInt(ctx.Param("version"), 10, 64)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(errInvalidUserVersion))
+			return
+		}
+
+		userVersion, err := service.GetUserVersion(ctx.Param("id"), version)
+		if err != nil {
+			ctx.JSON(userHistoryErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(userVersion))
+	}
+}
+
+// @Summary Restaura un usuario a una versión anterior
+// @Description Restaura el nombre, el apellido, el tipo, los atributos personalizados, las preferencias y los metadatos. El correo electrónico, la contraseña, el estado, el teléfono, la imagen de perfil y el vencimiento no cambian. Si cambia el tipo se bloquean las sesiones del usuario. La restauración crea una versión nueva.
+// @ID 		restore-user-version
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	id path string true "ID del usuario"
+// @Param 	version path int true "Número de versión a restaurar"
+// @Param 	If-Match header string false "ETag del usuario obtenido en GET"
+// @Success 200 {object} services.UpdateUserResponse
+// @Failure 400 {object} gin.H
+// @Failure 404 {object} gin.H
+// @Failure 412 {object} gin.H
+// @Failure 428 {object} gin.H
+// @Router 	/admin/users/{id}/history/{version}/restore [post]
+func handleRestoreUserVersion(service services.IUserService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		version, err := strconv.ParseInt(ctx.Param("version"), 10, 64)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(errInvalidUserVersion))
+			return
+		}
+
+		ifVersion, err := ifMatchVersion(ctx)
+		if err != nil {
+			ctx.JSON(http.StatusPreconditionFailed, utils.ErrorResponse(err))
+			return
+		}
+
+		user, err := service.RestoreUserVersion(ctx.Param("id"), version, sessionEmail(ctx), ifVersion)
+		if err != nil {
+			ctx.JSON(userHistoryErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.Header("ETag", userETag(user.User))
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(user))
+	}
+}
+
+func userHistoryErrorStatus(err error) int {
+	if errors.Is(err, services.ErrUserVersionNotFound) {
+		return http.StatusNotFound
+	}
+
+	return userErrorStatus(err)
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maramal/user-service/middlewares"
+	"github.com/maramal/user-service/services"
+	"github.com/maramal/user-service/utils"
+)
+
+// Tamaño máximo del archivo de importación (20 MB)
+const maxImportFileSize = 20 << 20
+
+// @Summary Importa usuarios desde un archivo CSV o NDJSON
+// @Description Con dry_run=true se validan todas las filas y se devuelve el resultado sin guardar usuarios.
+// @Description En caso contrario la importación se procesa en segundo plano y se consulta en /admin/users/imports/{id}.
+// @ID 		import-users
+// @Accept 	multipart/form-data
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	file 			formData file 	true  "Archivo CSV (con encabezado) o NDJSON"
+// @Param 	format 			formData string false "Formato del archivo (csv o ndjson). Por defecto se deduce de la extensión"
+// @Param 	mapping 		formData string false "Objeto JSON que asigna columnas del archivo a campos del usuario, por ejemplo {\"Correo\":\"email\"}"
+// @Param 	dry_run 		formData bool 	false "Validar sin guardar"
+// @Param 	on_duplicate 	formData string false "Qué hacer si el correo ya existe: skip (por defecto) o update. Al actualizar, la contraseña sólo cambia si la fila tiene reset_password=true"
+// @Success 200 {object} models.UserImport "Resultado de la prueba"
+// @Success 202 {object} models.UserImport "Importación iniciada"
+// @Failure 400 {object} gin.H
+// @Router 	/admin/users/import [post]
+func handleImportUsers(service services.IUserImportService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		fileHeader, err := ctx.FormFile("file")
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(errors.New("el archivo es requerido")))
+			return
+		}
+
+		if fileHeader.Size > maxImportFileSize {
+			err := fmt.Errorf("el archivo no puede superar los %d MB", maxImportFileSize>>20)
+			ctx.JSON(http.StatusRequestEntityTooLarge, utils.ErrorResponse(err))
+			return
+		}
+
+		file, err := fileHeader.Open()
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(err))
+			return
+		}
+		defer file.Close()
+
+		data, err := io.ReadAll(file)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(err))
+			return
+		}
+
+		format := strings.ToLower(ctx.PostForm("format"))
+		if format == "" {
+			format = importFormatFromFileName(fileHeader.Filename)
+		}
+
+		var mapping map[string]string
+		if value := ctx.PostForm("mapping"); value != "" {
+			if err := json.Unmarshal([]byte(value), &mapping); err != nil {
+				ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(fmt.Errorf("la asignación de columnas no es válida: %s", err)))
+				return
+			}
+		}
+
+		payload, ok := middlewares.GetAuthorizationPayload(ctx)
+		if !ok {
+			ctx.JSON(http.StatusUnauthorized, utils.ErrorResponse(errors.New("sesion no iniciada")))
+			return
+		}
+
+		job, err := service.ImportUsers(services.ImportUsersParams{
+			FileName:    fileHeader.Filename,
+			Format:      format,
+			Data:        data,
+			Mapping:     mapping,
+			DryRun:      ctx.PostForm("dry_run") == "true",
+			OnDuplicate: ctx.PostForm("on_duplicate"),
+			CreatedBy:   payload.Email,
+		})
+		if err != nil {
+			ctx.JSON(userImportErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		status := http.StatusAccepted
+		if job.DryRun {
+			status = http.StatusOK
+		}
+
+		ctx.JSON(status, utils.SuccessResponse(job))
+	}
+}
+
+// @Summary Obtiene las últimas importaciones de usuarios
+// @ID 		get-user-imports
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} services.GetImportsResponse
+// @Failure 400 {object} gin.H
+// @Router 	/admin/users/imports [get]
+func handleGetImports(service services.IUserImportService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		imports, err := service.GetImports()
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(imports))
+	}
+}
+
+// @Summary Obtiene el avance y los errores de una importación de usuarios
+// @ID 		get-user-import
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	id path string true "ID de la importación"
+// @Success 200 {object} models.UserImport
+// @Failure 404 {object} gin.H
+// @Router 	/admin/users/imports/{id} [get]
+func handleGetImport(service services.IUserImportService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.Param("id")
+		if id == "" {
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(errors.New("el id es requerido")))
+			return
+		}
+
+		job, err := service.GetImport(id)
+		if err != nil {
+			ctx.JSON(userImportErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(job))
+	}
+}
+
+// Deduce el formato del archivo a partir de su extensión
+func importFormatFromFileName(fileName string) string {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".csv":
+		return services.ImportFormatCSV
+	case ".ndjson", ".jsonl":
+		return services.ImportFormatNDJSON
+	default:
+		return ""
+	}
+}
+
+func userImportErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidImport):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrImportNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+/** Agrega los endpoints de importación al grupo de usuarios
+ *
+ * @param group *gin.RouterGroup "El grupo de endpoints de usuarios"
+ * @param service services.IUserImportService "El servicio de importaciones"
+ * @return *gin.RouterGroup "El grupo de endpoints"
+ */
+func newUserImportHandler(group gin.IRoutes, importService services.IUserImportService) *gin.IRoutes {
+	group.POST("/import", middlewares.RequireScopes("users:create"), handleImportUsers(importService))
+	group.GET("/imports", middlewares.RequireScopes("users:read"), handleGetImports(importService))
+	group.GET("/imports/:id", middlewares.RequireScopes("users:read"), handleGetImport(importService))
+
+	return &group
+}
@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maramal/user-service/middlewares"
+	"github.com/maramal/user-service/services"
+	"github.com/maramal/user-service/utils"
+)
+
+// @Summary Obtiene los metadatos del usuario de la sesión
+// @Description Incluye app_metadata, que sólo pueden modificar los administradores, y user_metadata, que modifica el propio usuario.
+// @ID 		get-my-metadata
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} services.UserMetadataResponse
+// @Router 	/me/metadata [get]
+func handleGetMyMetadata(userService services.IUserService, metadataService services.IUserMetadataService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userId, ok := currentUserID(ctx, userService)
+		if !ok {
+			return
+		}
+
+		metadata, err := metadataService.GetMetadata(userId)
+		if err != nil {
+			ctx.JSON(metadataErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(metadata))
+	}
+}
+
+// @Summary Modifica los metadatos propios del usuario de la sesión
+// @Description Modifica sólo user_metadata. Sigue JSON Merge Patch: los objetos se combinan en profundidad y null elimina la clave.
+// @ID 		patch-my-metadata
+// @Accept 	json
+// @Accept 	application/merge-patch+json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	patch body object true "Cambios en user_metadata, por ejemplo {\"sidebar\":{\"collapsed\":true},\"tour\":null}"
+// @Success 200 {object} services.UserMetadataResponse
+// @Failure 400 {object} gin.H
+// @Router 	/me/metadata [patch]
+func handlePatchMyMetadata(userService services.IUserService, metadataService services.IUserMetadataService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var patch map[string]interface{}
+		if err := ctx.ShouldBindJSON(&patch); err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(err))
+			return
+		}
+
+		userId, ok := currentUserID(ctx, userService)
+		if !ok {
+			return
+		}
+
+		metadata, err := metadataService.PatchUserMetadata(userId, patch, sessionEmail(ctx))
+		if err != nil {
+			ctx.JSON(metadataErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(metadata))
+	}
+}
+
+// @Summary Obtiene los metadatos de un usuario
+// @ID 		get-user-metadata
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	id path string true "ID del usuario"
+// @Success 200 {object} services.UserMetadataResponse
+// @Failure 404 {object} gin.H
+// @Router 	/admin/users/{id}/metadata [get]
+func handleGetUserMetadata(metadataService services.IUserMetadataService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		metadata, err := metadataService.GetMetadata(ctx.Param("id"))
+		if err != nil {
+			ctx.JSON(metadataErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(metadata))
+	}
+}
+
+// @Summary Modifica los metadatos de la aplicación de un usuario
+// @Description Modifica app_metadata, que el usuario no puede modificar. Sigue JSON Merge Patch: los objetos se combinan en profundidad y null elimina la clave.
+// @ID 		patch-user-app-metadata
+// @Accept 	json
+// @Accept 	application/merge-patch+json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	id path string true "ID del usuario"
+// @Param 	patch body object true "Cambios en app_metadata, por ejemplo {\"billing\":{\"customer_id\":\"cus_123\"}}"
+// @Success 200 {object} services.UserMetadataResponse
+// @Failure 400 {object} gin.H
+// @Failure 404 {object} gin.H
+// @Router 	/admin/users/{id}/app-metadata [patch]
+func handlePatchUserAppMetadata(metadataService services.IUserMetadataService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var patch map[string]interface{}
+		if err := ctx.ShouldBindJSON(&patch); err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(err))
+			return
+		}
+
+		metadata, err := metadataService.PatchAppMetadata(ctx.Param("id"), patch, sessionEmail(ctx))
+		if err != nil {
+			ctx.JSON(metadataErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(metadata))
+	}
+}
+
+// @Summary Modifica los metadatos propios de un usuario
+// @Description Modifica user_metadata en nombre del usuario. Sigue JSON Merge Patch: los objetos se combinan en profundidad y null elimina la clave.
+// @ID 		patch-user-user-metadata
+// @Accept 	json
+// @Accept 	application/merge-patch+json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	id path string true "ID del usuario"
+// @Param 	patch body object true "Cambios en user_metadata"
+// @Success 200 {object} services.UserMetadataResponse
+// @Failure 400 {object} gin.H
+// @Failure 404 {object} gin.H
+// @Router 	/admin/users/{id}/user-metadata [patch]
+func handlePatchUserUserMetadata(metadataService services.IUserMetadataService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var patch map[string]interface{}
+		if err := ctx.ShouldBindJSON(&patch); err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(err))
+			return
+		}
+
+		metadata, err := metadataService.PatchUserMetadata(ctx.Param("id"), patch, sessionEmail(ctx))
+		if err != nil {
+			ctx.JSON(metadataErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(metadata))
+	}
+}
+
+// @Summary Obtiene la configuración de los metadatos
+// @Description Devuelve las rutas de app_metadata que se incluyen en los claims de los tokens.
+// @ID 		get-metadata-config
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} services.MetadataConfigResponse
+// @Router 	/admin/metadata [get]
+func handleGetMetadataConfig(metadataService services.IUserMetadataService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		config, err := metadataService.GetMetadataConfig()
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(config))
+	}
+}
+
+// @Summary Reemplaza la configuración de los metadatos
+// @Description Define las rutas de app_metadata (separadas por puntos) que se incluyen en los claims de los tokens y el nombre de cada claim. Los tokens ya emitidos no cambian.
+// @ID 		update-metadata-config
+// @Accept 	json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	UpdateMetadataConfigRequest body services.UpdateMetadataConfigRequest true "Rutas y claims"
+// @Success 200 {object} services.MetadataConfigResponse
+// @Failure 400 {object} gin.H
+// @Router 	/admin/metadata [put]
+func handleUpdateMetadataConfig(metadataService services.IUserMetadataService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req services.UpdateMetadataConfigRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(err))
+			return
+		}
+
+		payload, ok := middlewares.GetAuthorizationPayload(ctx)
+		if !ok {
+			ctx.JSON(http.StatusUnauthorized, utils.ErrorResponse(errors.New("sesion no iniciada")))
+			return
+		}
+
+		config, err := metadataService.UpdateMetadataConfig(req, payload.Email)
+		if err != nil {
+			ctx.JSON(metadataErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(config))
+	}
+}
+
+func metadataErrorStatus(err error) int {
+	if errors.Is(err, services.ErrInvalidMetadata) {
+		return http.StatusBadRequest
+	}
+
+	return userErrorStatus(err)
+}
+
+/** Crea un nuevo grupo de endpoints de metadatos de los usuarios
+ *
+ * @param group *gin.RouterGroup "El grupo de endpoints padre"
+ * @param metadataService services.IUserMetadataService "El servicio de metadatos"
+ * @return *gin.RouterGroup "El grupo de endpoints creado"
+ */
+func newUserMetadataHandler(group gin.IRoutes, metadataService services.IUserMetadataService) *gin.IRoutes {
+	group.GET("/:id/metadata", middlewares.RequireScopes("users:read"), handleGetUserMetadata(metadataService))
+	group.PATCH("/:id/app-metadata", middlewares.RequireScopes("users:update"), handlePatchUserAppMetadata(metadataService))
+	group.PATCH("/:id/user-metadata", middlewares.RequireScopes("users:update"), handlePatchUserUserMetadata(metadataService))
+
+	return &group
+}
+
+/** Crea un nuevo grupo de endpoints de configuración de los metadatos de los usuarios
+ *
+ * @param group *gin.RouterGroup "El grupo de endpoints padre"
+ * @param metadataService services.IUserMetadataService "El servicio de metadatos"
+ * @return *gin.RouterGroup "El grupo de endpoints creado"
+ */
+func newMetadataConfigHandler(group gin.IRoutes, metadataService services.IUserMetadataService) *gin.IRoutes {
+	group.GET("", middlewares.RequireScopes("users:read"), handleGetMetadataConfig(metadataService))
+	group.PUT("", middlewares.RequireScopes("users:update"), handleUpdateMetadataConfig(metadataService))
+
+	return &group
+}
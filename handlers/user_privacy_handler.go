@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maramal/user-service/middlewares"
+	"github.com/maramal/user-service/services"
+	"github.com/maramal/user-service/utils"
+)
+
+// @Summary Exporta todos los datos de un usuario
+// @Description Archivo ZIP con el perfil, la imagen de perfil, las sesiones, el historial de estados, las operaciones masivas que lo incluyeron y las acciones que hizo como administrador. Incluye a los usuarios eliminados.
+// @ID 		export-user-data
+// @Produce application/zip
+// @Security ApiKeyAuth
+// @Param 	id path string true "ID del usuario"
+// @Success 200 {file} file
+// @Failure 404 {object} gin.H
+// @Router 	/admin/users/{id}/data-export [get]
+func handleExportUserData(service services.IUserPrivacyService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		exportUserData(ctx, service, ctx.Param("id"))
+	}
+}
+
+// @Summary Borra los datos personales de un usuario
+// @Description Los datos se anonimizan: el usuario conserva su id y su correo electrónico se reemplaza por un seudónimo en todas las colecciones. Se eliminan sus sesiones y su imagen de perfil. El borrado no se puede deshacer.
+// @ID 		erase-user
+// @Accept 	json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	id 					path string 					true "ID del usuario"
+// @Param 	EraseUserRequest 	body services.EraseUserRequest 	true "Motivo y confirmación (correo electrónico del usuario)"
+// @Success 200 {object} services.EraseUserResponse
+// @Failure 400 {object} gin.H
+// @Failure 404 {object} gin.H
+// @Failure 410 {object} gin.H
+// @Router 	/admin/users/{id}/erase [post]
+func handleEraseUser(service services.IUserPrivacyService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req services.EraseUserRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(err))
+			return
+		}
+
+		payload, ok := middlewares.GetAuthorizationPayload(ctx)
+		if !ok {
+			ctx.JSON(http.StatusUnauthorized, utils.ErrorResponse(errors.New("sesion no iniciada")))
+			return
+		}
+
+		erasure, err := service.EraseUser(ctx.Param("id"), req, payload.Email)
+		if err != nil {
+			ctx.JSON(userErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(erasure))
+	}
+}
+
+func exportUserData(ctx *gin.Context, service services.IUserPrivacyService, userId string) {
+	export, err := service.ExportUserData(userId)
+	if err != nil {
+		ctx.JSON(userErrorStatus(err), utils.ErrorResponse(err))
+		return
+	}
+
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", export.FileName))
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Data(http.StatusOK, "application/zip", export.Data)
+}
+
+/** Crea un nuevo grupo de endpoints de protección de datos
+ *
+ * @param group *gin.RouterGroup "El grupo de endpoints padre"
+ * @param service services.IUserPrivacyService "El servicio de protección de datos"
+ * @return *gin.RouterGroup "El grupo de endpoints creado"
+ */
+func newUserPrivacyHandler(group gin.IRoutes, service services.IUserPrivacyService) *gin.IRoutes {
+	group.GET("/:id/data-export", middlewares.RequireScopes("users:read"), handleExportUserData(service))
+	group.POST("/:id/erase", middlewares.RequireScopes("users:delete"), handleEraseUser(service))
+
+	return &group
+}
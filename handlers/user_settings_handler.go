@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maramal/user-service/middlewares"
+	"github.com/maramal/user-service/services"
+	"github.com/maramal/user-service/utils"
+)
+
+// @Summary Obtiene las preferencias del usuario de la sesión
+// @Description Cada preferencia toma el valor elegido por el usuario, el predeterminado para su tipo de usuario o el definido en el servidor, en ese orden.
+// @ID 		get-my-settings
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} services.UserSettingsResponse
+// @Router 	/me/settings [get]
+func handleGetMySettings(userService services.IUserService, settingsService services.IUserSettingsService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userId, ok := currentUserID(ctx, userService)
+		if !ok {
+			return
+		}
+
+		settings, err := settingsService.GetUserSettings(userId)
+		if err != nil {
+			ctx.JSON(settingsErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(settings))
+	}
+}
+
+// @Summary Modifica las preferencias del usuario de la sesión
+// @Description Sigue JSON Merge Patch: sólo cambian las preferencias enviadas y null vuelve al valor predeterminado. Las preferencias disponibles se listan en GET /admin/settings.
+// @ID 		patch-my-settings
+// @Accept 	json
+// @Accept 	application/merge-patch+json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	patch body object true "Preferencias a modificar, por ejemplo {\"locale\":\"es-UY\",\"theme\":null}"
+// @Success 200 {object} services.UserSettingsResponse
+// @Failure 400 {object} gin.H
+// @Router 	/me/settings [patch]
+func handlePatchMySettings(userService services.IUserService, settingsService services.IUserSettingsService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var patch map[string]interface{}
+		if err := ctx.ShouldBindJSON(&patch); err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(err))
+			return
+		}
+
+		userId, ok := currentUserID(ctx, userService)
+		if !ok {
+			return
+		}
+
+		settings, err := settingsService.PatchUserSettings(userId, patch)
+		if err != nil {
+			ctx.JSON(settingsErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(settings))
+	}
+}
+
+// @Summary Obtiene las preferencias disponibles y su configuración
+// @Description Devuelve las preferencias definidas en el servidor (tipo, valor predeterminado y valores permitidos), los valores predeterminados por tipo de usuario y las preferencias que se incluyen en los tokens.
+// @ID 		get-settings-config
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} services.SettingsConfigResponse
+// @Router 	/admin/settings [get]
+func handleGetSettingsConfig(settingsService services.IUserSettingsService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		config, err := settingsService.GetSettingsConfig()
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(config))
+	}
+}
+
+// @Summary Reemplaza la configuración de las preferencias
+// @Description Define los valores predeterminados por tipo de usuario y las preferencias que se incluyen en el payload de los tokens. Los tokens ya emitidos no cambian.
+// @ID 		update-settings-config
+// @Accept 	json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	UpdateSettingsConfigRequest body services.UpdateSettingsConfigRequest true "Configuración de las preferencias"
+// @Success 200 {object} services.SettingsConfigResponse
+// @Failure 400 {object} gin.H
+// @Router 	/admin/settings [put]
+func handleUpdateSettingsConfig(settingsService services.IUserSettingsService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req services.UpdateSettingsConfigRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(err))
+			return
+		}
+
+		payload, ok := middlewares.GetAuthorizationPayload(ctx)
+		if !ok {
+			ctx.JSON(http.StatusUnauthorized, utils.ErrorResponse(errors.New("sesion no iniciada")))
+			return
+		}
+
+		config, err := settingsService.UpdateSettingsConfig(req, payload.Email)
+		if err != nil {
+			ctx.JSON(settingsErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(config))
+	}
+}
+
+func settingsErrorStatus(err error) int {
+	if errors.Is(err, services.ErrInvalidSetting) {
+		return http.StatusBadRequest
+	}
+
+	return userErrorStatus(err)
+}
+
+/** Crea un nuevo grupo de endpoints de configuración de las preferencias de los usuarios
+ *
+ * @param group *gin.RouterGroup "El grupo de endpoints padre"
+ * @param settingsService services.IUserSettingsService "El servicio de preferencias"
+ * @return *gin.RouterGroup "El grupo de endpoints creado"
+ */
+func newSettingsConfigHandler(group gin.IRoutes, settingsService services.IUserSettingsService) *gin.IRoutes {
+	group.GET("", middlewares.RequireScopes("users:read"), handleGetSettingsConfig(settingsService))
+	group.PUT("", middlewares.RequireScopes("users:update"), handleUpdateSettingsConfig(settingsService))
+
+	return &group
+}
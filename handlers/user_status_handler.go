@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maramal/user-service/middlewares"
+	"github.com/maramal/user-service/models"
+	"github.com/maramal/user-service/services"
+	"github.com/maramal/user-service/utils"
+)
+
+// @Summary Activa un usuario
+// @Description Permitido desde invited, pending_verification, suspended, locked y deactivated
+// @ID 		activate-user
+// @Accept 	json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	id path string true "ID del usuario"
+// @Param 	ChangeUserStatusRequest body services.ChangeUserStatusRequest true "Motivo"
+// @Success 200 {object} services.UpdateUserResponse
+// @Failure 400 {object} gin.H
+// @Failure 404 {object} gin.H
+// @Failure 409 {object} gin.H
+// @Router 	/admin/users/{id}/activate [post]
+func handleActivateUser(service services.IUserService) gin.HandlerFunc {
+	return handleChangeUserStatus(service, models.UserStatusActive)
+}
+
+// @Summary Suspende un usuario
+// @Description Permitido desde active y locked. Bloquea las sesiones del usuario
+// @ID 		suspend-user
+// @Accept 	json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	id path string true "ID del usuario"
+// @Param 	ChangeUserStatusRequest body services.ChangeUserStatusRequest true "Motivo"
+// @Success 200 {object} services.UpdateUserResponse
+// @Failure 400 {object} gin.H
+// @Failure 404 {object} gin.H
+// @Failure 409 {object} gin.H
+// @Router 	/admin/users/{id}/suspend [post]
+func handleSuspendUser(service services.IUserService) gin.HandlerFunc {
+	return handleChangeUserStatus(service, models.UserStatusSuspended)
+}
+
+// @Summary Bloquea un usuario
+// @Description Permitido desde active. Bloquea las sesiones del usuario
+// @ID 		lock-user
+// @Accept 	json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	id path string true "ID del usuario"
+// @Param 	ChangeUserStatusRequest body services.ChangeUserStatusRequest true "Motivo"
+// @Success 200 {object} services.UpdateUserResponse
+// @Failure 400 {object} gin.H
+// @Failure 404 {object} gin.H
+// @Failure 409 {object} gin.H
+// @Router 	/admin/users/{id}/lock [post]
+func handleLockUser(service services.IUserService) gin.HandlerFunc {
+	return handleChangeUserStatus(service, models.UserStatusLocked)
+}
+
+// @Summary Desactiva un usuario
+// @Description Permitido desde cualquier estado salvo deleted. Bloquea las sesiones del usuario
+// @ID 		deactivate-user
+// @Accept 	json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	id path string true "ID del usuario"
+// @Param 	ChangeUserStatusRequest body services.ChangeUserStatusRequest true "Motivo"
+// @Success 200 {object} services.UpdateUserResponse
+// @Failure 400 {object} gin.H
+// @Failure 404 {object} gin.H
+// @Failure 409 {object} gin.H
+// @Router 	/admin/users/{id}/deactivate [post]
+func handleDeactivateUser(service services.IUserService) gin.HandlerFunc {
+	return handleChangeUserStatus(service, models.UserStatusDeactivated)
+}
+
+// @Summary Pasa un usuario invitado a pendiente de verificación
+// @Description Permitido desde invited
+// @ID 		require-user-verification
+// @Accept 	json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	id path string true "ID del usuario"
+// @Param 	ChangeUserStatusRequest body services.ChangeUserStatusRequest true "Motivo"
+// @Success 200 {object} services.UpdateUserResponse
+// @Failure 400 {object} gin.H
+// @Failure 404 {object} gin.H
+// @Failure 409 {object} gin.H
+// @Router 	/admin/users/{id}/require-verification [post]
+func handleRequireUserVerification(service services.IUserService) gin.HandlerFunc {
+	return handleChangeUserStatus(service, models.UserStatusPendingVerification)
+}
+
+// Cambia el estado del usuario al indicado, con el motivo recibido en el cuerpo
+func handleChangeUserStatus(service services.IUserService, status string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req services.ChangeUserStatusRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(err))
+			return
+		}
+
+		payload, ok := middlewares.GetAuthorizationPayload(ctx)
+		if !ok {
+			ctx.JSON(http.StatusUnauthorized, utils.ErrorResponse(errors.New("sesion no iniciada")))
+			return
+		}
+
+		user, err := service.ChangeStatus(ctx.Param("id"), status, req.Reason, payload.Email)
+		if err != nil {
+			ctx.JSON(userErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.Header("ETag", userETag(user.User))
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(user))
+	}
+}
+
+// @Summary Obtiene el historial de estados de un usuario
+// @ID 		get-user-status-history
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	id path string true "ID del usuario"
+// @Success 200 {object} services.GetStatusHistoryResponse
+// @Failure 404 {object} gin.H
+// @Router 	/admin/users/{id}/status-history [get]
+func handleGetUserStatusHistory(service services.IUserService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		history, err := service.GetStatusHistory(ctx.Param("id"))
+		if err != nil {
+			ctx.JSON(userErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(history))
+	}
+}
@@ -1,26 +1,47 @@
 package handlers
 
 import (
+	"encoding/json"
 	"errors"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/maramal/user-service/middlewares"
+	"github.com/maramal/user-service/models"
 	"github.com/maramal/user-service/services"
 	"github.com/maramal/user-service/utils"
 )
 
-// @Summary	Obtiene todos los usuarios
+// @Summary	Obtiene una página de usuarios
 // @ID 		get-users
 // @Produce json
 // @Security ApiKeyAuth
+// @Param 	limit 			query int 		false "Cantidad de usuarios por página (máximo 200)"
+// @Param 	cursor 			query string 	false "Cursor de la página (next_cursor o prev_cursor)"
+// @Param 	sort 			query string 	false "Campos de ordenamiento separados por coma, con - para orden descendente" default(-created_at)
+// @Param 	status 			query []string 	false "Estados" collectionFormat(multi)
+// @Param 	type 			query []string 	false "Tipos de usuario" collectionFormat(multi)
+// @Param 	created_from 	query string 	false "Fecha de creación mínima (RFC 3339)"
+// @Param 	created_to 		query string 	false "Fecha de creación máxima (RFC 3339)"
+// @Param 	email_domain 	query string 	false "Dominio del correo electrónico"
+// @Param 	custom 			query string 	false "Atributos personalizados declarados en el esquema, como custom[department]=ventas"
+// @Param 	include_total 	query bool 		false "Incluir el total de usuarios que cumplen los filtros"
 // @Success 200 {object} services.GetUsersResponse
-// @Failure 400 {object} services.GetUsersResponse
+// @Failure 400 {object} gin.H
 // @Router 	/admin/users [get]
 func handleGetUsers(service services.IUserService) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		users, err := service.GetUsers()
+		var req services.GetUsersRequest
+		if err := ctx.ShouldBindQuery(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(err))
+			return
+		}
+		req.Custom = ctx.QueryMap("custom")
+
+		users, err := service.GetUsers(req)
 		if err != nil {
-			ctx.JSON(http.StatusInternalServerError, utils.ErrorResponse(err))
+			ctx.JSON(userErrorStatus(err), utils.ErrorResponse(err))
 			return
 		}
 
@@ -45,11 +66,13 @@ func handleCreateUser(service services.IUserService) gin.HandlerFunc {
 			return
 		}
 
+		req.CreatedBy = sessionEmail(ctx)
 		userID, err := service.CreateUser(req)
 		if err != nil {
-			ctx.JSON(http.StatusInternalServerError, utils.ErrorResponse(err))
+			ctx.JSON(userErrorStatus(err), utils.ErrorResponse(err))
 			return
 		}
+		middlewares.SetAuditCreated(ctx, models.AuditTarget{Type: services.AuditTargetUsers, ID: userID.UserID})
 
 		ctx.JSON(http.StatusOK, utils.SuccessResponse(userID))
 	}
@@ -59,9 +82,11 @@ func handleCreateUser(service services.IUserService) gin.HandlerFunc {
 // @ID 		get-user
 // @Produce json
 // @Security ApiKeyAuth
-// @Param 	id path string true "ID del usuario"
-// @Success 200 {object} gin.H
-// @Failure 400 {object} gin.H
+// @Param 	id 				path 	string true 	"ID del usuario"
+// @Param 	If-None-Match 	header 	string false 	"ETag de la versión que ya tiene el cliente"
+// @Success 200 {object} services.GetUserResponse
+// @Success 304 "El usuario no cambió"
+// @Failure 404 {object} gin.H
 // @Router 	/admin/users/{id} [get]
 func handleGetUser(service services.IUserService) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
@@ -73,7 +98,14 @@ func handleGetUser(service services.IUserService) gin.HandlerFunc {
 
 		user, err := service.GetUser(id)
 		if err != nil {
-			ctx.JSON(http.StatusInternalServerError, utils.ErrorResponse(err))
+			ctx.JSON(userErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		etag := userETag(user.User)
+		ctx.Header("ETag", etag)
+		if etagMatches(ctx.GetHeader("If-None-Match"), etag) {
+			ctx.Status(http.StatusNotModified)
 			return
 		}
 
@@ -82,14 +114,20 @@ func handleGetUser(service services.IUserService) gin.HandlerFunc {
 }
 
 // @Summary Actualiza un usuario
+// @Description Los campos vacíos no se modifican. Para eliminar un campo o modificar sólo algunos usar PATCH. El correo electrónico se cambia con /admin/users/{id}/email-change.
 // @ID 		update-user
 // @Accept 	json
 // @Produce json
 // @Security ApiKeyAuth
 // @Param   id 					path string						true "ID del usuario"
 // @Param 	UpdateUserRequest 	body services.UpdateUserRequest true "Datos del usuario"
-// @Success 200 {object} gin.H
+// @Param 	If-Match 			header string 					false "ETag del usuario obtenido en GET"
+// @Success 200 {object} services.UpdateUserResponse
 // @Failure 400 {object} gin.H
+// @Failure 404 {object} gin.H
+// @Failure 409 {object} gin.H
+// @Failure 412 {object} gin.H
+// @Failure 428 {object} gin.H
 // @Router 	/admin/users/{id} [put]
 func handleUpdateUser(service services.IUserService) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
@@ -105,23 +143,98 @@ func handleUpdateUser(service services.IUserService) gin.HandlerFunc {
 			return
 		}
 
+		version, err := ifMatchVersion(ctx)
+		if err != nil {
+			ctx.JSON(http.StatusPreconditionFailed, utils.ErrorResponse(err))
+			return
+		}
+		req.IfVersion = version
+		req.ChangedBy = sessionEmail(ctx)
+
 		user, err := service.UpdateUser(id, req)
 		if err != nil {
-			ctx.JSON(http.StatusInternalServerError, utils.ErrorResponse(err))
+			ctx.JSON(userErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.Header("ETag", userETag(user.User))
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(user))
+	}
+}
+
+// @Summary Modifica parcialmente un usuario
+// @Description El cuerpo es un documento JSON Merge Patch (RFC 7396): sólo se modifican los campos presentes y null elimina el campo.
+// @Description Con el parámetro fields (máscara de campos) sólo se modifican los campos indicados; los que no estén en el cuerpo se eliminan.
+// @Description El correo electrónico y el estado no se pueden cambiar por esta vía.
+// @ID 		patch-user
+// @Accept 	json
+// @Accept 	application/merge-patch+json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param   id 		path 	string 	true 	"ID del usuario"
+// @Param 	fields 	query 	string 	false 	"Campos a modificar separados por coma"
+// @Param 	patch 	body 	object 	true 	"Campos a modificar, por ejemplo {\"first_name\":\"Juan\",\"last_name\":\"Pérez\"}"
+// @Param 	If-Match header string 	false 	"ETag del usuario obtenido en GET"
+// @Success 200 {object} services.UpdateUserResponse
+// @Failure 400 {object} gin.H
+// @Failure 404 {object} gin.H
+// @Failure 409 {object} gin.H
+// @Failure 412 {object} gin.H
+// @Failure 428 {object} gin.H
+// @Router 	/admin/users/{id} [patch]
+func handlePatchUser(service services.IUserService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		req, ok := bindPatchUserRequest(ctx)
+		if !ok {
+			return
+		}
+
+		user, err := service.PatchUser(ctx.Param("id"), req)
+		if err != nil {
+			ctx.JSON(userErrorStatus(err), utils.ErrorResponse(err))
 			return
 		}
 
+		ctx.Header("ETag", userETag(user.User))
 		ctx.JSON(http.StatusOK, utils.SuccessResponse(user))
 	}
 }
 
+// Lee el cuerpo (JSON Merge Patch), la máscara de campos y la cabecera If-Match de una modificación
+// parcial. Si no son válidos responde con el error y devuelve false
+func bindPatchUserRequest(ctx *gin.Context) (req services.PatchUserRequest, ok bool) {
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&req.Patch); err != nil || req.Patch == nil {
+		err := errors.New("el cuerpo debe ser un objeto JSON")
+		ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(err))
+		return
+	}
+
+	for _, field := range strings.Split(ctx.Query("fields"), ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			req.Fields = append(req.Fields, field)
+		}
+	}
+
+	version, err := ifMatchVersion(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusPreconditionFailed, utils.ErrorResponse(err))
+		return
+	}
+	req.IfVersion = version
+	req.ChangedBy = sessionEmail(ctx)
+
+	return req, true
+}
+
 // @Summary Elimina un usuario
 // @ID 		delete-user
 // @Produce json
 // @Security ApiKeyAuth
 // @Param 	id path string true "ID del usuario"
+// @Param 	reason query string false "Motivo de la eliminación, que queda en el historial de estados"
 // @Success 200 {object} gin.H
 // @Failure 400 {object} gin.H
+// @Failure 409 {object} gin.H
 // @Router 	/admin/users/{id} [delete]
 func handleDeleteUser(service services.IUserService) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
@@ -131,9 +244,23 @@ func handleDeleteUser(service services.IUserService) gin.HandlerFunc {
 			return
 		}
 
-		err := service.DeleteUser(id)
+		payload, ok := middlewares.GetAuthorizationPayload(ctx)
+		if !ok {
+			ctx.JSON(http.StatusUnauthorized, utils.ErrorResponse(errors.New("sesion no iniciada")))
+			return
+		}
+
+		version, err := ifMatchVersion(ctx)
 		if err != nil {
-			ctx.JSON(http.StatusInternalServerError, utils.ErrorResponse(err))
+			ctx.JSON(http.StatusPreconditionFailed, utils.ErrorResponse(err))
+			return
+		}
+
+		reason := ctx.DefaultQuery("reason", "usuario eliminado")
+
+		err = service.DeleteUser(id, payload.Email, reason, version)
+		if err != nil {
+			ctx.JSON(userErrorStatus(err), utils.ErrorResponse(err))
 			return
 		}
 
@@ -141,6 +268,97 @@ func handleDeleteUser(service services.IUserService) gin.HandlerFunc {
 	}
 }
 
+// @Summary Restaura un usuario eliminado
+// @Description El usuario vuelve al estado que tenía antes de ser eliminado
+// @ID 		restore-user
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	id path string true "ID del usuario"
+// @Success 200 {object} gin.H
+// @Failure 404 {object} gin.H
+// @Failure 410 {object} gin.H
+// @Router 	/admin/users/{id}/restore [post]
+func handleRestoreUser(service services.IUserService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.Param("id")
+		if id == "" {
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(errors.New("el id es requerido")))
+			return
+		}
+
+		payload, ok := middlewares.GetAuthorizationPayload(ctx)
+		if !ok {
+			ctx.JSON(http.StatusUnauthorized, utils.ErrorResponse(errors.New("sesion no iniciada")))
+			return
+		}
+
+		err := service.RestoreUser(id, payload.Email)
+		if err != nil {
+			ctx.JSON(userErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(nil))
+	}
+}
+
+// @Summary	Obtiene los correos electrónicos compartidos por varios usuarios
+// @Description Los detecta la migración que normaliza los correos al iniciar el servicio. Sólo el usuario marcado como owner tiene la dirección normalizada; los demás deben cambiar su correo o ser borrados.
+// @ID 		get-email-collisions
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} services.GetEmailCollisionsResponse
+// @Router 	/admin/users/email-collisions [get]
+func handleGetEmailCollisions(service services.IUserService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		collisions, err := service.GetEmailCollisions()
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(collisions))
+	}
+}
+
+// @Summary	Obtiene una página de usuarios eliminados
+// @ID 		get-deleted-users
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	limit 			query int 		false "Cantidad de usuarios por página (máximo 200)"
+// @Param 	cursor 			query string 	false "Cursor de la página (next_cursor o prev_cursor)"
+// @Param 	sort 			query string 	false "Campos de ordenamiento separados por coma, con - para orden descendente" default(-created_at)
+// @Param 	status 			query []string 	false "Estados" collectionFormat(multi)
+// @Param 	type 			query []string 	false "Tipos de usuario" collectionFormat(multi)
+// @Param 	created_from 	query string 	false "Fecha de creación mínima (RFC 3339)"
+// @Param 	created_to 		query string 	false "Fecha de creación máxima (RFC 3339)"
+// @Param 	email_domain 	query string 	false "Dominio del correo electrónico"
+// @Param 	custom 			query string 	false "Atributos personalizados declarados en el esquema, como custom[department]=ventas"
+// @Param 	include_total 	query bool 		false "Incluir el total de usuarios que cumplen los filtros"
+// @Success 200 {object} services.GetUsersResponse
+// @Failure 400 {object} gin.H
+// @Router 	/admin/users/deleted [get]
+func handleGetDeletedUsers(service services.IUserService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req services.GetUsersRequest
+		if err := ctx.ShouldBindQuery(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(err))
+			return
+		}
+		req.Custom = ctx.QueryMap("custom")
+
+		req.Deleted = true
+
+		users, err := service.GetUsers(req)
+		if err != nil {
+			ctx.JSON(userErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(users))
+	}
+}
+
 // @Summary Cambia la contraseña de un usuario
 // @ID 		change-password
 // @Accept 	json
@@ -165,9 +383,10 @@ func handleChangePassword(service services.IUserService) gin.HandlerFunc {
 			return
 		}
 
+		req.ChangedBy = sessionEmail(ctx)
 		err := service.ChangePassword(id, req)
 		if err != nil {
-			ctx.JSON(http.StatusInternalServerError, utils.ErrorResponse(err))
+			ctx.JSON(userErrorStatus(err), utils.ErrorResponse(err))
 			return
 		}
 
@@ -191,9 +410,9 @@ func handleSetSuperadmin(service services.IUserService) gin.HandlerFunc {
 			return
 		}
 
-		err := service.SetSuperadmin(id, true)
+		err := service.SetSuperadmin(id, true, sessionEmail(ctx))
 		if err != nil {
-			ctx.JSON(http.StatusInternalServerError, utils.ErrorResponse(err))
+			ctx.JSON(userErrorStatus(err), utils.ErrorResponse(err))
 			return
 		}
 
@@ -217,9 +436,9 @@ func handleUnsetSuperadmin(service services.IUserService) gin.HandlerFunc {
 			return
 		}
 
-		err := service.SetSuperadmin(id, false)
+		err := service.SetSuperadmin(id, false, sessionEmail(ctx))
 		if err != nil {
-			ctx.JSON(http.StatusInternalServerError, utils.ErrorResponse(err))
+			ctx.JSON(userErrorStatus(err), utils.ErrorResponse(err))
 			return
 		}
 
@@ -245,7 +464,7 @@ func handleGetUserByEmail(service services.IUserService) gin.HandlerFunc {
 
 		user, err := service.GetUserByEmail(email)
 		if err != nil {
-			ctx.JSON(http.StatusInternalServerError, utils.ErrorResponse(err))
+			ctx.JSON(userErrorStatus(err), utils.ErrorResponse(err))
 			return
 		}
 
@@ -253,25 +472,93 @@ func handleGetUserByEmail(service services.IUserService) gin.HandlerFunc {
 	}
 }
 
+// @Summary Busca usuarios por nombre, apellido o correo electrónico
+// @Description La búsqueda no distingue mayúsculas ni acentos y cada palabra coincide por prefijo
+// @ID 		search-users
+// @Produce json
+// @Security ApiKeyAuth
+// @Param 	q 		query string 	true  "Texto a buscar"
+// @Param 	limit 	query int 		false "Cantidad máxima de resultados (máximo 100)"
+// @Success 200 {object} services.SearchUsersResponse
+// @Failure 400 {object} gin.H
+// @Router 	/admin/users/search [get]
+func handleSearchUsers(service services.IUserService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req services.SearchUsersRequest
+		if err := ctx.ShouldBindQuery(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, utils.ErrorResponse(err))
+			return
+		}
+
+		users, err := service.SearchUsers(req)
+		if err != nil {
+			ctx.JSON(userErrorStatus(err), utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, utils.SuccessResponse(users))
+	}
+}
+
+// Obtiene el código de estado HTTP correspondiente a un error del servicio de usuarios
+func userErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrUserNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrEmailTaken), errors.Is(err, services.ErrInvalidStatusTransition):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrUserErased):
+		return http.StatusGone
+	case errors.Is(err, services.ErrVersionMismatch):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, services.ErrInvalidUserData):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrInvalidCursor), errors.Is(err, services.ErrInvalidSort), errors.Is(err, services.ErrInvalidSearchQuery):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 /** Crea un nuevo grupo de endpoints
  *
  * @param group *gin.RouterGroup "El grupo de endpoints padre"
  * @param service services.IUserService "El servicio de usuarios"
+ * @param requireIfMatch bool "Si las modificaciones y eliminaciones exigen la cabecera If-Match"
  * @return *gin.RouterGroup "El grupo de endpoints creado"
  */
-func newUserHandler(group gin.IRoutes, userService services.IUserService) *gin.IRoutes {
-	group.GET("/", handleGetUsers(userService))
-	group.POST("/", handleCreateUser(userService))
-
-	group.GET("/:id", handleGetUser(userService))
-	group.PUT("/:id", handleUpdateUser(userService))
-	group.DELETE("/:id", handleDeleteUser(userService))
-
-	group.POST("/:id/password", handleChangePassword(userService))
-	group.POST("/:id/set-superadmin", handleSetSuperadmin(userService))
-	group.POST("/:id/unset-superadmin", handleUnsetSuperadmin(userService))
-
-	group.GET("/email/:email", handleGetUserByEmail(userService))
+func newUserHandler(group gin.IRoutes, userService services.IUserService, requireIfMatch bool) *gin.IRoutes {
+	ifMatch := middlewares.RequireIfMatch(requireIfMatch)
+
+	group.GET("/", middlewares.RequireScopes("users:read"), handleGetUsers(userService))
+	group.POST("/", middlewares.RequireScopes("users:create"), handleCreateUser(userService))
+
+	group.GET("/:id", middlewares.RequireScopes("users:read"), handleGetUser(userService))
+	group.PUT("/:id", middlewares.RequireScopes("users:update"), ifMatch, handleUpdateUser(userService))
+	group.PATCH("/:id", middlewares.RequireScopes("users:update"), ifMatch, handlePatchUser(userService))
+	group.DELETE("/:id", middlewares.RequireScopes("users:delete"), ifMatch, handleDeleteUser(userService))
+
+	group.POST("/:id/password", middlewares.RequireScopes("users:password"), handleChangePassword(userService))
+	group.POST("/:id/set-superadmin", middlewares.RequireScopes("users:update"), handleSetSuperadmin(userService))
+	group.POST("/:id/unset-superadmin", middlewares.RequireScopes("users:update"), handleUnsetSuperadmin(userService))
+
+	group.POST("/:id/activate", middlewares.RequireScopes("users:update"), handleActivateUser(userService))
+	group.POST("/:id/suspend", middlewares.RequireScopes("users:update"), handleSuspendUser(userService))
+	group.POST("/:id/lock", middlewares.RequireScopes("users:update"), handleLockUser(userService))
+	group.POST("/:id/deactivate", middlewares.RequireScopes("users:update"), handleDeactivateUser(userService))
+	group.POST("/:id/require-verification", middlewares.RequireScopes("users:update"), handleRequireUserVerification(userService))
+	group.GET("/:id/status-history", middlewares.RequireScopes("users:read"), handleGetUserStatusHistory(userService))
+
+	group.GET("/:id/history", middlewares.RequireScopes("users:read"), handleGetUserHistory(userService))
+	group.GET("/:id/history/:version", middlewares.RequireScopes("users:read"), handleGetUserVersion(userService))
+	group.POST("/:id/history/:version/restore", middlewares.RequireScopes("users:update"), ifMatch, handleRestoreUserVersion(userService))
+
+	group.GET("/email/:email", middlewares.RequireScopes("users:read"), handleGetUserByEmail(userService))
+	group.GET("/search", middlewares.RequireScopes("users:read"), handleSearchUsers(userService))
+
+	group.GET("/email-collisions", middlewares.RequireScopes("users:read"), handleGetEmailCollisions(userService))
+	group.GET("/deleted", middlewares.RequireScopes("users:read"), handleGetDeletedUsers(userService))
+	group.POST("/:id/restore", middlewares.RequireScopes("users:delete"), handleRestoreUser(userService))
 
 	return &group
 }
@@ -0,0 +1,22 @@
+package mailer
+
+import (
+	"log"
+)
+
+// LogMailer escribe los correos en el log en lugar de enviarlos. Sirve para desarrollo
+type LogMailer struct{}
+
+func NewLogMailer() *LogMailer {
+	return &LogMailer{}
+}
+
+/** Escribe un correo en el log
+ *
+ * @param message Message "El correo"
+ * @return error "Siempre nil"
+ */
+func (mailer *LogMailer) Send(message Message) error {
+	log.Printf("Correo para %s: %s\n%s", message.To, message.Subject, message.Body)
+	return nil
+}
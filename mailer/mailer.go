@@ -0,0 +1,43 @@
+package mailer
+
+import (
+	"fmt"
+
+	"github.com/maramal/user-service/utils"
+)
+
+// Message es un correo electrónico de texto plano
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// IMailer envía correos electrónicos
+type IMailer interface {
+	// Envía un correo. Devuelve error si no se pudo entregar al servidor de correo
+	Send(message Message) error
+}
+
+/** Crea el servicio de correo indicado en la configuración (MAIL_BACKEND)
+ *
+ * @param config utils.Config "Configuración de la aplicación"
+ * @return IMailer "El servicio de correo"
+ * @return error "Error si la configuración no es válida"
+ */
+func NewMailer(config utils.Config) (IMailer, error) {
+	switch config.MailBackend {
+	case "", "log":
+		return NewLogMailer(), nil
+	case "smtp":
+		return NewSMTPMailer(SMTPConfig{
+			Host:     config.SMTPHost,
+			Port:     config.SMTPPort,
+			Username: config.SMTPUsername,
+			Password: config.SMTPPassword,
+			From:     config.MailFrom,
+		})
+	default:
+		return nil, fmt.Errorf("el servicio de correo \"%s\" no existe", config.MailBackend)
+	}
+}
@@ -0,0 +1,97 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type SMTPConfig struct {
+	Host string
+	// Puerto del servidor. Por defecto 587
+	Port     int
+	Username string
+	Password string
+	// Remitente de los correos, por ejemplo "Usuarios <no-reply@example.com>"
+	From string
+}
+
+// SMTPMailer envía los correos a un servidor SMTP. Usa STARTTLS si el servidor lo ofrece
+type SMTPMailer struct {
+	config SMTPConfig
+	from   *mail.Address
+}
+
+func NewSMTPMailer(config SMTPConfig) (*SMTPMailer, error) {
+	if config.Host == "" {
+		return nil, errors.New("SMTP_HOST es requerido")
+	}
+	if config.Port == 0 {
+		config.Port = 587
+	}
+
+	from, err := mail.ParseAddress(config.From)
+	if err != nil {
+		return nil, fmt.Errorf("MAIL_FROM no es válido: %s", err)
+	}
+
+	return &SMTPMailer{config: config, from: from}, nil
+}
+
+/** Envía un correo
+ *
+ * @param message Message "El correo"
+ * @return error "Error de conexión o del servidor"
+ */
+func (mailer *SMTPMailer) Send(message Message) error {
+	to, err := mail.ParseAddress(message.To)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if mailer.config.Username != "" {
+		auth = smtp.PlainAuth("", mailer.config.Username, mailer.config.Password, mailer.config.Host)
+	}
+
+	addr := net.JoinHostPort(mailer.config.Host, strconv.Itoa(mailer.config.Port))
+	return smtp.SendMail(addr, auth, mailer.from.Address, []string{to.Address}, mailer.compose(to, message))
+}
+
+// Arma el mensaje con los encabezados. El asunto se codifica para admitir caracteres no ASCII
+func (mailer *SMTPMailer) compose(to *mail.Address, message Message) []byte {
+	id := make([]byte, 16)
+	rand.Read(id)
+
+	domain := mailer.from.Address[strings.LastIndex(mailer.from.Address, "@")+1:]
+
+	var buffer bytes.Buffer
+	headers := [][2]string{
+		{"From", mailer.from.String()},
+		{"To", to.String()},
+		{"Subject", mime.QEncoding.Encode("utf-8", message.Subject)},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"Message-ID", fmt.Sprintf("<%s@%s>", hex.EncodeToString(id), domain)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "text/plain; charset=utf-8"},
+		{"Content-Transfer-Encoding", "8bit"},
+	}
+	for _, header := range headers {
+		fmt.Fprintf(&buffer, "%s: %s\r\n", header[0], header[1])
+	}
+	buffer.WriteString("\r\n")
+
+	body := strings.ReplaceAll(message.Body, "\r\n", "\n")
+	buffer.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	return buffer.Bytes()
+}
@@ -56,6 +56,8 @@ func main() {
 		Handler: server.Router,
 	}
 
+	go server.StartJobs(ctx)
+
 	go func() {
 		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Error al iniciar el servidor: %s", err)
@@ -0,0 +1,126 @@
+package middlewares
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maramal/user-service/models"
+	"github.com/maramal/user-service/services"
+)
+
+const (
+	auditTargetKey  = "audit_target"
+	auditCreatedKey = "audit_created"
+)
+
+// Métodos que no modifican datos y por eso no se registran, salvo las rutas de auditedReads
+var unauditedMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+}
+
+// Lecturas que se registran porque descargan datos personales de muchos usuarios o todos los de uno
+var auditedReads = map[string]bool{
+	http.MethodGet + " /api/admin/users/export":               true,
+	http.MethodGet + " /api/admin/users/exports/:id/download": true,
+	http.MethodGet + " /api/admin/users/:id/data-export":      true,
+	http.MethodGet + " /api/me/data-export":                   true,
+}
+
+// Crea un middleware que registra en audit_events cada solicitud que modifica datos o descarga datos personales,
+// con quién la hizo, sobre qué recurso, el resultado y, si el recurso es un usuario, los campos que cambiaron.
+// Se registran también las solicitudes rechazadas. Puede utilizarse antes de AuthMiddleware, para registrar
+// también las solicitudes sin una sesión válida, salvo en las rutas de /api/me, cuyo recurso es el usuario
+// de la sesión
+func Audit(auditService services.IAuditService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		action := ctx.Request.Method + " " + ctx.FullPath()
+		read := unauditedMethods[ctx.Request.Method]
+		if read && !auditedReads[action] {
+			ctx.Next()
+			return
+		}
+
+		event := models.AuditEvent{
+			Action:    action,
+			ClientIP:  ctx.ClientIP(),
+			UserAgent: ctx.Request.UserAgent(),
+			RequestID: GetRequestID(ctx),
+		}
+		if payload, ok := GetAuthorizationPayload(ctx); ok {
+			event.Actor, event.ActorType = payload.Email, payload.UserType
+		}
+
+		// Las lecturas no cambian al usuario: no se comparan sus campos
+		var before map[string]interface{}
+		var err error
+		event.Target = routeAuditTarget(ctx, event.Actor)
+		if !read {
+			if before, err = auditService.Snapshot(&event.Target); err != nil {
+				log.Printf("Error al obtener el usuario de la solicitud %s para la auditoría: %s", event.RequestID, err)
+			}
+		}
+
+		ctx.Next()
+
+		// Si el middleware está antes de AuthMiddleware, la sesión se conoce recién después de la solicitud
+		if payload, ok := GetAuthorizationPayload(ctx); ok && event.Actor == "" {
+			event.Actor, event.ActorType = payload.Email, payload.UserType
+		}
+
+		// El handler puede indicar el recurso si no está en la ruta
+		if target, ok := ctx.Get(auditTargetKey); ok {
+			event.Target = target.(models.AuditTarget)
+			before = nil
+			if ctx.GetBool(auditCreatedKey) {
+				before = map[string]interface{}{}
+			}
+		}
+
+		var after map[string]interface{}
+		if before != nil {
+			if after, err = auditService.Snapshot(&event.Target); err != nil {
+				log.Printf("Error al obtener el usuario de la solicitud %s para la auditoría: %s", event.RequestID, err)
+			}
+		}
+
+		event.Status = ctx.Writer.Status()
+		if err = auditService.Record(event, before, after); err != nil {
+			log.Printf("Error al registrar la solicitud %s en la auditoría: %s", event.RequestID, err)
+		}
+	}
+}
+
+// Indica al middleware Audit el recurso afectado cuando no está en la ruta, por ejemplo el usuario que
+// intenta ingresar. No se registran los cambios del recurso
+func SetAuditTarget(ctx *gin.Context, target models.AuditTarget) {
+	ctx.Set(auditTargetKey, target)
+}
+
+// Indica al middleware Audit el recurso creado por la solicitud. Se registran todos sus campos como cambios
+func SetAuditCreated(ctx *gin.Context, target models.AuditTarget) {
+	ctx.Set(auditTargetKey, target)
+	ctx.Set(auditCreatedKey, true)
+}
+
+/** Obtiene el recurso afectado a partir de la ruta: el primer segmento después de /api o /api/admin y el
+ * parámetro id. Las rutas de /api/me afectan al usuario de la sesión
+ *
+ * @param ctx *gin.Context "El contexto de la solicitud"
+ * @param actor string "El correo electrónico del usuario de la sesión"
+ * @return models.AuditTarget "El recurso"
+ */
+func routeAuditTarget(ctx *gin.Context, actor string) models.AuditTarget {
+	path := strings.TrimPrefix(ctx.FullPath(), "/api")
+	path = strings.TrimPrefix(path, "/admin")
+
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if segments[0] == "me" {
+		return models.AuditTarget{Type: services.AuditTargetUsers, Email: actor}
+	}
+
+	return models.AuditTarget{Type: segments[0], ID: ctx.Param("id")}
+}
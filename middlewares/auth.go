@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/maramal/user-service/services"
 	"github.com/maramal/user-service/token"
 	"github.com/maramal/user-service/utils"
 )
@@ -17,8 +19,15 @@ const (
 	authorizationPayloadKey = "authorization_payload"
 )
 
-// Crea un middleware de Gin para la autorización de usuarios
-func AuthMiddleware(tokenMaker token.IMaker) gin.HandlerFunc {
+// Únicas rutas permitidas a las sesiones de usuarios que deben cambiar su contraseña
+var passwordResetRoutes = map[string]bool{
+	http.MethodPost + " /api/me/password": true,
+}
+
+// Crea un middleware de Gin para la autorización de usuarios. Además de validar el token,
+// verifica que la sesión a la que pertenece no haya sido bloqueada, y si el usuario debe cambiar
+// su contraseña sólo le permite hacerlo
+func AuthMiddleware(tokenMaker token.IMaker, authService services.IAuthService) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		authorizationHeader := ctx.GetHeader(authorizationHeaderKey)
 		if len(authorizationHeader) == 0 {
@@ -48,7 +57,40 @@ func AuthMiddleware(tokenMaker token.IMaker) gin.HandlerFunc {
 			return
 		}
 
+		session, err := authService.GetSession(payload.SessionID)
+		if err != nil || session.IsBlocked || time.Now().After(session.ExpiresAt) {
+			err := errors.New("la sesión no es válida o fue bloqueada")
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, utils.ErrorResponse(err))
+			return
+		}
+
+		if session.UserExpiresAt != nil && !time.Now().Before(*session.UserExpiresAt) {
+			err := errors.New("la cuenta del usuario venció")
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, utils.ErrorResponse(err))
+			return
+		}
+
+		if session.PasswordResetRequired && !passwordResetRoutes[ctx.Request.Method+" "+ctx.FullPath()] {
+			err := errors.New("el usuario debe cambiar su contraseña en POST /api/me/password")
+			ctx.AbortWithStatusJSON(http.StatusForbidden, utils.ErrorResponse(err))
+			return
+		}
+
+		// Si el usuario cambió su correo electrónico, la sesión tiene el nuevo y el token todavía el anterior
+		payload.Email = session.Email
+
 		ctx.Set(authorizationPayloadKey, payload)
 		ctx.Next()
 	}
 }
+
+// Obtiene el payload del token guardado por AuthMiddleware en el contexto de la solicitud
+func GetAuthorizationPayload(ctx *gin.Context) (*token.Payload, bool) {
+	_payload, exists := ctx.Get(authorizationPayloadKey)
+	if !exists {
+		return nil, false
+	}
+
+	payload, ok := _payload.(*token.Payload)
+	return payload, ok
+}
@@ -0,0 +1,23 @@
+package middlewares
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maramal/user-service/utils"
+)
+
+// Crea un middleware que exige la cabecera If-Match, para evitar que una solicitud sobrescriba
+// los cambios de otra. Si required es falso, la cabecera es opcional
+func RequireIfMatch(required bool) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if required && ctx.GetHeader("If-Match") == "" {
+			err := errors.New("la cabecera If-Match es requerida")
+			ctx.AbortWithStatusJSON(http.StatusPreconditionRequired, utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.Next()
+	}
+}
@@ -0,0 +1,44 @@
+package middlewares
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	requestIDHeaderKey = "X-Request-ID"
+	requestIDKey       = "request_id"
+	// Largo máximo del id recibido en la cabecera. Si es más largo se genera uno nuevo
+	maxRequestIDLength = 128
+)
+
+// Crea un middleware que identifica cada solicitud. Usa el id recibido en la cabecera X-Request-ID, por
+// ejemplo de un balanceador de carga, o genera uno nuevo, y lo devuelve en la misma cabecera de la respuesta
+func RequestID() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		requestID := ctx.GetHeader(requestIDHeaderKey)
+		if requestID == "" || len(requestID) > maxRequestIDLength {
+			requestID = newRequestID()
+		}
+
+		ctx.Set(requestIDKey, requestID)
+		ctx.Header(requestIDHeaderKey, requestID)
+		ctx.Next()
+	}
+}
+
+// Obtiene el id de la solicitud guardado por RequestID en el contexto
+func GetRequestID(ctx *gin.Context) string {
+	return ctx.GetString(requestIDKey)
+}
+
+func newRequestID() string {
+	data := make([]byte, 16)
+	if _, err := rand.Read(data); err != nil {
+		return ""
+	}
+
+	return hex.EncodeToString(data)
+}
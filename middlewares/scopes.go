@@ -0,0 +1,34 @@
+package middlewares
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maramal/user-service/token"
+	"github.com/maramal/user-service/utils"
+)
+
+// Crea un middleware que permite el acceso sólo si el token incluye todos los alcances indicados.
+// Debe utilizarse después de AuthMiddleware
+func RequireScopes(scopes ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		_payload, exists := ctx.Get(authorizationPayloadKey)
+		if !exists {
+			err := errors.New("sesion no iniciada")
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, utils.ErrorResponse(err))
+			return
+		}
+
+		payload := _payload.(*token.Payload)
+		if !payload.HasScopes(scopes...) {
+			err := fmt.Errorf("el token no incluye los alcances requeridos: %s", strings.Join(scopes, ", "))
+			ctx.AbortWithStatusJSON(http.StatusForbidden, utils.ErrorResponse(err))
+			return
+		}
+
+		ctx.Next()
+	}
+}
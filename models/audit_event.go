@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Valor con el que se reemplazan los datos que no se guardan en los eventos
+const AuditRedacted = "[REDACTED]"
+
+// Acción hecha a través de la API. Los eventos no se eliminan ni se modifican, salvo al borrar los datos
+// personales de un usuario (ver services/user_privacy_service.go)
+type AuditEvent struct {
+	ID primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	// Método y ruta del endpoint, por ejemplo "PATCH /api/admin/users/:id"
+	Action string `bson:"action" json:"action"`
+	// Correo electrónico del usuario de la sesión. Vacío en las solicitudes sin sesión, como el login
+	Actor     string      `bson:"actor,omitempty" json:"actor,omitempty"`
+	ActorType string      `bson:"actor_type,omitempty" json:"actor_type,omitempty"`
+	Target    AuditTarget `bson:"target" json:"target"`
+	// Código de estado HTTP de la respuesta
+	Status    int    `bson:"status" json:"status"`
+	ClientIP  string `bson:"client_ip,omitempty" json:"client_ip,omitempty"`
+	UserAgent string `bson:"user_agent,omitempty" json:"user_agent,omitempty"`
+	RequestID string `bson:"request_id" json:"request_id"`
+	// Campos del usuario afectado que cambiaron. Los valores sensibles se reemplazan por AuditRedacted
+	Changes    []AuditChange `bson:"changes,omitempty" json:"changes,omitempty"`
+	OccurredAt time.Time     `bson:"occurred_at" json:"occurred_at"`
+}
+
+// Recurso afectado por una acción
+type AuditTarget struct {
+	// Recurso de la ruta, por ejemplo users, invitations o custom-attributes
+	Type  string `bson:"type" json:"type"`
+	ID    string `bson:"id,omitempty" json:"id,omitempty"`
+	Email string `bson:"email,omitempty" json:"email,omitempty"`
+}
+
+type AuditChange struct {
+	Field string      `bson:"field" json:"field"`
+	From  interface{} `bson:"from" json:"from"`
+	To    interface{} `bson:"to" json:"to"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type CustomAttributeInvalidUser struct {
+	UserID primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Email  string             `bson:"email" json:"email"`
+	Errors []string           `bson:"errors" json:"errors"`
+}
+
+// Resultado de validar los usuarios existentes con una versión del esquema
+type CustomAttributeReport struct {
+	Status       string                       `bson:"status" json:"status"`
+	CheckedUsers int                          `bson:"checked_users" json:"checked_users"`
+	InvalidCount int                          `bson:"invalid_count" json:"invalid_count"`
+	InvalidUsers []CustomAttributeInvalidUser `bson:"invalid_users" json:"invalid_users"`
+	Error        string                       `bson:"error,omitempty" json:"error,omitempty"`
+	FinishedAt   *time.Time                   `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+}
+
+type CustomAttributeSchema struct {
+	ID      primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	Version int                `bson:"version" json:"version"`
+	Schema  json.RawMessage    `bson:"schema" json:"schema" swaggertype:"object"`
+	// Atributo => nombre del claim en el token
+	Claims    map[string]string     `bson:"claims" json:"claims"`
+	Report    CustomAttributeReport `bson:"report" json:"report"`
+	CreatedBy string                `bson:"created_by" json:"created_by"`
+	CreatedAt time.Time             `bson:"created_at" json:"created_at"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Cambio de correo electrónico pendiente de confirmación. Los tokens se guardan como hash
+type EmailChange struct {
+	ID               primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	UserID           primitive.ObjectID `bson:"user_id" json:"user_id"`
+	OldEmail         string             `bson:"old_email" json:"old_email"`
+	NewEmail         string             `bson:"new_email" json:"new_email"`
+	ConfirmTokenHash string             `bson:"confirm_token_hash" json:"-"`
+	CancelTokenHash  string             `bson:"cancel_token_hash" json:"-"`
+	Status           string             `bson:"status" json:"status"`
+	RequestedBy      string             `bson:"requested_by" json:"requested_by"`
+	CreatedAt        time.Time          `bson:"created_at" json:"created_at"`
+	ExpiresAt        time.Time          `bson:"expires_at" json:"expires_at"`
+	FinishedAt       *time.Time         `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type EmailCollisionUser struct {
+	UserID primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Email  string             `bson:"email" json:"email"`
+	// Si es verdadero, el usuario tiene la dirección normalizada y puede seguir ingresando con ella
+	Owner     bool       `bson:"owner" json:"owner"`
+	CreatedAt time.Time  `bson:"created_at" json:"created_at"`
+	DeletedAt *time.Time `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
+}
+
+// Usuarios cuyos correos electrónicos son iguales al normalizarlos. La genera la migración de los correos
+// electrónicos y se elimina cuando se resuelve
+type EmailCollision struct {
+	ID         primitive.ObjectID   `bson:"_id,omitempty" json:"_id,omitempty"`
+	Email      string               `bson:"email" json:"email"`
+	Users      []EmailCollisionUser `bson:"users" json:"users"`
+	DetectedAt time.Time            `bson:"detected_at" json:"detected_at"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Invitación de un usuario creado en el estado invited. El token del enlace se guarda como hash
+type Invitation struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Email     string             `bson:"email" json:"email"`
+	TokenHash string             `bson:"token_hash" json:"-"`
+	Status    string             `bson:"status" json:"status"`
+	InvitedBy string             `bson:"invited_by" json:"invited_by"`
+	// Cantidad de veces que se reenvió. Cada reenvío invalida el enlace anterior
+	ResendCount int        `bson:"resend_count" json:"resend_count"`
+	CreatedAt   time.Time  `bson:"created_at" json:"created_at"`
+	SentAt      time.Time  `bson:"sent_at" json:"sent_at"`
+	ExpiresAt   time.Time  `bson:"expires_at" json:"expires_at"`
+	FinishedAt  *time.Time `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+	RevokedBy   string     `bson:"revoked_by,omitempty" json:"revoked_by,omitempty"`
+}
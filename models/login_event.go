@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Intento de ingreso de un usuario, exitoso o no
+type LoginEvent struct {
+	ID     primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	UserID primitive.ObjectID `bson:"user_id" json:"user_id"`
+	// Paso del ingreso: email o phone (la contraseña) o mfa (el código enviado por SMS)
+	Method string `bson:"method" json:"method"`
+	// Resultado del intento (ver services/login_events.go)
+	Result string `bson:"result" json:"result"`
+	// Motivo del rechazo, si el intento no fue exitoso
+	Reason    string      `bson:"reason,omitempty" json:"reason,omitempty"`
+	ClientIP  string      `bson:"client_ip" json:"client_ip"`
+	UserAgent string      `bson:"user_agent" json:"user_agent"`
+	Device    LoginDevice `bson:"device" json:"device"`
+	// Sesión creada por el ingreso exitoso
+	SessionID  primitive.ObjectID `bson:"session_id,omitempty" json:"session_id,omitempty"`
+	OccurredAt time.Time          `bson:"occurred_at" json:"occurred_at"`
+}
+
+// Dispositivo desde el que se intentó ingresar, obtenido del user agent
+type LoginDevice struct {
+	Browser string `bson:"browser,omitempty" json:"browser,omitempty"`
+	OS      string `bson:"os,omitempty" json:"os,omitempty"`
+	// desktop, mobile, tablet o bot
+	Type string `bson:"type,omitempty" json:"type,omitempty"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+)
+
+// Ruta de app_metadata que se incluye en los claims de los tokens
+type MetadataClaim struct {
+	// Ruta separada por puntos, por ejemplo billing.plan
+	Path string `bson:"path" json:"path" binding:"required"`
+	// Nombre del claim en el token
+	Claim string `bson:"claim" json:"claim" binding:"required"`
+}
+
+// Configuración de los metadatos de los usuarios definida por los administradores. Hay un solo documento
+type MetadataConfig struct {
+	ID        string          `bson:"_id" json:"-"`
+	Claims    []MetadataClaim `bson:"claims" json:"claims"`
+	UpdatedAt time.Time       `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+	UpdatedBy string          `bson:"updated_by,omitempty" json:"updated_by,omitempty"`
+}
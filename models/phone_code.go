@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Código de un solo uso enviado por SMS. El código y el token del desafío de ingreso se guardan como hash
+type PhoneCode struct {
+	ID     primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	UserID primitive.ObjectID `bson:"user_id" json:"user_id"`
+	// Teléfono al que se envió el código, en formato E.164
+	Phone string `bson:"phone" json:"phone"`
+	// verify para verificar un teléfono, login para el segundo factor del ingreso
+	Purpose       string     `bson:"purpose" json:"purpose"`
+	CodeHash      string     `bson:"code_hash" json:"-"`
+	ChallengeHash string     `bson:"challenge_hash,omitempty" json:"-"`
+	Attempts      int        `bson:"attempts" json:"attempts"`
+	CreatedAt     time.Time  `bson:"created_at" json:"created_at"`
+	ExpiresAt     time.Time  `bson:"expires_at" json:"expires_at"`
+	UsedAt        *time.Time `bson:"used_at,omitempty" json:"used_at,omitempty"`
+}
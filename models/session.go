@@ -7,12 +7,16 @@ import (
 )
 
 type Session struct {
-	ID           primitive.ObjectID `db:"id,omitempty"`
-	Email        string             `db:"email" json:"email"`
-	RefreshToken string             `db:"refresh_token" json:"refresh_token"`
-	UserAgent    string             `db:"user_agent" json:"user_agent"`
-	ClientIP     string             `db:"client_ip" json:"client_ip"`
-	IsBlocked    bool               `db:"is_blocked" json:"is_blocked"`
-	CreatedAt    time.Time          `db:"created_at" json:"created_at"`
-	ExpiresAt    time.Time          `db:"expires_at" json:"expires_at"`
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	Email        string             `bson:"email" json:"email"`
+	RefreshToken string             `bson:"refresh_token" json:"refresh_token"`
+	UserAgent    string             `bson:"user_agent" json:"user_agent"`
+	ClientIP     string             `bson:"client_ip" json:"client_ip"`
+	IsBlocked    bool               `bson:"is_blocked" json:"is_blocked"`
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+	ExpiresAt    time.Time          `bson:"expires_at" json:"expires_at"`
+	// Vencimiento de la cuenta del usuario, para rechazar los tokens sin consultar al usuario
+	UserExpiresAt *time.Time `bson:"user_expires_at,omitempty" json:"user_expires_at,omitempty"`
+	// El usuario debe cambiar su contraseña: la sesión sólo puede usarse para hacerlo
+	PasswordResetRequired bool `bson:"password_reset_required,omitempty" json:"password_reset_required,omitempty"`
 }
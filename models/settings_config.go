@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+)
+
+// Configuración de las preferencias de los usuarios definida por los administradores. Hay un solo documento
+type SettingsConfig struct {
+	ID string `bson:"_id" json:"-"`
+	// Tipo de usuario => preferencia => valor predeterminado, que reemplaza al definido en el servidor
+	TypeDefaults map[string]map[string]interface{} `bson:"type_defaults" json:"type_defaults"`
+	// Preferencias que se incluyen en el payload de los tokens
+	TokenKeys []string  `bson:"token_keys" json:"token_keys"`
+	UpdatedAt time.Time `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+	UpdatedBy string    `bson:"updated_by,omitempty" json:"updated_by,omitempty"`
+}
@@ -6,6 +6,18 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Estados del ciclo de vida de un usuario. Las transiciones permitidas están en services/user_status.go
+const (
+	UserStatusInvited             = "invited"
+	UserStatusPendingVerification = "pending_verification"
+	UserStatusActive              = "active"
+	UserStatusSuspended           = "suspended"
+	UserStatusLocked              = "locked"
+	UserStatusDeactivated         = "deactivated"
+	UserStatusExpired             = "expired"
+	UserStatusDeleted             = "deleted"
+)
+
 type User struct {
 	ID                primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
 	FirstName         string             `bson:"first_name" json:"first_name"`
@@ -14,8 +26,53 @@ type User struct {
 	Password          string             `bson:"password" json:"password,omitempty"`
 	Type              string             `bson:"type" json:"type"`
 	Status            string             `bson:"status" json:"status"`
+	StatusReason      string             `bson:"status_reason,omitempty" json:"status_reason,omitempty"`
+	StatusChangedAt   *time.Time         `bson:"status_changed_at,omitempty" json:"status_changed_at,omitempty"`
 	ProfileImage      string             `bson:"profile_image,omitempty" json:"profile_image,omitempty"`
 	PasswordChangedAt time.Time          `bson:"password_changed_at,omitempty" json:"password_changed_at,omitempty"`
 	CreatedAt         time.Time          `bson:"created_at" json:"created_at"`
 	UpdatedAt         time.Time          `bson:"updated_at" json:"updated_at"`
+	Version           int64              `bson:"version" json:"version"`
+	SearchTerms       []string           `bson:"search_terms,omitempty" json:"-"`
+	DeletedAt         *time.Time         `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
+	DeletedBy         string             `bson:"deleted_by,omitempty" json:"deleted_by,omitempty"`
+	// Nombre y apellido normalizados como los términos de búsqueda (ver utils.NormalizeSearchText), para
+	// ordenar los resultados de la búsqueda sin distinguir mayúsculas ni acentos
+	SortFirstName string `bson:"sort_first_name" json:"-"`
+	SortLastName  string `bson:"sort_last_name" json:"-"`
+	// Fecha en que se borraron los datos personales del usuario. El borrado no se puede deshacer
+	ErasedAt *time.Time `bson:"erased_at,omitempty" json:"erased_at,omitempty"`
+	// Atributos personalizados, validados con el esquema definido por los administradores
+	Custom map[string]interface{} `bson:"custom,omitempty" json:"custom,omitempty"`
+	// Si es verdadero, el usuario debe cambiar su contraseña antes de seguir usando la aplicación
+	PasswordResetRequired bool `bson:"password_reset_required,omitempty" json:"password_reset_required,omitempty"`
+	// Imagen de perfil subida al almacenamiento. Se sirve en la URL de ProfileImage
+	Avatar *UserAvatar `bson:"avatar,omitempty" json:"avatar,omitempty"`
+	// Teléfono verificado en formato E.164 (ver utils.NormalizePhone). Se puede usar para ingresar
+	Phone           string     `bson:"phone,omitempty" json:"phone,omitempty"`
+	PhoneVerifiedAt *time.Time `bson:"phone_verified_at,omitempty" json:"phone_verified_at,omitempty"`
+	// Si es verdadero, el ingreso requiere además un código enviado por SMS al teléfono
+	PhoneMFA bool `bson:"phone_mfa,omitempty" json:"phone_mfa,omitempty"`
+	// Preferencias elegidas por el usuario. Las demás toman el valor predeterminado (ver services/user_settings_service.go)
+	Settings map[string]interface{} `bson:"settings,omitempty" json:"settings,omitempty"`
+	// Metadatos libres que sólo modifican los administradores, por ejemplo permisos contratados o ids de facturación
+	AppMetadata map[string]interface{} `bson:"app_metadata,omitempty" json:"app_metadata,omitempty"`
+	// Metadatos libres que modifica el propio usuario, por ejemplo el estado de la interfaz
+	UserMetadata map[string]interface{} `bson:"user_metadata,omitempty" json:"user_metadata,omitempty"`
+	// Fecha en que vence la cuenta. Desde entonces el usuario no puede ingresar y pasa al estado expired
+	ExpiresAt *time.Time `bson:"expires_at,omitempty" json:"expires_at,omitempty"`
+	// Fecha en que se avisó al usuario que su cuenta está por vencer
+	ExpirationRemindedAt *time.Time `bson:"expiration_reminded_at,omitempty" json:"expiration_reminded_at,omitempty"`
+	// Fecha del último ingreso y cantidad de ingresos. Los intentos se registran en login_events
+	LastLoginAt *time.Time `bson:"last_login_at,omitempty" json:"last_login_at,omitempty"`
+	LoginCount  int64      `bson:"login_count,omitempty" json:"login_count,omitempty"`
+}
+
+type UserAvatar struct {
+	// Identifica la versión de la imagen. Cambia con cada imagen subida
+	ID          string `bson:"id" json:"id"`
+	ContentType string `bson:"content_type" json:"content_type"`
+	// Lados de las miniaturas cuadradas disponibles, en píxeles
+	Sizes     []int     `bson:"sizes" json:"sizes"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
 }
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type UserBulkOperationItem struct {
+	UserID primitive.ObjectID `bson:"user_id,omitempty" json:"user_id,omitempty"`
+	Email  string             `bson:"email,omitempty" json:"email,omitempty"`
+	Status string             `bson:"status" json:"status"`
+	Error  string             `bson:"error,omitempty" json:"error,omitempty"`
+}
+
+type UserBulkOperation struct {
+	ID         primitive.ObjectID      `bson:"_id,omitempty" json:"_id,omitempty"`
+	Action     string                  `bson:"action" json:"action"`
+	Reason     string                  `bson:"reason,omitempty" json:"reason,omitempty"`
+	Status     string                  `bson:"status" json:"status"`
+	Total      int                     `bson:"total" json:"total"`
+	Processed  int                     `bson:"processed" json:"processed"`
+	Succeeded  int                     `bson:"succeeded" json:"succeeded"`
+	Skipped    int                     `bson:"skipped" json:"skipped"`
+	Failed     int                     `bson:"failed" json:"failed"`
+	Items      []UserBulkOperationItem `bson:"items" json:"items"`
+	Error      string                  `bson:"error,omitempty" json:"error,omitempty"`
+	CreatedBy  string                  `bson:"created_by" json:"created_by"`
+	CreatedAt  time.Time               `bson:"created_at" json:"created_at"`
+	FinishedAt *time.Time              `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Registro de un borrado de datos personales. No guarda ningún dato personal del usuario borrado
+type UserErasure struct {
+	ID     primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	UserID primitive.ObjectID `bson:"user_id" json:"user_id"`
+	// Correo electrónico con el que se reemplazó el del usuario en todas las colecciones
+	Pseudonym string    `bson:"pseudonym" json:"pseudonym"`
+	Reason    string    `bson:"reason" json:"reason"`
+	ErasedBy  string    `bson:"erased_by" json:"erased_by"`
+	ErasedAt  time.Time `bson:"erased_at" json:"erased_at"`
+}
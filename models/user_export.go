@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type UserExport struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	Format     string             `bson:"format" json:"format"`
+	Columns    []string           `bson:"columns" json:"columns"`
+	Status     string             `bson:"status" json:"status"`
+	Rows       int                `bson:"rows" json:"rows"`
+	FilePath   string             `bson:"file_path" json:"-"`
+	Error      string             `bson:"error,omitempty" json:"error,omitempty"`
+	CreatedBy  string             `bson:"created_by" json:"created_by"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+	FinishedAt *time.Time         `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type UserImportRowError struct {
+	Row   int    `bson:"row" json:"row"`
+	Email string `bson:"email,omitempty" json:"email,omitempty"`
+	Error string `bson:"error" json:"error"`
+}
+
+type UserImport struct {
+	ID            primitive.ObjectID   `bson:"_id,omitempty" json:"_id,omitempty"`
+	FileName      string               `bson:"file_name" json:"file_name"`
+	Format        string               `bson:"format" json:"format"`
+	DryRun        bool                 `bson:"dry_run" json:"dry_run"`
+	OnDuplicate   string               `bson:"on_duplicate" json:"on_duplicate"`
+	Status        string               `bson:"status" json:"status"`
+	TotalRows     int                  `bson:"total_rows" json:"total_rows"`
+	ProcessedRows int                  `bson:"processed_rows" json:"processed_rows"`
+	Created       int                  `bson:"created" json:"created"`
+	Updated       int                  `bson:"updated" json:"updated"`
+	Skipped       int                  `bson:"skipped" json:"skipped"`
+	Failed        int                  `bson:"failed" json:"failed"`
+	RowErrors     []UserImportRowError `bson:"row_errors" json:"row_errors"`
+	Error         string               `bson:"error,omitempty" json:"error,omitempty"`
+	CreatedBy     string               `bson:"created_by" json:"created_by"`
+	CreatedAt     time.Time            `bson:"created_at" json:"created_at"`
+	FinishedAt    *time.Time           `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+}
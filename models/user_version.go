@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Copia de un usuario tal como quedó después de un cambio
+type UserVersion struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Version   int64              `bson:"version" json:"version"`
+	Action    string             `bson:"action" json:"action"`
+	ChangedBy string             `bson:"changed_by,omitempty" json:"changed_by,omitempty"`
+	ChangedAt time.Time          `bson:"changed_at" json:"changed_at"`
+	// El usuario en esta versión, sin la contraseña
+	Snapshot User `bson:"snapshot" json:"snapshot"`
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/maramal/user-service/database"
 	"github.com/maramal/user-service/models"
 	"github.com/maramal/user-service/utils"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 var ctx = context.Background()
@@ -28,6 +29,12 @@ func main() {
 		log.Fatalf("Error al leer la configuración: %v", err)
 	}
 
+	// Normaliza el correo electrónico como al crear cualquier usuario
+	email, err := utils.NormalizeEmail(*adminEmail)
+	if err != nil {
+		log.Fatalf("Error en el correo electrónico del administrador: %v", err)
+	}
+
 	// Encripta el password
 	password, err := utils.HashPassword(*adminPassword)
 	if err != nil {
@@ -47,17 +54,24 @@ func main() {
 	admin := models.User{
 		FirstName:         *adminFirstName,
 		LastName:          *adminLastName,
-		Email:             *adminEmail,
+		Email:             email,
 		Password:          password,
 		Type:              "superadmin",
-		Status:            "active",
+		Status:            models.UserStatusActive,
 		CreatedAt:         time.Now(),
 		UpdatedAt:         time.Now(),
+		Version:           1,
 		PasswordChangedAt: time.Now(),
+		SearchTerms:       utils.UserSearchTerms(*adminFirstName, *adminLastName, email),
+		SortFirstName:     utils.NormalizeSearchText(*adminFirstName),
+		SortLastName:      utils.NormalizeSearchText(*adminLastName),
 	}
 
 	// Inserta el registro en la base de dats
 	_, err = collection.InsertOne(ctx, admin)
+	if mongo.IsDuplicateKeyError(err) {
+		log.Fatalf("Ya existe un usuario con el correo electrónico %s", email)
+	}
 	if err != nil {
 		log.Fatalf("Error al crear el superadministrador: %v", err)
 	}
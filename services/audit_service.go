@@ -0,0 +1,180 @@
+package services
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/maramal/user-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Tipo de los eventos cuyo recurso es un usuario, y del que se guardan los cambios
+const AuditTargetUsers = "users"
+
+type GetAuditEventsRequest struct {
+	// Cantidad de eventos por página (máximo 200)
+	Limit int `form:"limit"`
+	// Cursor obtenido de next_cursor de una respuesta anterior
+	Cursor     string    `form:"cursor"`
+	Actor      string    `form:"actor"`
+	Action     string    `form:"action"`
+	TargetType string    `form:"target_type"`
+	TargetID   string    `form:"target_id"`
+	RequestID  string    `form:"request_id"`
+	From       time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To         time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	// Si es verdadero, se listan sólo las solicitudes rechazadas (código de estado 400 o mayor)
+	Failed bool `form:"failed"`
+}
+
+type GetAuditEventsResponse struct {
+	Events     []models.AuditEvent `json:"events"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
+type IAuditService interface {
+	Snapshot(target *models.AuditTarget) (fields map[string]interface{}, err error)
+	Record(event models.AuditEvent, before, after map[string]interface{}) (err error)
+	GetEvents(req GetAuditEventsRequest) (response GetAuditEventsResponse, err error)
+}
+
+type AuditService struct {
+	db *mongo.Database
+}
+
+/** Obtiene los campos del usuario afectado por una acción, para comparar cómo estaba antes y después de
+ * ella. Completa el id y el correo electrónico del recurso con los del usuario
+ *
+ * @param target *models.AuditTarget "El recurso. Si no es un usuario no se obtiene nada"
+ * @return map[string]interface{} "Los campos del usuario, o nil si no se encontró"
+ * @return err error "El error de la operación"
+ */
+func (service *AuditService) Snapshot(target *models.AuditTarget) (fields map[string]interface{}, err error) {
+	if target.Type != AuditTargetUsers {
+		return
+	}
+
+	var filter bson.M
+	if id, idErr := primitive.ObjectIDFromHex(target.ID); idErr == nil {
+		filter = bson.M{"_id": id}
+	} else if target.Email != "" {
+		filter = bson.M{"email": target.Email}
+	} else {
+		return
+	}
+
+	var user models.User
+	opts := options.FindOne().SetProjection(bson.M{"search_terms": 0})
+	err = service.db.Collection("users").FindOne(ctx, filter, opts).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		err = nil
+		return
+	}
+	if err != nil {
+		return
+	}
+
+	target.ID, target.Email = user.ID.Hex(), user.Email
+	return userVersionFields(user)
+}
+
+/** Guarda un evento de auditoría. Si se indican los campos del usuario antes y después de la acción, el evento
+ * incluye los que cambiaron, sin los valores sensibles
+ *
+ * @param event models.AuditEvent "El evento"
+ * @param before map[string]interface{} "Los campos antes de la acción (ver Snapshot). Vacío si la acción creó al usuario"
+ * @param after map[string]interface{} "Los campos después de la acción"
+ * @return err error "El error de la operación"
+ */
+func (service *AuditService) Record(event models.AuditEvent, before, after map[string]interface{}) (err error) {
+	if before != nil && after != nil {
+		var changes []UserFieldChange
+		diffUserFields("", before, after, &changes)
+		sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+
+		// Si la acción borró los datos personales, los valores anteriores no se pueden guardar
+		_, erased := after["erased_at"]
+		for _, change := range changes {
+			if erased || sensitiveAuditField(change.Field) {
+				change.From, change.To = models.AuditRedacted, models.AuditRedacted
+			}
+			event.Changes = append(event.Changes, models.AuditChange{Field: change.Field, From: change.From, To: change.To})
+		}
+	}
+
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+
+	_, err = service.db.Collection("audit_events").InsertOne(ctx, event)
+	return
+}
+
+/** Obtiene una página de eventos de auditoría, del más reciente al más antiguo
+ *
+ * @param req GetAuditEventsRequest "Los filtros y la página"
+ * @return GetAuditEventsResponse "Los eventos y el cursor de la página siguiente"
+ * @return err error "El error de la operación"
+ */
+func (service *AuditService) GetEvents(req GetAuditEventsRequest) (response GetAuditEventsResponse, err error) {
+	collection := service.db.Collection("audit_events")
+
+	response.NextCursor, err = findEventsPage(collection, buildAuditFilter(req), req.Limit, req.Cursor, &response.Events)
+	return
+}
+
+// Crea el filtro de Mongo a partir de los filtros de la solicitud
+func buildAuditFilter(req GetAuditEventsRequest) bson.M {
+	filter := bson.M{}
+
+	if actor := strings.TrimSpace(req.Actor); actor != "" {
+		if normalized, err := normalizeEmail(actor); err == nil {
+			actor = normalized
+		}
+		filter["actor"] = actor
+	}
+	if req.Action != "" {
+		filter["action"] = req.Action
+	}
+	if req.TargetType != "" {
+		filter["target.type"] = req.TargetType
+	}
+	if req.TargetID != "" {
+		filter["target.id"] = req.TargetID
+	}
+	if req.RequestID != "" {
+		filter["request_id"] = req.RequestID
+	}
+	if req.Failed {
+		filter["status"] = bson.M{"$gte": 400}
+	}
+
+	occurredAt := bson.M{}
+	if !req.From.IsZero() {
+		occurredAt["$gte"] = req.From
+	}
+	if !req.To.IsZero() {
+		occurredAt["$lte"] = req.To
+	}
+	if len(occurredAt) > 0 {
+		filter["occurred_at"] = occurredAt
+	}
+
+	return filter
+}
+
+// Indica si un campo guarda un secreto, como la contraseña, cuyo valor no se registra en los eventos
+func sensitiveAuditField(field string) bool {
+	name := strings.ToLower(field[strings.LastIndex(field, ".")+1:])
+	return name == "password" || strings.Contains(name, "secret") || strings.Contains(name, "token")
+}
+
+func NewAuditService(db *mongo.Database) IAuditService {
+	return &AuditService{
+		db: db,
+	}
+}
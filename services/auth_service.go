@@ -1,26 +1,54 @@
 package services
 
 import (
+	"errors"
 	"time"
 
 	"github.com/maramal/user-service/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+var ErrSessionNotFound = errors.New("no se encontró la sesión")
+
 type CreateSessionParams struct {
-	Email        string    `json:"email"`
-	RefreshToken string    `json:"refresh_token"`
-	UserAgent    string    `json:"user_agent"`
-	ClientIp     string    `json:"client_ip"`
-	IsBlocked    bool      `json:"is_blocked"`
-	ExpiresAt    time.Time `json:"expires_at"`
+	ID           primitive.ObjectID `json:"id"`
+	Email        string             `json:"email"`
+	RefreshToken string             `json:"refresh_token"`
+	UserAgent    string             `json:"user_agent"`
+	ClientIp     string             `json:"client_ip"`
+	IsBlocked    bool               `json:"is_blocked"`
+	ExpiresAt    time.Time          `json:"expires_at"`
+	// Vencimiento de la cuenta del usuario, si tiene
+	UserExpiresAt *time.Time `json:"user_expires_at"`
+	// Si el usuario debe cambiar su contraseña antes de usar la sesión
+	PasswordResetRequired bool `json:"password_reset_required"`
+}
+
+// Sesión de un usuario, sin el token de refresco
+type UserSession struct {
+	ID        primitive.ObjectID `json:"_id"`
+	UserAgent string             `json:"user_agent"`
+	ClientIP  string             `json:"client_ip"`
+	CreatedAt time.Time          `json:"created_at"`
+	ExpiresAt time.Time          `json:"expires_at"`
+	// Indica si es la sesión con la que se hizo la consulta
+	Current bool `json:"current"`
+}
+
+type GetSessionsResponse struct {
+	Sessions []UserSession `json:"sessions"`
 }
 
 type IAuthService interface {
 	CreateSession(params CreateSessionParams) (models.Session, error)
 	GetSession(sessionId string) (models.Session, error)
+	GetUserSessions(email string, currentSessionId string) (GetSessionsResponse, error)
+	RevokeUserSession(email string, sessionId string) error
+	RecordLogin(params RecordLoginParams) error
+	GetLoginEvents(userId string, req GetLoginEventsRequest) (GetLoginEventsResponse, error)
 }
 
 type AuthService struct {
@@ -37,6 +65,7 @@ func (service *AuthService) CreateSession(params CreateSessionParams) (models.Se
 	var collection = service.db.Collection("sessions")
 
 	session := models.Session{
+		ID:           params.ID,
 		Email:        params.Email,
 		RefreshToken: params.RefreshToken,
 		UserAgent:    params.UserAgent,
@@ -44,6 +73,9 @@ func (service *AuthService) CreateSession(params CreateSessionParams) (models.Se
 		IsBlocked:    params.IsBlocked,
 		CreatedAt:    time.Now(),
 		ExpiresAt:    params.ExpiresAt,
+
+		UserExpiresAt:         params.UserExpiresAt,
+		PasswordResetRequired: params.PasswordResetRequired,
 	}
 
 	result, err := collection.InsertOne(ctx, &session)
@@ -66,8 +98,13 @@ func (service *AuthService) GetSession(sessionId string) (models.Session, error)
 	var collection = service.db.Collection("sessions")
 	var session models.Session
 
-	var filter = bson.M{"id": sessionId}
-	err := collection.FindOne(ctx, filter).Decode(&session)
+	id, err := primitive.ObjectIDFromHex(sessionId)
+	if err != nil {
+		return models.Session{}, err
+	}
+
+	var filter = bson.M{"_id": id}
+	err = collection.FindOne(ctx, filter).Decode(&session)
 	if err != nil {
 		return models.Session{}, err
 	}
@@ -75,6 +112,139 @@ func (service *AuthService) GetSession(sessionId string) (models.Session, error)
 	return session, nil
 }
 
+/** Obtiene las sesiones vigentes (no bloqueadas ni vencidas) de un usuario, de la más reciente a la más antigua
+ *
+ * @param email string "El correo electrónico del usuario"
+ * @param currentSessionId string "El id de la sesión de la consulta, que se marca como actual"
+ * @return GetSessionsResponse "Las sesiones"
+ * @return error "El error de la operación"
+ */
+func (service *AuthService) GetUserSessions(email string, currentSessionId string) (GetSessionsResponse, error) {
+	var collection = service.db.Collection("sessions")
+
+	filter := bson.M{"email": email, "is_blocked": false, "expires_at": bson.M{"$gt": time.Now()}}
+	opts := options.Find().SetSort(bson.M{"created_at": -1})
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return GetSessionsResponse{}, err
+	}
+
+	var sessions []models.Session
+	if err = cursor.All(ctx, &sessions); err != nil {
+		return GetSessionsResponse{}, err
+	}
+
+	response := GetSessionsResponse{Sessions: []UserSession{}}
+	for _, session := range sessions {
+		response.Sessions = append(response.Sessions, UserSession{
+			ID:        session.ID,
+			UserAgent: session.UserAgent,
+			ClientIP:  session.ClientIP,
+			CreatedAt: session.CreatedAt,
+			ExpiresAt: session.ExpiresAt,
+			Current:   session.ID.Hex() == currentSessionId,
+		})
+	}
+
+	return response, nil
+}
+
+/** Bloquea una sesión de un usuario. Revocar la sesión actual equivale a cerrar sesión
+ *
+ * @param email string "El correo electrónico del usuario dueño de la sesión"
+ * @param sessionId string "El id de la sesión"
+ * @return error "ErrSessionNotFound si la sesión no existe, no es del usuario o ya fue revocada"
+ */
+func (service *AuthService) RevokeUserSession(email string, sessionId string) error {
+	var collection = service.db.Collection("sessions")
+
+	id, err := primitive.ObjectIDFromHex(sessionId)
+	if err != nil {
+		return ErrSessionNotFound
+	}
+
+	filter := bson.M{"_id": id, "email": email, "is_blocked": false}
+	result, err := collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"is_blocked": true}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrSessionNotFound
+	}
+
+	return nil
+}
+
+/** Bloquea todas las sesiones de un usuario
+ *
+ * @param db *mongo.Database "La base de datos"
+ * @param email string "El correo electrónico del usuario"
+ * @return error "El error de la operación"
+ */
+func blockUserSessions(db *mongo.Database, email string) error {
+	var collection = db.Collection("sessions")
+
+	filter := bson.M{"email": email, "is_blocked": false}
+	update := bson.M{"$set": bson.M{"is_blocked": true}}
+	_, err := collection.UpdateMany(ctx, filter, update)
+	return err
+}
+
+/** Elimina todas las sesiones de un usuario
+ *
+ * @param db *mongo.Database "La base de datos"
+ * @param email string "El correo electrónico del usuario"
+ * @return error "El error de la operación"
+ */
+func deleteUserSessions(db *mongo.Database, email string) error {
+	var collection = db.Collection("sessions")
+
+	_, err := collection.DeleteMany(ctx, bson.M{"email": email})
+	return err
+}
+
+/** Pasa las sesiones de un usuario a su nuevo correo electrónico, para que sigan siendo válidas
+ *
+ * @param db *mongo.Database "La base de datos"
+ * @param from string "El correo electrónico anterior"
+ * @param to string "El correo electrónico nuevo"
+ * @return error "El error de la operación"
+ */
+func migrateUserSessions(db *mongo.Database, from string, to string) error {
+	_, err := db.Collection("sessions").UpdateMany(ctx, bson.M{"email": from}, bson.M{"$set": bson.M{"email": to}})
+	return err
+}
+
+/** Actualiza el vencimiento de la cuenta guardado en las sesiones de un usuario
+ *
+ * @param db *mongo.Database "La base de datos"
+ * @param email string "El correo electrónico del usuario"
+ * @param expiresAt *time.Time "El vencimiento nuevo, o nil si la cuenta no vence"
+ * @return error "El error de la operación"
+ */
+func setSessionsUserExpiration(db *mongo.Database, email string, expiresAt *time.Time) error {
+	update := bson.M{"$set": bson.M{"user_expires_at": expiresAt}}
+	if expiresAt == nil {
+		update = bson.M{"$unset": bson.M{"user_expires_at": ""}}
+	}
+
+	_, err := db.Collection("sessions").UpdateMany(ctx, bson.M{"email": email}, update)
+	return err
+}
+
+/** Quita de las sesiones de un usuario la restricción de cambiar la contraseña
+ *
+ * @param db *mongo.Database "La base de datos"
+ * @param email string "El correo electrónico del usuario"
+ * @return error "El error de la operación"
+ */
+func clearSessionsPasswordReset(db *mongo.Database, email string) error {
+	filter := bson.M{"email": email, "password_reset_required": true}
+	_, err := db.Collection("sessions").UpdateMany(ctx, filter, bson.M{"$unset": bson.M{"password_reset_required": ""}})
+	return err
+}
+
 func NewAuthService(db *mongo.Database) IAuthService {
 	return &AuthService{db: db}
 }
@@ -0,0 +1,399 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/maramal/user-service/models"
+	"github.com/maramal/user-service/token"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	AuthzDecisionAllow = "allow"
+	AuthzDecisionDeny  = "deny"
+
+	// Cantidad máxima de comprobaciones aceptadas en una consulta por lotes
+	maxAuthzBatchSize = 100
+)
+
+var (
+	ErrInvalidAuthzSubject   = errors.New("el sujeto debe contener un token o un id de usuario")
+	ErrInvalidScope          = errors.New("alcance no permitido")
+	ErrAuthzSubjectForbidden = errors.New("sólo los administradores pueden evaluar a otros usuarios por su id")
+	ErrAuthzBatchTooLarge    = fmt.Errorf("no se pueden evaluar más de %d comprobaciones por solicitud", maxAuthzBatchSize)
+)
+
+// Alcances que se pueden solicitar para un token. Cada alcance corresponde a una acción
+var availableScopes = []string{
+	"users:read",
+	"users:create",
+	"users:update",
+	"users:delete",
+	"users:password",
+	"authz:check",
+	"audit:read",
+}
+
+// Permiso otorgado a un tipo de usuario
+type permission struct {
+	// Acción permitida, por ejemplo "users:read". Admite los comodines "*" y "users:*"
+	Action string
+	// Si es verdadero, sólo aplica a recursos del propio sujeto (users/<id del sujeto>)
+	OwnOnly bool
+}
+
+// Permisos de cada tipo de usuario (campo Type del usuario)
+var rolePermissions = map[string][]permission{
+	"superadmin": {
+		{Action: "*"},
+	},
+	"admin": {
+		{Action: "users:*"},
+		{Action: "authz:check"},
+		{Action: "audit:read"},
+	},
+	"user": {
+		{Action: "users:read", OwnOnly: true},
+		{Action: "users:update", OwnOnly: true},
+		{Action: "authz:check"},
+	},
+}
+
+type AuthzSubject struct {
+	Token  string `json:"token,omitempty"`
+	UserID string `json:"user_id,omitempty"`
+}
+
+type AuthzCheckRequest struct {
+	Subject  AuthzSubject `json:"subject" binding:"required"`
+	Action   string       `json:"action" binding:"required"`
+	Resource string       `json:"resource" binding:"required"`
+}
+
+type AuthzBatchRequest struct {
+	Checks []AuthzCheckRequest `json:"checks" binding:"required,min=1,dive"`
+}
+
+// Usuario de la sesión que consulta la autorización
+type AuthzCaller struct {
+	Email    string
+	UserType string
+}
+
+type AuthzCheckResponse struct {
+	Allowed  bool     `json:"allowed"`
+	Decision string   `json:"decision"`
+	Reasons  []string `json:"reasons"`
+	UserID   string   `json:"user_id,omitempty"`
+	UserType string   `json:"user_type,omitempty"`
+	// Tiempo en segundos durante el cual la decisión puede ser cacheada
+	TTL int `json:"ttl"`
+}
+
+type AuthzBatchResponse struct {
+	Results []AuthzCheckResponse `json:"results"`
+	// Tiempo en segundos durante el cual todas las decisiones pueden ser cacheadas
+	TTL int `json:"ttl"`
+}
+
+type IAuthzService interface {
+	Check(req AuthzCheckRequest, caller AuthzCaller) (response AuthzCheckResponse, err error)
+	CheckBatch(req AuthzBatchRequest, caller AuthzCaller) (response AuthzBatchResponse, err error)
+}
+
+type AuthzService struct {
+	db         *mongo.Database
+	tokenMaker token.IMaker
+	cacheTTL   time.Duration
+}
+
+/** Evalúa si un sujeto puede realizar una acción sobre un recurso. Sólo los administradores pueden evaluar a
+ * otros usuarios por su id, y el tipo y el estado del sujeto sólo se informan a los administradores o al propio
+ * usuario, para no revelar datos de otras cuentas
+ *
+ * @param req AuthzCheckRequest "El sujeto, la acción y el recurso a evaluar"
+ * @param caller AuthzCaller "El usuario de la sesión que consulta"
+ * @return AuthzCheckResponse "La decisión y sus motivos"
+ * @return err error "ErrAuthzSubjectForbidden si un usuario que no es administrador consulta por otro id"
+ */
+func (service *AuthzService) Check(req AuthzCheckRequest, caller AuthzCaller) (response AuthzCheckResponse, err error) {
+	if req.Subject.Token == "" && req.Subject.UserID == "" {
+		err = ErrInvalidAuthzSubject
+		return
+	}
+
+	admin := authzAdminCaller(caller)
+	if req.Subject.Token == "" && !admin {
+		// Se responde igual si el usuario no existe, para no revelar qué ids existen
+		id, idErr := primitive.ObjectIDFromHex(req.Subject.UserID)
+		count, countErr := service.db.Collection("users").CountDocuments(ctx, bson.M{"_id": id, "email": caller.Email})
+		if countErr != nil {
+			err = countErr
+			return
+		}
+		if idErr != nil || count == 0 {
+			err = ErrAuthzSubjectForbidden
+			return
+		}
+	}
+
+	ttl := service.cacheTTL
+
+	user, payload, reason := service.resolveSubject(req.Subject)
+	reveal := admin || (user.Email != "" && user.Email == caller.Email)
+	if reason != "" {
+		if !reveal && !user.ID.IsZero() {
+			reason = "el usuario del sujeto no está habilitado"
+		}
+		response = newAuthzDeny([]string{reason}, 0)
+		return
+	}
+
+	if payload != nil {
+		if remaining := time.Until(payload.ExpiredAt); remaining < ttl {
+			ttl = remaining
+		}
+	}
+
+	allowed, reasons := evaluatePermissions(user, req.Action, req.Resource)
+	if allowed && payload != nil && !payload.HasScopes(req.Action) {
+		allowed = false
+		reasons = []string{fmt.Sprintf("el token no incluye el alcance \"%s\"", req.Action)}
+	}
+
+	if allowed {
+		response = newAuthzAllow(reasons, ttl)
+	} else {
+		response = newAuthzDeny(reasons, ttl)
+	}
+
+	response.UserID = user.ID.Hex()
+	if reveal {
+		response.UserType = user.Type
+	}
+	return
+}
+
+/** Evalúa varias comprobaciones de autorización en una sola llamada
+ *
+ * @param req AuthzBatchRequest "Las comprobaciones a evaluar"
+ * @param caller AuthzCaller "El usuario de la sesión que consulta"
+ * @return AuthzBatchResponse "Las decisiones en el mismo orden de la solicitud"
+ * @return err error "ErrAuthzBatchTooLarge si hay más de maxAuthzBatchSize comprobaciones"
+ */
+func (service *AuthzService) CheckBatch(req AuthzBatchRequest, caller AuthzCaller) (response AuthzBatchResponse, err error) {
+	if len(req.Checks) > maxAuthzBatchSize {
+		err = ErrAuthzBatchTooLarge
+		return
+	}
+
+	response.Results = make([]AuthzCheckResponse, 0, len(req.Checks))
+	response.TTL = int(service.cacheTTL.Seconds())
+
+	for _, check := range req.Checks {
+		var result AuthzCheckResponse
+		result, err = service.Check(check, caller)
+		if err != nil {
+			return
+		}
+
+		if result.TTL < response.TTL {
+			response.TTL = result.TTL
+		}
+
+		response.Results = append(response.Results, result)
+	}
+
+	return
+}
+
+/** Obtiene el usuario asociado al sujeto de la comprobación
+ *
+ * @param subject AuthzSubject "El sujeto a resolver"
+ * @return models.User "El usuario del sujeto"
+ * @return *token.Payload "El payload del token, si el sujeto es un token"
+ * @return string "El motivo por el cual no se pudo resolver el sujeto"
+ */
+func (service *AuthzService) resolveSubject(subject AuthzSubject) (user models.User, payload *token.Payload, reason string) {
+	collection := service.db.Collection("users")
+
+	var filter bson.M
+	if subject.Token != "" {
+		var err error
+		payload, err = service.tokenMaker.Valid(subject.Token)
+		if err != nil {
+			reason = fmt.Sprintf("el token del sujeto no es válido: %s", err)
+			return
+		}
+
+		var session models.Session
+		sessionID, _ := primitive.ObjectIDFromHex(payload.SessionID)
+		err = service.db.Collection("sessions").FindOne(ctx, bson.M{"_id": sessionID}).Decode(&session)
+		if err != nil || session.IsBlocked || time.Now().After(session.ExpiresAt) {
+			reason = "la sesión del token no es válida o fue bloqueada"
+			return
+		}
+
+		// La sesión tiene el correo electrónico vigente aunque el usuario lo haya cambiado después de emitir el token
+		payload.Email = session.Email
+		filter = bson.M{"email": payload.Email, "deleted_at": nil}
+	} else {
+		id, err := primitive.ObjectIDFromHex(subject.UserID)
+		if err != nil {
+			reason = "el id de usuario del sujeto no es válido"
+			return
+		}
+
+		filter = bson.M{"_id": id, "deleted_at": nil}
+	}
+
+	if err := collection.FindOne(ctx, filter).Decode(&user); err != nil {
+		reason = "no se encontró el usuario del sujeto"
+		return
+	}
+
+	if user.Status != models.UserStatusActive {
+		reason = fmt.Sprintf("el usuario del sujeto no está activo (%s)", user.Status)
+	} else if userExpired(user) {
+		reason = "la cuenta del usuario del sujeto venció"
+	}
+
+	return
+}
+
+/** Busca entre los permisos del tipo de usuario alguno que conceda la acción sobre el recurso
+ *
+ * @param user models.User "El usuario que solicita la acción"
+ * @param action string "La acción solicitada"
+ * @param resource string "El recurso sobre el que se realiza la acción"
+ * @return bool "Si la acción está permitida"
+ * @return []string "Los motivos de la decisión"
+ */
+func evaluatePermissions(user models.User, action, resource string) (bool, []string) {
+	permissions, ok := rolePermissions[user.Type]
+	if !ok {
+		return false, []string{fmt.Sprintf("el tipo de usuario \"%s\" no tiene permisos asignados", user.Type)}
+	}
+
+	var reasons []string
+	for _, perm := range permissions {
+		if !matchesAction(perm.Action, action) {
+			continue
+		}
+
+		if perm.OwnOnly && !isOwnResource(user, resource) {
+			reasons = append(reasons, fmt.Sprintf("el permiso \"%s\" sólo aplica a recursos propios", perm.Action))
+			continue
+		}
+
+		return true, []string{fmt.Sprintf("el tipo de usuario \"%s\" tiene el permiso \"%s\"", user.Type, perm.Action)}
+	}
+
+	reasons = append(reasons, fmt.Sprintf("ningún permiso del tipo de usuario \"%s\" concede la acción \"%s\" sobre \"%s\"", user.Type, action, resource))
+	return false, reasons
+}
+
+/** Obtiene los alcances que se otorgan a un token
+ *
+ * @param userType string "El tipo de usuario del token"
+ * @param requested []string "Los alcances solicitados. Si está vacío se otorgan todos los del tipo de usuario"
+ * @return []string "Los alcances otorgados"
+ * @return err error "El error si se solicitó un alcance no permitido"
+ */
+func ResolveScopes(userType string, requested []string) (scopes []string, err error) {
+	granted := make([]string, 0, len(availableScopes))
+	for _, scope := range availableScopes {
+		if roleGrants(userType, scope) {
+			granted = append(granted, scope)
+		}
+	}
+
+	if len(requested) == 0 {
+		scopes = granted
+		return
+	}
+
+	for _, scope := range requested {
+		if !containsString(granted, scope) {
+			err = fmt.Errorf("%w: \"%s\"", ErrInvalidScope, scope)
+			return
+		}
+
+		if !containsString(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	return
+}
+
+// Indica si quien consulta es administrador, con los mismos tipos de usuario que middlewares.AdminMiddleware
+func authzAdminCaller(caller AuthzCaller) bool {
+	return caller.UserType == "superadmin" || caller.UserType == "admin"
+}
+
+// Verifica si algún permiso del tipo de usuario concede la acción, sin considerar el recurso
+func roleGrants(userType, action string) bool {
+	for _, perm := range rolePermissions[userType] {
+		if matchesAction(perm.Action, action) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Verifica si un permiso (con o sin comodín) coincide con la acción
+func matchesAction(granted, action string) bool {
+	if granted == "*" || granted == action {
+		return true
+	}
+
+	if strings.HasSuffix(granted, ":*") {
+		return strings.HasPrefix(action, strings.TrimSuffix(granted, "*"))
+	}
+
+	return false
+}
+
+// Verifica si el recurso pertenece al usuario, por ejemplo "users/<id del usuario>"
+func isOwnResource(user models.User, resource string) bool {
+	return resource == "users/"+user.ID.Hex()
+}
+
+func newAuthzAllow(reasons []string, ttl time.Duration) AuthzCheckResponse {
+	return AuthzCheckResponse{
+		Allowed:  true,
+		Decision: AuthzDecisionAllow,
+		Reasons:  reasons,
+		TTL:      int(ttl.Seconds()),
+	}
+}
+
+func newAuthzDeny(reasons []string, ttl time.Duration) AuthzCheckResponse {
+	return AuthzCheckResponse{
+		Allowed:  false,
+		Decision: AuthzDecisionDeny,
+		Reasons:  reasons,
+		TTL:      int(ttl.Seconds()),
+	}
+}
+
+func NewAuthzService(db *mongo.Database, tokenMaker token.IMaker, cacheTTL time.Duration) IAuthzService {
+	return &AuthzService{db: db, tokenMaker: tokenMaker, cacheTTL: cacheTTL}
+}
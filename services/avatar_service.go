@@ -0,0 +1,351 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/maramal/user-service/models"
+	"github.com/maramal/user-service/storage"
+	"github.com/maramal/user-service/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// Tamaño máximo de la imagen subida si no se configura AVATAR_MAX_SIZE (5 MB)
+	defaultAvatarMaxSize = 5 << 20
+	// Lado mayor máximo de la imagen completa, en píxeles
+	avatarMaxDimension = 1024
+)
+
+var (
+	ErrInvalidAvatar  = errors.New("la imagen de perfil no es válida")
+	ErrAvatarTooLarge = errors.New("la imagen de perfil supera el tamaño máximo")
+	ErrAvatarNotFound = errors.New("el usuario no tiene imagen de perfil")
+)
+
+// Lados de las miniaturas cuadradas que se generan para cada imagen de perfil
+var AvatarSizes = []int{64, 128, 256}
+
+// Tipos de imagen que se aceptan al subir una imagen de perfil
+var avatarContentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+type AvatarImage struct {
+	Data        []byte
+	ContentType string
+	// Cambia cada vez que cambia la imagen, para usar en la cabecera ETag
+	Version string
+}
+
+type IAvatarService interface {
+	SetAvatar(userId string, data []byte, contentType string, changedBy string) (response UpdateUserResponse, err error)
+	DeleteAvatar(userId string, changedBy string) (response UpdateUserResponse, err error)
+	GetAvatar(userId string, size int) (response AvatarImage, err error)
+	MaxSize() int
+}
+
+type AvatarService struct {
+	db      *mongo.Database
+	storage storage.IStorage
+	maxSize int
+}
+
+/** Guarda la imagen de perfil de un usuario. La imagen se valida, se eliminan sus metadatos (EXIF),
+ * se reduce a avatarMaxDimension y se generan las miniaturas de AvatarSizes. La imagen anterior se elimina
+ *
+ * @param id string "El id del usuario"
+ * @param data []byte "El contenido de la imagen"
+ * @param contentType string "El tipo de contenido informado por el cliente"
+ * @param changedBy string "El correo electrónico de quien sube la imagen, para el historial de versiones"
+ * @return UpdateUserResponse "El usuario actualizado"
+ * @return err error "El error de la operación"
+ */
+func (service *AvatarService) SetAvatar(userId string, data []byte, contentType string, changedBy string) (response UpdateUserResponse, err error) {
+	collection := service.db.Collection("users")
+
+	id, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		err = ErrUserNotFound
+		return
+	}
+
+	if len(data) > service.maxSize {
+		err = fmt.Errorf("%w: el máximo es %d bytes", ErrAvatarTooLarge, service.maxSize)
+		return
+	}
+
+	// El tipo informado, si es de imagen, debe coincidir con el contenido real del archivo
+	detected := http.DetectContentType(data)
+	declared := avatarContentType(contentType)
+	if !avatarContentTypes[detected] || (strings.HasPrefix(declared, "image/") && declared != detected) {
+		err = fmt.Errorf("%w: sólo se aceptan imágenes JPEG, PNG o GIF", ErrInvalidAvatar)
+		return
+	}
+
+	count, err := collection.CountDocuments(ctx, bson.M{"_id": id, "deleted_at": nil})
+	if err != nil {
+		return
+	}
+	if count == 0 {
+		err = ErrUserNotFound
+		return
+	}
+
+	img, err := utils.DecodeImage(data)
+	if err != nil {
+		err = fmt.Errorf("%w: %s", ErrInvalidAvatar, err)
+		return
+	}
+
+	avatar := models.UserAvatar{
+		ID:          primitive.NewObjectID().Hex(),
+		ContentType: utils.ImageContentType(img),
+		Sizes:       AvatarSizes,
+		UpdatedAt:   time.Now(),
+	}
+
+	if err = service.storeAvatar(id, avatar, utils.FitImage(img, avatarMaxDimension)); err != nil {
+		return
+	}
+
+	var before models.User
+	filter := bson.M{"_id": id, "deleted_at": nil}
+	update := bson.M{
+		"$set": bson.M{"avatar": avatar, "profile_image": AvatarURL(id), "updated_at": time.Now()},
+		"$inc": bson.M{"version": 1},
+	}
+	err = collection.FindOneAndUpdate(ctx, filter, update).Decode(&before)
+	if err == mongo.ErrNoDocuments {
+		err = ErrUserNotFound
+	}
+	if err != nil {
+		service.deleteAvatarFiles(id, &avatar)
+		return
+	}
+
+	service.deleteAvatarFiles(id, before.Avatar)
+
+	if err = recordUserVersion(service.db, id, UserVersionAvatar, changedBy); err != nil {
+		return
+	}
+
+	opts := options.FindOne().SetProjection(bson.M{"password": 0})
+	err = collection.FindOne(ctx, bson.M{"_id": id}, opts).Decode(&response.User)
+	return
+}
+
+/** Elimina la imagen de perfil de un usuario
+ *
+ * @param id string "El id del usuario"
+ * @param changedBy string "El correo electrónico de quien elimina la imagen, para el historial de versiones"
+ * @return UpdateUserResponse "El usuario actualizado"
+ * @return err error "El error de la operación"
+ */
+func (service *AvatarService) DeleteAvatar(userId string, changedBy string) (response UpdateUserResponse, err error) {
+	collection := service.db.Collection("users")
+
+	id, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		err = ErrUserNotFound
+		return
+	}
+
+	var before models.User
+	filter := bson.M{"_id": id, "deleted_at": nil, "avatar": bson.M{"$ne": nil}}
+	update := bson.M{
+		"$unset": bson.M{"avatar": "", "profile_image": ""},
+		"$set":   bson.M{"updated_at": time.Now()},
+		"$inc":   bson.M{"version": 1},
+	}
+	err = collection.FindOneAndUpdate(ctx, filter, update).Decode(&before)
+	if err == mongo.ErrNoDocuments {
+		err = service.avatarNotFoundError(id)
+	}
+	if err != nil {
+		return
+	}
+
+	service.deleteAvatarFiles(id, before.Avatar)
+
+	if err = recordUserVersion(service.db, id, UserVersionAvatar, changedBy); err != nil {
+		return
+	}
+
+	opts := options.FindOne().SetProjection(bson.M{"password": 0})
+	err = collection.FindOne(ctx, bson.M{"_id": id}, opts).Decode(&response.User)
+	return
+}
+
+/** Obtiene la imagen de perfil vigente de un usuario
+ *
+ * @param id string "El id del usuario"
+ * @param size int "El lado de la miniatura (uno de AvatarSizes) o 0 para la imagen completa"
+ * @return AvatarImage "La imagen"
+ * @return err error "El error de la operación"
+ */
+func (service *AvatarService) GetAvatar(userId string, size int) (response AvatarImage, err error) {
+	collection := service.db.Collection("users")
+	var user models.User
+
+	id, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		err = ErrUserNotFound
+		return
+	}
+
+	opts := options.FindOne().SetProjection(bson.M{"avatar": 1})
+	err = collection.FindOne(ctx, bson.M{"_id": id, "deleted_at": nil}, opts).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		err = ErrUserNotFound
+	}
+	if err != nil {
+		return
+	}
+
+	if user.Avatar == nil {
+		err = ErrAvatarNotFound
+		return
+	}
+
+	if size != 0 && !containsInt(user.Avatar.Sizes, size) {
+		err = fmt.Errorf("%w: el tamaño %d no existe", ErrInvalidAvatar, size)
+		return
+	}
+
+	data, _, err := service.storage.Get(avatarKey(id, user.Avatar, size))
+	if errors.Is(err, storage.ErrObjectNotFound) {
+		err = ErrAvatarNotFound
+	}
+	if err != nil {
+		return
+	}
+
+	response = AvatarImage{
+		Data:        data,
+		ContentType: user.Avatar.ContentType,
+		Version:     user.Avatar.ID + "-" + strconv.Itoa(size),
+	}
+	return
+}
+
+// Tamaño máximo en bytes de la imagen subida
+func (service *AvatarService) MaxSize() int {
+	return service.maxSize
+}
+
+// Guarda la imagen completa y sus miniaturas
+func (service *AvatarService) storeAvatar(id primitive.ObjectID, avatar models.UserAvatar, full *image.NRGBA) error {
+	variants := map[int]*image.NRGBA{0: full}
+	for _, size := range avatar.Sizes {
+		variants[size] = utils.ThumbnailImage(full, size)
+	}
+
+	for size, img := range variants {
+		data, err := utils.EncodeImage(img, avatar.ContentType)
+		if err != nil {
+			service.deleteAvatarFiles(id, &avatar)
+			return err
+		}
+
+		if err = service.storage.Put(avatarKey(id, &avatar, size), data, avatar.ContentType); err != nil {
+			service.deleteAvatarFiles(id, &avatar)
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (service *AvatarService) deleteAvatarFiles(id primitive.ObjectID, avatar *models.UserAvatar) {
+	deleteAvatarFiles(service.storage, id, avatar)
+}
+
+// Elimina los archivos de una imagen de perfil. Los errores se ignoran: un archivo huérfano no afecta al usuario
+func deleteAvatarFiles(store storage.IStorage, id primitive.ObjectID, avatar *models.UserAvatar) {
+	if avatar == nil {
+		return
+	}
+
+	for _, size := range append([]int{0}, avatar.Sizes...) {
+		store.Delete(avatarKey(id, avatar, size))
+	}
+}
+
+func (service *AvatarService) avatarNotFoundError(id primitive.ObjectID) error {
+	count, err := service.db.Collection("users").CountDocuments(ctx, bson.M{"_id": id, "deleted_at": nil})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrUserNotFound
+	}
+
+	return ErrAvatarNotFound
+}
+
+/** Obtiene la URL estable de la imagen de perfil de un usuario, que no cambia al subir otra imagen
+ *
+ * @param id primitive.ObjectID "El id del usuario"
+ * @return string "La URL, relativa al servidor"
+ */
+func AvatarURL(id primitive.ObjectID) string {
+	return "/api/avatars/" + id.Hex()
+}
+
+// Clave de una variante de la imagen en el almacenamiento. El tamaño 0 es la imagen completa
+func avatarKey(id primitive.ObjectID, avatar *models.UserAvatar, size int) string {
+	name := "full"
+	if size != 0 {
+		name = strconv.Itoa(size)
+	}
+
+	extension := "jpg"
+	if avatar.ContentType == "image/png" {
+		extension = "png"
+	}
+
+	return fmt.Sprintf("avatars/%s/%s/%s.%s", id.Hex(), avatar.ID, name, extension)
+}
+
+// Normaliza el tipo de contenido informado por el cliente
+func avatarContentType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	if mediaType == "image/jpg" || mediaType == "image/pjpeg" {
+		return "image/jpeg"
+	}
+
+	return mediaType
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func NewAvatarService(db *mongo.Database, store storage.IStorage, maxSize int) IAvatarService {
+	if maxSize <= 0 {
+		maxSize = defaultAvatarMaxSize
+	}
+
+	return &AvatarService{db: db, storage: store, maxSize: maxSize}
+}
@@ -0,0 +1,402 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/maramal/user-service/models"
+	"github.com/maramal/user-service/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	CustomReportRunning   = "running"
+	CustomReportCompleted = "completed"
+	CustomReportFailed    = "failed"
+
+	// Cantidad máxima de usuarios inválidos que se guardan en el reporte
+	maxCustomReportUsers = 1000
+)
+
+var (
+	ErrInvalidCustomSchema  = errors.New("el esquema de atributos personalizados no es válido")
+	ErrCustomSchemaNotFound = errors.New("no se encontró el esquema de atributos personalizados")
+)
+
+type SaveCustomSchemaRequest struct {
+	// Esquema JSON (draft 7) de los atributos personalizados. La raíz debe ser de tipo object
+	Schema json.RawMessage `json:"schema" swaggertype:"object" binding:"required"`
+	// Atributo => nombre del claim con el que se incluye en los tokens
+	Claims map[string]string `json:"claims"`
+}
+
+type GetCustomSchemasResponse struct {
+	Schemas []models.CustomAttributeSchema `json:"schemas"`
+}
+
+type ICustomAttributeService interface {
+	GetSchema() (response models.CustomAttributeSchema, err error)
+	GetSchemaVersion(version int) (response models.CustomAttributeSchema, err error)
+	GetSchemaVersions() (response GetCustomSchemasResponse, err error)
+	SaveSchema(req SaveCustomSchemaRequest, createdBy string) (response models.CustomAttributeSchema, err error)
+	TokenClaims(user models.User) (claims map[string]interface{}, err error)
+}
+
+type CustomAttributeService struct {
+	db *mongo.Database
+}
+
+/** Obtiene la versión vigente del esquema de atributos personalizados
+ *
+ * @return models.CustomAttributeSchema "El esquema"
+ * @return err error "ErrCustomSchemaNotFound si todavía no se definió un esquema"
+ */
+func (service *CustomAttributeService) GetSchema() (response models.CustomAttributeSchema, err error) {
+	schema, err := currentCustomSchema(service.db)
+	if err != nil {
+		return
+	}
+	if schema == nil {
+		err = ErrCustomSchemaNotFound
+		return
+	}
+
+	return *schema, nil
+}
+
+/** Obtiene una versión del esquema con el reporte de usuarios que no la cumplen
+ *
+ * @param version int "La versión del esquema"
+ * @return models.CustomAttributeSchema "El esquema"
+ * @return err error "El error de la operación"
+ */
+func (service *CustomAttributeService) GetSchemaVersion(version int) (response models.CustomAttributeSchema, err error) {
+	collection := service.db.Collection("custom_attribute_schemas")
+
+	err = collection.FindOne(ctx, bson.M{"version": version}).Decode(&response)
+	if err == mongo.ErrNoDocuments {
+		err = ErrCustomSchemaNotFound
+	}
+
+	return
+}
+
+/** Obtiene todas las versiones del esquema, sin el detalle de los usuarios inválidos
+ *
+ * @return GetCustomSchemasResponse "Las versiones, de la más reciente a la más antigua"
+ * @return err error "El error de la operación"
+ */
+func (service *CustomAttributeService) GetSchemaVersions() (response GetCustomSchemasResponse, err error) {
+	collection := service.db.Collection("custom_attribute_schemas")
+
+	opts := options.Find().
+		SetSort(bson.M{"version": -1}).
+		SetProjection(bson.M{"report.invalid_users": 0})
+
+	cursor, err := collection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return
+	}
+
+	response.Schemas = []models.CustomAttributeSchema{}
+	err = cursor.All(ctx, &response.Schemas)
+	return
+}
+
+/** Guarda una nueva versión del esquema y valida en segundo plano a los usuarios existentes
+ *
+ * Los usuarios que no cumplen el nuevo esquema no se modifican; se informan en el reporte de la versión.
+ *
+ * @param req SaveCustomSchemaRequest "El esquema y los claims"
+ * @param createdBy string "El correo electrónico de quien define el esquema"
+ * @return models.CustomAttributeSchema "La versión creada"
+ * @return err error "El error de la operación"
+ */
+func (service *CustomAttributeService) SaveSchema(req SaveCustomSchemaRequest, createdBy string) (response models.CustomAttributeSchema, err error) {
+	collection := service.db.Collection("custom_attribute_schemas")
+
+	schema, err := utils.ParseJSONSchema(req.Schema)
+	if err != nil {
+		err = fmt.Errorf("%w: %s", ErrInvalidCustomSchema, err)
+		return
+	}
+
+	var root struct {
+		Type       string                     `json:"type"`
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	if json.Unmarshal(req.Schema, &root) != nil || root.Type != "object" {
+		err = fmt.Errorf("%w: la raíz del esquema debe ser de tipo object", ErrInvalidCustomSchema)
+		return
+	}
+
+	for attribute, claim := range req.Claims {
+		if _, ok := root.Properties[attribute]; !ok {
+			err = fmt.Errorf("%w: el claim \"%s\" corresponde al atributo inexistente \"%s\"", ErrInvalidCustomSchema, claim, attribute)
+			return
+		}
+		if strings.TrimSpace(claim) == "" {
+			err = fmt.Errorf("%w: el atributo \"%s\" no tiene nombre de claim", ErrInvalidCustomSchema, attribute)
+			return
+		}
+	}
+
+	current, err := currentCustomSchema(service.db)
+	if err != nil {
+		return
+	}
+
+	response = models.CustomAttributeSchema{
+		Version:   1,
+		Schema:    req.Schema,
+		Claims:    req.Claims,
+		Report:    models.CustomAttributeReport{Status: CustomReportRunning, InvalidUsers: []models.CustomAttributeInvalidUser{}},
+		CreatedBy: createdBy,
+		CreatedAt: time.Now(),
+	}
+	if current != nil {
+		response.Version = current.Version + 1
+	}
+	if response.Claims == nil {
+		response.Claims = map[string]string{}
+	}
+
+	// El índice único de version impide que dos solicitudes simultáneas creen la misma versión
+	result, err := collection.InsertOne(ctx, response)
+	if err != nil {
+		return
+	}
+	response.ID = result.InsertedID.(primitive.ObjectID)
+
+	go service.checkUsers(response, schema)
+
+	return
+}
+
+/** Obtiene los claims del token de un usuario según los atributos indicados en el esquema vigente
+ *
+ * @param user models.User "El usuario"
+ * @return map[string]interface{} "Nombre del claim => valor. nil si no hay claims"
+ * @return err error "El error de la operación"
+ */
+func (service *CustomAttributeService) TokenClaims(user models.User) (claims map[string]interface{}, err error) {
+	if len(user.Custom) == 0 {
+		return
+	}
+
+	schema, err := currentCustomSchema(service.db)
+	if err != nil || schema == nil {
+		return
+	}
+
+	for attribute, claim := range schema.Claims {
+		if value, ok := user.Custom[attribute]; ok {
+			if claims == nil {
+				claims = map[string]interface{}{}
+			}
+			claims[claim] = value
+		}
+	}
+
+	return
+}
+
+/** Valida los atributos de todos los usuarios con una versión del esquema, actualiza sus términos
+ * de búsqueda y guarda el reporte en la versión
+ *
+ * @param version models.CustomAttributeSchema "La versión del esquema"
+ * @param schema *utils.JSONSchema "El esquema ya interpretado"
+ */
+func (service *CustomAttributeService) checkUsers(version models.CustomAttributeSchema, schema *utils.JSONSchema) {
+	users := service.db.Collection("users")
+	report := &version.Report
+
+	err := func() error {
+		opts := options.Find().SetProjection(bson.M{"first_name": 1, "last_name": 1, "email": 1, "custom": 1, "version": 1})
+		cursor, err := users.Find(ctx, bson.M{"deleted_at": nil}, opts)
+		if err != nil {
+			return err
+		}
+		defer cursor.Close(ctx)
+
+		for cursor.Next(ctx) {
+			var user models.User
+			if err := cursor.Decode(&user); err != nil {
+				return err
+			}
+			report.CheckedUsers++
+
+			custom, err := normalizeCustomAttributes(user.Custom)
+			if err != nil {
+				return err
+			}
+
+			if errs := schema.Validate(custom); len(errs) > 0 {
+				report.InvalidCount++
+				if len(report.InvalidUsers) < maxCustomReportUsers {
+					report.InvalidUsers = append(report.InvalidUsers, models.CustomAttributeInvalidUser{
+						UserID: user.ID,
+						Email:  user.Email,
+						Errors: errs,
+					})
+				}
+			}
+
+			// Las propiedades de búsqueda pueden haber cambiado con la nueva versión. Si el usuario se modificó
+			// después de leerlo no se actualiza: esa modificación ya calculó sus términos con sus datos nuevos
+			terms := utils.UserSearchTerms(user.FirstName, user.LastName, user.Email, customSearchWords(schema, custom)...)
+			filter := bson.M{"_id": user.ID, "version": user.Version}
+			if _, err := users.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"search_terms": terms}}); err != nil {
+				return err
+			}
+		}
+
+		return cursor.Err()
+	}()
+
+	report.Status = CustomReportCompleted
+	if err != nil {
+		report.Status = CustomReportFailed
+		report.Error = err.Error()
+	}
+	finishedAt := time.Now()
+	report.FinishedAt = &finishedAt
+
+	collection := service.db.Collection("custom_attribute_schemas")
+	collection.UpdateByID(ctx, version.ID, bson.M{"$set": bson.M{"report": report}})
+}
+
+// Obtiene la versión más reciente del esquema, o nil si todavía no se definió
+func currentCustomSchema(db *mongo.Database) (*models.CustomAttributeSchema, error) {
+	collection := db.Collection("custom_attribute_schemas")
+
+	var schema models.CustomAttributeSchema
+	opts := options.FindOne().SetSort(bson.M{"version": -1}).SetProjection(bson.M{"report": 0})
+	err := collection.FindOne(ctx, bson.M{}, opts).Decode(&schema)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &schema, nil
+}
+
+/** Valida atributos personalizados con el esquema vigente. Si no se definió un esquema, sólo se
+ * aceptan atributos vacíos
+ *
+ * @param db *mongo.Database "La base de datos"
+ * @param custom map[string]interface{} "Los atributos"
+ * @return map[string]interface{} "Los atributos normalizados, listos para guardar. nil si están vacíos"
+ * @return err error "ErrInvalidUserData si los atributos no cumplen el esquema"
+ */
+func validateCustomAttributes(db *mongo.Database, custom map[string]interface{}) (normalized map[string]interface{}, err error) {
+	current, err := currentCustomSchema(db)
+	if err != nil {
+		return
+	}
+	if current == nil {
+		if len(custom) > 0 {
+			err = fmt.Errorf("%w: no se definió un esquema de atributos personalizados", ErrInvalidUserData)
+		}
+		return
+	}
+
+	schema, err := utils.ParseJSONSchema(current.Schema)
+	if err != nil {
+		return
+	}
+
+	if normalized, err = normalizeCustomAttributes(custom); err != nil {
+		return
+	}
+
+	if errs := schema.Validate(normalized); len(errs) > 0 {
+		err = fmt.Errorf("%w: atributos personalizados: %s", ErrInvalidUserData, strings.Join(errs, "; "))
+		return
+	}
+
+	if len(normalized) == 0 {
+		normalized = nil
+	}
+
+	return
+}
+
+/** Obtiene los términos de búsqueda de un usuario, incluyendo las propiedades de búsqueda de sus
+ * atributos personalizados
+ *
+ * @param db *mongo.Database "La base de datos"
+ * @param user models.User "El usuario con los valores a guardar"
+ * @return []string "Los términos de búsqueda"
+ * @return err error "El error de la operación"
+ */
+func userSearchTerms(db *mongo.Database, user models.User) (terms []string, err error) {
+	var words []string
+	if len(user.Custom) > 0 {
+		current, schemaErr := currentCustomSchema(db)
+		if err = schemaErr; err != nil {
+			return
+		}
+
+		if current != nil {
+			schema, parseErr := utils.ParseJSONSchema(current.Schema)
+			if err = parseErr; err != nil {
+				return
+			}
+
+			custom, normalizeErr := normalizeCustomAttributes(user.Custom)
+			if err = normalizeErr; err != nil {
+				return
+			}
+			words = customSearchWords(schema, custom)
+		}
+	}
+
+	return utils.UserSearchTerms(user.FirstName, user.LastName, user.Email, words...), nil
+}
+
+// Convierte los atributos a los tipos de encoding/json, que son los que entiende el validador
+func normalizeCustomAttributes(custom map[string]interface{}) (map[string]interface{}, error) {
+	normalized := map[string]interface{}{}
+	if len(custom) == 0 {
+		return normalized, nil
+	}
+
+	data, err := json.Marshal(custom)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, &normalized)
+	return normalized, err
+}
+
+// Obtiene los textos de las propiedades marcadas con x-searchable
+func customSearchWords(schema *utils.JSONSchema, custom map[string]interface{}) (words []string) {
+	for _, property := range schema.SearchableProperties() {
+		switch value := custom[property].(type) {
+		case string:
+			words = append(words, value)
+		case []interface{}:
+			for _, item := range value {
+				if text, ok := item.(string); ok {
+					words = append(words, text)
+				}
+			}
+		}
+	}
+
+	return
+}
+
+func NewCustomAttributeService(db *mongo.Database) ICustomAttributeService {
+	return &CustomAttributeService{db: db}
+}
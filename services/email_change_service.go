@@ -0,0 +1,433 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/maramal/user-service/mailer"
+	"github.com/maramal/user-service/models"
+	"github.com/maramal/user-service/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	EmailChangeStatusPending   = "pending"
+	EmailChangeStatusConfirmed = "confirmed"
+	EmailChangeStatusCancelled = "cancelled"
+	// El cambio se confirmó y luego se canceló desde el correo anterior, que volvió a ser el del usuario
+	EmailChangeStatusReverted = "reverted"
+
+	// Validez de los enlaces si no se configura EMAIL_CHANGE_TTL
+	defaultEmailChangeTTL = 24 * time.Hour
+)
+
+var ErrEmailChangeNotFound = errors.New("no se encontró el cambio de correo electrónico o el enlace venció")
+
+type RequestEmailChangeRequest struct {
+	Email string `json:"email" binding:"required"`
+}
+
+type RequestOwnEmailChangeRequest struct {
+	Email           string `json:"email" binding:"required"`
+	CurrentPassword string `json:"current_password" binding:"required"`
+}
+
+type EmailChangeTokenRequest struct {
+	// Token recibido en el enlace del correo
+	Token string `json:"token" binding:"required"`
+}
+
+type EmailChangeResponse struct {
+	EmailChange models.EmailChange `json:"email_change"`
+}
+
+type IEmailChangeService interface {
+	RequestEmailChange(userId string, email string, requestedBy string) (response EmailChangeResponse, err error)
+	RequestOwnEmailChange(userId string, req RequestOwnEmailChangeRequest) (response EmailChangeResponse, err error)
+	GetPendingEmailChange(userId string) (response EmailChangeResponse, err error)
+	CancelPendingEmailChange(userId string) (err error)
+	ConfirmEmailChange(token string) (response UpdateUserResponse, err error)
+	CancelEmailChange(token string) (err error)
+}
+
+type EmailChangeService struct {
+	db     *mongo.Database
+	mailer mailer.IMailer
+	appURL string
+	ttl    time.Duration
+}
+
+/** Inicia el cambio de correo electrónico de un usuario. El correo no cambia hasta que se confirma con el enlace
+ * enviado a la dirección nueva; la dirección actual recibe un aviso con un enlace para cancelar el cambio.
+ * Un cambio pendiente anterior se cancela
+ *
+ * @param id string "El id del usuario"
+ * @param email string "El correo electrónico nuevo"
+ * @param requestedBy string "El correo electrónico de quien solicita el cambio"
+ * @return EmailChangeResponse "El cambio pendiente"
+ * @return err error "El error de la operación"
+ */
+func (service *EmailChangeService) RequestEmailChange(userId string, email string, requestedBy string) (response EmailChangeResponse, err error) {
+	collection := service.db.Collection("email_changes")
+
+	user, err := service.findUser(userId)
+	if err != nil {
+		return
+	}
+
+	if email, err = normalizeEmail(email); err != nil {
+		return
+	}
+	if email == user.Email {
+		err = fmt.Errorf("%w: el correo electrónico nuevo es igual al actual", ErrInvalidUserData)
+		return
+	}
+	if err = service.checkEmailAvailable(email, user.ID); err != nil {
+		return
+	}
+
+	confirmToken, err := utils.NewSecureToken()
+	if err != nil {
+		return
+	}
+	cancelToken, err := utils.NewSecureToken()
+	if err != nil {
+		return
+	}
+
+	if err = service.cancelPending(user.ID); err != nil {
+		return
+	}
+
+	now := time.Now()
+	change := models.EmailChange{
+		UserID:           user.ID,
+		OldEmail:         user.Email,
+		NewEmail:         email,
+		ConfirmTokenHash: utils.HashToken(confirmToken),
+		CancelTokenHash:  utils.HashToken(cancelToken),
+		Status:           EmailChangeStatusPending,
+		RequestedBy:      requestedBy,
+		CreatedAt:        now,
+		ExpiresAt:        now.Add(service.ttl),
+	}
+
+	result, err := collection.InsertOne(ctx, change)
+	if err != nil {
+		return
+	}
+	change.ID = result.InsertedID.(primitive.ObjectID)
+
+	if err = service.sendRequestMessages(change, confirmToken, cancelToken); err != nil {
+		// Sin los dos correos el cambio no se puede confirmar o el dueño actual no se entera: se descarta
+		service.cancelPending(user.ID)
+		return
+	}
+
+	response.EmailChange = change
+	return
+}
+
+/** Inicia el cambio de correo electrónico del propio usuario, después de verificar su contraseña
+ *
+ * @param id string "El id del usuario"
+ * @param req RequestOwnEmailChangeRequest "El correo electrónico nuevo y la contraseña actual"
+ * @return EmailChangeResponse "El cambio pendiente"
+ * @return err error "El error de la operación"
+ */
+func (service *EmailChangeService) RequestOwnEmailChange(userId string, req RequestOwnEmailChangeRequest) (response EmailChangeResponse, err error) {
+	user, err := service.findUser(userId)
+	if err != nil {
+		return
+	}
+
+	if utils.CheckPassword(req.CurrentPassword, user.Password) != nil {
+		err = fmt.Errorf("%w: la contraseña actual no es correcta", ErrInvalidUserData)
+		return
+	}
+
+	return service.RequestEmailChange(userId, req.Email, user.Email)
+}
+
+/** Obtiene el cambio de correo electrónico pendiente de un usuario
+ *
+ * @param id string "El id del usuario"
+ * @return EmailChangeResponse "El cambio pendiente"
+ * @return err error "ErrEmailChangeNotFound si no hay un cambio pendiente"
+ */
+func (service *EmailChangeService) GetPendingEmailChange(userId string) (response EmailChangeResponse, err error) {
+	id, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		err = ErrUserNotFound
+		return
+	}
+
+	filter := bson.M{"user_id": id, "status": EmailChangeStatusPending, "expires_at": bson.M{"$gt": time.Now()}}
+	opts := options.FindOne().SetSort(bson.M{"created_at": -1})
+
+	err = service.db.Collection("email_changes").FindOne(ctx, filter, opts).Decode(&response.EmailChange)
+	if err == mongo.ErrNoDocuments {
+		err = ErrEmailChangeNotFound
+	}
+	return
+}
+
+/** Cancela el cambio de correo electrónico pendiente de un usuario
+ *
+ * @param id string "El id del usuario"
+ * @return err error "ErrEmailChangeNotFound si no hay un cambio pendiente"
+ */
+func (service *EmailChangeService) CancelPendingEmailChange(userId string) (err error) {
+	if _, err = service.GetPendingEmailChange(userId); err != nil {
+		return
+	}
+
+	id, _ := primitive.ObjectIDFromHex(userId)
+	return service.cancelPending(id)
+}
+
+/** Confirma un cambio de correo electrónico con el token enviado a la dirección nueva. Se vuelve a verificar
+ * que la dirección no esté en uso. Las sesiones del usuario pasan a la dirección nueva y siguen siendo válidas
+ *
+ * @param token string "El token del enlace de confirmación"
+ * @return UpdateUserResponse "El usuario actualizado"
+ * @return err error "El error de la operación"
+ */
+func (service *EmailChangeService) ConfirmEmailChange(token string) (response UpdateUserResponse, err error) {
+	collection := service.db.Collection("email_changes")
+	var change models.EmailChange
+
+	now := time.Now()
+	filter := bson.M{
+		"confirm_token_hash": utils.HashToken(token),
+		"status":             EmailChangeStatusPending,
+		"expires_at":         bson.M{"$gt": now},
+	}
+	err = collection.FindOne(ctx, filter).Decode(&change)
+	if err == mongo.ErrNoDocuments {
+		err = ErrEmailChangeNotFound
+	}
+	if err != nil {
+		return
+	}
+
+	if err = service.checkEmailAvailable(change.NewEmail, change.UserID); err != nil {
+		return
+	}
+
+	result, err := collection.UpdateOne(ctx, bson.M{"_id": change.ID, "status": EmailChangeStatusPending}, bson.M{
+		"$set": bson.M{"status": EmailChangeStatusConfirmed, "finished_at": now},
+	})
+	if err != nil {
+		return
+	}
+	if result.MatchedCount == 0 {
+		err = ErrEmailChangeNotFound
+		return
+	}
+
+	if response.User, err = service.replaceUserEmail(change.UserID, change.OldEmail, change.NewEmail); err != nil {
+		// El cambio vuelve a quedar pendiente para poder confirmarlo de nuevo
+		service.resetEmailChangeStatus(change)
+		return
+	}
+
+	if err = migrateUserSessions(service.db, change.OldEmail, change.NewEmail); err != nil {
+		return
+	}
+
+	// El aviso es informativo: si falla, el cambio ya está hecho
+	service.mailer.Send(mailer.Message{
+		To:      change.OldEmail,
+		Subject: "Se cambió tu correo electrónico",
+		Body: fmt.Sprintf("El correo electrónico de tu cuenta ahora es %s.\n\nSi no fuiste tú, puedes deshacer el cambio hasta el %s con el enlace que te enviamos al solicitarlo.",
+			change.NewEmail, change.ExpiresAt.Format(time.RFC1123)),
+	})
+
+	return
+}
+
+/** Cancela un cambio de correo electrónico con el token enviado a la dirección anterior. Si el cambio ya se
+ * confirmó y el enlace no venció, el usuario vuelve a la dirección anterior y todas sus sesiones se bloquean
+ *
+ * @param token string "El token del enlace de cancelación"
+ * @return err error "El error de la operación"
+ */
+func (service *EmailChangeService) CancelEmailChange(token string) (err error) {
+	collection := service.db.Collection("email_changes")
+	var change models.EmailChange
+
+	now := time.Now()
+	filter := bson.M{
+		"cancel_token_hash": utils.HashToken(token),
+		"$or": bson.A{
+			bson.M{"status": EmailChangeStatusPending},
+			bson.M{"status": EmailChangeStatusConfirmed, "expires_at": bson.M{"$gt": now}},
+		},
+	}
+	err = collection.FindOne(ctx, filter).Decode(&change)
+	if err == mongo.ErrNoDocuments {
+		err = ErrEmailChangeNotFound
+	}
+	if err != nil {
+		return
+	}
+
+	status := EmailChangeStatusCancelled
+	if change.Status == EmailChangeStatusConfirmed {
+		status = EmailChangeStatusReverted
+
+		if err = service.checkEmailAvailable(change.OldEmail, change.UserID); err != nil {
+			return
+		}
+	}
+
+	result, err := collection.UpdateOne(ctx, bson.M{"_id": change.ID, "status": change.Status}, bson.M{
+		"$set": bson.M{"status": status, "finished_at": now},
+	})
+	if err != nil {
+		return
+	}
+	if result.MatchedCount == 0 {
+		err = ErrEmailChangeNotFound
+		return
+	}
+
+	if status == EmailChangeStatusCancelled {
+		return
+	}
+
+	if _, err = service.replaceUserEmail(change.UserID, change.NewEmail, change.OldEmail); err != nil {
+		// El cambio vuelve a quedar confirmado para poder deshacerlo de nuevo
+		service.resetEmailChangeStatus(change)
+		return
+	}
+
+	// Quien confirmó el cambio pudo haber iniciado sesión con la dirección nueva
+	return blockUserSessions(service.db, change.NewEmail)
+}
+
+// Devuelve un cambio de correo electrónico al estado que tenía antes de intentar completarlo
+func (service *EmailChangeService) resetEmailChangeStatus(change models.EmailChange) {
+	update := bson.M{"$set": bson.M{"status": change.Status}, "$unset": bson.M{"finished_at": ""}}
+	if change.FinishedAt != nil {
+		update = bson.M{"$set": bson.M{"status": change.Status, "finished_at": change.FinishedAt}}
+	}
+
+	service.db.Collection("email_changes").UpdateOne(ctx, bson.M{"_id": change.ID}, update)
+}
+
+// Reemplaza el correo electrónico de un usuario, sólo si todavía tiene el correo esperado. El cambio lo hace
+// quien usó el token enviado a la dirección que queda, por eso la versión se registra a su nombre
+func (service *EmailChangeService) replaceUserEmail(id primitive.ObjectID, from string, to string) (user models.User, err error) {
+	collection := service.db.Collection("users")
+
+	filter := bson.M{"_id": id, "email": from, "deleted_at": nil}
+	if err = collection.FindOne(ctx, filter).Decode(&user); err != nil {
+		if err == mongo.ErrNoDocuments {
+			err = ErrUserNotFound
+		}
+		return
+	}
+
+	user.Email = to
+	terms, err := userSearchTerms(service.db, user)
+	if err != nil {
+		return
+	}
+
+	update := bson.M{
+		"$set": bson.M{"email": to, "search_terms": terms, "updated_at": time.Now()},
+		"$inc": bson.M{"version": 1},
+	}
+	opts := options.FindOneAndUpdate().
+		SetReturnDocument(options.After).
+		SetProjection(bson.M{"password": 0})
+
+	err = collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		err = ErrUserNotFound
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		err = ErrEmailTaken
+	}
+	if err != nil {
+		return
+	}
+
+	err = recordUserVersion(service.db, id, UserVersionEmail, to)
+	return
+}
+
+func (service *EmailChangeService) findUser(userId string) (user models.User, err error) {
+	id, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		err = ErrUserNotFound
+		return
+	}
+
+	err = service.db.Collection("users").FindOne(ctx, bson.M{"_id": id, "deleted_at": nil}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		err = ErrUserNotFound
+	}
+	return
+}
+
+func (service *EmailChangeService) checkEmailAvailable(email string, userID primitive.ObjectID) error {
+	count, err := service.db.Collection("users").CountDocuments(ctx, bson.M{"email": email, "_id": bson.M{"$ne": userID}})
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrEmailTaken
+	}
+
+	return nil
+}
+
+func (service *EmailChangeService) cancelPending(userID primitive.ObjectID) error {
+	filter := bson.M{"user_id": userID, "status": EmailChangeStatusPending}
+	update := bson.M{"$set": bson.M{"status": EmailChangeStatusCancelled, "finished_at": time.Now()}}
+
+	_, err := service.db.Collection("email_changes").UpdateMany(ctx, filter, update)
+	return err
+}
+
+// Envía el enlace de confirmación a la dirección nueva y el aviso con el enlace de cancelación a la anterior
+func (service *EmailChangeService) sendRequestMessages(change models.EmailChange, confirmToken string, cancelToken string) error {
+	expires := change.ExpiresAt.Format(time.RFC1123)
+
+	err := service.mailer.Send(mailer.Message{
+		To:      change.NewEmail,
+		Subject: "Confirma tu nuevo correo electrónico",
+		Body: fmt.Sprintf("Para usar esta dirección en tu cuenta, abre el siguiente enlace antes del %s:\n\n%s\n\nSi no solicitaste el cambio, ignora este correo.",
+			expires, service.link("confirm", confirmToken)),
+	})
+	if err != nil {
+		return err
+	}
+
+	return service.mailer.Send(mailer.Message{
+		To:      change.OldEmail,
+		Subject: "Solicitud de cambio de correo electrónico",
+		Body: fmt.Sprintf("Se solicitó cambiar el correo electrónico de tu cuenta a %s.\n\nSi no fuiste tú, cancela el cambio antes del %s con el siguiente enlace:\n\n%s",
+			change.NewEmail, expires, service.link("cancel", cancelToken)),
+	})
+}
+
+func (service *EmailChangeService) link(action string, token string) string {
+	return fmt.Sprintf("%s/email-change/%s?token=%s", strings.TrimRight(service.appURL, "/"), action, token)
+}
+
+func NewEmailChangeService(db *mongo.Database, mail mailer.IMailer, appURL string, ttl time.Duration) IEmailChangeService {
+	if ttl <= 0 {
+		ttl = defaultEmailChangeTTL
+	}
+
+	return &EmailChangeService{db: db, mailer: mail, appURL: appURL, ttl: ttl}
+}
@@ -0,0 +1,356 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/maramal/user-service/mailer"
+	"github.com/maramal/user-service/models"
+	"github.com/maramal/user-service/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	InvitationStatusPending  = "pending"
+	InvitationStatusAccepted = "accepted"
+	InvitationStatusRevoked  = "revoked"
+
+	// Validez de los enlaces si no se configura INVITATION_TTL
+	defaultInvitationTTL = 7 * 24 * time.Hour
+)
+
+var ErrInvitationNotFound = errors.New("no se encontró la invitación o el enlace venció")
+
+type CreateInvitationRequest struct {
+	Email     string `json:"email" binding:"required"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Type      string `json:"type"`
+	// Fecha en que vence la cuenta, para usuarios temporales
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type AcceptInvitationRequest struct {
+	// Token recibido en el enlace del correo
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+	// Si se indican, reemplazan el nombre y el apellido cargados por el administrador
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+}
+
+type InvitationResponse struct {
+	Invitation models.Invitation `json:"invitation"`
+}
+
+type GetInvitationsResponse struct {
+	Invitations []models.Invitation `json:"invitations"`
+}
+
+type IInvitationService interface {
+	CreateInvitation(req CreateInvitationRequest, invitedBy string) (response InvitationResponse, err error)
+	GetPendingInvitations() (response GetInvitationsResponse, err error)
+	ResendInvitation(invitationId string) (response InvitationResponse, err error)
+	RevokeInvitation(invitationId string, revokedBy string) (err error)
+	AcceptInvitation(req AcceptInvitationRequest) (response UpdateUserResponse, err error)
+}
+
+type InvitationService struct {
+	db     *mongo.Database
+	mailer mailer.IMailer
+	appURL string
+	ttl    time.Duration
+}
+
+/** Invita a un usuario: lo crea en el estado invited, sin contraseña, y le envía un enlace para aceptar la
+ * invitación, en el que elige su contraseña
+ *
+ * @param req CreateInvitationRequest "El correo electrónico y los datos iniciales del usuario"
+ * @param invitedBy string "El correo electrónico de quien invita"
+ * @return InvitationResponse "La invitación creada"
+ * @return err error "El error de la operación"
+ */
+func (service *InvitationService) CreateInvitation(req CreateInvitationRequest, invitedBy string) (response InvitationResponse, err error) {
+	users := service.db.Collection("users")
+	invitations := service.db.Collection("invitations")
+
+	if req.Email, err = normalizeEmail(req.Email); err != nil {
+		return
+	}
+	if req.Type == "" {
+		req.Type = defaultUserType
+	}
+	if _, ok := rolePermissions[req.Type]; !ok {
+		err = fmt.Errorf("%w: el tipo de usuario \"%s\" no existe", ErrInvalidUserData, req.Type)
+		return
+	}
+	if err = validateExpiration(req.ExpiresAt); err != nil {
+		return
+	}
+
+	count, err := users.CountDocuments(ctx, bson.M{"email": req.Email})
+	if err != nil {
+		return
+	}
+	if count > 0 {
+		err = ErrEmailTaken
+		return
+	}
+
+	now := time.Now()
+	user := models.User{
+		FirstName: strings.TrimSpace(req.FirstName),
+		LastName:  strings.TrimSpace(req.LastName),
+		Email:     req.Email,
+		Type:      req.Type,
+		Status:    models.UserStatusInvited,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Version:   1,
+		ExpiresAt: req.ExpiresAt,
+	}
+	user.SortFirstName, user.SortLastName = utils.NormalizeSearchText(user.FirstName), utils.NormalizeSearchText(user.LastName)
+	if user.SearchTerms, err = userSearchTerms(service.db, user); err != nil {
+		return
+	}
+
+	result, err := users.InsertOne(ctx, user)
+	if mongo.IsDuplicateKeyError(err) {
+		err = ErrEmailTaken
+	}
+	if err != nil {
+		return
+	}
+	user.ID = result.InsertedID.(primitive.ObjectID)
+	if err = recordUserVersion(service.db, user.ID, UserVersionCreate, invitedBy); err != nil {
+		return
+	}
+
+	token, err := utils.NewSecureToken()
+	if err != nil {
+		return
+	}
+
+	invitation := models.Invitation{
+		ID:        primitive.NewObjectID(),
+		UserID:    user.ID,
+		Email:     user.Email,
+		TokenHash: utils.HashToken(token),
+		Status:    InvitationStatusPending,
+		InvitedBy: invitedBy,
+		CreatedAt: now,
+		SentAt:    now,
+		ExpiresAt: now.Add(service.ttl),
+	}
+
+	if _, err = invitations.InsertOne(ctx, invitation); err == nil {
+		err = service.sendInvitation(invitation, token)
+	}
+	if err != nil {
+		// Sin la invitación el usuario no puede ingresar: se descarta para poder invitarlo de nuevo
+		invitations.DeleteOne(ctx, bson.M{"_id": invitation.ID})
+		users.DeleteOne(ctx, bson.M{"_id": user.ID, "status": models.UserStatusInvited})
+		return
+	}
+
+	response.Invitation = invitation
+	return
+}
+
+/** Obtiene las invitaciones pendientes, incluso las vencidas, que se pueden reenviar
+ *
+ * @return GetInvitationsResponse "Las invitaciones, de la más reciente a la más antigua"
+ * @return err error "El error de la operación"
+ */
+func (service *InvitationService) GetPendingInvitations() (response GetInvitationsResponse, err error) {
+	opts := options.Find().SetSort(bson.M{"created_at": -1})
+
+	response.Invitations = []models.Invitation{}
+	err = findAll(service.db.Collection("invitations"), bson.M{"status": InvitationStatusPending}, opts, &response.Invitations)
+	return
+}
+
+/** Reenvía una invitación pendiente con un enlace nuevo. El enlace anterior deja de ser válido y el
+ * vencimiento se cuenta desde el reenvío
+ *
+ * @param invitationId string "El id de la invitación"
+ * @return InvitationResponse "La invitación actualizada"
+ * @return err error "ErrInvitationNotFound si la invitación no existe o no está pendiente"
+ */
+func (service *InvitationService) ResendInvitation(invitationId string) (response InvitationResponse, err error) {
+	id, err := primitive.ObjectIDFromHex(invitationId)
+	if err != nil {
+		err = ErrInvitationNotFound
+		return
+	}
+
+	token, err := utils.NewSecureToken()
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	filter := bson.M{"_id": id, "status": InvitationStatusPending}
+	update := bson.M{
+		"$set": bson.M{"token_hash": utils.HashToken(token), "sent_at": now, "expires_at": now.Add(service.ttl)},
+		"$inc": bson.M{"resend_count": 1},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	err = service.db.Collection("invitations").FindOneAndUpdate(ctx, filter, update, opts).Decode(&response.Invitation)
+	if err == mongo.ErrNoDocuments {
+		err = ErrInvitationNotFound
+	}
+	if err != nil {
+		return
+	}
+
+	err = service.sendInvitation(response.Invitation, token)
+	return
+}
+
+/** Revoca una invitación pendiente. El enlace deja de ser válido y el usuario invitado se elimina
+ *
+ * @param invitationId string "El id de la invitación"
+ * @param revokedBy string "El correo electrónico de quien revoca la invitación"
+ * @return err error "ErrInvitationNotFound si la invitación no existe o no está pendiente"
+ */
+func (service *InvitationService) RevokeInvitation(invitationId string, revokedBy string) (err error) {
+	id, err := primitive.ObjectIDFromHex(invitationId)
+	if err != nil {
+		return ErrInvitationNotFound
+	}
+
+	now := time.Now()
+	filter := bson.M{"_id": id, "status": InvitationStatusPending}
+	update := bson.M{"$set": bson.M{"status": InvitationStatusRevoked, "finished_at": now, "revoked_by": revokedBy}}
+
+	var invitation models.Invitation
+	err = service.db.Collection("invitations").FindOneAndUpdate(ctx, filter, update).Decode(&invitation)
+	if err == mongo.ErrNoDocuments {
+		err = ErrInvitationNotFound
+	}
+	if err != nil {
+		return
+	}
+
+	extra := bson.M{"deleted_at": now, "deleted_by": revokedBy}
+	_, err = transitionUserStatus(service.db, invitation.UserID, nil, models.UserStatusDeleted, "invitación revocada", revokedBy, extra)
+	// Si el usuario ya no está invitado (por ejemplo, un administrador lo activó) se conserva
+	if errors.Is(err, ErrInvalidStatusTransition) || errors.Is(err, ErrUserNotFound) {
+		err = nil
+	}
+	return
+}
+
+/** Acepta una invitación con el token del enlace: el usuario elige su contraseña, puede corregir su
+ * nombre y pasa al estado active
+ *
+ * @param req AcceptInvitationRequest "El token, la contraseña y, opcionalmente, el nombre y el apellido"
+ * @return UpdateUserResponse "El usuario activado"
+ * @return err error "El error de la operación"
+ */
+func (service *InvitationService) AcceptInvitation(req AcceptInvitationRequest) (response UpdateUserResponse, err error) {
+	collection := service.db.Collection("invitations")
+	var invitation models.Invitation
+
+	if len(req.Password) < minPasswordLength {
+		err = fmt.Errorf("%w: la contraseña debe tener al menos %d caracteres", ErrInvalidUserData, minPasswordLength)
+		return
+	}
+
+	now := time.Now()
+	filter := bson.M{
+		"token_hash": utils.HashToken(req.Token),
+		"status":     InvitationStatusPending,
+		"expires_at": bson.M{"$gt": now},
+	}
+	err = collection.FindOne(ctx, filter).Decode(&invitation)
+	if err == mongo.ErrNoDocuments {
+		err = ErrInvitationNotFound
+	}
+	if err != nil {
+		return
+	}
+
+	var user models.User
+	err = service.db.Collection("users").FindOne(ctx, bson.M{"_id": invitation.UserID, "status": models.UserStatusInvited, "deleted_at": nil}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		err = ErrInvitationNotFound
+	}
+	if err != nil {
+		return
+	}
+
+	if firstName := strings.TrimSpace(req.FirstName); firstName != "" {
+		user.FirstName = firstName
+	}
+	if lastName := strings.TrimSpace(req.LastName); lastName != "" {
+		user.LastName = lastName
+	}
+
+	password, err := utils.HashPassword(req.Password)
+	if err != nil {
+		return
+	}
+	terms, err := userSearchTerms(service.db, user)
+	if err != nil {
+		return
+	}
+
+	result, err := collection.UpdateOne(ctx, bson.M{"_id": invitation.ID, "status": InvitationStatusPending}, bson.M{
+		"$set": bson.M{"status": InvitationStatusAccepted, "finished_at": now},
+	})
+	if err != nil {
+		return
+	}
+	if result.MatchedCount == 0 {
+		err = ErrInvitationNotFound
+		return
+	}
+
+	extra := bson.M{
+		"first_name":          user.FirstName,
+		"last_name":           user.LastName,
+		"password":            password,
+		"password_changed_at": now,
+		"search_terms":        terms,
+	}
+	setUserSortNames(extra, user)
+	if _, err = transitionUserStatus(service.db, user.ID, nil, models.UserStatusActive, "invitación aceptada", user.Email, extra); err != nil {
+		// La invitación vuelve a quedar pendiente para poder aceptarla de nuevo
+		collection.UpdateOne(ctx, bson.M{"_id": invitation.ID}, bson.M{
+			"$set":   bson.M{"status": InvitationStatusPending},
+			"$unset": bson.M{"finished_at": ""},
+		})
+		return
+	}
+
+	opts := options.FindOne().SetProjection(bson.M{"password": 0})
+	err = service.db.Collection("users").FindOne(ctx, bson.M{"_id": user.ID}, opts).Decode(&response.User)
+	return
+}
+
+func (service *InvitationService) sendInvitation(invitation models.Invitation, token string) error {
+	link := fmt.Sprintf("%s/invitations/accept?token=%s", strings.TrimRight(service.appURL, "/"), token)
+
+	return service.mailer.Send(mailer.Message{
+		To:      invitation.Email,
+		Subject: "Te invitaron a crear tu cuenta",
+		Body: fmt.Sprintf("%s te invitó a crear tu cuenta. Para elegir tu contraseña y completar tu perfil, abre el siguiente enlace antes del %s:\n\n%s\n\nSi no esperabas esta invitación, ignora este correo.",
+			invitation.InvitedBy, invitation.ExpiresAt.Format(time.RFC1123), link),
+	})
+}
+
+func NewInvitationService(db *mongo.Database, mail mailer.IMailer, appURL string, ttl time.Duration) IInvitationService {
+	if ttl <= 0 {
+		ttl = defaultInvitationTTL
+	}
+
+	return &InvitationService{db: db, mailer: mail, appURL: appURL, ttl: ttl}
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"time"
+
+	"github.com/maramal/user-service/models"
+	"github.com/maramal/user-service/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Resultados de los intentos de ingreso
+const (
+	LoginResultSuccess = "success"
+	// La contraseña no es correcta
+	LoginResultWrongPassword = "wrong_password"
+	// El estado del usuario no le permite ingresar: bloqueado, suspendido, vencido, etc.
+	LoginResultLocked = "locked"
+	// Se solicitaron alcances que el usuario no tiene
+	LoginResultInvalidScope = "invalid_scope"
+	// La contraseña es correcta y se envió el código del segundo factor por SMS
+	LoginResultMFARequired = "mfa_required"
+	// El código del segundo factor no es correcto o venció
+	LoginResultMFAFailed = "mfa_failed"
+)
+
+// Pasos del ingreso
+const (
+	LoginMethodEmail = "email"
+	LoginMethodPhone = "phone"
+	LoginMethodMFA   = "mfa"
+)
+
+type RecordLoginParams struct {
+	UserID    primitive.ObjectID
+	Method    string
+	Result    string
+	Reason    string
+	ClientIP  string
+	UserAgent string
+	SessionID primitive.ObjectID
+}
+
+type GetLoginEventsRequest struct {
+	// Cantidad de intentos por página (máximo 200)
+	Limit int `form:"limit"`
+	// Cursor obtenido de next_cursor de una respuesta anterior
+	Cursor string   `form:"cursor"`
+	Result []string `form:"result"`
+}
+
+type GetLoginEventsResponse struct {
+	Events     []models.LoginEvent `json:"events"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
+/** Registra un intento de ingreso de un usuario. Si fue exitoso, actualiza la fecha del último ingreso y la
+ * cantidad de ingresos del usuario
+ *
+ * @param params RecordLoginParams "El usuario, el resultado y el origen del intento"
+ * @return error "El error de la operación"
+ */
+func (service *AuthService) RecordLogin(params RecordLoginParams) error {
+	now := time.Now()
+
+	browser, system, deviceType := utils.ParseUserAgent(params.UserAgent)
+	event := models.LoginEvent{
+		UserID:     params.UserID,
+		Method:     params.Method,
+		Result:     params.Result,
+		Reason:     params.Reason,
+		ClientIP:   params.ClientIP,
+		UserAgent:  params.UserAgent,
+		Device:     models.LoginDevice{Browser: browser, OS: system, Type: deviceType},
+		SessionID:  params.SessionID,
+		OccurredAt: now,
+	}
+	if _, err := service.db.Collection("login_events").InsertOne(ctx, event); err != nil {
+		return err
+	}
+
+	if params.Result != LoginResultSuccess {
+		return nil
+	}
+
+	// No es una modificación del usuario: no cambia su versión
+	update := bson.M{"$set": bson.M{"last_login_at": now}, "$inc": bson.M{"login_count": 1}}
+	_, err := service.db.Collection("users").UpdateOne(ctx, bson.M{"_id": params.UserID}, update)
+	return err
+}
+
+/** Obtiene una página de los intentos de ingreso de un usuario, del más reciente al más antiguo
+ *
+ * @param userId string "El id del usuario"
+ * @param req GetLoginEventsRequest "Los filtros y la página"
+ * @return GetLoginEventsResponse "Los intentos y el cursor de la página siguiente"
+ * @return err error "El error de la operación"
+ */
+func (service *AuthService) GetLoginEvents(userId string, req GetLoginEventsRequest) (response GetLoginEventsResponse, err error) {
+	id, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		err = ErrUserNotFound
+		return
+	}
+
+	filter := bson.M{"user_id": id}
+	if len(req.Result) > 0 {
+		filter["result"] = bson.M{"$in": req.Result}
+	}
+
+	response.NextCursor, err = findEventsPage(service.db.Collection("login_events"), filter, req.Limit, req.Cursor, &response.Events)
+	return
+}
@@ -0,0 +1,226 @@
+package services
+
+import (
+	"log"
+	"time"
+
+	"github.com/maramal/user-service/models"
+	"github.com/maramal/user-service/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+/** Ejecuta las migraciones de datos pendientes. Cada migración debe poder ejecutarse más de una vez
+ *
+ * @param db *mongo.Database "La base de datos"
+ * @return err error "El error de la operación"
+ */
+func RunMigrations(db *mongo.Database) (err error) {
+	migrations := []func(db *mongo.Database) error{
+		migrateSearchTerms,
+		migrateSortNames,
+		migrateUserVersions,
+		migrateUserStatuses,
+		migrateUserEmails,
+	}
+
+	for _, migration := range migrations {
+		if err = migration(db); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// Calcula los términos de búsqueda de los usuarios creados antes de que existieran
+func migrateSearchTerms(db *mongo.Database) (err error) {
+	collection := db.Collection("users")
+
+	cursor, err := collection.Find(ctx, bson.M{"search_terms": bson.M{"$exists": false}})
+	if err != nil {
+		return
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var user models.User
+		if err = cursor.Decode(&user); err != nil {
+			return
+		}
+
+		terms := utils.UserSearchTerms(user.FirstName, user.LastName, user.Email)
+		update := bson.M{"$set": bson.M{"search_terms": terms}}
+		if _, err = collection.UpdateByID(ctx, user.ID, update); err != nil {
+			return
+		}
+	}
+
+	return cursor.Err()
+}
+
+// Calcula el nombre y el apellido normalizados con los que se ordena la búsqueda de los usuarios creados
+// antes de que existieran
+func migrateSortNames(db *mongo.Database) (err error) {
+	collection := db.Collection("users")
+
+	filter := bson.M{"sort_last_name": bson.M{"$exists": false}, "erased_at": nil}
+	opts := options.Find().SetProjection(bson.M{"first_name": 1, "last_name": 1})
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var user models.User
+		if err = cursor.Decode(&user); err != nil {
+			return
+		}
+
+		set := bson.M{}
+		setUserSortNames(set, user)
+		if _, err = collection.UpdateByID(ctx, user.ID, bson.M{"$set": set}); err != nil {
+			return
+		}
+	}
+
+	return cursor.Err()
+}
+
+// Asigna la versión inicial a los usuarios creados antes de que existiera el control de versiones
+func migrateUserVersions(db *mongo.Database) (err error) {
+	collection := db.Collection("users")
+
+	filter := bson.M{"version": bson.M{"$exists": false}}
+	_, err = collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"version": 1}})
+	return
+}
+
+// Adapta los estados libres de los usuarios existentes al ciclo de vida: los usuarios sin estado pasan a
+// activos, los eliminados a deleted y los estados desconocidos a deactivated
+func migrateUserStatuses(db *mongo.Database) (err error) {
+	collection := db.Collection("users")
+
+	migrations := []struct {
+		filter bson.M
+		set    bson.M
+	}{
+		{
+			bson.M{"deleted_at": bson.M{"$ne": nil}, "status": bson.M{"$ne": models.UserStatusDeleted}},
+			bson.M{"status": models.UserStatusDeleted},
+		},
+		{
+			bson.M{"deleted_at": nil, "status": bson.M{"$in": bson.A{nil, ""}}},
+			bson.M{"status": models.UserStatusActive},
+		},
+		{
+			bson.M{"deleted_at": nil, "status": bson.M{"$nin": userStatuses}},
+			bson.M{"status": models.UserStatusDeactivated, "status_reason": "estado no reconocido al migrar"},
+		},
+	}
+
+	for _, migration := range migrations {
+		if _, err = collection.UpdateMany(ctx, migration.filter, bson.M{"$set": migration.set}); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// Normaliza los correos electrónicos guardados antes de que se normalizaran (ver utils.NormalizeEmail) y pasa sus
+// sesiones a la dirección normalizada. Si varios usuarios tienen la misma dirección normalizada, sólo la recibe
+// uno (el que ya la tiene o el más antiguo); los demás conservan su correo y la colisión se registra en
+// email_collisions para que la resuelva un administrador. Las colisiones resueltas se eliminan del registro
+func migrateUserEmails(db *mongo.Database) (err error) {
+	collection := db.Collection("users")
+
+	opts := options.Find().
+		SetProjection(bson.M{"email": 1, "created_at": 1, "deleted_at": 1}).
+		SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}})
+
+	cursor, err := collection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return
+	}
+
+	var users []models.User
+	if err = cursor.All(ctx, &users); err != nil {
+		return
+	}
+
+	var emails []string
+	groups := map[string][]models.User{}
+	for _, user := range users {
+		// Los correos que no son válidos se dejan como están
+		normalized, normalizeErr := utils.NormalizeEmail(user.Email)
+		if normalizeErr != nil {
+			normalized = user.Email
+		}
+
+		if _, ok := groups[normalized]; !ok {
+			emails = append(emails, normalized)
+		}
+		groups[normalized] = append(groups[normalized], user)
+	}
+
+	collisions := []interface{}{}
+	for _, email := range emails {
+		group := groups[email]
+
+		owner := 0
+		for i, user := range group {
+			if user.Email == email {
+				owner = i
+				break
+			}
+		}
+
+		if group[owner].Email != email {
+			_, updateErr := collection.UpdateByID(ctx, group[owner].ID, bson.M{"$set": bson.M{"email": email}})
+			switch {
+			case mongo.IsDuplicateKeyError(updateErr):
+				owner = -1
+			case updateErr != nil:
+				return updateErr
+			default:
+				if err = migrateUserSessions(db, group[owner].Email, email); err != nil {
+					return
+				}
+				group[owner].Email = email
+			}
+		}
+
+		if len(group) > 1 {
+			collisions = append(collisions, newEmailCollision(email, group, owner))
+		}
+	}
+
+	if _, err = db.Collection("email_collisions").DeleteMany(ctx, bson.M{}); err != nil {
+		return
+	}
+	if len(collisions) == 0 {
+		return
+	}
+
+	log.Printf("Hay %d correos electrónicos compartidos por varios usuarios. Ver GET /api/admin/users/email-collisions", len(collisions))
+	_, err = db.Collection("email_collisions").InsertMany(ctx, collisions)
+	return
+}
+
+func newEmailCollision(email string, users []models.User, owner int) models.EmailCollision {
+	collision := models.EmailCollision{Email: email, DetectedAt: time.Now()}
+	for i, user := range users {
+		collision.Users = append(collision.Users, models.EmailCollisionUser{
+			UserID:    user.ID,
+			Email:     user.Email,
+			Owner:     i == owner,
+			CreatedAt: user.CreatedAt,
+			DeletedAt: user.DeletedAt,
+		})
+	}
+
+	return collision
+}
@@ -0,0 +1,559 @@
+package services
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/maramal/user-service/models"
+	"github.com/maramal/user-service/sms"
+	"github.com/maramal/user-service/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	PhoneCodePurposeVerify = "verify"
+	PhoneCodePurposeLogin  = "login"
+
+	phoneCodeDigits = 6
+	// Intentos permitidos por código. Al agotarlos hay que pedir otro
+	phoneCodeMaxAttempts = 5
+
+	// Valores por defecto si no se configuran SMS_CODE_TTL, SMS_RESEND_INTERVAL y SMS_MAX_PER_HOUR
+	defaultPhoneCodeTTL        = 10 * time.Minute
+	defaultPhoneResendInterval = time.Minute
+	defaultPhoneMaxPerHour     = 5
+)
+
+var (
+	ErrPhoneTaken          = errors.New("el teléfono ya está asociado a otro usuario")
+	ErrPhoneNotVerified    = errors.New("el usuario no tiene un teléfono verificado")
+	ErrPhoneAmbiguous      = errors.New("el teléfono está asociado a más de un usuario, ingresa con el correo electrónico")
+	ErrPhoneCodeInvalid    = errors.New("el código no es correcto o venció")
+	ErrPhoneCodeThrottled  = errors.New("se enviaron demasiados códigos")
+	ErrPhoneCodeNotPending = errors.New("no hay un código pendiente, solicita uno nuevo")
+)
+
+type StartPhoneVerificationRequest struct {
+	Phone           string `json:"phone" binding:"required"`
+	CurrentPassword string `json:"current_password" binding:"required"`
+}
+
+type ConfirmPhoneVerificationRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type RemoveOwnPhoneRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+}
+
+type SetPhoneMFARequest struct {
+	Enabled         *bool  `json:"enabled" binding:"required"`
+	CurrentPassword string `json:"current_password" binding:"required"`
+}
+
+type PhoneVerificationResponse struct {
+	// Teléfono al que se envió el código, oculto salvo los últimos dígitos
+	Phone     string    `json:"phone"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Desafío del segundo factor del ingreso. El token identifica el desafío al enviar el código
+type PhoneLoginChallenge struct {
+	Token     string    `json:"mfa_token"`
+	Phone     string    `json:"phone"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Configuración de los códigos enviados por SMS
+type PhoneServiceConfig struct {
+	// Si es verdadero, un teléfono verificado no puede estar asociado a más de un usuario
+	Unique bool
+	// Validez de cada código
+	CodeTTL time.Duration
+	// Tiempo mínimo entre dos envíos al mismo usuario o teléfono
+	ResendInterval time.Duration
+	// Envíos permitidos por hora al mismo usuario o teléfono
+	MaxPerHour int
+}
+
+type IPhoneService interface {
+	StartPhoneVerification(userId string, req StartPhoneVerificationRequest) (response PhoneVerificationResponse, err error)
+	ConfirmPhoneVerification(userId string, code string) (response UpdateUserResponse, err error)
+	RemovePhone(userId string, changedBy string) (response UpdateUserResponse, err error)
+	RemoveOwnPhone(userId string, req RemoveOwnPhoneRequest) (response UpdateUserResponse, err error)
+	SetPhoneMFA(userId string, req SetPhoneMFARequest) (response UpdateUserResponse, err error)
+	GetUserByPhone(phone string) (user models.User, err error)
+	StartLoginChallenge(user models.User) (challenge PhoneLoginChallenge, err error)
+	VerifyLoginChallenge(token string, code string) (user models.User, err error)
+}
+
+type PhoneService struct {
+	db     *mongo.Database
+	sender sms.ISender
+	config PhoneServiceConfig
+}
+
+/** Inicia la verificación de un teléfono del propio usuario, después de verificar su contraseña. Se envía un
+ * código por SMS al teléfono; el teléfono del usuario no cambia hasta que se confirma el código
+ *
+ * @param userId string "El id del usuario"
+ * @param req StartPhoneVerificationRequest "El teléfono y la contraseña actual"
+ * @return PhoneVerificationResponse "El teléfono oculto y el vencimiento del código"
+ * @return err error "El error de la operación"
+ */
+func (service *PhoneService) StartPhoneVerification(userId string, req StartPhoneVerificationRequest) (response PhoneVerificationResponse, err error) {
+	user, err := service.findUser(userId)
+	if err != nil {
+		return
+	}
+	if err = checkCurrentPassword(user, req.CurrentPassword); err != nil {
+		return
+	}
+
+	phone, err := utils.NormalizePhone(req.Phone)
+	if err != nil {
+		err = fmt.Errorf("%w: %s", ErrInvalidUserData, err)
+		return
+	}
+	if phone == user.Phone {
+		err = fmt.Errorf("%w: el teléfono ya está verificado", ErrInvalidUserData)
+		return
+	}
+	if err = service.checkPhoneAvailable(phone, user.ID); err != nil {
+		return
+	}
+
+	code, _, err := service.sendCode(user.ID, phone, PhoneCodePurposeVerify, false)
+	if err != nil {
+		return
+	}
+
+	response.Phone = utils.MaskPhone(phone)
+	response.ExpiresAt = code.ExpiresAt
+	return
+}
+
+/** Confirma la verificación de un teléfono con el código enviado por SMS. El teléfono pasa a ser el del
+ * usuario y reemplaza al anterior; si el usuario usaba el segundo factor por SMS, los códigos se envían al
+ * teléfono nuevo
+ *
+ * @param userId string "El id del usuario"
+ * @param code string "El código recibido"
+ * @return UpdateUserResponse "El usuario actualizado"
+ * @return err error "El error de la operación"
+ */
+func (service *PhoneService) ConfirmPhoneVerification(userId string, code string) (response UpdateUserResponse, err error) {
+	user, err := service.findUser(userId)
+	if err != nil {
+		return
+	}
+
+	phoneCode, err := service.latestCode(bson.M{"user_id": user.ID, "purpose": PhoneCodePurposeVerify})
+	if err != nil {
+		return
+	}
+	if err = service.checkCode(phoneCode, code); err != nil {
+		return
+	}
+
+	// El teléfono se pudo verificar en otro usuario mientras tanto
+	if err = service.checkPhoneAvailable(phoneCode.Phone, user.ID); err != nil {
+		return
+	}
+
+	now := time.Now()
+	response.User, err = service.updateUser(bson.M{"_id": user.ID, "deleted_at": nil}, bson.M{
+		"$set": bson.M{"phone": phoneCode.Phone, "phone_verified_at": now, "updated_at": now},
+		"$inc": bson.M{"version": 1},
+	}, user.Email)
+	return
+}
+
+/** Elimina el teléfono de un usuario y desactiva el segundo factor por SMS. Sirve para recuperar el acceso
+ * de un usuario que perdió su teléfono
+ *
+ * @param userId string "El id del usuario"
+ * @param changedBy string "El correo electrónico de quien elimina el teléfono, para el historial de versiones"
+ * @return UpdateUserResponse "El usuario actualizado"
+ * @return err error "ErrPhoneNotVerified si el usuario no tiene teléfono"
+ */
+func (service *PhoneService) RemovePhone(userId string, changedBy string) (response UpdateUserResponse, err error) {
+	user, err := service.findUser(userId)
+	if err != nil {
+		return
+	}
+	if user.Phone == "" {
+		err = ErrPhoneNotVerified
+		return
+	}
+
+	response.User, err = service.updateUser(bson.M{"_id": user.ID, "deleted_at": nil}, bson.M{
+		"$set":   bson.M{"updated_at": time.Now()},
+		"$unset": bson.M{"phone": "", "phone_verified_at": "", "phone_mfa": ""},
+		"$inc":   bson.M{"version": 1},
+	}, changedBy)
+	return
+}
+
+/** Elimina el teléfono del propio usuario, después de verificar su contraseña
+ *
+ * @param userId string "El id del usuario"
+ * @param req RemoveOwnPhoneRequest "La contraseña actual"
+ * @return UpdateUserResponse "El usuario actualizado"
+ * @return err error "El error de la operación"
+ */
+func (service *PhoneService) RemoveOwnPhone(userId string, req RemoveOwnPhoneRequest) (response UpdateUserResponse, err error) {
+	user, err := service.findUser(userId)
+	if err != nil {
+		return
+	}
+	if err = checkCurrentPassword(user, req.CurrentPassword); err != nil {
+		return
+	}
+
+	return service.RemovePhone(userId, user.Email)
+}
+
+/** Activa o desactiva el segundo factor por SMS del propio usuario, después de verificar su contraseña.
+ * Para activarlo el usuario debe tener un teléfono verificado
+ *
+ * @param userId string "El id del usuario"
+ * @param req SetPhoneMFARequest "Si se activa y la contraseña actual"
+ * @return UpdateUserResponse "El usuario actualizado"
+ * @return err error "El error de la operación"
+ */
+func (service *PhoneService) SetPhoneMFA(userId string, req SetPhoneMFARequest) (response UpdateUserResponse, err error) {
+	user, err := service.findUser(userId)
+	if err != nil {
+		return
+	}
+	if err = checkCurrentPassword(user, req.CurrentPassword); err != nil {
+		return
+	}
+
+	now := time.Now()
+	filter := bson.M{"_id": user.ID, "deleted_at": nil}
+	update := bson.M{
+		"$set":   bson.M{"updated_at": now},
+		"$unset": bson.M{"phone_mfa": ""},
+		"$inc":   bson.M{"version": 1},
+	}
+
+	if *req.Enabled {
+		if user.PhoneVerifiedAt == nil {
+			err = ErrPhoneNotVerified
+			return
+		}
+
+		// El teléfono se pudo eliminar entre la consulta y la actualización
+		filter["phone_verified_at"] = bson.M{"$ne": nil}
+		update = bson.M{
+			"$set": bson.M{"phone_mfa": true, "updated_at": now},
+			"$inc": bson.M{"version": 1},
+		}
+	}
+
+	response.User, err = service.updateUser(filter, update, user.Email)
+	return
+}
+
+/** Obtiene el usuario con un teléfono verificado, para ingresar con el teléfono
+ *
+ * @param phone string "El teléfono, en cualquier formato aceptado por utils.NormalizePhone"
+ * @return models.User "El usuario"
+ * @return err error "ErrUserNotFound si ningún usuario tiene el teléfono, ErrPhoneAmbiguous si lo tiene más de uno"
+ */
+func (service *PhoneService) GetUserByPhone(phone string) (user models.User, err error) {
+	phone, err = utils.NormalizePhone(phone)
+	if err != nil {
+		err = ErrUserNotFound
+		return
+	}
+
+	filter := bson.M{"phone": phone, "phone_verified_at": bson.M{"$ne": nil}, "deleted_at": nil}
+	var users []models.User
+	if err = findAll(service.db.Collection("users"), filter, options.Find().SetLimit(2), &users); err != nil {
+		return
+	}
+
+	switch len(users) {
+	case 0:
+		err = ErrUserNotFound
+	case 1:
+		user = users[0]
+	default:
+		err = ErrPhoneAmbiguous
+	}
+	return
+}
+
+/** Inicia el segundo factor del ingreso: envía un código por SMS al teléfono verificado del usuario
+ *
+ * @param user models.User "El usuario que ingresó su contraseña"
+ * @return PhoneLoginChallenge "El token del desafío, el teléfono oculto y el vencimiento del código"
+ * @return err error "El error de la operación"
+ */
+func (service *PhoneService) StartLoginChallenge(user models.User) (challenge PhoneLoginChallenge, err error) {
+	if user.Phone == "" || user.PhoneVerifiedAt == nil {
+		err = ErrPhoneNotVerified
+		return
+	}
+
+	code, token, err := service.sendCode(user.ID, user.Phone, PhoneCodePurposeLogin, true)
+	if err != nil {
+		return
+	}
+
+	challenge = PhoneLoginChallenge{
+		Token:     token,
+		Phone:     utils.MaskPhone(user.Phone),
+		ExpiresAt: code.ExpiresAt,
+	}
+	return
+}
+
+/** Completa el segundo factor del ingreso con el código enviado por SMS
+ *
+ * @param token string "El token del desafío"
+ * @param code string "El código recibido"
+ * @return models.User "El usuario que ingresa. Si el código no es correcto, sólo tiene el id del usuario del desafío"
+ * @return err error "ErrPhoneCodeInvalid si el desafío o el código no son válidos"
+ */
+func (service *PhoneService) VerifyLoginChallenge(token string, code string) (user models.User, err error) {
+	phoneCode, err := service.latestCode(bson.M{"challenge_hash": utils.HashToken(token), "purpose": PhoneCodePurposeLogin})
+	if err == ErrPhoneCodeNotPending {
+		err = ErrPhoneCodeInvalid
+	}
+	if err != nil {
+		return
+	}
+	if err = service.checkCode(phoneCode, code); err != nil {
+		// Para registrar el intento fallido
+		user.ID = phoneCode.UserID
+		return
+	}
+
+	// El teléfono pudo cambiar o eliminarse después de enviar el código
+	filter := bson.M{"_id": phoneCode.UserID, "phone": phoneCode.Phone, "phone_mfa": true, "deleted_at": nil}
+	err = service.db.Collection("users").FindOne(ctx, filter).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		err = ErrPhoneCodeInvalid
+	}
+	return
+}
+
+/** Genera un código, lo guarda y lo envía por SMS, respetando los límites de envío por usuario y por teléfono.
+ * Los códigos anteriores del mismo propósito dejan de ser válidos
+ *
+ * @param userID primitive.ObjectID "El id del usuario"
+ * @param phone string "El teléfono en formato E.164"
+ * @param purpose string "El propósito del código"
+ * @param challenge bool "Si se genera un token de desafío para identificar el código sin sesión"
+ * @return models.PhoneCode "El código guardado"
+ * @return string "El token del desafío, si se pidió"
+ * @return err error "ErrPhoneCodeThrottled si se superó algún límite de envío"
+ */
+func (service *PhoneService) sendCode(userID primitive.ObjectID, phone string, purpose string, challenge bool) (code models.PhoneCode, token string, err error) {
+	collection := service.db.Collection("phone_codes")
+
+	if err = service.checkThrottle(userID, phone); err != nil {
+		return
+	}
+
+	value, err := utils.NewNumericCode(phoneCodeDigits)
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	code = models.PhoneCode{
+		ID:        primitive.NewObjectID(),
+		UserID:    userID,
+		Phone:     phone,
+		Purpose:   purpose,
+		CreatedAt: now,
+		ExpiresAt: now.Add(service.config.CodeTTL),
+	}
+	code.CodeHash = phoneCodeHash(code.ID, value)
+
+	if challenge {
+		if token, err = utils.NewSecureToken(); err != nil {
+			return
+		}
+		code.ChallengeHash = utils.HashToken(token)
+	}
+
+	// Sólo vale el último código de cada propósito
+	filter := bson.M{"user_id": userID, "purpose": purpose, "used_at": nil}
+	if _, err = collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"expires_at": now}}); err != nil {
+		return
+	}
+
+	if _, err = collection.InsertOne(ctx, code); err != nil {
+		return
+	}
+
+	minutes := int(math.Ceil(service.config.CodeTTL.Minutes()))
+	err = service.sender.Send(sms.Message{
+		To:   phone,
+		Body: fmt.Sprintf("Tu código de verificación es %s. Vence en %d minutos. No lo compartas con nadie.", value, minutes),
+	})
+	if err != nil {
+		// El código no llegó: se invalida, pero sigue contando para los límites de envío
+		collection.UpdateOne(ctx, bson.M{"_id": code.ID}, bson.M{"$set": bson.M{"expires_at": now}})
+	}
+	return
+}
+
+// Verifica que no se haya enviado un código al usuario o al teléfono hace muy poco, ni demasiados en la última hora
+func (service *PhoneService) checkThrottle(userID primitive.ObjectID, phone string) error {
+	collection := service.db.Collection("phone_codes")
+	now := time.Now()
+
+	recipients := bson.A{bson.M{"user_id": userID}, bson.M{"phone": phone}}
+
+	var last models.PhoneCode
+	opts := options.FindOne().SetSort(bson.M{"created_at": -1})
+	err := collection.FindOne(ctx, bson.M{"$or": recipients}, opts).Decode(&last)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return err
+	}
+	if wait := last.CreatedAt.Add(service.config.ResendInterval).Sub(now); err == nil && wait > 0 {
+		return fmt.Errorf("%w: intenta de nuevo en %d segundos", ErrPhoneCodeThrottled, int(math.Ceil(wait.Seconds())))
+	}
+
+	count, err := collection.CountDocuments(ctx, bson.M{"$or": recipients, "created_at": bson.M{"$gt": now.Add(-time.Hour)}})
+	if err != nil {
+		return err
+	}
+	if count >= int64(service.config.MaxPerHour) {
+		return fmt.Errorf("%w: intenta de nuevo más tarde", ErrPhoneCodeThrottled)
+	}
+
+	return nil
+}
+
+// Obtiene el último código vigente y sin usar que cumple el filtro
+func (service *PhoneService) latestCode(filter bson.M) (code models.PhoneCode, err error) {
+	filter["used_at"] = nil
+	filter["expires_at"] = bson.M{"$gt": time.Now()}
+	opts := options.FindOne().SetSort(bson.M{"created_at": -1})
+
+	err = service.db.Collection("phone_codes").FindOne(ctx, filter, opts).Decode(&code)
+	if err == mongo.ErrNoDocuments {
+		err = ErrPhoneCodeNotPending
+	}
+	return
+}
+
+// Compara el código recibido con el guardado. Cada comparación consume un intento; si coincide, el código se
+// marca como usado para que no se pueda volver a usar
+func (service *PhoneService) checkCode(code models.PhoneCode, value string) error {
+	collection := service.db.Collection("phone_codes")
+
+	filter := bson.M{"_id": code.ID, "used_at": nil, "attempts": bson.M{"$lt": phoneCodeMaxAttempts}}
+	result, err := collection.UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"attempts": 1}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrPhoneCodeInvalid
+	}
+
+	if subtle.ConstantTimeCompare([]byte(phoneCodeHash(code.ID, value)), []byte(code.CodeHash)) != 1 {
+		return ErrPhoneCodeInvalid
+	}
+
+	result, err = collection.UpdateOne(ctx, bson.M{"_id": code.ID, "used_at": nil}, bson.M{"$set": bson.M{"used_at": time.Now()}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrPhoneCodeInvalid
+	}
+
+	return nil
+}
+
+// Verifica que el teléfono no esté verificado en otro usuario, si los teléfonos deben ser únicos
+func (service *PhoneService) checkPhoneAvailable(phone string, userID primitive.ObjectID) error {
+	if !service.config.Unique {
+		return nil
+	}
+
+	filter := bson.M{"phone": phone, "phone_verified_at": bson.M{"$ne": nil}, "_id": bson.M{"$ne": userID}}
+	count, err := service.db.Collection("users").CountDocuments(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrPhoneTaken
+	}
+
+	return nil
+}
+
+// Verifica la contraseña actual del usuario antes de un cambio que la requiere
+func checkCurrentPassword(user models.User, password string) error {
+	if utils.CheckPassword(password, user.Password) != nil {
+		return fmt.Errorf("%w: la contraseña actual no es correcta", ErrInvalidUserData)
+	}
+
+	return nil
+}
+
+func (service *PhoneService) findUser(userId string) (user models.User, err error) {
+	id, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		err = ErrUserNotFound
+		return
+	}
+
+	err = service.db.Collection("users").FindOne(ctx, bson.M{"_id": id, "deleted_at": nil}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		err = ErrUserNotFound
+	}
+	return
+}
+
+func (service *PhoneService) updateUser(filter bson.M, update bson.M, changedBy string) (user models.User, err error) {
+	opts := options.FindOneAndUpdate().
+		SetReturnDocument(options.After).
+		SetProjection(bson.M{"password": 0})
+
+	err = service.db.Collection("users").FindOneAndUpdate(ctx, filter, update, opts).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		err = ErrUserNotFound
+	}
+	if err != nil {
+		return
+	}
+
+	err = recordUserVersion(service.db, user.ID, UserVersionPhone, changedBy)
+	return
+}
+
+// Los códigos tienen pocas combinaciones: se incluye el id para que el mismo código no tenga el mismo hash
+func phoneCodeHash(id primitive.ObjectID, code string) string {
+	return utils.HashToken(id.Hex() + ":" + code)
+}
+
+func NewPhoneService(db *mongo.Database, sender sms.ISender, config PhoneServiceConfig) IPhoneService {
+	if config.CodeTTL <= 0 {
+		config.CodeTTL = defaultPhoneCodeTTL
+	}
+	if config.ResendInterval <= 0 {
+		config.ResendInterval = defaultPhoneResendInterval
+	}
+	if config.MaxPerHour <= 0 {
+		config.MaxPerHour = defaultPhoneMaxPerHour
+	}
+
+	return &PhoneService{db: db, sender: sender, config: config}
+}
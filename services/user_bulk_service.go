@@ -0,0 +1,379 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/maramal/user-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	BulkActionSuspend            = "suspend"
+	BulkActionActivate           = "activate"
+	BulkActionDelete             = "delete"
+	BulkActionRevokeSessions     = "revoke_sessions"
+	BulkActionForcePasswordReset = "force_password_reset"
+
+	BulkStatusPending   = "pending"
+	BulkStatusRunning   = "running"
+	BulkStatusCompleted = "completed"
+
+	BulkItemSucceeded = "succeeded"
+	BulkItemSkipped   = "skipped"
+	BulkItemFailed    = "failed"
+
+	// Cantidad máxima de usuarios por operación si no se configura BULK_MAX_USERS
+	defaultBulkMaxUsers = 1000
+	// Cada cuántos usuarios se guarda el avance de la operación
+	bulkProgressInterval = 100
+)
+
+var (
+	ErrInvalidBulkOperation     = errors.New("la operación masiva no es válida")
+	ErrBulkLimitExceeded        = errors.New("la operación masiva supera el límite de usuarios")
+	ErrBulkConfirmationRequired = errors.New("la operación masiva requiere confirmación")
+	ErrBulkOperationNotFound    = errors.New("no se encontró la operación masiva")
+)
+
+var bulkActions = map[string]bool{
+	BulkActionSuspend:            true,
+	BulkActionActivate:           true,
+	BulkActionDelete:             true,
+	BulkActionRevokeSessions:     true,
+	BulkActionForcePasswordReset: true,
+}
+
+// Acciones que cambian el estado de los usuarios => estado de destino. Requieren un motivo
+var bulkStatusActions = map[string]string{
+	BulkActionSuspend:  models.UserStatusSuspended,
+	BulkActionActivate: models.UserStatusActive,
+	BulkActionDelete:   models.UserStatusDeleted,
+}
+
+// Filtro de usuarios de una operación masiva. Tiene el mismo significado que los filtros del listado
+type BulkUsersFilter struct {
+	Status      []string  `json:"status"`
+	Type        []string  `json:"type"`
+	CreatedFrom time.Time `json:"created_from"`
+	CreatedTo   time.Time `json:"created_to"`
+	EmailDomain string    `json:"email_domain"`
+	// Atributo personalizado => valor
+	Custom map[string]string `json:"custom"`
+}
+
+type BulkUsersRequest struct {
+	// suspend, activate, delete, revoke_sessions o force_password_reset
+	Action string `json:"action" binding:"required"`
+	// Ids de los usuarios. No se puede usar junto con filter
+	UserIDs []string `json:"user_ids"`
+	// Filtro de los usuarios. No se puede usar junto con user_ids
+	Filter *BulkUsersFilter `json:"filter"`
+	// Cantidad de usuarios afectados. Debe coincidir con la cantidad de usuarios encontrados
+	Confirm int `json:"confirm"`
+	// Motivo del cambio de estado. Requerido para suspend, activate y delete
+	Reason string `json:"reason"`
+}
+
+type IUserBulkService interface {
+	StartBulkOperation(req BulkUsersRequest, actor string) (response models.UserBulkOperation, err error)
+	GetBulkOperation(id string) (response models.UserBulkOperation, err error)
+}
+
+type UserBulkService struct {
+	db       *mongo.Database
+	maxUsers int
+}
+
+/** Valida una operación masiva y la procesa en segundo plano
+ *
+ * La operación sólo se inicia si la cantidad de usuarios no supera el límite y si req.Confirm
+ * coincide con la cantidad de usuarios encontrados. El usuario que la solicita nunca es afectado.
+ *
+ * @param req BulkUsersRequest "La acción y los usuarios afectados"
+ * @param actor string "El correo electrónico del usuario que solicita la operación"
+ * @return models.UserBulkOperation "La operación creada"
+ * @return err error "El error de la operación"
+ */
+func (service *UserBulkService) StartBulkOperation(req BulkUsersRequest, actor string) (response models.UserBulkOperation, err error) {
+	if !bulkActions[req.Action] {
+		err = fmt.Errorf("%w: la acción \"%s\" no existe", ErrInvalidBulkOperation, req.Action)
+		return
+	}
+
+	req.Reason = strings.TrimSpace(req.Reason)
+	if _, ok := bulkStatusActions[req.Action]; ok && req.Reason == "" {
+		err = fmt.Errorf("%w: la acción \"%s\" requiere un motivo", ErrInvalidBulkOperation, req.Action)
+		return
+	}
+
+	if (len(req.UserIDs) > 0) == (req.Filter != nil) {
+		err = fmt.Errorf("%w: se debe indicar user_ids o filter", ErrInvalidBulkOperation)
+		return
+	}
+
+	var ids []primitive.ObjectID
+	var filter bson.M
+	if req.Filter != nil {
+		if filter, err = bulkFilter(service.db, *req.Filter); err != nil {
+			return
+		}
+	} else {
+		if len(req.UserIDs) > service.maxUsers {
+			err = fmt.Errorf("%w: se indicaron %d usuarios y el máximo es %d", ErrBulkLimitExceeded, len(req.UserIDs), service.maxUsers)
+			return
+		}
+
+		if ids, err = parseBulkUserIDs(req.UserIDs); err != nil {
+			return
+		}
+		filter = bson.M{"_id": bson.M{"$in": ids}, "deleted_at": nil}
+	}
+
+	items, err := service.findBulkUsers(filter)
+	if err != nil {
+		return
+	}
+
+	if req.Confirm != len(items) {
+		err = fmt.Errorf("%w: la operación afecta a %d usuarios, indique confirm=%d", ErrBulkConfirmationRequired, len(items), len(items))
+		return
+	}
+
+	operation := models.UserBulkOperation{
+		Action:    req.Action,
+		Reason:    req.Reason,
+		Status:    BulkStatusPending,
+		Items:     []models.UserBulkOperationItem{},
+		CreatedBy: actor,
+		CreatedAt: time.Now(),
+	}
+
+	// Los ids que no corresponden a un usuario se informan como errores
+	found := map[primitive.ObjectID]bool{}
+	for _, item := range items {
+		found[item.UserID] = true
+	}
+	for _, id := range ids {
+		if !found[id] {
+			operation.Items = append(operation.Items, models.UserBulkOperationItem{
+				UserID: id,
+				Status: BulkItemFailed,
+				Error:  ErrUserNotFound.Error(),
+			})
+			operation.Failed++
+		}
+	}
+	operation.Total = len(items) + operation.Failed
+	operation.Processed = operation.Failed
+
+	result, err := service.db.Collection("user_bulk_operations").InsertOne(ctx, operation)
+	if err != nil {
+		return
+	}
+	operation.ID = result.InsertedID.(primitive.ObjectID)
+
+	response = operation
+	go service.processBulkOperation(&operation, items)
+
+	return
+}
+
+/** Obtiene una operación masiva con su avance y el resultado de cada usuario
+ *
+ * @param id string "El id de la operación"
+ * @return models.UserBulkOperation "La operación"
+ * @return err error "El error de la operación"
+ */
+func (service *UserBulkService) GetBulkOperation(operationId string) (response models.UserBulkOperation, err error) {
+	collection := service.db.Collection("user_bulk_operations")
+
+	id, err := primitive.ObjectIDFromHex(operationId)
+	if err != nil {
+		err = ErrBulkOperationNotFound
+		return
+	}
+
+	err = collection.FindOne(ctx, bson.M{"_id": id}).Decode(&response)
+	if err == mongo.ErrNoDocuments {
+		err = ErrBulkOperationNotFound
+	}
+
+	return
+}
+
+/** Aplica la acción a cada usuario, guardando el avance periódicamente
+ *
+ * @param operation *models.UserBulkOperation "La operación"
+ * @param items []models.UserBulkOperationItem "Los usuarios afectados"
+ */
+func (service *UserBulkService) processBulkOperation(operation *models.UserBulkOperation, items []models.UserBulkOperationItem) {
+	operation.Status = BulkStatusRunning
+	service.saveBulkOperation(operation)
+
+	for _, item := range items {
+		if item.Email == operation.CreatedBy {
+			item.Status = BulkItemSkipped
+			item.Error = "no se puede aplicar una operación masiva a la propia cuenta"
+			operation.Skipped++
+		} else if err := service.applyBulkAction(operation, item); err != nil {
+			item.Status = BulkItemFailed
+			item.Error = err.Error()
+			operation.Failed++
+		} else {
+			item.Status = BulkItemSucceeded
+			operation.Succeeded++
+		}
+
+		operation.Items = append(operation.Items, item)
+		operation.Processed++
+
+		if operation.Processed%bulkProgressInterval == 0 {
+			service.saveBulkOperation(operation)
+		}
+	}
+
+	operation.Status = BulkStatusCompleted
+	finishedAt := time.Now()
+	operation.FinishedAt = &finishedAt
+	service.saveBulkOperation(operation)
+}
+
+// Aplica la acción de la operación a un usuario. Los cambios de estado se registran en el historial
+// del usuario y aplican los efectos del nuevo estado
+func (service *UserBulkService) applyBulkAction(operation *models.UserBulkOperation, item models.UserBulkOperationItem) error {
+	collection := service.db.Collection("users")
+	now := time.Now()
+
+	switch operation.Action {
+	case BulkActionSuspend, BulkActionActivate, BulkActionDelete:
+		var extra bson.M
+		if operation.Action == BulkActionDelete {
+			extra = bson.M{"deleted_at": now, "deleted_by": operation.CreatedBy}
+		}
+
+		if operation.Action == BulkActionActivate {
+			if err := checkUserCanActivate(service.db, item.UserID); err != nil {
+				return err
+			}
+		}
+
+		status := bulkStatusActions[operation.Action]
+		_, err := transitionUserStatus(service.db, item.UserID, nil, status, operation.Reason, operation.CreatedBy, extra)
+		return err
+	case BulkActionForcePasswordReset:
+		filter := bson.M{"_id": item.UserID, "deleted_at": nil}
+		update := bson.M{"$set": bson.M{"password_reset_required": true, "updated_at": now}, "$inc": bson.M{"version": 1}}
+
+		result, err := collection.UpdateOne(ctx, filter, update)
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return ErrUserNotFound
+		}
+		if err = recordUserVersion(service.db, item.UserID, UserVersionBulk, operation.CreatedBy); err != nil {
+			return err
+		}
+	}
+
+	return blockUserSessions(service.db, item.Email)
+}
+
+// Guarda el avance de la operación
+func (service *UserBulkService) saveBulkOperation(operation *models.UserBulkOperation) {
+	collection := service.db.Collection("user_bulk_operations")
+
+	if _, err := collection.ReplaceOne(ctx, bson.M{"_id": operation.ID}, operation); err != nil {
+		operation.Error = err.Error()
+	}
+}
+
+// Obtiene el id y el correo de los usuarios que cumplen el filtro
+func (service *UserBulkService) findBulkUsers(filter bson.M) (items []models.UserBulkOperationItem, err error) {
+	collection := service.db.Collection("users")
+
+	count, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return
+	}
+	if count > int64(service.maxUsers) {
+		err = fmt.Errorf("%w: la operación afecta a %d usuarios y el máximo es %d", ErrBulkLimitExceeded, count, service.maxUsers)
+		return
+	}
+
+	opts := options.Find().
+		SetProjection(bson.M{"_id": 1, "email": 1}).
+		SetSort(bson.M{"_id": 1})
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return
+	}
+
+	var users []models.User
+	if err = cursor.All(ctx, &users); err != nil {
+		return
+	}
+
+	items = []models.UserBulkOperationItem{}
+	for _, user := range users {
+		items = append(items, models.UserBulkOperationItem{UserID: user.ID, Email: user.Email})
+	}
+
+	return
+}
+
+// Convierte el filtro de la operación en un filtro de Mongo. Exige al menos un criterio
+func bulkFilter(db *mongo.Database, req BulkUsersFilter) (bson.M, error) {
+	filter, err := buildUsersFilter(db, GetUsersRequest{
+		Status:      req.Status,
+		Type:        req.Type,
+		CreatedFrom: req.CreatedFrom,
+		CreatedTo:   req.CreatedTo,
+		EmailDomain: req.EmailDomain,
+		Custom:      req.Custom,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// buildUsersFilter siempre agrega deleted_at
+	if len(filter) == 1 {
+		return nil, fmt.Errorf("%w: el filtro debe tener al menos un criterio", ErrInvalidBulkOperation)
+	}
+
+	return filter, nil
+}
+
+// Convierte los ids de la solicitud, sin repetidos
+func parseBulkUserIDs(values []string) (ids []primitive.ObjectID, err error) {
+	seen := map[primitive.ObjectID]bool{}
+	for _, value := range values {
+		id, parseErr := primitive.ObjectIDFromHex(value)
+		if parseErr != nil {
+			err = fmt.Errorf("%w: el id \"%s\" no es válido", ErrInvalidBulkOperation, value)
+			return
+		}
+
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	return
+}
+
+func NewUserBulkService(db *mongo.Database, maxUsers int) IUserBulkService {
+	if maxUsers <= 0 {
+		maxUsers = defaultBulkMaxUsers
+	}
+
+	return &UserBulkService{db: db, maxUsers: maxUsers}
+}
@@ -0,0 +1,251 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/maramal/user-service/mailer"
+	"github.com/maramal/user-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Autor de los cambios que hacen las tareas de mantenimiento, en lugar del correo electrónico de un usuario
+const systemActor = "system"
+
+type SetExpirationRequest struct {
+	// Fecha en que vence la cuenta. Debe ser futura
+	ExpiresAt time.Time `json:"expires_at" binding:"required"`
+}
+
+type IUserExpirationService interface {
+	SetExpiration(userId string, req SetExpirationRequest, changedBy string) (response UpdateUserResponse, err error)
+	ClearExpiration(userId string, changedBy string) (response UpdateUserResponse, err error)
+	ExpireUsers() (expired int64, err error)
+	SendExpirationReminders(before time.Duration) (sent int64, err error)
+}
+
+type UserExpirationService struct {
+	db     *mongo.Database
+	mailer mailer.IMailer
+}
+
+/** Define o extiende la fecha de vencimiento de la cuenta de un usuario. Si la cuenta ya había vencido,
+ * el usuario vuelve al estado que tenía antes de vencer
+ *
+ * @param userId string "El id del usuario"
+ * @param req SetExpirationRequest "La nueva fecha de vencimiento"
+ * @param changedBy string "El correo electrónico de quien cambia el vencimiento"
+ * @return UpdateUserResponse "El usuario actualizado"
+ * @return err error "El error de la operación"
+ */
+func (service *UserExpirationService) SetExpiration(userId string, req SetExpirationRequest, changedBy string) (response UpdateUserResponse, err error) {
+	if err = validateExpiration(&req.ExpiresAt); err != nil {
+		return
+	}
+
+	return service.updateExpiration(userId, &req.ExpiresAt, "vencimiento extendido", changedBy)
+}
+
+/** Quita la fecha de vencimiento de la cuenta de un usuario. Si la cuenta ya había vencido, el usuario
+ * vuelve al estado que tenía antes de vencer
+ *
+ * @param userId string "El id del usuario"
+ * @param changedBy string "El correo electrónico de quien quita el vencimiento"
+ * @return UpdateUserResponse "El usuario actualizado"
+ * @return err error "El error de la operación"
+ */
+func (service *UserExpirationService) ClearExpiration(userId string, changedBy string) (response UpdateUserResponse, err error) {
+	return service.updateExpiration(userId, nil, "vencimiento quitado", changedBy)
+}
+
+/** Pasa al estado expired a los usuarios cuya cuenta venció y bloquea sus sesiones
+ *
+ * @return int64 "La cantidad de usuarios vencidos"
+ * @return err error "El error de la operación"
+ */
+func (service *UserExpirationService) ExpireUsers() (expired int64, err error) {
+	filter := bson.M{
+		"expires_at": bson.M{"$lte": time.Now()},
+		"status":     bson.M{"$in": userStatusTransitions[models.UserStatusExpired]},
+		"deleted_at": nil,
+	}
+	opts := options.Find().SetProjection(bson.M{"_id": 1})
+
+	cursor, err := service.db.Collection("users").Find(ctx, filter, opts)
+	if err != nil {
+		return
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var user models.User
+		if err = cursor.Decode(&user); err != nil {
+			return
+		}
+
+		_, err = transitionUserStatus(service.db, user.ID, nil, models.UserStatusExpired, "la cuenta venció", systemActor, nil)
+		// El usuario pudo cambiar de estado o ser eliminado desde la búsqueda
+		if errors.Is(err, ErrInvalidStatusTransition) || errors.Is(err, ErrUserNotFound) {
+			err = nil
+			continue
+		}
+		if err != nil {
+			return
+		}
+		expired++
+	}
+
+	err = cursor.Err()
+	return
+}
+
+/** Avisa por correo a los usuarios activos cuya cuenta vence dentro del plazo indicado. Cada usuario
+ * recibe un solo aviso por fecha de vencimiento
+ *
+ * @param before time.Duration "Anticipación con la que se avisa el vencimiento"
+ * @return int64 "La cantidad de avisos enviados"
+ * @return err error "El error de la operación"
+ */
+func (service *UserExpirationService) SendExpirationReminders(before time.Duration) (sent int64, err error) {
+	collection := service.db.Collection("users")
+
+	now := time.Now()
+	filter := bson.M{
+		"expires_at":             bson.M{"$gt": now, "$lte": now.Add(before)},
+		"expiration_reminded_at": nil,
+		"status":                 models.UserStatusActive,
+		"deleted_at":             nil,
+	}
+
+	cursor, err := collection.Find(ctx, filter, options.Find().SetProjection(bson.M{"password": 0}))
+	if err != nil {
+		return
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var user models.User
+		if err = cursor.Decode(&user); err != nil {
+			return
+		}
+
+		if err = service.sendReminder(user); err != nil {
+			return
+		}
+
+		// Si se cambió el vencimiento mientras tanto, el usuario recibe otro aviso por la nueva fecha
+		update := bson.M{"$set": bson.M{"expiration_reminded_at": time.Now()}}
+		if _, err = collection.UpdateOne(ctx, bson.M{"_id": user.ID, "expires_at": user.ExpiresAt}, update); err != nil {
+			return
+		}
+		sent++
+	}
+
+	err = cursor.Err()
+	return
+}
+
+// Cambia el vencimiento del usuario y de sus sesiones y, si la cuenta había vencido, restaura el estado anterior
+func (service *UserExpirationService) updateExpiration(userId string, expiresAt *time.Time, reason string, changedBy string) (response UpdateUserResponse, err error) {
+	collection := service.db.Collection("users")
+
+	id, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		err = ErrUserNotFound
+		return
+	}
+
+	now := time.Now()
+	set := bson.M{"updated_at": now}
+	unset := bson.M{"expiration_reminded_at": ""}
+	if expiresAt != nil {
+		set["expires_at"] = *expiresAt
+	} else {
+		unset["expires_at"] = ""
+	}
+
+	filter := bson.M{"_id": id, "deleted_at": nil}
+	update := bson.M{"$set": set, "$unset": unset, "$inc": bson.M{"version": 1}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(bson.M{"password": 0})
+
+	err = collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&response.User)
+	if err == mongo.ErrNoDocuments {
+		err = ErrUserNotFound
+	}
+	if err != nil {
+		return
+	}
+
+	if err = setSessionsUserExpiration(service.db, response.User.Email, expiresAt); err != nil {
+		return
+	}
+
+	if response.User.Status != models.UserStatusExpired {
+		err = recordUserVersion(service.db, id, UserVersionExpiration, changedBy)
+		return
+	}
+
+	status, err := statusBefore(service.db, id, models.UserStatusExpired)
+	if err != nil {
+		return
+	}
+
+	update = bson.M{
+		"$set": bson.M{"status": status, "status_reason": reason, "status_changed_at": now, "updated_at": now},
+		"$inc": bson.M{"version": 1},
+	}
+
+	err = collection.FindOneAndUpdate(ctx, bson.M{"_id": id, "status": models.UserStatusExpired}, update, opts).Decode(&response.User)
+	if err == mongo.ErrNoDocuments {
+		// Otro cambio de estado se adelantó; se devuelve el usuario tal como quedó
+		if err = collection.FindOne(ctx, bson.M{"_id": id}, options.FindOne().SetProjection(bson.M{"password": 0})).Decode(&response.User); err != nil {
+			return
+		}
+		err = recordUserVersion(service.db, id, UserVersionExpiration, changedBy)
+		return
+	}
+	if err != nil {
+		return
+	}
+
+	change := models.UserStatusChange{
+		UserID:    id,
+		From:      models.UserStatusExpired,
+		To:        status,
+		Reason:    reason,
+		ChangedBy: changedBy,
+		ChangedAt: now,
+	}
+	if _, err = service.db.Collection("user_status_changes").InsertOne(ctx, change); err != nil {
+		return
+	}
+
+	err = recordUserVersion(service.db, id, UserVersionExpiration, changedBy)
+	return
+}
+
+func (service *UserExpirationService) sendReminder(user models.User) error {
+	return service.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Tu cuenta está por vencer",
+		Body: fmt.Sprintf("Tu cuenta vence el %s. Desde ese momento no podrás ingresar.\n\nSi necesitas seguir usándola, pide a un administrador que extienda el vencimiento.",
+			user.ExpiresAt.Format(time.RFC1123)),
+	})
+}
+
+// Verifica que la fecha de vencimiento de una cuenta, si se indica, sea futura
+func validateExpiration(expiresAt *time.Time) error {
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return fmt.Errorf("%w: la fecha de vencimiento debe ser futura", ErrInvalidUserData)
+	}
+
+	return nil
+}
+
+func NewUserExpirationService(db *mongo.Database, mail mailer.IMailer) IUserExpirationService {
+	return &UserExpirationService{db: db, mailer: mail}
+}
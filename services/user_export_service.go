@@ -0,0 +1,444 @@
+package services
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/maramal/user-service/models"
+	"github.com/maramal/user-service/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	ExportFormatCSV    = "csv"
+	ExportFormatNDJSON = "ndjson"
+	ExportFormatXLSX   = "xlsx"
+
+	ExportStatusRunning   = "running"
+	ExportStatusCompleted = "completed"
+	ExportStatusFailed    = "failed"
+)
+
+var (
+	ErrInvalidExport  = errors.New("la exportación no es válida")
+	ErrExportNotFound = errors.New("no se encontró la exportación")
+	ErrExportNotReady = errors.New("la exportación todavía no está lista")
+)
+
+// Columnas que se pueden exportar. La contraseña nunca se exporta
+var exportColumns = map[string]func(user models.User) interface{}{
+	"id":                  func(user models.User) interface{} { return user.ID.Hex() },
+	"email":               func(user models.User) interface{} { return user.Email },
+	"first_name":          func(user models.User) interface{} { return user.FirstName },
+	"last_name":           func(user models.User) interface{} { return user.LastName },
+	"type":                func(user models.User) interface{} { return user.Type },
+	"status":              func(user models.User) interface{} { return user.Status },
+	"profile_image":       func(user models.User) interface{} { return user.ProfileImage },
+	"password_changed_at": func(user models.User) interface{} { return user.PasswordChangedAt },
+	"created_at":          func(user models.User) interface{} { return user.CreatedAt },
+	"updated_at":          func(user models.User) interface{} { return user.UpdatedAt },
+	"deleted_at":          func(user models.User) interface{} { return user.DeletedAt },
+	"deleted_by":          func(user models.User) interface{} { return user.DeletedBy },
+}
+
+// Columnas exportadas cuando no se indica ninguna
+var defaultExportColumns = []string{"id", "email", "first_name", "last_name", "type", "status", "profile_image", "created_at", "updated_at"}
+
+// Tipos de contenido y extensiones de cada formato de exportación
+var exportFormats = map[string]struct {
+	ContentType string
+	Extension   string
+}{
+	ExportFormatCSV:    {ContentType: "text/csv; charset=utf-8", Extension: "csv"},
+	ExportFormatNDJSON: {ContentType: "application/x-ndjson", Extension: "ndjson"},
+	ExportFormatXLSX:   {ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", Extension: "xlsx"},
+}
+
+type ExportUsersRequest struct {
+	GetUsersRequest
+	Format string `form:"format"`
+	// Columnas separadas por coma
+	Columns string `form:"columns"`
+}
+
+type IUserExportService interface {
+	ExportUsers(req ExportUsersRequest, writer io.Writer) (rows int, err error)
+	StartExport(req ExportUsersRequest, createdBy string) (response models.UserExport, err error)
+	GetExport(id string) (response models.UserExport, err error)
+	PurgeExports(olderThan time.Duration) (deleted int64, err error)
+}
+
+type UserExportService struct {
+	db  *mongo.Database
+	dir string
+}
+
+/** Obtiene el tipo de contenido y el nombre de archivo de un formato de exportación
+ *
+ * @param format string "El formato de la exportación"
+ * @return string "El tipo de contenido"
+ * @return string "El nombre del archivo"
+ * @return err error "El error si el formato no es válido"
+ */
+func ExportFileInfo(format string) (contentType string, fileName string, err error) {
+	info, ok := exportFormats[format]
+	if !ok {
+		err = fmt.Errorf("%w: el formato debe ser csv, ndjson o xlsx", ErrInvalidExport)
+		return
+	}
+
+	contentType = info.ContentType
+	fileName = fmt.Sprintf("usuarios-%s.%s", time.Now().Format("20060102-150405"), info.Extension)
+	return
+}
+
+/** Escribe los usuarios que cumplen los filtros en el formato solicitado, leyéndolos uno por uno del cursor
+ *
+ * @param req ExportUsersRequest "Los filtros, el formato y las columnas"
+ * @param writer io.Writer "El destino de la exportación"
+ * @return int "La cantidad de usuarios exportados"
+ * @return err error "El error de la operación"
+ */
+func (service *UserExportService) ExportUsers(req ExportUsersRequest, writer io.Writer) (rows int, err error) {
+	collection := service.db.Collection("users")
+
+	columns, err := parseExportColumns(req.Columns)
+	if err != nil {
+		return
+	}
+
+	fields, err := parseUsersSort(req.Sort)
+	if err != nil {
+		return
+	}
+
+	// Antes de escribir el encabezado, para poder responder el error
+	filter, err := buildUsersFilter(service.db, req.GetUsersRequest)
+	if err != nil {
+		return
+	}
+
+	rowWriter, err := newExportRowWriter(req.Format, writer, columns)
+	if err != nil {
+		return
+	}
+
+	opts := options.Find().
+		SetSort(sortDocument(fields, false)).
+		SetProjection(bson.M{"password": 0, "search_terms": 0})
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var user models.User
+		if err = cursor.Decode(&user); err != nil {
+			return
+		}
+
+		values := make([]interface{}, len(columns))
+		for i, column := range columns {
+			values[i] = exportColumns[column](user)
+		}
+
+		if err = rowWriter.Write(values); err != nil {
+			return
+		}
+		rows++
+	}
+	if err = cursor.Err(); err != nil {
+		return
+	}
+
+	err = rowWriter.Close()
+	return
+}
+
+/** Inicia una exportación en segundo plano que se guarda en un archivo para descargarlo después
+ *
+ * @param req ExportUsersRequest "Los filtros, el formato y las columnas"
+ * @param createdBy string "El correo electrónico de quien solicita la exportación"
+ * @return models.UserExport "La exportación creada"
+ * @return err error "El error de la operación"
+ */
+func (service *UserExportService) StartExport(req ExportUsersRequest, createdBy string) (response models.UserExport, err error) {
+	collection := service.db.Collection("user_exports")
+
+	columns, err := parseExportColumns(req.Columns)
+	if err != nil {
+		return
+	}
+
+	info, ok := exportFormats[req.Format]
+	if !ok {
+		err = fmt.Errorf("%w: el formato debe ser csv, ndjson o xlsx", ErrInvalidExport)
+		return
+	}
+
+	if _, err = parseUsersSort(req.Sort); err != nil {
+		return
+	}
+
+	if _, err = buildUsersFilter(service.db, req.GetUsersRequest); err != nil {
+		return
+	}
+
+	if err = os.MkdirAll(service.dir, 0o700); err != nil {
+		return
+	}
+
+	job := models.UserExport{
+		ID:        primitive.NewObjectID(),
+		Format:    req.Format,
+		Columns:   columns,
+		Status:    ExportStatusRunning,
+		CreatedBy: createdBy,
+		CreatedAt: time.Now(),
+	}
+	job.FilePath = filepath.Join(service.dir, job.ID.Hex()+"."+info.Extension)
+
+	if _, err = collection.InsertOne(ctx, job); err != nil {
+		return
+	}
+
+	response = job
+	go service.runExport(job, req)
+
+	return
+}
+
+/** Obtiene una exportación
+ *
+ * @param id string "El id de la exportación"
+ * @return models.UserExport "La exportación"
+ * @return err error "El error de la operación"
+ */
+func (service *UserExportService) GetExport(exportId string) (response models.UserExport, err error) {
+	collection := service.db.Collection("user_exports")
+
+	id, err := primitive.ObjectIDFromHex(exportId)
+	if err != nil {
+		return
+	}
+
+	err = collection.FindOne(ctx, bson.M{"_id": id}).Decode(&response)
+	if err == mongo.ErrNoDocuments {
+		err = ErrExportNotFound
+	}
+
+	return
+}
+
+/** Elimina las exportaciones y sus archivos creados hace más tiempo que olderThan
+ *
+ * @param olderThan time.Duration "La antigüedad a partir de la cual se eliminan"
+ * @return int64 "La cantidad de exportaciones eliminadas"
+ * @return err error "El error de la operación"
+ */
+func (service *UserExportService) PurgeExports(olderThan time.Duration) (deleted int64, err error) {
+	collection := service.db.Collection("user_exports")
+
+	filter := bson.M{"created_at": bson.M{"$lte": time.Now().Add(-olderThan)}}
+	cursor, err := collection.Find(ctx, filter)
+	if err != nil {
+		return
+	}
+
+	var exports []models.UserExport
+	if err = cursor.All(ctx, &exports); err != nil {
+		return
+	}
+
+	for _, export := range exports {
+		if err = os.Remove(export.FilePath); err != nil && !os.IsNotExist(err) {
+			return
+		}
+	}
+
+	result, err := collection.DeleteMany(ctx, filter)
+	if err != nil {
+		return
+	}
+
+	deleted = result.DeletedCount
+	return
+}
+
+// Escribe el archivo de una exportación en segundo plano y guarda su resultado
+func (service *UserExportService) runExport(job models.UserExport, req ExportUsersRequest) {
+	collection := service.db.Collection("user_exports")
+
+	rows, err := service.writeExportFile(job.FilePath, req)
+
+	job.Rows = rows
+	job.Status = ExportStatusCompleted
+	if err != nil {
+		job.Status = ExportStatusFailed
+		job.Error = err.Error()
+		os.Remove(job.FilePath)
+	}
+
+	finishedAt := time.Now()
+	job.FinishedAt = &finishedAt
+
+	collection.ReplaceOne(ctx, bson.M{"_id": job.ID}, job)
+}
+
+func (service *UserExportService) writeExportFile(path string, req ExportUsersRequest) (rows int, err error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return
+	}
+
+	rows, err = service.ExportUsers(req, file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return
+}
+
+// Valida las columnas solicitadas, separadas por coma
+func parseExportColumns(value string) (columns []string, err error) {
+	if strings.TrimSpace(value) == "" {
+		columns = defaultExportColumns
+		return
+	}
+
+	for _, column := range strings.Split(value, ",") {
+		column = strings.TrimSpace(column)
+		if _, ok := exportColumns[column]; !ok {
+			err = fmt.Errorf("%w: la columna \"%s\" no existe", ErrInvalidExport, column)
+			return
+		}
+		columns = append(columns, column)
+	}
+
+	return
+}
+
+// Escribe las filas de una exportación en un formato determinado
+type exportRowWriter interface {
+	Write(values []interface{}) error
+	Close() error
+}
+
+func newExportRowWriter(format string, writer io.Writer, columns []string) (exportRowWriter, error) {
+	switch format {
+	case ExportFormatCSV:
+		csvWriter := csv.NewWriter(writer)
+		if err := csvWriter.Write(columns); err != nil {
+			return nil, err
+		}
+		return &csvExportWriter{writer: csvWriter}, nil
+	case ExportFormatNDJSON:
+		return &ndjsonExportWriter{encoder: json.NewEncoder(writer), columns: columns}, nil
+	case ExportFormatXLSX:
+		xlsxWriter, err := utils.NewXLSXWriter(writer)
+		if err != nil {
+			return nil, err
+		}
+		if err = xlsxWriter.Write(columns); err != nil {
+			return nil, err
+		}
+		return &xlsxExportWriter{writer: xlsxWriter}, nil
+	default:
+		return nil, fmt.Errorf("%w: el formato debe ser csv, ndjson o xlsx", ErrInvalidExport)
+	}
+}
+
+type csvExportWriter struct {
+	writer *csv.Writer
+}
+
+func (w *csvExportWriter) Write(values []interface{}) error {
+	return w.writer.Write(exportValuesToStrings(values))
+}
+
+func (w *csvExportWriter) Close() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+type ndjsonExportWriter struct {
+	encoder *json.Encoder
+	columns []string
+}
+
+func (w *ndjsonExportWriter) Write(values []interface{}) error {
+	row := make(map[string]interface{}, len(values))
+	for i, value := range values {
+		row[w.columns[i]] = value
+	}
+
+	return w.encoder.Encode(row)
+}
+
+func (w *ndjsonExportWriter) Close() error {
+	return nil
+}
+
+type xlsxExportWriter struct {
+	writer *utils.XLSXWriter
+}
+
+func (w *xlsxExportWriter) Write(values []interface{}) error {
+	return w.writer.Write(exportValuesToStrings(values))
+}
+
+func (w *xlsxExportWriter) Close() error {
+	return w.writer.Close()
+}
+
+// Convierte los valores de una fila en texto. Las fechas se escriben en formato RFC 3339 y los textos
+// que una planilla interpretaría como fórmula se escapan (ver escapeSpreadsheetFormula)
+func exportValuesToStrings(values []interface{}) []string {
+	result := make([]string, len(values))
+	for i, value := range values {
+		switch v := value.(type) {
+		case time.Time:
+			if !v.IsZero() {
+				result[i] = v.Format(time.RFC3339)
+			}
+		case *time.Time:
+			if v != nil {
+				result[i] = v.Format(time.RFC3339)
+			}
+		default:
+			result[i] = escapeSpreadsheetFormula(fmt.Sprint(v))
+		}
+	}
+
+	return result
+}
+
+// Antepone un apóstrofo a los textos que empiezan con =, +, -, @, tabulación o retorno de carro, para que
+// Excel y otras planillas no los ejecuten como fórmulas al abrir la exportación
+func escapeSpreadsheetFormula(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+
+	return value
+}
+
+func NewUserExportService(db *mongo.Database, dir string) IUserExportService {
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "user-exports")
+	}
+
+	return &UserExportService{db: db, dir: dir}
+}
@@ -0,0 +1,622 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/maramal/user-service/models"
+	"github.com/maramal/user-service/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	ImportFormatCSV    = "csv"
+	ImportFormatNDJSON = "ndjson"
+
+	ImportOnDuplicateSkip   = "skip"
+	ImportOnDuplicateUpdate = "update"
+
+	ImportStatusPending   = "pending"
+	ImportStatusRunning   = "running"
+	ImportStatusCompleted = "completed"
+	ImportStatusFailed    = "failed"
+
+	// Cantidad de filas que se insertan en cada lote
+	importBatchSize = 500
+	// Cantidad máxima de errores por fila que se guardan en la importación
+	maxImportRowErrors = 1000
+	// Cantidad de importaciones que devuelve el listado
+	importsListLimit = 50
+)
+
+var (
+	ErrInvalidImport  = errors.New("la importación no es válida")
+	ErrImportNotFound = errors.New("no se encontró la importación")
+)
+
+// Campos del usuario a los que se pueden asignar las columnas del archivo
+var importableUserFields = map[string]bool{
+	"first_name": true,
+	"last_name":  true,
+	"email":      true,
+	"password":   true,
+	"type":       true,
+	"status":     true,
+	// Con un valor verdadero, la fila reemplaza la contraseña de un usuario existente
+	"reset_password": true,
+}
+
+type ImportUsersParams struct {
+	FileName string
+	Format   string
+	Data     []byte
+	// Columna del archivo (o clave del objeto JSON) => campo del usuario. Si está vacío, las columnas
+	// deben llamarse igual que los campos del usuario
+	Mapping     map[string]string
+	DryRun      bool
+	OnDuplicate string
+	CreatedBy   string
+}
+
+type GetImportsResponse struct {
+	Imports []models.UserImport `json:"imports"`
+}
+
+type IUserImportService interface {
+	ImportUsers(params ImportUsersParams) (response models.UserImport, err error)
+	GetImport(id string) (response models.UserImport, err error)
+	GetImports() (response GetImportsResponse, err error)
+}
+
+type UserImportService struct {
+	db *mongo.Database
+}
+
+// Fila del archivo ya convertida en una solicitud de creación de usuario
+type importRow struct {
+	Row     int
+	Request CreateUserRequest
+	// Campos del usuario con un valor en la fila. Al actualizar un usuario existente sólo se modifican estos
+	Fields map[string]bool
+	// Si la fila actualiza un usuario existente, indica si se reemplaza su contraseña
+	ResetPassword bool
+	ParseError    string
+}
+
+/** Importa usuarios desde un archivo CSV o NDJSON
+ *
+ * Si es una prueba (DryRun) se validan todas las filas y se devuelve el resultado sin guardar usuarios.
+ * En caso contrario la importación se procesa en segundo plano y su avance se consulta con GetImport.
+ *
+ * @param params ImportUsersParams "El archivo, su formato y las opciones de la importación"
+ * @return models.UserImport "La importación creada"
+ * @return err error "El error de la operación"
+ */
+func (service *UserImportService) ImportUsers(params ImportUsersParams) (response models.UserImport, err error) {
+	collection := service.db.Collection("user_imports")
+
+	if params.OnDuplicate == "" {
+		params.OnDuplicate = ImportOnDuplicateSkip
+	}
+	if params.OnDuplicate != ImportOnDuplicateSkip && params.OnDuplicate != ImportOnDuplicateUpdate {
+		err = fmt.Errorf("%w: on_duplicate debe ser \"%s\" o \"%s\"", ErrInvalidImport, ImportOnDuplicateSkip, ImportOnDuplicateUpdate)
+		return
+	}
+
+	for source, target := range params.Mapping {
+		if !importableUserFields[target] {
+			err = fmt.Errorf("%w: la columna \"%s\" está asignada al campo desconocido \"%s\"", ErrInvalidImport, source, target)
+			return
+		}
+	}
+
+	rows, err := parseImportRows(params.Format, params.Data, params.Mapping)
+	if err != nil {
+		return
+	}
+
+	job := models.UserImport{
+		FileName:    params.FileName,
+		Format:      params.Format,
+		DryRun:      params.DryRun,
+		OnDuplicate: params.OnDuplicate,
+		Status:      ImportStatusPending,
+		TotalRows:   len(rows),
+		RowErrors:   []models.UserImportRowError{},
+		CreatedBy:   params.CreatedBy,
+		CreatedAt:   time.Now(),
+	}
+
+	result, err := collection.InsertOne(ctx, job)
+	if err != nil {
+		return
+	}
+	job.ID = result.InsertedID.(primitive.ObjectID)
+
+	if params.DryRun {
+		service.processImport(&job, rows)
+		response = job
+		return
+	}
+
+	response = job
+	go service.processImport(&job, rows)
+
+	return
+}
+
+/** Obtiene una importación con su avance y los errores de cada fila
+ *
+ * @param id string "El id de la importación"
+ * @return models.UserImport "La importación"
+ * @return err error "El error de la operación"
+ */
+func (service *UserImportService) GetImport(importId string) (response models.UserImport, err error) {
+	collection := service.db.Collection("user_imports")
+
+	id, err := primitive.ObjectIDFromHex(importId)
+	if err != nil {
+		return
+	}
+
+	err = collection.FindOne(ctx, bson.M{"_id": id}).Decode(&response)
+	if err == mongo.ErrNoDocuments {
+		err = ErrImportNotFound
+	}
+
+	return
+}
+
+/** Obtiene las últimas importaciones, sin los errores de cada fila
+ *
+ * @return GetImportsResponse "Las importaciones, de la más reciente a la más antigua"
+ * @return err error "El error de la operación"
+ */
+func (service *UserImportService) GetImports() (response GetImportsResponse, err error) {
+	collection := service.db.Collection("user_imports")
+
+	opts := options.Find().
+		SetSort(bson.M{"created_at": -1}).
+		SetLimit(importsListLimit).
+		SetProjection(bson.M{"row_errors": 0})
+
+	cursor, err := collection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return
+	}
+
+	response.Imports = []models.UserImport{}
+	err = cursor.All(ctx, &response.Imports)
+	return
+}
+
+/** Procesa las filas de una importación por lotes, guardando el avance después de cada lote
+ *
+ * @param job *models.UserImport "La importación"
+ * @param rows []importRow "Las filas del archivo"
+ */
+func (service *UserImportService) processImport(job *models.UserImport, rows []importRow) {
+	job.Status = ImportStatusRunning
+	service.saveImport(job)
+
+	seen := map[string]int{}
+	for start := 0; start < len(rows); start += importBatchSize {
+		end := start + importBatchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+
+		if err := service.processImportBatch(job, rows[start:end], seen); err != nil {
+			job.Status = ImportStatusFailed
+			job.Error = err.Error()
+			break
+		}
+
+		job.ProcessedRows = end
+		service.saveImport(job)
+	}
+
+	if job.Status != ImportStatusFailed {
+		job.Status = ImportStatusCompleted
+	}
+
+	finishedAt := time.Now()
+	job.FinishedAt = &finishedAt
+	service.saveImport(job)
+}
+
+/** Valida un lote de filas y crea, actualiza u omite cada usuario
+ *
+ * @param job *models.UserImport "La importación"
+ * @param rows []importRow "Las filas del lote"
+ * @param seen map[string]int "Los correos ya procesados en lotes anteriores y la fila en que aparecieron"
+ * @return err error "El error que impide continuar con la importación"
+ */
+func (service *UserImportService) processImportBatch(job *models.UserImport, rows []importRow, seen map[string]int) (err error) {
+	collection := service.db.Collection("users")
+
+	var valid []importRow
+	var emails []string
+	for _, row := range rows {
+		if row.ParseError != "" {
+			addImportRowError(job, row, row.ParseError)
+			continue
+		}
+
+		// El resto de la fila se valida al saber si crea o actualiza al usuario
+		email, err := normalizeEmail(row.Request.Email)
+		if err != nil {
+			addImportRowError(job, row, err.Error())
+			continue
+		}
+		row.Request.Email = email
+
+		if previous, ok := seen[row.Request.Email]; ok {
+			addImportRowError(job, row, fmt.Sprintf("el correo electrónico está repetido en la fila %d", previous))
+			continue
+		}
+
+		seen[row.Request.Email] = row.Row
+		valid = append(valid, row)
+		emails = append(emails, row.Request.Email)
+	}
+
+	if len(valid) == 0 {
+		return
+	}
+
+	cursor, err := collection.Find(ctx, bson.M{"email": bson.M{"$in": emails}})
+	if err != nil {
+		return
+	}
+
+	var existingUsers []models.User
+	if err = cursor.All(ctx, &existingUsers); err != nil {
+		return
+	}
+
+	existing := map[string]models.User{}
+	for _, user := range existingUsers {
+		existing[user.Email] = user
+	}
+
+	var inserts []importRow
+	for _, row := range valid {
+		user, found := existing[row.Request.Email]
+		if !found {
+			if err := ValidateCreateUserRequest(&row.Request); err != nil {
+				addImportRowError(job, row, err.Error())
+				continue
+			}
+			inserts = append(inserts, row)
+			continue
+		}
+
+		if job.OnDuplicate == ImportOnDuplicateSkip {
+			job.Skipped++
+			continue
+		}
+
+		if user.DeletedAt != nil {
+			addImportRowError(job, row, "el usuario existente está eliminado")
+			continue
+		}
+
+		if err := validateImportUpdate(&row); err != nil {
+			addImportRowError(job, row, err.Error())
+			continue
+		}
+
+		if !job.DryRun {
+			if err := updateImportedUser(collection, user, row); err != nil {
+				addImportRowError(job, row, err.Error())
+				continue
+			}
+			if err := recordUserVersion(service.db, user.ID, UserVersionImport, job.CreatedBy); err != nil {
+				addImportRowError(job, row, err.Error())
+				continue
+			}
+		}
+
+		job.Updated++
+	}
+
+	if job.DryRun || len(inserts) == 0 {
+		job.Created += len(inserts)
+		return
+	}
+
+	var documents []interface{}
+	var documentRows []importRow
+	for _, row := range inserts {
+		user, err := newUserFromRequest(row.Request)
+		if err != nil {
+			addImportRowError(job, row, err.Error())
+			continue
+		}
+
+		documents = append(documents, user)
+		documentRows = append(documentRows, row)
+	}
+
+	if len(documents) == 0 {
+		return
+	}
+
+	// Sin orden, los errores de una fila no impiden insertar las demás
+	result, insertErr := collection.InsertMany(ctx, documents, options.InsertMany().SetOrdered(false))
+	if result != nil {
+		job.Created += len(result.InsertedIDs)
+
+		// Las filas que no se insertaron no tienen usuario y no registran versión
+		for _, id := range result.InsertedIDs {
+			if err = recordUserVersion(service.db, id.(primitive.ObjectID), UserVersionImport, job.CreatedBy); err != nil {
+				return
+			}
+		}
+	}
+
+	var bulkErr mongo.BulkWriteException
+	if errors.As(insertErr, &bulkErr) {
+		for _, writeErr := range bulkErr.WriteErrors {
+			message := writeErr.Message
+			if writeErr.Code == 11000 {
+				message = ErrEmailTaken.Error()
+			}
+			addImportRowError(job, documentRows[writeErr.Index], message)
+		}
+		return
+	}
+
+	err = insertErr
+	return
+}
+
+// Valida una fila que actualiza un usuario existente con las reglas de PatchUser: sólo los campos con valor, sin
+// los valores predeterminados de la creación. La contraseña se exige sólo si la fila pide reemplazarla
+func validateImportUpdate(row *importRow) (err error) {
+	req := &row.Request
+	for field, value := range map[string]*string{"first_name": &req.FirstName, "last_name": &req.LastName, "type": &req.Type} {
+		if !row.Fields[field] {
+			continue
+		}
+		if *value, err = validateUserField(field, *value); err != nil {
+			return
+		}
+	}
+
+	if row.ResetPassword && len(req.Password) < minPasswordLength {
+		err = fmt.Errorf("%w: la contraseña debe tener al menos %d caracteres", ErrInvalidUserData, minPasswordLength)
+	}
+
+	return
+}
+
+// Actualiza un usuario existente con los valores de una fila de la importación, ya validada con
+// validateImportUpdate. Sólo se modifican el nombre, el apellido y el tipo que tengan valor en la fila, y la
+// contraseña si la fila lo pide con reset_password; los atributos personalizados y el estado no se modifican.
+// Si cambian el tipo o la contraseña se bloquean las sesiones del usuario
+func updateImportedUser(collection *mongo.Collection, user models.User, row importRow) error {
+	req := row.Request
+	previousType := user.Type
+
+	set := bson.M{"updated_at": time.Now()}
+	if row.Fields["first_name"] {
+		user.FirstName, set["first_name"] = req.FirstName, req.FirstName
+	}
+	if row.Fields["last_name"] {
+		user.LastName, set["last_name"] = req.LastName, req.LastName
+	}
+	if row.Fields["type"] {
+		user.Type, set["type"] = req.Type, req.Type
+	}
+
+	terms, err := userSearchTerms(collection.Database(), user)
+	if err != nil {
+		return err
+	}
+	set["search_terms"] = terms
+	setUserSortNames(set, user)
+
+	if row.ResetPassword {
+		if set["password"], err = utils.HashPassword(req.Password); err != nil {
+			return err
+		}
+		set["password_changed_at"] = time.Now()
+	}
+
+	if _, err = collection.UpdateByID(ctx, user.ID, bson.M{"$inc": bson.M{"version": 1}, "$set": set}); err != nil {
+		return err
+	}
+
+	if row.ResetPassword || user.Type != previousType {
+		return blockUserSessions(collection.Database(), user.Email)
+	}
+
+	return nil
+}
+
+// Registra el error de una fila. Se guardan hasta maxImportRowErrors errores, pero se cuentan todos
+func addImportRowError(job *models.UserImport, row importRow, message string) {
+	job.Failed++
+	if len(job.RowErrors) >= maxImportRowErrors {
+		return
+	}
+
+	job.RowErrors = append(job.RowErrors, models.UserImportRowError{
+		Row:   row.Row,
+		Email: row.Request.Email,
+		Error: message,
+	})
+}
+
+// Guarda el avance de la importación
+func (service *UserImportService) saveImport(job *models.UserImport) {
+	collection := service.db.Collection("user_imports")
+
+	if _, err := collection.ReplaceOne(ctx, bson.M{"_id": job.ID}, job); err != nil {
+		job.Error = err.Error()
+	}
+}
+
+/** Convierte el contenido del archivo en filas
+ *
+ * @param format string "El formato del archivo (csv o ndjson)"
+ * @param data []byte "El contenido del archivo"
+ * @param mapping map[string]string "La asignación de columnas a campos del usuario"
+ * @return []importRow "Las filas del archivo"
+ * @return err error "El error si el archivo no se puede leer"
+ */
+func parseImportRows(format string, data []byte, mapping map[string]string) (rows []importRow, err error) {
+	switch format {
+	case ImportFormatCSV:
+		return parseCSVRows(data, mapping)
+	case ImportFormatNDJSON:
+		return parseNDJSONRows(data, mapping)
+	default:
+		err = fmt.Errorf("%w: el formato debe ser \"%s\" o \"%s\"", ErrInvalidImport, ImportFormatCSV, ImportFormatNDJSON)
+		return
+	}
+}
+
+func parseCSVRows(data []byte, mapping map[string]string) (rows []importRow, err error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		err = fmt.Errorf("%w: no se pudo leer el encabezado del CSV: %s", ErrInvalidImport, err)
+		return
+	}
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+
+	for {
+		record, readErr := reader.Read()
+		if readErr == io.EOF {
+			break
+		}
+
+		if readErr != nil {
+			var parseErr *csv.ParseError
+			row := importRow{ParseError: readErr.Error()}
+			if errors.As(readErr, &parseErr) {
+				row.Row = parseErr.StartLine
+			}
+
+			rows = append(rows, row)
+			continue
+		}
+
+		line, _ := reader.FieldPos(0)
+
+		values := map[string]string{}
+		for i, column := range header {
+			values[strings.TrimSpace(column)] = record[i]
+		}
+
+		row := mapImportValues(values, mapping)
+		row.Row = line
+		rows = append(rows, row)
+	}
+
+	return
+}
+
+func parseNDJSONRows(data []byte, mapping map[string]string) (rows []importRow, err error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var object map[string]interface{}
+		if jsonErr := json.Unmarshal([]byte(text), &object); jsonErr != nil {
+			rows = append(rows, importRow{Row: line, ParseError: fmt.Sprintf("JSON inválido: %s", jsonErr)})
+			continue
+		}
+
+		values := map[string]string{}
+		for key, value := range object {
+			if value != nil {
+				values[key] = fmt.Sprint(value)
+			}
+		}
+
+		row := mapImportValues(values, mapping)
+		row.Row = line
+		rows = append(rows, row)
+	}
+
+	if err = scanner.Err(); err != nil {
+		err = fmt.Errorf("%w: %s", ErrInvalidImport, err)
+	}
+
+	return
+}
+
+// Asigna los valores de una fila a los campos del usuario según la asignación de columnas
+func mapImportValues(values map[string]string, mapping map[string]string) (row importRow) {
+	req := &row.Request
+	row.Fields = map[string]bool{}
+	for source, value := range values {
+		target := source
+		if len(mapping) > 0 {
+			var ok bool
+			if target, ok = mapping[source]; !ok {
+				continue
+			}
+		}
+		if strings.TrimSpace(value) != "" {
+			row.Fields[target] = true
+		}
+
+		switch target {
+		case "first_name":
+			req.FirstName = value
+		case "last_name":
+			req.LastName = value
+		case "email":
+			req.Email = value
+		case "password":
+			req.Password = value
+		case "type":
+			req.Type = value
+		case "status":
+			req.Status = value
+		case "reset_password":
+			if value == "" {
+				continue
+			}
+			reset, err := strconv.ParseBool(value)
+			if err != nil {
+				row.ParseError = fmt.Sprintf("reset_password debe ser true o false: \"%s\"", value)
+				continue
+			}
+			row.ResetPassword = reset
+		}
+	}
+
+	return
+}
+
+func NewUserImportService(db *mongo.Database) IUserImportService {
+	return &UserImportService{db: db}
+}
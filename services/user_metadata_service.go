@@ -0,0 +1,365 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/maramal/user-service/models"
+	"github.com/maramal/user-service/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// Tamaño máximo de cada grupo de metadatos, en bytes de JSON, si no se configura METADATA_MAX_SIZE
+	defaultMetadataMaxSize = 16 * 1024
+	// Niveles de anidamiento permitidos en los metadatos
+	metadataMaxDepth = 10
+	// Intentos de guardar los metadatos si el usuario se modifica al mismo tiempo
+	metadataUpdateAttempts = 3
+
+	// Id del único documento de metadata_config
+	metadataConfigID = "users"
+)
+
+var ErrInvalidMetadata = errors.New("los metadatos no son válidos")
+
+type UserMetadataResponse struct {
+	// Metadatos que sólo modifican los administradores
+	AppMetadata map[string]interface{} `json:"app_metadata"`
+	// Metadatos que modifica el propio usuario
+	UserMetadata map[string]interface{} `json:"user_metadata"`
+}
+
+type MetadataConfigResponse struct {
+	Config models.MetadataConfig `json:"config"`
+}
+
+type UpdateMetadataConfigRequest struct {
+	Claims []models.MetadataClaim `json:"claims" binding:"dive"`
+}
+
+type IUserMetadataService interface {
+	GetMetadata(userId string) (response UserMetadataResponse, err error)
+	PatchAppMetadata(userId string, patch map[string]interface{}, changedBy string) (response UserMetadataResponse, err error)
+	PatchUserMetadata(userId string, patch map[string]interface{}, changedBy string) (response UserMetadataResponse, err error)
+	GetMetadataConfig() (response MetadataConfigResponse, err error)
+	UpdateMetadataConfig(req UpdateMetadataConfigRequest, updatedBy string) (response MetadataConfigResponse, err error)
+	TokenClaims(user models.User) (claims map[string]interface{}, err error)
+}
+
+type UserMetadataService struct {
+	db      *mongo.Database
+	maxSize int
+}
+
+/** Obtiene los metadatos de un usuario
+ *
+ * @param userId string "El id del usuario"
+ * @return UserMetadataResponse "Los metadatos de la aplicación y del usuario"
+ * @return err error "El error de la operación"
+ */
+func (service *UserMetadataService) GetMetadata(userId string) (response UserMetadataResponse, err error) {
+	user, err := service.findUser(userId)
+	if err != nil {
+		return
+	}
+
+	return metadataResponse(user)
+}
+
+/** Modifica los metadatos de la aplicación de un usuario siguiendo JSON Merge Patch: los objetos se
+ * combinan en profundidad y null elimina la clave
+ *
+ * @param userId string "El id del usuario"
+ * @param patch map[string]interface{} "Los cambios"
+ * @param changedBy string "El correo electrónico de quien modifica los metadatos"
+ * @return UserMetadataResponse "Los metadatos actualizados"
+ * @return err error "ErrInvalidMetadata si el resultado supera los límites"
+ */
+func (service *UserMetadataService) PatchAppMetadata(userId string, patch map[string]interface{}, changedBy string) (response UserMetadataResponse, err error) {
+	return service.patchMetadata(userId, "app_metadata", patch, changedBy)
+}
+
+/** Modifica los metadatos del usuario siguiendo JSON Merge Patch: los objetos se combinan en profundidad
+ * y null elimina la clave
+ *
+ * @param userId string "El id del usuario"
+ * @param patch map[string]interface{} "Los cambios"
+ * @param changedBy string "El correo electrónico de quien modifica los metadatos"
+ * @return UserMetadataResponse "Los metadatos actualizados"
+ * @return err error "ErrInvalidMetadata si el resultado supera los límites"
+ */
+func (service *UserMetadataService) PatchUserMetadata(userId string, patch map[string]interface{}, changedBy string) (response UserMetadataResponse, err error) {
+	return service.patchMetadata(userId, "user_metadata", patch, changedBy)
+}
+
+/** Obtiene la configuración de los metadatos
+ *
+ * @return MetadataConfigResponse "La configuración"
+ * @return err error "El error de la operación"
+ */
+func (service *UserMetadataService) GetMetadataConfig() (response MetadataConfigResponse, err error) {
+	response.Config, err = service.config()
+	return
+}
+
+/** Reemplaza las rutas de app_metadata que se incluyen en los claims de los tokens. Se aplica a los
+ * tokens emitidos a partir del cambio
+ *
+ * @param req UpdateMetadataConfigRequest "La configuración nueva"
+ * @param updatedBy string "El correo electrónico de quien modifica la configuración"
+ * @return MetadataConfigResponse "La configuración guardada"
+ * @return err error "ErrInvalidMetadata si alguna ruta o claim no es válido o se repite"
+ */
+func (service *UserMetadataService) UpdateMetadataConfig(req UpdateMetadataConfigRequest, updatedBy string) (response MetadataConfigResponse, err error) {
+	config := models.MetadataConfig{
+		ID:        metadataConfigID,
+		Claims:    []models.MetadataClaim{},
+		UpdatedAt: time.Now(),
+		UpdatedBy: updatedBy,
+	}
+
+	claimNames := []string{}
+	for _, claim := range req.Claims {
+		claim.Path = strings.TrimSpace(claim.Path)
+		claim.Claim = strings.TrimSpace(claim.Claim)
+
+		for _, segment := range strings.Split(claim.Path, ".") {
+			if err = validateMetadataKey(segment); err != nil {
+				err = fmt.Errorf("%w: la ruta \"%s\" no es válida", ErrInvalidMetadata, claim.Path)
+				return
+			}
+		}
+		if claim.Claim == "" {
+			err = fmt.Errorf("%w: la ruta \"%s\" no tiene nombre de claim", ErrInvalidMetadata, claim.Path)
+			return
+		}
+		if containsString(claimNames, claim.Claim) {
+			err = fmt.Errorf("%w: el claim \"%s\" está repetido", ErrInvalidMetadata, claim.Claim)
+			return
+		}
+
+		claimNames = append(claimNames, claim.Claim)
+		config.Claims = append(config.Claims, claim)
+	}
+
+	opts := options.Replace().SetUpsert(true)
+	if _, err = service.db.Collection("metadata_config").ReplaceOne(ctx, bson.M{"_id": metadataConfigID}, config, opts); err != nil {
+		return
+	}
+
+	response.Config = config
+	return
+}
+
+/** Obtiene los claims del token de un usuario según las rutas de app_metadata configuradas
+ *
+ * @param user models.User "El usuario"
+ * @return map[string]interface{} "Nombre del claim => valor. nil si no hay claims"
+ * @return err error "El error de la operación"
+ */
+func (service *UserMetadataService) TokenClaims(user models.User) (claims map[string]interface{}, err error) {
+	if len(user.AppMetadata) == 0 {
+		return
+	}
+
+	config, err := service.config()
+	if err != nil || len(config.Claims) == 0 {
+		return
+	}
+
+	metadata, err := normalizeCustomAttributes(user.AppMetadata)
+	if err != nil {
+		return
+	}
+
+	for _, claim := range config.Claims {
+		if value, ok := metadataValue(metadata, claim.Path); ok {
+			if claims == nil {
+				claims = map[string]interface{}{}
+			}
+			claims[claim.Claim] = value
+		}
+	}
+
+	return
+}
+
+// Combina los metadatos actuales con los cambios y los guarda si el usuario no se modificó mientras tanto
+func (service *UserMetadataService) patchMetadata(userId string, field string, patch map[string]interface{}, changedBy string) (response UserMetadataResponse, err error) {
+	collection := service.db.Collection("users")
+
+	for attempt := 0; attempt < metadataUpdateAttempts; attempt++ {
+		var user models.User
+		if user, err = service.findUser(userId); err != nil {
+			return
+		}
+
+		current := user.UserMetadata
+		if field == "app_metadata" {
+			current = user.AppMetadata
+		}
+
+		var merged map[string]interface{}
+		if merged, err = normalizeCustomAttributes(current); err != nil {
+			return
+		}
+		merged = utils.MergePatch(merged, patch).(map[string]interface{})
+
+		if err = service.validateMetadata(field, merged); err != nil {
+			return
+		}
+
+		update := bson.M{
+			"$set": bson.M{field: merged, "updated_at": time.Now()},
+			"$inc": bson.M{"version": 1},
+		}
+		if len(merged) == 0 {
+			update["$set"] = bson.M{"updated_at": time.Now()}
+			update["$unset"] = bson.M{field: ""}
+		}
+
+		filter := bson.M{"_id": user.ID, "deleted_at": nil, "version": user.Version}
+		opts := options.FindOneAndUpdate().
+			SetReturnDocument(options.After).
+			SetProjection(bson.M{"app_metadata": 1, "user_metadata": 1})
+
+		err = collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&user)
+		if err == mongo.ErrNoDocuments {
+			// Otra modificación cambió la versión: se vuelve a combinar con los metadatos nuevos
+			continue
+		}
+		if err != nil {
+			return
+		}
+		if err = recordUserVersion(service.db, user.ID, UserVersionMetadata, changedBy); err != nil {
+			return
+		}
+
+		return metadataResponse(user)
+	}
+
+	err = ErrVersionMismatch
+	return
+}
+
+// Verifica el tamaño, el anidamiento y las claves de un grupo de metadatos
+func (service *UserMetadataService) validateMetadata(field string, metadata map[string]interface{}) error {
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	if len(data) > service.maxSize {
+		return fmt.Errorf("%w: %s ocupa %d bytes y el máximo es %d", ErrInvalidMetadata, field, len(data), service.maxSize)
+	}
+
+	if err := validateMetadataValue(metadata, 1); err != nil {
+		return fmt.Errorf("%w: %s %s", ErrInvalidMetadata, field, err)
+	}
+
+	return nil
+}
+
+// Obtiene la configuración de los administradores, vacía si nunca se guardó
+func (service *UserMetadataService) config() (config models.MetadataConfig, err error) {
+	err = service.db.Collection("metadata_config").FindOne(ctx, bson.M{"_id": metadataConfigID}).Decode(&config)
+	if err == mongo.ErrNoDocuments {
+		err = nil
+	}
+	if config.Claims == nil {
+		config.Claims = []models.MetadataClaim{}
+	}
+	return
+}
+
+func (service *UserMetadataService) findUser(userId string) (user models.User, err error) {
+	id, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		err = ErrUserNotFound
+		return
+	}
+
+	opts := options.FindOne().SetProjection(bson.M{"version": 1, "app_metadata": 1, "user_metadata": 1})
+	err = service.db.Collection("users").FindOne(ctx, bson.M{"_id": id, "deleted_at": nil}, opts).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		err = ErrUserNotFound
+	}
+	return
+}
+
+// Convierte los metadatos guardados en objetos JSON, vacíos si el usuario no tiene
+func metadataResponse(user models.User) (response UserMetadataResponse, err error) {
+	if response.AppMetadata, err = normalizeCustomAttributes(user.AppMetadata); err != nil {
+		return
+	}
+
+	response.UserMetadata, err = normalizeCustomAttributes(user.UserMetadata)
+	return
+}
+
+// Recorre los objetos y listas de los metadatos verificando el anidamiento y las claves
+func validateMetadataValue(value interface{}, depth int) error {
+	if depth > metadataMaxDepth {
+		return fmt.Errorf("supera los %d niveles de anidamiento", metadataMaxDepth)
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			if err := validateMetadataKey(key); err != nil {
+				return err
+			}
+			if err := validateMetadataValue(item, depth+1); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, item := range value {
+			if err := validateMetadataValue(item, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Las claves no pueden estar vacías, empezar con $ ni contener puntos, que MongoDB y las rutas de los claims interpretan
+func validateMetadataKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "$") || strings.Contains(key, ".") {
+		return fmt.Errorf("tiene la clave inválida \"%s\"", key)
+	}
+
+	return nil
+}
+
+// Obtiene el valor de una ruta separada por puntos dentro de los metadatos
+func metadataValue(metadata map[string]interface{}, path string) (interface{}, bool) {
+	var value interface{} = metadata
+
+	for _, segment := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[segment]; !ok {
+			return nil, false
+		}
+	}
+
+	return value, true
+}
+
+func NewUserMetadataService(db *mongo.Database, maxSize int) IUserMetadataService {
+	if maxSize <= 0 {
+		maxSize = defaultMetadataMaxSize
+	}
+
+	return &UserMetadataService{db: db, maxSize: maxSize}
+}
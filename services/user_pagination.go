@@ -0,0 +1,401 @@
+package services
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/maramal/user-service/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	defaultUsersPageSize = 50
+	maxUsersPageSize     = 200
+	defaultUsersSort     = "-created_at"
+
+	defaultEventsPageSize = 50
+	maxEventsPageSize     = 200
+)
+
+var (
+	ErrInvalidCursor = errors.New("el cursor no es válido")
+	ErrInvalidSort   = errors.New("el ordenamiento no es válido")
+)
+
+// Campos por los que se puede ordenar el listado de usuarios
+var sortableUserFields = map[string]bool{
+	"created_at": true,
+	"updated_at": true,
+	"email":      true,
+	"first_name": true,
+	"last_name":  true,
+	"status":     true,
+	"type":       true,
+}
+
+type GetUsersRequest struct {
+	// Cantidad de usuarios por página (máximo 200)
+	Limit int `form:"limit"`
+	// Cursor obtenido de next_cursor o prev_cursor de una respuesta anterior
+	Cursor string `form:"cursor"`
+	// Campos de ordenamiento separados por coma. Un "-" delante indica orden descendente
+	Sort        string    `form:"sort"`
+	Status      []string  `form:"status"`
+	Type        []string  `form:"type"`
+	CreatedFrom time.Time `form:"created_from" time_format:"2006-01-02T15:04:05Z07:00"`
+	CreatedTo   time.Time `form:"created_to" time_format:"2006-01-02T15:04:05Z07:00"`
+	EmailDomain string    `form:"email_domain"`
+	// Si es verdadero, la respuesta incluye el total de usuarios que cumplen los filtros
+	IncludeTotal bool `form:"include_total"`
+	// Si es verdadero, se listan sólo los usuarios eliminados. Lo define el endpoint, no la consulta
+	Deleted bool `form:"-"`
+	// Atributo personalizado => valor, por ejemplo custom[department]=ventas. Se obtiene con QueryMap
+	Custom map[string]string `form:"-"`
+}
+
+// Orden de los listados de eventos, como la auditoría y los ingresos: del más reciente al más antiguo
+var eventsSort = []sortField{{Field: "occurred_at", Desc: true}, {Field: "_id", Desc: true}}
+
+type sortField struct {
+	Field string
+	Desc  bool
+}
+
+// Posición de un usuario dentro del listado, codificada en los cursores
+type usersCursor struct {
+	Sort     string `bson:"s"`
+	Backward bool   `bson:"b"`
+	Values   bson.D `bson:"v"`
+}
+
+/** Interpreta el ordenamiento solicitado, por ejemplo "-created_at,last_name"
+ *
+ * @param spec string "Los campos de ordenamiento"
+ * @return []sortField "Los campos de ordenamiento, terminando siempre en _id"
+ * @return err error "El error si algún campo no es válido"
+ */
+func parseUsersSort(spec string) (fields []sortField, err error) {
+	if spec == "" {
+		spec = defaultUsersSort
+	}
+
+	seen := map[string]bool{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		field := sortField{Field: strings.TrimPrefix(part, "-"), Desc: strings.HasPrefix(part, "-")}
+
+		if !sortableUserFields[field.Field] || seen[field.Field] {
+			err = fmt.Errorf("%w: \"%s\"", ErrInvalidSort, part)
+			return
+		}
+
+		seen[field.Field] = true
+		fields = append(fields, field)
+	}
+
+	// _id desempata usuarios con los mismos valores
+	fields = append(fields, sortField{Field: "_id", Desc: fields[len(fields)-1].Desc})
+	return
+}
+
+// Convierte los campos de ordenamiento en su representación normalizada
+func sortSpec(fields []sortField) string {
+	parts := make([]string, 0, len(fields))
+	for _, f := range fields {
+		if f.Desc {
+			parts = append(parts, "-"+f.Field)
+		} else {
+			parts = append(parts, f.Field)
+		}
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// Crea el documento de ordenamiento de Mongo. Si backward es verdadero se invierte el orden
+func sortDocument(fields []sortField, backward bool) bson.D {
+	doc := bson.D{}
+	for _, f := range fields {
+		direction := 1
+		if f.Desc != backward {
+			direction = -1
+		}
+		doc = append(doc, bson.E{Key: f.Field, Value: direction})
+	}
+
+	return doc
+}
+
+/** Crea el filtro de Mongo a partir de los filtros de la solicitud
+ *
+ * @param db *mongo.Database "La base de datos, para obtener el esquema de los atributos personalizados"
+ * @param req GetUsersRequest "Los filtros"
+ * @return bson.M "El filtro"
+ * @return err error "ErrInvalidUserData si se filtra por un atributo que el esquema vigente no declara"
+ */
+func buildUsersFilter(db *mongo.Database, req GetUsersRequest) (filter bson.M, err error) {
+	if err = validateCustomFilter(db, req.Custom); err != nil {
+		return
+	}
+
+	filter = bson.M{"deleted_at": nil}
+	if req.Deleted {
+		filter["deleted_at"] = bson.M{"$ne": nil}
+	}
+
+	if len(req.Status) > 0 {
+		filter["status"] = bson.M{"$in": req.Status}
+	}
+
+	if len(req.Type) > 0 {
+		filter["type"] = bson.M{"$in": req.Type}
+	}
+
+	createdAt := bson.M{}
+	if !req.CreatedFrom.IsZero() {
+		createdAt["$gte"] = req.CreatedFrom
+	}
+	if !req.CreatedTo.IsZero() {
+		createdAt["$lte"] = req.CreatedTo
+	}
+	if len(createdAt) > 0 {
+		filter["created_at"] = createdAt
+	}
+
+	if domain := strings.TrimPrefix(strings.TrimSpace(req.EmailDomain), "@"); domain != "" {
+		// Los dominios internacionalizados se guardan en punycode
+		if normalized, err := utils.NormalizeEmailDomain(domain); err == nil {
+			domain = normalized
+		}
+		filter["email"] = bson.M{"$regex": "@" + regexp.QuoteMeta(domain) + "$", "$options": "i"}
+	}
+
+	for attribute, value := range req.Custom {
+		filter["custom."+attribute] = customFilterValue(value)
+	}
+
+	return
+}
+
+// Verifica que los atributos personalizados del filtro estén declarados en el esquema vigente, para que
+// las claves no puedan agregar operadores ni rutas arbitrarias al filtro de Mongo
+func validateCustomFilter(db *mongo.Database, custom map[string]string) error {
+	if len(custom) == 0 {
+		return nil
+	}
+
+	current, err := currentCustomSchema(db)
+	if err != nil {
+		return err
+	}
+
+	var schema *utils.JSONSchema
+	if current != nil {
+		if schema, err = utils.ParseJSONSchema(current.Schema); err != nil {
+			return err
+		}
+	}
+
+	for attribute := range custom {
+		if err := validateMetadataKey(attribute); err != nil {
+			return fmt.Errorf("%w: el filtro de atributos personalizados %s", ErrInvalidUserData, err)
+		}
+		if schema == nil || !schema.HasProperty(attribute) {
+			return fmt.Errorf("%w: el atributo personalizado \"%s\" no está definido en el esquema", ErrInvalidUserData, attribute)
+		}
+	}
+
+	return nil
+}
+
+// Los parámetros de la consulta son texto; el atributo puede estar guardado como número o booleano
+func customFilterValue(value string) bson.M {
+	values := bson.A{value}
+	if number, err := strconv.ParseFloat(value, 64); err == nil {
+		values = append(values, number)
+	}
+	if boolean, err := strconv.ParseBool(value); err == nil {
+		values = append(values, boolean)
+	}
+
+	return bson.M{"$in": values}
+}
+
+/** Crea el filtro que selecciona los usuarios posteriores (o anteriores) a la posición del cursor
+ *
+ * @param fields []sortField "Los campos de ordenamiento"
+ * @param cursor usersCursor "La posición de referencia"
+ * @return bson.M "El filtro"
+ */
+func keysetFilter(fields []sortField, cursor usersCursor) bson.M {
+	or := bson.A{}
+	for i, f := range fields {
+		condition := bson.M{}
+		for j := 0; j < i; j++ {
+			condition[fields[j].Field] = cursor.Values[j].Value
+		}
+
+		operator := "$gt"
+		if f.Desc != cursor.Backward {
+			operator = "$lt"
+		}
+		condition[f.Field] = bson.M{operator: cursor.Values[i].Value}
+
+		or = append(or, condition)
+	}
+
+	return bson.M{"$or": or}
+}
+
+/** Codifica la posición de un usuario en un cursor opaco
+ *
+ * @param fields []sortField "Los campos de ordenamiento"
+ * @param doc bson.Raw "El documento del usuario"
+ * @param backward bool "Si el cursor recorre el listado hacia atrás"
+ * @return string "El cursor"
+ * @return err error "El error de la operación"
+ */
+func encodeUsersCursor(fields []sortField, doc bson.Raw, backward bool) (string, error) {
+	cursor := usersCursor{Sort: sortSpec(fields), Backward: backward}
+	for _, f := range fields {
+		value, err := doc.LookupErr(f.Field)
+		if err != nil {
+			cursor.Values = append(cursor.Values, bson.E{Key: f.Field, Value: nil})
+			continue
+		}
+		cursor.Values = append(cursor.Values, bson.E{Key: f.Field, Value: value})
+	}
+
+	data, err := bson.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+/** Decodifica un cursor y verifica que corresponda al ordenamiento solicitado
+ *
+ * @param value string "El cursor"
+ * @param fields []sortField "Los campos de ordenamiento"
+ * @return usersCursor "La posición decodificada"
+ * @return err error "El error si el cursor no es válido"
+ */
+func decodeUsersCursor(value string, fields []sortField) (cursor usersCursor, err error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		err = ErrInvalidCursor
+		return
+	}
+
+	if err = bson.Unmarshal(data, &cursor); err != nil {
+		err = ErrInvalidCursor
+		return
+	}
+
+	if cursor.Sort != sortSpec(fields) || len(cursor.Values) != len(fields) {
+		err = fmt.Errorf("%w: no corresponde al ordenamiento solicitado", ErrInvalidCursor)
+		return
+	}
+
+	// Los valores se usan tal cual en el filtro: un valor de otro tipo cambiaría el resultado de la comparación
+	for i, f := range fields {
+		if cursor.Values[i].Key != f.Field || !validCursorValue(f.Field, cursor.Values[i].Value) {
+			err = fmt.Errorf("%w: el valor de \"%s\" no es válido", ErrInvalidCursor, f.Field)
+			return
+		}
+	}
+
+	return
+}
+
+// Verifica que el valor de un cursor tenga el tipo del campo de ordenamiento. Un campo ausente se codifica como null
+func validCursorValue(field string, value interface{}) bool {
+	if value == nil {
+		return true
+	}
+
+	switch field {
+	case "_id":
+		_, ok := value.(primitive.ObjectID)
+		return ok
+	case "created_at", "updated_at", "occurred_at":
+		_, ok := value.(primitive.DateTime)
+		return ok
+	default:
+		_, ok := value.(string)
+		return ok
+	}
+}
+
+/** Obtiene una página de un listado de eventos, ordenado por eventsSort. Los listados de eventos sólo se
+ * recorren hacia adelante
+ *
+ * @param collection *mongo.Collection "La colección de los eventos"
+ * @param filter bson.M "Los filtros del listado"
+ * @param limit int "La cantidad de eventos por página. Si es 0 se usa la predeterminada"
+ * @param value string "El cursor de la página, o vacío para la primera"
+ * @param results interface{} "Puntero al slice donde se decodifican los eventos"
+ * @return string "El cursor de la página siguiente, o vacío si no hay más"
+ * @return err error "El error de la operación"
+ */
+func findEventsPage(collection *mongo.Collection, filter bson.M, limit int, value string, results interface{}) (next string, err error) {
+	if limit <= 0 {
+		limit = defaultEventsPageSize
+	}
+	if limit > maxEventsPageSize {
+		limit = maxEventsPageSize
+	}
+
+	query := filter
+	if value != "" {
+		var position usersCursor
+		if position, err = decodeUsersCursor(value, eventsSort); err != nil {
+			return
+		}
+		if position.Backward {
+			err = fmt.Errorf("%w: el listado sólo se recorre hacia adelante", ErrInvalidCursor)
+			return
+		}
+
+		query = bson.M{"$and": bson.A{filter, keysetFilter(eventsSort, position)}}
+	}
+
+	// Se pide un evento extra para saber si hay más páginas
+	opts := options.Find().
+		SetSort(sortDocument(eventsSort, false)).
+		SetLimit(int64(limit + 1))
+
+	var docs []bson.Raw
+	if err = findAll(collection, query, opts, &docs); err != nil {
+		return
+	}
+
+	if len(docs) > limit {
+		docs = docs[:limit]
+		if next, err = encodeUsersCursor(eventsSort, docs[limit-1], false); err != nil {
+			return
+		}
+	}
+
+	slice := reflect.ValueOf(results).Elem()
+	slice.Set(reflect.MakeSlice(slice.Type(), 0, len(docs)))
+	for _, doc := range docs {
+		item := reflect.New(slice.Type().Elem())
+		if err = bson.Unmarshal(doc, item.Interface()); err != nil {
+			return
+		}
+		slice.Set(reflect.Append(slice, item.Elem()))
+	}
+
+	return
+}
@@ -0,0 +1,310 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/maramal/user-service/models"
+	"github.com/maramal/user-service/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Campos de texto que se pueden modificar con PatchUser. El valor indica si el campo acepta null (se elimina).
+// Los atributos personalizados (custom) se combinan aparte, con customPatch. El estado y el correo electrónico
+// se aceptan sólo si no cambian, para que se pueda enviar el usuario completo; los cambios de estado tienen sus
+// propios endpoints y los de correo electrónico requieren confirmación (ver EmailChangeService). La imagen de
+// perfil sólo la modifica AvatarService
+var patchableUserFields = map[string]bool{
+	"first_name": false,
+	"last_name":  false,
+	"email":      false,
+	"type":       false,
+	"status":     false,
+}
+
+type PatchUserRequest struct {
+	// Documento JSON Merge Patch (RFC 7396) con los campos a modificar. Un valor null elimina el campo
+	Patch map[string]json.RawMessage
+	// Máscara de campos. Si no está vacía, sólo se modifican estos campos y los que no estén en Patch
+	// se eliminan
+	Fields []string
+	// Versión esperada del usuario (If-Match). Si es nil no se verifica
+	IfVersion *int64
+	// Correo electrónico de quien modifica el usuario, para el historial de versiones
+	ChangedBy string
+}
+
+/** Modifica sólo los campos indicados de un usuario
+ *
+ * Si req.Fields está vacío se aplican todos los campos de req.Patch (JSON Merge Patch); en caso
+ * contrario se aplican sólo los campos de la máscara. Únicamente se guardan los campos que cambian.
+ *
+ * @param id string "El id del usuario"
+ * @param req PatchUserRequest "Los cambios a aplicar"
+ * @return UpdateUserResponse "El usuario actualizado"
+ * @return err error "El error de la operación"
+ */
+func (service *UserService) PatchUser(userId string, req PatchUserRequest) (response UpdateUserResponse, err error) {
+	collection := service.db.Collection("users")
+	var user models.User
+
+	id, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		err = ErrUserNotFound
+		return
+	}
+
+	changes, err := userPatchValues(req)
+	if err != nil {
+		return
+	}
+
+	filter := bson.M{"_id": id, "deleted_at": nil}
+	err = collection.FindOne(ctx, filter).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		err = ErrUserNotFound
+	}
+	if err != nil {
+		return
+	}
+
+	if req.IfVersion != nil && *req.IfVersion != user.Version {
+		err = ErrVersionMismatch
+		return
+	}
+
+	set, unset := userPatchUpdate(user, changes)
+	if _, ok := set["status"]; ok {
+		err = fmt.Errorf("%w: el estado se cambia con los endpoints de estado", ErrInvalidStatusTransition)
+		return
+	}
+	if _, ok := set["email"]; ok {
+		err = fmt.Errorf("%w: el correo electrónico se cambia con /email-change y requiere confirmación", ErrInvalidUserData)
+		return
+	}
+
+	if raw, ok := customPatch(req); ok {
+		custom, customErr := service.mergeCustomAttributes(user.Custom, raw)
+		if err = customErr; err != nil {
+			return
+		}
+
+		current, normalizeErr := normalizeCustomAttributes(user.Custom)
+		if err = normalizeErr; err != nil {
+			return
+		}
+
+		if (len(current) > 0 || len(custom) > 0) && !reflect.DeepEqual(current, custom) {
+			if len(custom) == 0 {
+				unset["custom"] = ""
+			} else {
+				set["custom"] = custom
+			}
+		}
+		user.Custom = custom
+	}
+
+	if len(set) == 0 && len(unset) == 0 {
+		user.Password = ""
+		response.User = user
+		return
+	}
+
+	if value, ok := set["first_name"]; ok {
+		user.FirstName = value.(string)
+	}
+	if value, ok := set["last_name"]; ok {
+		user.LastName = value.(string)
+	}
+	if set["search_terms"], err = userSearchTerms(service.db, user); err != nil {
+		return
+	}
+	setUserSortNames(set, user)
+	set["updated_at"] = time.Now()
+
+	update := bson.M{"$set": set, "$inc": bson.M{"version": 1}}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	opts := options.FindOneAndUpdate().
+		SetReturnDocument(options.After).
+		SetProjection(bson.M{"password": 0})
+
+	err = collection.FindOneAndUpdate(ctx, withVersion(filter, req.IfVersion), update, opts).Decode(&response.User)
+	if err == mongo.ErrNoDocuments {
+		err = service.notMatchedError(id, req.IfVersion)
+	}
+	if err != nil {
+		return
+	}
+
+	if err = recordUserVersion(service.db, id, UserVersionUpdate, req.ChangedBy); err != nil {
+		return
+	}
+
+	// Las sesiones abiertas conservan los permisos del tipo anterior
+	if _, ok := set["type"]; ok {
+		err = blockUserSessions(service.db, response.User.Email)
+	}
+
+	return
+}
+
+/** Obtiene y valida los valores a aplicar de una solicitud de modificación
+ *
+ * @param req PatchUserRequest "Los cambios solicitados"
+ * @return map[string]*string "Campo => nuevo valor. nil indica que el campo se elimina"
+ * @return err error "El error si algún campo o valor no es válido"
+ */
+func userPatchValues(req PatchUserRequest) (values map[string]*string, err error) {
+	fields := req.Fields
+	if len(fields) == 0 {
+		for field := range req.Patch {
+			if field != "custom" {
+				fields = append(fields, field)
+			}
+		}
+	} else {
+		for field := range req.Patch {
+			if !containsString(fields, field) {
+				err = fmt.Errorf("%w: el campo \"%s\" no está en la máscara de campos", ErrInvalidUserData, field)
+				return
+			}
+		}
+	}
+
+	values = map[string]*string{}
+	for _, field := range fields {
+		if field == "custom" {
+			continue
+		}
+
+		nullable, ok := patchableUserFields[field]
+		if !ok {
+			err = fmt.Errorf("%w: el campo \"%s\" no se puede modificar", ErrInvalidUserData, field)
+			return
+		}
+
+		raw, present := req.Patch[field]
+		if !present || string(raw) == "null" {
+			if !nullable {
+				err = fmt.Errorf("%w: el campo \"%s\" es requerido", ErrInvalidUserData, field)
+				return
+			}
+			values[field] = nil
+			continue
+		}
+
+		var value string
+		if json.Unmarshal(raw, &value) != nil {
+			err = fmt.Errorf("%w: el campo \"%s\" debe ser un texto", ErrInvalidUserData, field)
+			return
+		}
+
+		if value, err = validateUserField(field, value); err != nil {
+			return
+		}
+		values[field] = &value
+	}
+
+	return
+}
+
+// Normaliza y valida el valor de un campo modificable del usuario
+func validateUserField(field string, value string) (string, error) {
+	value = strings.TrimSpace(value)
+
+	switch field {
+	case "first_name", "last_name", "status":
+		if value == "" {
+			return "", fmt.Errorf("%w: el campo \"%s\" no puede estar vacío", ErrInvalidUserData, field)
+		}
+	case "email":
+		return normalizeEmail(value)
+	case "type":
+		if _, ok := rolePermissions[value]; !ok {
+			return "", fmt.Errorf("%w: el tipo de usuario \"%s\" no existe", ErrInvalidUserData, value)
+		}
+	}
+
+	return value, nil
+}
+
+// Compara los valores con el usuario actual y devuelve sólo los campos que cambian
+func userPatchUpdate(user models.User, values map[string]*string) (set bson.M, unset bson.M) {
+	current := map[string]string{
+		"first_name": user.FirstName,
+		"last_name":  user.LastName,
+		"email":      user.Email,
+		"type":       user.Type,
+		"status":     user.Status,
+	}
+
+	set, unset = bson.M{}, bson.M{}
+	for field, value := range values {
+		switch {
+		case value == nil && current[field] != "":
+			unset[field] = ""
+		case value != nil && *value != current[field]:
+			set[field] = *value
+		}
+	}
+
+	return
+}
+
+// Obtiene los cambios de los atributos personalizados. Con una máscara que incluye custom y sin valor
+// en el documento, se eliminan todos los atributos
+func customPatch(req PatchUserRequest) (json.RawMessage, bool) {
+	raw, present := req.Patch["custom"]
+	if len(req.Fields) == 0 {
+		return raw, present
+	}
+
+	if !containsString(req.Fields, "custom") {
+		return nil, false
+	}
+	if !present {
+		return json.RawMessage("null"), true
+	}
+
+	return raw, true
+}
+
+/** Combina los atributos personalizados actuales con los cambios y valida el resultado
+ *
+ * @param current map[string]interface{} "Los atributos actuales"
+ * @param raw json.RawMessage "Los cambios (un objeto JSON Merge Patch, o null para eliminarlos)"
+ * @return map[string]interface{} "Los atributos resultantes, ya validados"
+ * @return err error "ErrInvalidUserData si los cambios o el resultado no son válidos"
+ */
+func (service *UserService) mergeCustomAttributes(current map[string]interface{}, raw json.RawMessage) (result map[string]interface{}, err error) {
+	var patch interface{}
+	if err = json.Unmarshal(raw, &patch); err != nil {
+		err = fmt.Errorf("%w: custom debe ser un objeto JSON", ErrInvalidUserData)
+		return
+	}
+
+	var merged map[string]interface{}
+	switch patch.(type) {
+	case nil:
+		merged = map[string]interface{}{}
+	case map[string]interface{}:
+		if merged, err = normalizeCustomAttributes(current); err != nil {
+			return
+		}
+		merged = utils.MergePatch(merged, patch).(map[string]interface{})
+	default:
+		err = fmt.Errorf("%w: custom debe ser un objeto JSON", ErrInvalidUserData)
+		return
+	}
+
+	return validateCustomAttributes(service.db, merged)
+}
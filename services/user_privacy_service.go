@@ -0,0 +1,621 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/maramal/user-service/models"
+	"github.com/maramal/user-service/storage"
+	"github.com/maramal/user-service/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrUserErased = errors.New("los datos personales del usuario fueron borrados")
+
+// Dominio reservado (RFC 2606) de los correos electrónicos con los que se reemplaza el de los usuarios borrados
+const erasedEmailDomain = "erased.invalid"
+
+// Campos de otras colecciones que guardan el correo electrónico de un usuario. En los campos dentro de un
+// arreglo, $[item] indica cada elemento
+var userEmailReferences = []struct {
+	collection string
+	field      string
+}{
+	{"users", "deleted_by"},
+	{"user_status_changes", "changed_by"},
+	{"user_bulk_operations", "created_by"},
+	{"user_bulk_operations", "items.$[item].email"},
+	{"user_imports", "created_by"},
+	{"user_imports", "row_errors.$[item].email"},
+	{"user_exports", "created_by"},
+	{"custom_attribute_schemas", "created_by"},
+	{"custom_attribute_schemas", "report.invalid_users.$[item].email"},
+	{"invitations", "invited_by"},
+	{"invitations", "revoked_by"},
+	{"user_versions", "changed_by"},
+	{"user_versions", "snapshot.deleted_by"},
+	{"audit_events", "actor"},
+	{"audit_events", "target.email"},
+}
+
+type EraseUserRequest struct {
+	// Motivo del borrado, por ejemplo el número de la solicitud del titular de los datos
+	Reason string `json:"reason" binding:"required"`
+	// Correo electrónico del usuario, para confirmar que se borra el usuario correcto
+	Confirm string `json:"confirm" binding:"required"`
+}
+
+type EraseUserResponse struct {
+	Erasure models.UserErasure `json:"erasure"`
+}
+
+type UserDataExport struct {
+	Data     []byte
+	FileName string
+}
+
+// Sesión incluida en la exportación de datos, sin el token de refresco
+type dataExportSession struct {
+	ID        primitive.ObjectID `json:"_id"`
+	UserAgent string             `json:"user_agent"`
+	ClientIP  string             `json:"client_ip"`
+	IsBlocked bool               `json:"is_blocked"`
+	CreatedAt time.Time          `json:"created_at"`
+	ExpiresAt time.Time          `json:"expires_at"`
+}
+
+// Operación masiva que incluyó al usuario, con el resultado para él
+type dataExportBulkOperation struct {
+	ID        primitive.ObjectID           `json:"_id"`
+	Action    string                       `json:"action"`
+	Reason    string                       `json:"reason,omitempty"`
+	Result    models.UserBulkOperationItem `json:"result"`
+	CreatedBy string                       `json:"created_by"`
+	CreatedAt time.Time                    `json:"created_at"`
+}
+
+// Acción hecha por el usuario sobre otros usuarios, como administrador
+type dataExportActivity struct {
+	Type    string             `json:"type"`
+	ID      primitive.ObjectID `json:"_id"`
+	Summary string             `json:"summary"`
+	At      time.Time          `json:"at"`
+}
+
+type dataExportManifest struct {
+	UserID      primitive.ObjectID `json:"user_id"`
+	GeneratedAt time.Time          `json:"generated_at"`
+	Files       []string           `json:"files"`
+}
+
+type IUserPrivacyService interface {
+	ExportUserData(userId string) (response UserDataExport, err error)
+	EraseUser(userId string, req EraseUserRequest, erasedBy string) (response EraseUserResponse, err error)
+}
+
+type UserPrivacyService struct {
+	db      *mongo.Database
+	storage storage.IStorage
+}
+
+/** Reúne en un archivo ZIP todos los datos que se guardan de un usuario: el perfil, la imagen de perfil,
+ * las sesiones, los intentos de ingreso, el historial de estados, los cambios de correo electrónico, las operaciones masivas que lo
+ * incluyeron, los eventos de auditoría y las acciones que hizo como administrador. Incluye a los usuarios eliminados
+ *
+ * @param id string "El id del usuario"
+ * @return UserDataExport "El archivo ZIP y su nombre"
+ * @return err error "El error de la operación"
+ */
+func (service *UserPrivacyService) ExportUserData(userId string) (response UserDataExport, err error) {
+	id, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		err = ErrUserNotFound
+		return
+	}
+
+	var user models.User
+	err = service.db.Collection("users").FindOne(ctx, bson.M{"_id": id}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		err = ErrUserNotFound
+	}
+	if err != nil {
+		return
+	}
+	user.Password = ""
+
+	sections := []struct {
+		name string
+		load func(user models.User) (interface{}, error)
+	}{
+		{"profile.json", func(user models.User) (interface{}, error) { return user, nil }},
+		{"sessions.json", service.exportSessions},
+		{"logins.json", service.exportLogins},
+		{"status_history.json", service.exportStatusHistory},
+		{"email_changes.json", service.exportEmailChanges},
+		{"phone_codes.json", service.exportPhoneCodes},
+		{"invitations.json", service.exportInvitations},
+		{"versions.json", service.exportVersions},
+		{"bulk_operations.json", service.exportBulkOperations},
+		{"audit_events.json", service.exportAuditEvents},
+		{"activity.json", service.exportActivity},
+	}
+
+	var buffer bytes.Buffer
+	archive := zip.NewWriter(&buffer)
+	manifest := dataExportManifest{UserID: id, GeneratedAt: time.Now()}
+
+	for _, section := range sections {
+		var value interface{}
+		if value, err = section.load(user); err != nil {
+			return
+		}
+		if err = writeZipJSON(archive, section.name, value); err != nil {
+			return
+		}
+		manifest.Files = append(manifest.Files, section.name)
+	}
+
+	if user.Avatar != nil {
+		name := "avatar.jpg"
+		if user.Avatar.ContentType == "image/png" {
+			name = "avatar.png"
+		}
+
+		data, _, getErr := service.storage.Get(avatarKey(id, user.Avatar, 0))
+		if getErr != nil && !errors.Is(getErr, storage.ErrObjectNotFound) {
+			err = getErr
+			return
+		}
+		if getErr == nil {
+			if err = writeZipFile(archive, name, data); err != nil {
+				return
+			}
+			manifest.Files = append(manifest.Files, name)
+		}
+	}
+
+	if err = writeZipJSON(archive, "manifest.json", manifest); err != nil {
+		return
+	}
+	if err = archive.Close(); err != nil {
+		return
+	}
+
+	response = UserDataExport{
+		Data:     buffer.Bytes(),
+		FileName: fmt.Sprintf("user-%s-data.zip", id.Hex()),
+	}
+	return
+}
+
+/** Borra los datos personales de un usuario. Los datos se anonimizan en el lugar: el usuario conserva su id, para
+ * que las referencias de otras colecciones sigan siendo válidas, y su correo electrónico se reemplaza por un
+ * seudónimo en todas las colecciones. Se eliminan sus sesiones, sus intentos de ingreso, sus cambios de correo electrónico y su imagen de
+ * perfil. El usuario queda eliminado, no se puede restaurar y el borrado queda registrado en user_erasures
+ *
+ * @param id string "El id del usuario"
+ * @param req EraseUserRequest "El motivo y la confirmación del borrado"
+ * @param erasedBy string "El correo electrónico de quien borra los datos"
+ * @return EraseUserResponse "El registro del borrado"
+ * @return err error "El error de la operación"
+ */
+func (service *UserPrivacyService) EraseUser(userId string, req EraseUserRequest, erasedBy string) (response EraseUserResponse, err error) {
+	collection := service.db.Collection("users")
+
+	id, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		err = ErrUserNotFound
+		return
+	}
+
+	var user models.User
+	err = collection.FindOne(ctx, bson.M{"_id": id}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		err = ErrUserNotFound
+	}
+	if err != nil {
+		return
+	}
+
+	if user.ErasedAt != nil {
+		err = ErrUserErased
+		return
+	}
+
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		err = fmt.Errorf("%w: el motivo del borrado es requerido", ErrInvalidUserData)
+		return
+	}
+	if confirm, _ := utils.NormalizeEmail(req.Confirm); confirm != user.Email && strings.TrimSpace(req.Confirm) != user.Email {
+		err = fmt.Errorf("%w: la confirmación no coincide con el correo electrónico del usuario", ErrInvalidUserData)
+		return
+	}
+	if strings.EqualFold(erasedBy, user.Email) {
+		err = fmt.Errorf("%w: no se pueden borrar los datos de la propia cuenta", ErrInvalidUserData)
+		return
+	}
+
+	now := time.Now()
+	pseudonym := erasedUserEmail(id)
+
+	deletedAt, deletedBy := &now, erasedBy
+	if user.DeletedAt != nil {
+		deletedAt, deletedBy = user.DeletedAt, user.DeletedBy
+	}
+
+	filter := bson.M{"_id": id, "erased_at": nil}
+	update := bson.M{
+		"$set": bson.M{
+			"first_name":        "",
+			"last_name":         "",
+			"email":             pseudonym,
+			"password":          "",
+			"status":            models.UserStatusDeleted,
+			"status_reason":     reason,
+			"status_changed_at": now,
+			"updated_at":        now,
+			"deleted_at":        deletedAt,
+			"deleted_by":        deletedBy,
+			"erased_at":         now,
+		},
+		"$unset": bson.M{
+			"profile_image":           "",
+			"avatar":                  "",
+			"custom":                  "",
+			"search_terms":            "",
+			"sort_first_name":         "",
+			"sort_last_name":          "",
+			"password_reset_required": "",
+			"phone":                   "",
+			"phone_verified_at":       "",
+			"phone_mfa":               "",
+			"settings":                "",
+			"app_metadata":            "",
+			"user_metadata":           "",
+			"last_login_at":           "",
+			"login_count":             "",
+		},
+		"$inc": bson.M{"version": 1},
+	}
+
+	result, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return
+	}
+	if result.MatchedCount == 0 {
+		err = ErrUserErased
+		return
+	}
+
+	if err = deleteUserSessions(service.db, user.Email); err != nil {
+		return
+	}
+	if _, err = service.db.Collection("email_changes").DeleteMany(ctx, bson.M{"user_id": id}); err != nil {
+		return
+	}
+	if _, err = service.db.Collection("phone_codes").DeleteMany(ctx, bson.M{"user_id": id}); err != nil {
+		return
+	}
+	if _, err = service.db.Collection("invitations").DeleteMany(ctx, bson.M{"user_id": id}); err != nil {
+		return
+	}
+	// Las versiones guardan los datos personales que se borran
+	if _, err = service.db.Collection("user_versions").DeleteMany(ctx, bson.M{"user_id": id}); err != nil {
+		return
+	}
+	if _, err = service.db.Collection("login_events").DeleteMany(ctx, bson.M{"user_id": id}); err != nil {
+		return
+	}
+	deleteAvatarFiles(service.storage, id, user.Avatar)
+
+	if err = service.eraseAuditData(user); err != nil {
+		return
+	}
+	if err = service.replaceEmailReferences(user.Email, pseudonym); err != nil {
+		return
+	}
+
+	change := models.UserStatusChange{
+		UserID:    id,
+		From:      user.Status,
+		To:        models.UserStatusDeleted,
+		Reason:    reason,
+		ChangedBy: erasedBy,
+		ChangedAt: now,
+	}
+	if _, err = service.db.Collection("user_status_changes").InsertOne(ctx, change); err != nil {
+		return
+	}
+
+	erasure := models.UserErasure{
+		UserID:    id,
+		Pseudonym: pseudonym,
+		Reason:    reason,
+		ErasedBy:  erasedBy,
+		ErasedAt:  now,
+	}
+	insert, err := service.db.Collection("user_erasures").InsertOne(ctx, erasure)
+	if err != nil {
+		return
+	}
+	erasure.ID = insert.InsertedID.(primitive.ObjectID)
+
+	response.Erasure = erasure
+	return
+}
+
+/** Borra los datos personales de un usuario guardados en los eventos de auditoría, que no se eliminan: los valores
+ * de los campos que cambiaron en sus datos y la IP y el navegador de las solicitudes que hizo. El correo
+ * electrónico se reemplaza después con replaceEmailReferences
+ *
+ * @param user models.User "El usuario, antes del borrado"
+ * @return error "El error de la operación"
+ */
+func (service *UserPrivacyService) eraseAuditData(user models.User) error {
+	collection := service.db.Collection("audit_events")
+	target := bson.M{"target.type": AuditTargetUsers, "target.id": user.ID.Hex()}
+
+	filter := bson.M{"$and": bson.A{target, bson.M{"changes.0": bson.M{"$exists": true}}}}
+	update := bson.M{"$set": bson.M{"changes.$[].from": models.AuditRedacted, "changes.$[].to": models.AuditRedacted}}
+	if _, err := collection.UpdateMany(ctx, filter, update); err != nil {
+		return err
+	}
+
+	// Las solicitudes sin sesión sobre el usuario, como sus ingresos, también las hizo él
+	filter = bson.M{"$or": bson.A{
+		bson.M{"actor": user.Email},
+		bson.M{"$and": bson.A{target, bson.M{"actor": nil}}},
+	}}
+	update = bson.M{"$unset": bson.M{"client_ip": "", "user_agent": ""}}
+	_, err := collection.UpdateMany(ctx, filter, update)
+	return err
+}
+
+// Reemplaza el correo electrónico de un usuario en todos los campos de userEmailReferences
+func (service *UserPrivacyService) replaceEmailReferences(email string, pseudonym string) error {
+	for _, reference := range userEmailReferences {
+		filter := bson.M{reference.field: email}
+		opts := options.Update()
+
+		if parts := strings.SplitN(reference.field, ".$[item].", 2); len(parts) == 2 {
+			filter = bson.M{parts[0] + "." + parts[1]: email}
+			opts.SetArrayFilters(options.ArrayFilters{Filters: []interface{}{bson.M{"item." + parts[1]: email}}})
+		}
+
+		update := bson.M{"$set": bson.M{reference.field: pseudonym}}
+		if _, err := service.db.Collection(reference.collection).UpdateMany(ctx, filter, update, opts); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (service *UserPrivacyService) exportSessions(user models.User) (interface{}, error) {
+	var sessions []models.Session
+	opts := options.Find().SetSort(bson.M{"created_at": -1})
+	if err := findAll(service.db.Collection("sessions"), bson.M{"email": user.Email}, opts, &sessions); err != nil {
+		return nil, err
+	}
+
+	exported := []dataExportSession{}
+	for _, session := range sessions {
+		exported = append(exported, dataExportSession{
+			ID:        session.ID,
+			UserAgent: session.UserAgent,
+			ClientIP:  session.ClientIP,
+			IsBlocked: session.IsBlocked,
+			CreatedAt: session.CreatedAt,
+			ExpiresAt: session.ExpiresAt,
+		})
+	}
+
+	return exported, nil
+}
+
+func (service *UserPrivacyService) exportStatusHistory(user models.User) (interface{}, error) {
+	changes := []models.UserStatusChange{}
+	opts := options.Find().SetSort(bson.D{{Key: "changed_at", Value: -1}, {Key: "_id", Value: -1}})
+	err := findAll(service.db.Collection("user_status_changes"), bson.M{"user_id": user.ID}, opts, &changes)
+	return changes, err
+}
+
+func (service *UserPrivacyService) exportEmailChanges(user models.User) (interface{}, error) {
+	changes := []models.EmailChange{}
+	opts := options.Find().SetSort(bson.M{"created_at": -1})
+	err := findAll(service.db.Collection("email_changes"), bson.M{"user_id": user.ID}, opts, &changes)
+	return changes, err
+}
+
+func (service *UserPrivacyService) exportPhoneCodes(user models.User) (interface{}, error) {
+	codes := []models.PhoneCode{}
+	opts := options.Find().SetSort(bson.M{"created_at": -1})
+	err := findAll(service.db.Collection("phone_codes"), bson.M{"user_id": user.ID}, opts, &codes)
+	return codes, err
+}
+
+func (service *UserPrivacyService) exportInvitations(user models.User) (interface{}, error) {
+	invitations := []models.Invitation{}
+	opts := options.Find().SetSort(bson.M{"created_at": -1})
+	err := findAll(service.db.Collection("invitations"), bson.M{"user_id": user.ID}, opts, &invitations)
+	return invitations, err
+}
+
+func (service *UserPrivacyService) exportLogins(user models.User) (interface{}, error) {
+	events := []models.LoginEvent{}
+	opts := options.Find().SetSort(bson.M{"occurred_at": -1})
+	err := findAll(service.db.Collection("login_events"), bson.M{"user_id": user.ID}, opts, &events)
+	return events, err
+}
+
+func (service *UserPrivacyService) exportVersions(user models.User) (interface{}, error) {
+	versions := []models.UserVersion{}
+	opts := options.Find().SetSort(bson.M{"version": -1})
+	err := findAll(service.db.Collection("user_versions"), bson.M{"user_id": user.ID}, opts, &versions)
+	return versions, err
+}
+
+// Eventos de auditoría hechos por el usuario o sobre él. La IP y el navegador se incluyen sólo si la solicitud la hizo él
+func (service *UserPrivacyService) exportAuditEvents(user models.User) (interface{}, error) {
+	events := []models.AuditEvent{}
+	filter := bson.M{"$or": bson.A{
+		bson.M{"actor": user.Email},
+		bson.M{"target.type": AuditTargetUsers, "target.id": user.ID.Hex()},
+	}}
+	opts := options.Find().SetSort(bson.M{"occurred_at": -1})
+	if err := findAll(service.db.Collection("audit_events"), filter, opts, &events); err != nil {
+		return nil, err
+	}
+
+	for i := range events {
+		if events[i].Actor != "" && events[i].Actor != user.Email {
+			events[i].ClientIP, events[i].UserAgent = "", ""
+		}
+	}
+
+	return events, nil
+}
+
+func (service *UserPrivacyService) exportBulkOperations(user models.User) (interface{}, error) {
+	var operations []models.UserBulkOperation
+	opts := options.Find().SetSort(bson.M{"created_at": -1})
+	if err := findAll(service.db.Collection("user_bulk_operations"), bson.M{"items.user_id": user.ID}, opts, &operations); err != nil {
+		return nil, err
+	}
+
+	exported := []dataExportBulkOperation{}
+	for _, operation := range operations {
+		for _, item := range operation.Items {
+			if item.UserID != user.ID {
+				continue
+			}
+
+			exported = append(exported, dataExportBulkOperation{
+				ID:        operation.ID,
+				Action:    operation.Action,
+				Reason:    operation.Reason,
+				Result:    item,
+				CreatedBy: operation.CreatedBy,
+				CreatedAt: operation.CreatedAt,
+			})
+		}
+	}
+
+	return exported, nil
+}
+
+func (service *UserPrivacyService) exportActivity(user models.User) (interface{}, error) {
+	activity := []dataExportActivity{}
+
+	var changes []models.UserStatusChange
+	if err := findAll(service.db.Collection("user_status_changes"), bson.M{"changed_by": user.Email}, nil, &changes); err != nil {
+		return nil, err
+	}
+	for _, change := range changes {
+		summary := fmt.Sprintf("cambió el estado del usuario %s de \"%s\" a \"%s\"", change.UserID.Hex(), change.From, change.To)
+		activity = append(activity, dataExportActivity{Type: "status_change", ID: change.ID, Summary: summary, At: change.ChangedAt})
+	}
+
+	var operations []models.UserBulkOperation
+	if err := findAll(service.db.Collection("user_bulk_operations"), bson.M{"created_by": user.Email}, nil, &operations); err != nil {
+		return nil, err
+	}
+	for _, operation := range operations {
+		summary := fmt.Sprintf("inició la operación masiva \"%s\" sobre %d usuarios", operation.Action, operation.Total)
+		activity = append(activity, dataExportActivity{Type: "bulk_operation", ID: operation.ID, Summary: summary, At: operation.CreatedAt})
+	}
+
+	var imports []models.UserImport
+	if err := findAll(service.db.Collection("user_imports"), bson.M{"created_by": user.Email}, nil, &imports); err != nil {
+		return nil, err
+	}
+	for _, userImport := range imports {
+		summary := fmt.Sprintf("importó el archivo \"%s\"", userImport.FileName)
+		activity = append(activity, dataExportActivity{Type: "import", ID: userImport.ID, Summary: summary, At: userImport.CreatedAt})
+	}
+
+	var exports []models.UserExport
+	if err := findAll(service.db.Collection("user_exports"), bson.M{"created_by": user.Email}, nil, &exports); err != nil {
+		return nil, err
+	}
+	for _, export := range exports {
+		summary := fmt.Sprintf("exportó usuarios en formato %s", export.Format)
+		activity = append(activity, dataExportActivity{Type: "export", ID: export.ID, Summary: summary, At: export.CreatedAt})
+	}
+
+	var schemas []models.CustomAttributeSchema
+	if err := findAll(service.db.Collection("custom_attribute_schemas"), bson.M{"created_by": user.Email}, nil, &schemas); err != nil {
+		return nil, err
+	}
+	for _, schema := range schemas {
+		summary := fmt.Sprintf("publicó la versión %d del esquema de atributos personalizados", schema.Version)
+		activity = append(activity, dataExportActivity{Type: "custom_attribute_schema", ID: schema.ID, Summary: summary, At: schema.CreatedAt})
+	}
+
+	var invitations []models.Invitation
+	if err := findAll(service.db.Collection("invitations"), bson.M{"invited_by": user.Email}, nil, &invitations); err != nil {
+		return nil, err
+	}
+	for _, invitation := range invitations {
+		summary := fmt.Sprintf("invitó a %s", invitation.Email)
+		activity = append(activity, dataExportActivity{Type: "invitation", ID: invitation.ID, Summary: summary, At: invitation.CreatedAt})
+	}
+
+	sort.Slice(activity, func(i, j int) bool { return activity[i].At.After(activity[j].At) })
+	return activity, nil
+}
+
+/** Obtiene el correo electrónico con el que se reemplaza el de un usuario borrado
+ *
+ * @param id primitive.ObjectID "El id del usuario"
+ * @return string "El seudónimo, único para cada usuario"
+ */
+func erasedUserEmail(id primitive.ObjectID) string {
+	return fmt.Sprintf("erased-%s@%s", id.Hex(), erasedEmailDomain)
+}
+
+func findAll(collection *mongo.Collection, filter bson.M, opts *options.FindOptions, results interface{}) error {
+	if opts == nil {
+		opts = options.Find()
+	}
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return err
+	}
+
+	return cursor.All(ctx, results)
+}
+
+func writeZipJSON(archive *zip.Writer, name string, value interface{}) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeZipFile(archive, name, data)
+}
+
+func writeZipFile(archive *zip.Writer, name string, data []byte) error {
+	writer, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = writer.Write(data)
+	return err
+}
+
+func NewUserPrivacyService(db *mongo.Database, store storage.IStorage) IUserPrivacyService {
+	return &UserPrivacyService{db: db, storage: store}
+}
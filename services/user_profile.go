@@ -0,0 +1,93 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/maramal/user-service/models"
+	"github.com/maramal/user-service/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Campos que el propio usuario puede modificar con PATCH /api/me. El resto (correo, tipo, estado,
+// atributos personalizados) sólo lo modifican los administradores
+var profileUserFields = map[string]bool{
+	"first_name": true,
+	"last_name":  true,
+}
+
+type ChangeOwnPasswordRequest struct {
+	CurrentPassword      string `json:"current_password" binding:"required"`
+	Password             string `json:"password"`
+	PasswordConfirmation string `json:"password_confirmation"`
+}
+
+/** Modifica el perfil del propio usuario. Igual que PatchUser, pero sólo acepta los campos de profileUserFields
+ *
+ * @param id string "El id del usuario"
+ * @param req PatchUserRequest "Los cambios a aplicar"
+ * @return UpdateUserResponse "El usuario actualizado"
+ * @return err error "El error de la operación"
+ */
+func (service *UserService) PatchProfile(userId string, req PatchUserRequest) (response UpdateUserResponse, err error) {
+	for field := range req.Patch {
+		if !profileUserFields[field] {
+			err = fmt.Errorf("%w: el campo \"%s\" no se puede modificar", ErrInvalidUserData, field)
+			return
+		}
+	}
+	for _, field := range req.Fields {
+		if !profileUserFields[field] {
+			err = fmt.Errorf("%w: el campo \"%s\" no se puede modificar", ErrInvalidUserData, field)
+			return
+		}
+	}
+
+	return service.PatchUser(userId, req)
+}
+
+/** Cambia la contraseña del propio usuario después de verificar la contraseña actual.
+ * Las demás sesiones del usuario se bloquean
+ *
+ * @param id string "El id del usuario"
+ * @param sessionId string "El id de la sesión que hace el cambio, que sigue siendo válida"
+ * @param req ChangeOwnPasswordRequest "La contraseña actual y la nueva"
+ * @return err error "El error de la operación"
+ */
+func (service *UserService) ChangeOwnPassword(userId string, sessionId string, req ChangeOwnPasswordRequest) (err error) {
+	collection := service.db.Collection("users")
+	var user models.User
+
+	id, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		err = ErrUserNotFound
+		return
+	}
+
+	err = collection.FindOne(ctx, bson.M{"_id": id, "deleted_at": nil}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		err = ErrUserNotFound
+	}
+	if err != nil {
+		return
+	}
+
+	if utils.CheckPassword(req.CurrentPassword, user.Password) != nil {
+		err = fmt.Errorf("%w: la contraseña actual no es correcta", ErrInvalidUserData)
+		return
+	}
+
+	if err = service.ChangePassword(userId, ChangePasswordRequest{
+		Password:             req.Password,
+		PasswordConfirmation: req.PasswordConfirmation,
+		ChangedBy:            user.Email,
+	}); err != nil {
+		return
+	}
+
+	sessionID, _ := primitive.ObjectIDFromHex(sessionId)
+	filter := bson.M{"email": user.Email, "is_blocked": false, "_id": bson.M{"$ne": sessionID}}
+	_, err = service.db.Collection("sessions").UpdateMany(ctx, filter, bson.M{"$set": bson.M{"is_blocked": true}})
+	return
+}
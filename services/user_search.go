@@ -0,0 +1,102 @@
+package services
+
+import (
+	"errors"
+	"regexp"
+
+	"github.com/maramal/user-service/models"
+	"github.com/maramal/user-service/utils"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+	// Cantidad máxima de palabras consideradas en una búsqueda
+	maxSearchWords = 5
+)
+
+var ErrInvalidSearchQuery = errors.New("la búsqueda debe contener al menos una palabra")
+
+type SearchUsersRequest struct {
+	Query string `form:"q" binding:"required"`
+	Limit int    `form:"limit"`
+}
+
+type SearchUserResult struct {
+	models.User `bson:",inline"`
+	// Relevancia del resultado: mayor cuanto más palabras coinciden en forma exacta
+	Score float64 `bson:"score" json:"score"`
+}
+
+type SearchUsersResponse struct {
+	Users []SearchUserResult `json:"users"`
+}
+
+/** Busca usuarios por nombre, apellido o correo electrónico, sin distinguir mayúsculas ni acentos
+ *
+ * Cada palabra de la búsqueda debe coincidir con el inicio de algún término del usuario.
+ * Los resultados se ordenan por relevancia y luego por apellido y nombre, sin distinguir mayúsculas ni acentos.
+ *
+ * @param req SearchUsersRequest "El texto a buscar y la cantidad de resultados"
+ * @return SearchUsersResponse "Los usuarios encontrados"
+ * @return err error "El error de la operación"
+ */
+func (service *UserService) SearchUsers(req SearchUsersRequest) (response SearchUsersResponse, err error) {
+	collection := service.db.Collection("users")
+
+	words := utils.SearchWords(req.Query)
+	if len(words) == 0 {
+		err = ErrInvalidSearchQuery
+		return
+	}
+	if len(words) > maxSearchWords {
+		words = words[:maxSearchWords]
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+
+	conditions := bson.A{}
+	score := bson.A{}
+	for _, word := range words {
+		conditions = append(conditions, bson.M{"search_terms": bson.M{"$regex": "^" + regexp.QuoteMeta(word)}})
+		// Una coincidencia exacta vale más que una por prefijo
+		score = append(score, bson.M{"$cond": bson.A{bson.M{"$in": bson.A{word, "$search_terms"}}, 2, 1}})
+	}
+
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{"$and": conditions, "deleted_at": nil}},
+		// Sin collation, para que el $match pueda usar el índice de search_terms. Los nombres se ordenan
+		// con sort_last_name y sort_first_name, que ya están normalizados como los términos de búsqueda
+		bson.M{"$addFields": bson.M{"score": bson.M{"$add": score}}},
+		bson.M{"$sort": bson.D{
+			{Key: "score", Value: -1},
+			{Key: "sort_last_name", Value: 1},
+			{Key: "sort_first_name", Value: 1},
+			{Key: "_id", Value: 1},
+		}},
+		bson.M{"$limit": limit},
+		bson.M{"$project": bson.M{"password": 0, "sort_last_name": 0, "sort_first_name": 0}},
+	}
+
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return
+	}
+
+	response.Users = []SearchUserResult{}
+	err = cursor.All(ctx, &response.Users)
+	return
+}
+
+// Agrega a set el nombre y el apellido normalizados del usuario con los que se ordena la búsqueda
+func setUserSortNames(set bson.M, user models.User) {
+	set["sort_first_name"] = utils.NormalizeSearchText(user.FirstName)
+	set["sort_last_name"] = utils.NormalizeSearchText(user.LastName)
+}
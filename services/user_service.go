@@ -2,7 +2,10 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/maramal/user-service/models"
@@ -10,34 +13,62 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrUserNotFound    = errors.New("no se encontró el usuario")
+	ErrEmailTaken      = errors.New("el correo electrónico ya está ingresado en la base de datos")
+	ErrInvalidUserData = errors.New("los datos del usuario no son válidos")
+	ErrVersionMismatch = errors.New("el usuario fue modificado por otra solicitud")
+)
+
+const (
+	minPasswordLength = 6
+	defaultUserType   = "user"
 )
 
 type CreateUserRequest struct {
-	FirstName    string `json:"first_name"`
-	LastName     string `json:"last_name"`
-	Email        string `json:"email"`
-	Password     string `json:"password"`
-	ProfileImage string `json:"profile_image"`
-	Type         string `json:"type"`
-	Status       string `json:"status"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Email     string `json:"email"`
+	Password  string `json:"password"`
+	Type      string `json:"type"`
+	Status    string `json:"status"`
+	// Atributos personalizados, según el esquema definido por los administradores
+	Custom map[string]interface{} `json:"custom"`
+	// Fecha en que vence la cuenta, para usuarios temporales
+	ExpiresAt *time.Time `json:"expires_at"`
+	// Correo electrónico de quien crea el usuario, para el historial de versiones
+	CreatedBy string `json:"-"`
 }
 
 type UpdateUserRequest struct {
-	FirstName    string `json:"first_name"`
-	LastName     string `json:"last_name"`
-	Email        string `json:"email"`
-	ProfileImage string `json:"profile_image"`
-	Type         string `json:"type"`
-	Status       string `json:"status"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Email     string `json:"email"`
+	Type      string `json:"type"`
+	Status    string `json:"status"`
+	// Atributos personalizados a modificar. Se combinan con los actuales como en JSON Merge Patch
+	Custom map[string]interface{} `json:"custom"`
+	// Versión esperada del usuario (If-Match). Si es nil no se verifica
+	IfVersion *int64 `json:"-"`
+	// Correo electrónico de quien modifica el usuario, para el historial de versiones
+	ChangedBy string `json:"-"`
 }
 
 type ChangePasswordRequest struct {
 	Password             string `json:"password"`
 	PasswordConfirmation string `json:"password_confirmation"`
+	// Correo electrónico de quien cambia la contraseña, para el historial de versiones
+	ChangedBy string `json:"-"`
 }
 
 type GetUsersResponse struct {
-	Users []models.User `json:"users"`
+	Users      []models.User `json:"users"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+	PrevCursor string        `json:"prev_cursor,omitempty"`
+	Total      *int64        `json:"total,omitempty"`
 }
 
 type CreateUserResponse struct {
@@ -48,22 +79,40 @@ type GetUserResponse struct {
 	User models.User `json:"user"`
 }
 
+type GetEmailCollisionsResponse struct {
+	Collisions []models.EmailCollision `json:"collisions"`
+}
+
 type UpdateUserResponse struct {
 	User models.User `json:"user"`
 }
 
 type IUserService interface {
-	GetUsers() (response GetUsersResponse, err error)
+	GetUsers(req GetUsersRequest) (response GetUsersResponse, err error)
 	CreateUser(req CreateUserRequest) (response CreateUserResponse, err error)
 
 	GetUser(id string) (response GetUserResponse, err error)
 	UpdateUser(id string, req UpdateUserRequest) (response UpdateUserResponse, err error)
-	DeleteUser(id string) (err error)
+	PatchUser(id string, req PatchUserRequest) (response UpdateUserResponse, err error)
+	DeleteUser(id string, deletedBy string, reason string, ifVersion *int64) (err error)
+	RestoreUser(id string, restoredBy string) (err error)
+	PurgeDeletedUsers(retention time.Duration) (deleted int64, err error)
 
 	ChangePassword(id string, req ChangePasswordRequest) (err error)
-	SetSuperadmin(id string, enable bool) (err error)
+	PatchProfile(id string, req PatchUserRequest) (response UpdateUserResponse, err error)
+	ChangeOwnPassword(id string, sessionId string, req ChangeOwnPasswordRequest) (err error)
+	SetSuperadmin(id string, enable bool, changedBy string) (err error)
+
+	ChangeStatus(id string, status string, reason string, changedBy string) (response UpdateUserResponse, err error)
+	GetStatusHistory(id string) (response GetStatusHistoryResponse, err error)
+
+	GetUserHistory(id string) (response GetUserHistoryResponse, err error)
+	GetUserVersion(id string, version int64) (response GetUserVersionResponse, err error)
+	RestoreUserVersion(id string, version int64, restoredBy string, ifVersion *int64) (response UpdateUserResponse, err error)
 
 	GetUserByEmail(email string) (response GetUserResponse, err error)
+	GetEmailCollisions() (response GetEmailCollisionsResponse, err error)
+	SearchUsers(req SearchUsersRequest) (response SearchUsersResponse, err error)
 }
 
 type UserService struct {
@@ -72,26 +121,104 @@ type UserService struct {
 
 var ctx = context.Background()
 
-/** Obtiene todos los usuarios
+/** Obtiene una página de usuarios
  *
- * @return GetUsersResponse "Los usuarios"
+ * @param req GetUsersRequest "Los filtros, el ordenamiento y el cursor de la página"
+ * @return GetUsersResponse "Los usuarios y los cursores de las páginas siguiente y anterior"
  * @return err error "El error de la operación"
  */
-func (service *UserService) GetUsers() (response GetUsersResponse, err error) {
-	var users []models.User
+func (service *UserService) GetUsers(req GetUsersRequest) (response GetUsersResponse, err error) {
 	collection := service.db.Collection("users")
 
-	cursor, err := collection.Find(ctx, bson.M{})
+	fields, err := parseUsersSort(req.Sort)
+	if err != nil {
+		return
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultUsersPageSize
+	}
+	if limit > maxUsersPageSize {
+		limit = maxUsersPageSize
+	}
+
+	filter, err := buildUsersFilter(service.db, req)
+	if err != nil {
+		return
+	}
+	query := filter
+
+	var position usersCursor
+	hasCursor := req.Cursor != ""
+	if hasCursor {
+		if position, err = decodeUsersCursor(req.Cursor, fields); err != nil {
+			return
+		}
+
+		query = bson.M{"$and": bson.A{filter, keysetFilter(fields, position)}}
+	}
+
+	// Se pide un usuario extra para saber si hay más páginas
+	opts := options.Find().
+		SetSort(sortDocument(fields, position.Backward)).
+		SetLimit(int64(limit + 1))
+
+	cursor, err := collection.Find(ctx, query, opts)
 	if err != nil {
 		return
 	}
+	defer cursor.Close(ctx)
 
-	if err = cursor.All(ctx, &users); err != nil {
+	users := []models.User{}
+	docs := []bson.Raw{}
+	for cursor.Next(ctx) {
+		var user models.User
+		if err = cursor.Decode(&user); err != nil {
+			return
+		}
+
+		user.Password = ""
+		users = append(users, user)
+		docs = append(docs, append(bson.Raw{}, cursor.Current...))
+	}
+	if err = cursor.Err(); err != nil {
 		return
 	}
 
-	for i := 0; i < len(users); i++ {
-		users[i].Password = ""
+	hasMore := len(users) > limit
+	if hasMore {
+		users = users[:limit]
+		docs = docs[:limit]
+	}
+
+	if position.Backward {
+		for i, j := 0, len(users)-1; i < j; i, j = i+1, j-1 {
+			users[i], users[j] = users[j], users[i]
+			docs[i], docs[j] = docs[j], docs[i]
+		}
+	}
+
+	if len(users) > 0 {
+		if (!position.Backward && hasMore) || (position.Backward && hasCursor) {
+			if response.NextCursor, err = encodeUsersCursor(fields, docs[len(docs)-1], false); err != nil {
+				return
+			}
+		}
+
+		if (position.Backward && hasMore) || (!position.Backward && hasCursor) {
+			if response.PrevCursor, err = encodeUsersCursor(fields, docs[0], true); err != nil {
+				return
+			}
+		}
+	}
+
+	if req.IncludeTotal {
+		var total int64
+		if total, err = collection.CountDocuments(ctx, filter); err != nil {
+			return
+		}
+		response.Total = &total
 	}
 
 	response.Users = users
@@ -107,6 +234,10 @@ func (service *UserService) GetUsers() (response GetUsersResponse, err error) {
 func (service *UserService) CreateUser(req CreateUserRequest) (response CreateUserResponse, err error) {
 	collection := service.db.Collection("users")
 
+	if err = ValidateCreateUserRequest(&req); err != nil {
+		return
+	}
+
 	filter := bson.M{"email": req.Email}
 	existingUser, err := collection.CountDocuments(ctx, filter)
 	if err != nil {
@@ -114,34 +245,113 @@ func (service *UserService) CreateUser(req CreateUserRequest) (response CreateUs
 	}
 
 	if existingUser > 0 {
-		err = errors.New("el correo electrónico ya está ingresado en la base de datos")
+		err = ErrEmailTaken
 		return
 	}
 
+	user, err := newUserFromRequest(req)
+	if err != nil {
+		return
+	}
+
+	if user.Custom, err = validateCustomAttributes(service.db, req.Custom); err != nil {
+		return
+	}
+	if user.SearchTerms, err = userSearchTerms(service.db, user); err != nil {
+		return
+	}
+
+	// El índice único de email evita duplicados si dos solicitudes crean el mismo usuario a la vez
+	result, err := collection.InsertOne(ctx, user)
+	if mongo.IsDuplicateKeyError(err) {
+		err = ErrEmailTaken
+	}
+	if err != nil {
+		return
+	}
+
+	id := result.InsertedID.(primitive.ObjectID)
+	if err = recordUserVersion(service.db, id, UserVersionCreate, req.CreatedBy); err != nil {
+		return
+	}
+
+	response.UserID = id.Hex()
+	return
+}
+
+/** Valida los valores de un usuario a crear y completa los valores predeterminados
+ *
+ * @param req *CreateUserRequest "Los valores del usuario a crear"
+ * @return err error "El error con el primer valor inválido"
+ */
+func ValidateCreateUserRequest(req *CreateUserRequest) (err error) {
+	req.FirstName = strings.TrimSpace(req.FirstName)
+	req.LastName = strings.TrimSpace(req.LastName)
+
+	if req.Type == "" {
+		req.Type = defaultUserType
+	}
+	if req.Status == "" {
+		req.Status = models.UserStatusActive
+	}
+
+	if req.Email, err = normalizeEmail(req.Email); err != nil {
+		return
+	}
+
+	if len(req.Password) < minPasswordLength {
+		err = fmt.Errorf("%w: la contraseña debe tener al menos %d caracteres", ErrInvalidUserData, minPasswordLength)
+		return
+	}
+
+	if _, ok := rolePermissions[req.Type]; !ok {
+		err = fmt.Errorf("%w: el tipo de usuario \"%s\" no existe", ErrInvalidUserData, req.Type)
+		return
+	}
+
+	if !containsString(initialUserStatuses, req.Status) {
+		err = fmt.Errorf("%w: un usuario sólo se puede crear con el estado %s", ErrInvalidUserData, strings.Join(initialUserStatuses, ", "))
+		return
+	}
+
+	err = validateExpiration(req.ExpiresAt)
+	return
+}
+
+// Verifica que el correo electrónico tenga un formato válido y lo normaliza (ver utils.NormalizeEmail)
+func normalizeEmail(email string) (string, error) {
+	normalized, err := utils.NormalizeEmail(email)
+	if err != nil {
+		return "", fmt.Errorf("%w: el correo electrónico \"%s\" no es válido", ErrInvalidUserData, strings.TrimSpace(email))
+	}
+
+	return normalized, nil
+}
+
+// Crea el documento de un usuario nuevo a partir de una solicitud ya validada
+func newUserFromRequest(req CreateUserRequest) (user models.User, err error) {
 	password, err := utils.HashPassword(req.Password)
 	if err != nil {
 		return
 	}
 
-	user := models.User{
+	user = models.User{
 		FirstName:         req.FirstName,
 		LastName:          req.LastName,
 		Email:             req.Email,
 		Password:          password,
 		Type:              req.Type,
 		Status:            req.Status,
-		ProfileImage:      req.ProfileImage,
 		PasswordChangedAt: time.Now(),
 		CreatedAt:         time.Now(),
 		UpdatedAt:         time.Now(),
+		Version:           1,
+		SearchTerms:       utils.UserSearchTerms(req.FirstName, req.LastName, req.Email),
+		SortFirstName:     utils.NormalizeSearchText(req.FirstName),
+		SortLastName:      utils.NormalizeSearchText(req.LastName),
+		ExpiresAt:         req.ExpiresAt,
 	}
 
-	result, err := collection.InsertOne(ctx, user)
-	if err != nil {
-		return
-	}
-
-	response.UserID = result.InsertedID.(primitive.ObjectID).Hex()
 	return
 }
 
@@ -160,14 +370,14 @@ func (service *UserService) GetUser(userId string) (response GetUserResponse, er
 		return
 	}
 
-	filter := bson.M{"_id": id}
+	filter := bson.M{"_id": id, "deleted_at": nil}
 
 	count, err := collection.CountDocuments(ctx, filter)
 	if err != nil {
 		return
 	}
 	if count == 0 {
-		err = errors.New("no se encontró el usuario")
+		err = ErrUserNotFound
 		return
 	}
 
@@ -188,7 +398,7 @@ func (service *UserService) GetUser(userId string) (response GetUserResponse, er
 
 }
 
-/** Actualiza un usuario
+/** Actualiza un usuario. Los campos vacíos de la solicitud no se modifican
  *
  * @param req UpdateUserRequest "Los valores del usuario a actualizar"
  * @param id string "El id del usuario"
@@ -196,75 +406,152 @@ func (service *UserService) GetUser(userId string) (response GetUserResponse, er
  * @return err error "El error de la operación"
  */
 func (service *UserService) UpdateUser(userId string, req UpdateUserRequest) (response UpdateUserResponse, err error) {
-	collection := service.db.Collection("users")
-	var user models.User
+	values := map[string]string{
+		"first_name": req.FirstName,
+		"last_name":  req.LastName,
+		"email":      req.Email,
+		"type":       req.Type,
+		"status":     req.Status,
+	}
 
+	patch := map[string]json.RawMessage{}
+	for field, value := range values {
+		if value == "" {
+			continue
+		}
+		if patch[field], err = json.Marshal(value); err != nil {
+			return
+		}
+	}
+
+	if req.Custom != nil {
+		if patch["custom"], err = json.Marshal(req.Custom); err != nil {
+			return
+		}
+	}
+
+	return service.PatchUser(userId, PatchUserRequest{Patch: patch, IfVersion: req.IfVersion, ChangedBy: req.ChangedBy})
+}
+
+/** Elimina un usuario de forma lógica y bloquea sus sesiones. El usuario se elimina
+ * definitivamente cuando se cumple el período de retención (ver PurgeDeletedUsers)
+ *
+ * @param id string "El id del usuario"
+ * @param deletedBy string "El correo electrónico de quien elimina al usuario"
+ * @param reason string "El motivo de la eliminación, que queda en el historial de estados"
+ * @param ifVersion *int64 "La versión esperada del usuario. Si es nil no se verifica"
+ * @return err error "El error de la operación"
+ */
+func (service *UserService) DeleteUser(userId string, deletedBy string, reason string, ifVersion *int64) (err error) {
 	id, err := primitive.ObjectIDFromHex(userId)
 	if err != nil {
 		return
 	}
 
-	filter := bson.M{"_id": id}
+	extra := bson.M{"deleted_at": time.Now(), "deleted_by": deletedBy}
+	_, err = transitionUserStatus(service.db, id, ifVersion, models.UserStatusDeleted, reason, deletedBy, extra)
+	return
+}
 
-	count, err := collection.CountDocuments(ctx, filter)
+/** Restaura un usuario eliminado que aún no fue eliminado definitivamente. El usuario
+ * vuelve al estado que tenía antes de ser eliminado
+ *
+ * @param id string "El id del usuario"
+ * @param restoredBy string "El correo electrónico de quien restaura al usuario"
+ * @return err error "ErrUserErased si se borraron los datos del usuario"
+ */
+func (service *UserService) RestoreUser(userId string, restoredBy string) (err error) {
+	collection := service.db.Collection("users")
+
+	id, err := primitive.ObjectIDFromHex(userId)
 	if err != nil {
 		return
 	}
-	if count == 0 {
-		err = errors.New("no se encontró el usuario")
-		return
-	}
 
-	cursor, err := collection.Find(ctx, filter)
+	status, err := statusBefore(service.db, id, models.UserStatusDeleted)
 	if err != nil {
 		return
 	}
 
-	if err = cursor.Decode(&user); err != nil {
-		return
+	now := time.Now()
+	reason := "usuario restaurado"
+	filter := bson.M{"_id": id, "deleted_at": bson.M{"$ne": nil}, "erased_at": nil}
+	update := bson.M{
+		"$unset": bson.M{"deleted_at": "", "deleted_by": ""},
+		"$set":   bson.M{"status": status, "status_reason": reason, "status_changed_at": now, "updated_at": now},
+		"$inc":   bson.M{"version": 1},
 	}
 
-	user.UpdatedAt = time.Now()
-
-	updatedValues, err := utils.ToDoc(user)
+	result, err := collection.UpdateOne(ctx, filter, update)
 	if err != nil {
 		return
 	}
-
-	if req.ProfileImage != "" && req.ProfileImage != user.ProfileImage {
-		// guardar imagen, etc
+	if result.MatchedCount == 0 {
+		err = ErrUserNotFound
+		if count, _ := collection.CountDocuments(ctx, bson.M{"_id": id, "erased_at": bson.M{"$ne": nil}}); count > 0 {
+			err = ErrUserErased
+		}
+		return
 	}
 
-	update := bson.D{{"$set", updatedValues}}
-	if _, err = collection.UpdateOne(ctx, filter, update); err != nil {
+	change := models.UserStatusChange{
+		UserID:    id,
+		From:      models.UserStatusDeleted,
+		To:        status,
+		Reason:    reason,
+		ChangedBy: restoredBy,
+		ChangedAt: now,
+	}
+	if _, err = service.db.Collection("user_status_changes").InsertOne(ctx, change); err != nil {
 		return
 	}
 
-	user.Password = ""
-
-	response.User = user
+	err = recordUserVersion(service.db, id, UserVersionRestore, restoredBy)
 	return
 }
 
-/** Elimina un usuario
+/** Elimina definitivamente los usuarios eliminados hace más tiempo que el período de retención
  *
- * @param id string "El id del usuario"
+ * @param retention time.Duration "El período de retención de los usuarios eliminados"
+ * @return int64 "La cantidad de usuarios eliminados"
  * @return err error "El error de la operación"
  */
-func (service *UserService) DeleteUser(userId string) (err error) {
+func (service *UserService) PurgeDeletedUsers(retention time.Duration) (deleted int64, err error) {
 	collection := service.db.Collection("users")
 
-	id, err := primitive.ObjectIDFromHex(userId)
+	// Los usuarios con los datos borrados no tienen datos personales y se conservan para no romper las referencias
+	filter := bson.M{"deleted_at": bson.M{"$lte": time.Now().Add(-retention)}, "erased_at": nil}
+
+	cursor, err := collection.Find(ctx, filter)
 	if err != nil {
 		return
 	}
+	defer cursor.Close(ctx)
 
-	filter := bson.M{"id": id}
-	result := collection.FindOneAndDelete(ctx, filter)
-	if err = result.Err(); err != nil {
-		return
+	for cursor.Next(ctx) {
+		var user models.User
+		if err = cursor.Decode(&user); err != nil {
+			return
+		}
+
+		if err = deleteUserSessions(service.db, user.Email); err != nil {
+			return
+		}
+		if _, err = service.db.Collection("user_versions").DeleteMany(ctx, bson.M{"user_id": user.ID}); err != nil {
+			return
+		}
+		if _, err = service.db.Collection("login_events").DeleteMany(ctx, bson.M{"user_id": user.ID}); err != nil {
+			return
+		}
+
+		var result *mongo.DeleteResult
+		if result, err = collection.DeleteOne(ctx, bson.M{"_id": user.ID}); err != nil {
+			return
+		}
+		deleted += result.DeletedCount
 	}
 
+	err = cursor.Err()
 	return
 }
 
@@ -276,26 +563,18 @@ func (service *UserService) DeleteUser(userId string) (err error) {
  */
 func (service *UserService) ChangePassword(userId string, req ChangePasswordRequest) (err error) {
 	collection := service.db.Collection("users")
-	var user models.User
 
 	id, err := primitive.ObjectIDFromHex(userId)
 	if err != nil {
 		return
 	}
 
-	if req.Password == "" {
-		err = errors.New("la contraseña no puede estar vacía")
+	if len(req.Password) < minPasswordLength {
+		err = fmt.Errorf("%w: la contraseña debe tener al menos %d caracteres", ErrInvalidUserData, minPasswordLength)
 		return
 	}
 	if req.Password != req.PasswordConfirmation {
-		err = errors.New("las contraseñas no coinciden")
-		return
-	}
-
-	filter := bson.M{"_id": id}
-
-	result := collection.FindOne(ctx, filter)
-	if err = result.Err(); err != nil {
+		err = fmt.Errorf("%w: las contraseñas no coinciden", ErrInvalidUserData)
 		return
 	}
 
@@ -304,19 +583,35 @@ func (service *UserService) ChangePassword(userId string, req ChangePasswordRequ
 		return
 	}
 
-	user.Password = password
-	user.PasswordChangedAt = time.Now()
+	filter := bson.M{"_id": id, "deleted_at": nil}
+	update := bson.M{
+		"$set": bson.M{
+			"password":            password,
+			"password_changed_at": time.Now(),
+			"updated_at":          time.Now(),
+		},
+		"$unset": bson.M{"password_reset_required": ""},
+		"$inc":   bson.M{"version": 1},
+	}
 
-	updatedValues, err := utils.ToDoc(user)
+	var user models.User
+	opts := options.FindOneAndUpdate().SetProjection(bson.M{"email": 1, "password_reset_required": 1})
+	err = collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		err = ErrUserNotFound
+	}
 	if err != nil {
 		return
 	}
 
-	update := bson.D{{"$set", updatedValues}}
-	if _, err = collection.UpdateOne(ctx, filter, update); err != nil {
-		return
+	// Las sesiones abiertas para cambiar la contraseña vuelven a poder usarse normalmente
+	if user.PasswordResetRequired {
+		if err = clearSessionsPasswordReset(service.db, user.Email); err != nil {
+			return
+		}
 	}
 
+	err = recordUserVersion(service.db, id, UserVersionPassword, req.ChangedBy)
 	return
 }
 
@@ -324,42 +619,51 @@ func (service *UserService) ChangePassword(userId string, req ChangePasswordRequ
  *
  * @param id string "El id del usuario"
  * @param enable bool "Si se desea habilitar o deshabilitar"
+ * @param changedBy string "El correo electrónico de quien hace el cambio"
  * @return err error "El error de la operación"
  */
-func (service *UserService) SetSuperadmin(userId string, enable bool) (err error) {
+func (service *UserService) SetSuperadmin(userId string, enable bool, changedBy string) (err error) {
 	collection := service.db.Collection("users")
-	var user models.User
 
 	id, err := primitive.ObjectIDFromHex(userId)
 	if err != nil {
 		return
 	}
 
-	filter := bson.M{"_id": id}
-	err = collection.FindOne(ctx, filter).Decode(&user)
-	if err != nil {
-		return
-	}
-
+	userType := "user"
 	if enable {
-		user.Type = "superadmin"
-	} else {
-		user.Type = "user"
+		userType = "superadmin"
 	}
 
-	user.UpdatedAt = time.Now()
+	filter := bson.M{"_id": id, "deleted_at": nil}
+	update := bson.M{
+		"$set": bson.M{"type": userType, "updated_at": time.Now()},
+		"$inc": bson.M{"version": 1},
+	}
 
-	updatedValues, err := utils.ToDoc(user)
+	var before models.User
+	opts := options.FindOneAndUpdate().SetProjection(bson.M{"email": 1, "type": 1})
+	err = collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&before)
+	if err == mongo.ErrNoDocuments {
+		err = ErrUserNotFound
+	}
 	if err != nil {
 		return
 	}
-	update := bson.D{{"$set", updatedValues}}
-	_, err = collection.UpdateOne(ctx, filter, update)
 
-	return err
+	if err = recordUserVersion(service.db, id, UserVersionUpdate, changedBy); err != nil {
+		return
+	}
+
+	// Las sesiones abiertas conservan los permisos del tipo anterior
+	if before.Type != userType {
+		err = blockUserSessions(service.db, before.Email)
+	}
+
+	return
 }
 
-/** Obtiene un usuario por su email
+/** Obtiene un usuario por su email. No distingue mayúsculas (ver utils.NormalizeEmail)
  *
  * @param email string "El email del usuario"
  * @return GetUserResponse "El usuario"
@@ -369,8 +673,16 @@ func (service *UserService) GetUserByEmail(email string) (response GetUserRespon
 	collection := service.db.Collection("users")
 	var user models.User
 
-	filter := bson.M{"email": email}
-	if err = collection.FindOne(ctx, filter).Decode(&user); err != nil {
+	// Primero se busca el correo tal cual, para no confundir a los usuarios que la migración no pudo
+	// normalizar por colisionar con otro (ver migrateUserEmails) con el dueño de la dirección normalizada
+	err = collection.FindOne(ctx, bson.M{"email": email, "deleted_at": nil}).Decode(&user)
+	if normalized, normalizeErr := utils.NormalizeEmail(email); err == mongo.ErrNoDocuments && normalizeErr == nil && normalized != email {
+		err = collection.FindOne(ctx, bson.M{"email": normalized, "deleted_at": nil}).Decode(&user)
+	}
+	if err == mongo.ErrNoDocuments {
+		err = ErrUserNotFound
+	}
+	if err != nil {
 		return
 	}
 
@@ -378,6 +690,55 @@ func (service *UserService) GetUserByEmail(email string) (response GetUserRespon
 	return
 }
 
+/** Obtiene los correos electrónicos compartidos por varios usuarios que la migración no pudo normalizar.
+ * Se resuelven cambiando el correo de los usuarios que no son dueños de la dirección o borrando sus datos
+ *
+ * @return GetEmailCollisionsResponse "Las colisiones detectadas en el último inicio del servicio"
+ * @return err error "El error de la operación"
+ */
+func (service *UserService) GetEmailCollisions() (response GetEmailCollisionsResponse, err error) {
+	opts := options.Find().SetSort(bson.M{"email": 1})
+	cursor, err := service.db.Collection("email_collisions").Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return
+	}
+
+	response.Collisions = []models.EmailCollision{}
+	err = cursor.All(ctx, &response.Collisions)
+	return
+}
+
+// Agrega al filtro la versión esperada del usuario, si se indicó
+func withVersion(filter bson.M, version *int64) bson.M {
+	if version == nil {
+		return filter
+	}
+
+	result := bson.M{"version": *version}
+	for key, value := range filter {
+		result[key] = value
+	}
+
+	return result
+}
+
+// Determina por qué una actualización con versión no encontró al usuario: no existe o fue modificado
+func (service *UserService) notMatchedError(id primitive.ObjectID, version *int64) error {
+	if version == nil {
+		return ErrUserNotFound
+	}
+
+	count, err := service.db.Collection("users").CountDocuments(ctx, bson.M{"_id": id, "deleted_at": nil})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrUserNotFound
+	}
+
+	return ErrVersionMismatch
+}
+
 func NewUserService(db *mongo.Database) IUserService {
 	return &UserService{db: db}
 }
@@ -0,0 +1,399 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	// Las imágenes de Docker no incluyen la base de datos de zonas horarias
+	_ "time/tzdata"
+
+	"github.com/maramal/user-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/text/language"
+)
+
+const (
+	SettingTypeString = "string"
+	SettingTypeBool   = "bool"
+	SettingTypeEnum   = "enum"
+
+	// Id del único documento de settings_config
+	settingsConfigID = "users"
+)
+
+var ErrInvalidSetting = errors.New("la preferencia no es válida")
+
+// Preferencia definida en el servidor
+type SettingDefinition struct {
+	Key         string      `json:"key"`
+	Type        string      `json:"type"`
+	Default     interface{} `json:"default"`
+	Values      []string    `json:"values,omitempty"`
+	Description string      `json:"description"`
+	// Valida un valor de tipo string y devuelve su forma normalizada
+	normalize func(value string) (string, error)
+}
+
+// Preferencias disponibles. Para agregar una preferencia basta con agregarla a esta lista
+var settingDefinitions = []SettingDefinition{
+	{
+		Key:         "locale",
+		Type:        SettingTypeString,
+		Default:     "es",
+		Description: "Idioma y región, como etiqueta BCP 47, por ejemplo es-UY",
+		normalize:   normalizeLocale,
+	},
+	{
+		Key:         "timezone",
+		Type:        SettingTypeString,
+		Default:     "UTC",
+		Description: "Zona horaria IANA, por ejemplo America/Montevideo",
+		normalize:   normalizeTimezone,
+	},
+	{
+		Key:         "theme",
+		Type:        SettingTypeEnum,
+		Default:     "system",
+		Values:      []string{"light", "dark", "system"},
+		Description: "Tema de la interfaz",
+	},
+	{
+		Key:         "notifications_email",
+		Type:        SettingTypeBool,
+		Default:     true,
+		Description: "Recibir notificaciones por correo electrónico",
+	},
+	{
+		Key:         "notifications_sms",
+		Type:        SettingTypeBool,
+		Default:     false,
+		Description: "Recibir notificaciones por SMS",
+	},
+}
+
+type UserSettingsResponse struct {
+	// Valor de cada preferencia, elegido por el usuario o predeterminado
+	Settings map[string]interface{} `json:"settings"`
+	// Preferencias elegidas por el usuario
+	Overridden []string `json:"overridden"`
+}
+
+type SettingsConfigResponse struct {
+	Definitions []SettingDefinition   `json:"definitions"`
+	Config      models.SettingsConfig `json:"config"`
+}
+
+type UpdateSettingsConfigRequest struct {
+	TypeDefaults map[string]map[string]interface{} `json:"type_defaults"`
+	TokenKeys    []string                          `json:"token_keys"`
+}
+
+type IUserSettingsService interface {
+	GetUserSettings(userId string) (response UserSettingsResponse, err error)
+	PatchUserSettings(userId string, patch map[string]interface{}) (response UserSettingsResponse, err error)
+	GetSettingsConfig() (response SettingsConfigResponse, err error)
+	UpdateSettingsConfig(req UpdateSettingsConfigRequest, updatedBy string) (response SettingsConfigResponse, err error)
+	TokenSettings(user models.User) (settings map[string]interface{}, err error)
+}
+
+type UserSettingsService struct {
+	db *mongo.Database
+}
+
+/** Obtiene las preferencias de un usuario. Cada preferencia toma el valor elegido por el usuario, el
+ * predeterminado para su tipo de usuario o el definido en el servidor, en ese orden
+ *
+ * @param userId string "El id del usuario"
+ * @return UserSettingsResponse "Las preferencias"
+ * @return err error "El error de la operación"
+ */
+func (service *UserSettingsService) GetUserSettings(userId string) (response UserSettingsResponse, err error) {
+	user, err := service.findUser(userId)
+	if err != nil {
+		return
+	}
+
+	config, err := service.config()
+	if err != nil {
+		return
+	}
+
+	return effectiveSettings(user, config), nil
+}
+
+/** Modifica las preferencias de un usuario siguiendo JSON Merge Patch: null vuelve al valor predeterminado
+ *
+ * @param userId string "El id del usuario"
+ * @param patch map[string]interface{} "Preferencia => valor nuevo o null"
+ * @return UserSettingsResponse "Las preferencias actualizadas"
+ * @return err error "ErrInvalidSetting si alguna preferencia no existe o su valor no es válido"
+ */
+func (service *UserSettingsService) PatchUserSettings(userId string, patch map[string]interface{}) (response UserSettingsResponse, err error) {
+	user, err := service.findUser(userId)
+	if err != nil {
+		return
+	}
+
+	set := bson.M{"updated_at": time.Now()}
+	unset := bson.M{}
+	for key, value := range patch {
+		if value == nil {
+			if _, ok := findSettingDefinition(key); !ok {
+				err = fmt.Errorf("%w: la preferencia \"%s\" no existe", ErrInvalidSetting, key)
+				return
+			}
+			unset["settings."+key] = ""
+			continue
+		}
+
+		if value, err = validateSetting(key, value); err != nil {
+			return
+		}
+		set["settings."+key] = value
+	}
+
+	update := bson.M{"$set": set, "$inc": bson.M{"version": 1}}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	opts := options.FindOneAndUpdate().
+		SetReturnDocument(options.After).
+		SetProjection(bson.M{"password": 0})
+
+	err = service.db.Collection("users").FindOneAndUpdate(ctx, bson.M{"_id": user.ID, "deleted_at": nil}, update, opts).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		err = ErrUserNotFound
+	}
+	if err != nil {
+		return
+	}
+
+	// Sólo el propio usuario modifica sus preferencias
+	if err = recordUserVersion(service.db, user.ID, UserVersionSettings, user.Email); err != nil {
+		return
+	}
+
+	config, err := service.config()
+	if err != nil {
+		return
+	}
+
+	return effectiveSettings(user, config), nil
+}
+
+/** Obtiene las preferencias definidas en el servidor y la configuración de los administradores
+ *
+ * @return SettingsConfigResponse "Las definiciones y la configuración"
+ * @return err error "El error de la operación"
+ */
+func (service *UserSettingsService) GetSettingsConfig() (response SettingsConfigResponse, err error) {
+	response.Definitions = settingDefinitions
+	response.Config, err = service.config()
+	return
+}
+
+/** Reemplaza la configuración de las preferencias: los valores predeterminados por tipo de usuario y las
+ * preferencias que se incluyen en los tokens. Se aplica a los tokens emitidos a partir del cambio
+ *
+ * @param req UpdateSettingsConfigRequest "La configuración nueva"
+ * @param updatedBy string "El correo electrónico de quien modifica la configuración"
+ * @return SettingsConfigResponse "Las definiciones y la configuración guardada"
+ * @return err error "ErrInvalidSetting si algún tipo de usuario, preferencia o valor no es válido"
+ */
+func (service *UserSettingsService) UpdateSettingsConfig(req UpdateSettingsConfigRequest, updatedBy string) (response SettingsConfigResponse, err error) {
+	config := models.SettingsConfig{
+		ID:           settingsConfigID,
+		TypeDefaults: map[string]map[string]interface{}{},
+		TokenKeys:    []string{},
+		UpdatedAt:    time.Now(),
+		UpdatedBy:    updatedBy,
+	}
+
+	for userType, defaults := range req.TypeDefaults {
+		if _, ok := rolePermissions[userType]; !ok {
+			err = fmt.Errorf("%w: el tipo de usuario \"%s\" no existe", ErrInvalidSetting, userType)
+			return
+		}
+
+		config.TypeDefaults[userType] = map[string]interface{}{}
+		for key, value := range defaults {
+			if value, err = validateSetting(key, value); err != nil {
+				return
+			}
+			config.TypeDefaults[userType][key] = value
+		}
+	}
+
+	for _, key := range req.TokenKeys {
+		if _, ok := findSettingDefinition(key); !ok {
+			err = fmt.Errorf("%w: la preferencia \"%s\" no existe", ErrInvalidSetting, key)
+			return
+		}
+		if !containsString(config.TokenKeys, key) {
+			config.TokenKeys = append(config.TokenKeys, key)
+		}
+	}
+
+	opts := options.Replace().SetUpsert(true)
+	if _, err = service.db.Collection("settings_config").ReplaceOne(ctx, bson.M{"_id": settingsConfigID}, config, opts); err != nil {
+		return
+	}
+
+	response.Definitions = settingDefinitions
+	response.Config = config
+	return
+}
+
+/** Obtiene las preferencias de un usuario que se incluyen en el payload de sus tokens
+ *
+ * @param user models.User "El usuario"
+ * @return map[string]interface{} "Preferencia => valor, o nil si no se incluye ninguna"
+ * @return err error "El error de la operación"
+ */
+func (service *UserSettingsService) TokenSettings(user models.User) (settings map[string]interface{}, err error) {
+	config, err := service.config()
+	if err != nil || len(config.TokenKeys) == 0 {
+		return
+	}
+
+	effective := effectiveSettings(user, config).Settings
+	settings = map[string]interface{}{}
+	for _, key := range config.TokenKeys {
+		if value, ok := effective[key]; ok {
+			settings[key] = value
+		}
+	}
+
+	return
+}
+
+// Obtiene la configuración de los administradores, vacía si nunca se guardó
+func (service *UserSettingsService) config() (config models.SettingsConfig, err error) {
+	err = service.db.Collection("settings_config").FindOne(ctx, bson.M{"_id": settingsConfigID}).Decode(&config)
+	if err == mongo.ErrNoDocuments {
+		err = nil
+	}
+	if config.TypeDefaults == nil {
+		config.TypeDefaults = map[string]map[string]interface{}{}
+	}
+	if config.TokenKeys == nil {
+		config.TokenKeys = []string{}
+	}
+	return
+}
+
+func (service *UserSettingsService) findUser(userId string) (user models.User, err error) {
+	id, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		err = ErrUserNotFound
+		return
+	}
+
+	opts := options.FindOne().SetProjection(bson.M{"type": 1, "settings": 1})
+	err = service.db.Collection("users").FindOne(ctx, bson.M{"_id": id, "deleted_at": nil}, opts).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		err = ErrUserNotFound
+	}
+	return
+}
+
+// Combina los valores del servidor, los predeterminados del tipo de usuario y los elegidos por el usuario.
+// Los valores guardados que dejaron de ser válidos se ignoran
+func effectiveSettings(user models.User, config models.SettingsConfig) UserSettingsResponse {
+	response := UserSettingsResponse{Settings: map[string]interface{}{}, Overridden: []string{}}
+
+	for _, definition := range settingDefinitions {
+		response.Settings[definition.Key] = definition.Default
+
+		if value, ok := config.TypeDefaults[user.Type][definition.Key]; ok {
+			if value, err := validateSetting(definition.Key, value); err == nil {
+				response.Settings[definition.Key] = value
+			}
+		}
+
+		if value, ok := user.Settings[definition.Key]; ok {
+			if value, err := validateSetting(definition.Key, value); err == nil {
+				response.Settings[definition.Key] = value
+				response.Overridden = append(response.Overridden, definition.Key)
+			}
+		}
+	}
+
+	return response
+}
+
+func findSettingDefinition(key string) (SettingDefinition, bool) {
+	for _, definition := range settingDefinitions {
+		if definition.Key == key {
+			return definition, true
+		}
+	}
+
+	return SettingDefinition{}, false
+}
+
+// Valida el valor de una preferencia según su definición y devuelve su forma normalizada
+func validateSetting(key string, value interface{}) (interface{}, error) {
+	definition, ok := findSettingDefinition(key)
+	if !ok {
+		return nil, fmt.Errorf("%w: la preferencia \"%s\" no existe", ErrInvalidSetting, key)
+	}
+
+	switch definition.Type {
+	case SettingTypeBool:
+		if boolean, ok := value.(bool); ok {
+			return boolean, nil
+		}
+		return nil, fmt.Errorf("%w: \"%s\" debe ser verdadero o falso", ErrInvalidSetting, key)
+	case SettingTypeEnum:
+		if text, ok := value.(string); ok && containsString(definition.Values, text) {
+			return text, nil
+		}
+		return nil, fmt.Errorf("%w: \"%s\" debe ser uno de %s", ErrInvalidSetting, key, strings.Join(definition.Values, ", "))
+	default:
+		text, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: \"%s\" debe ser un texto", ErrInvalidSetting, key)
+		}
+		if definition.normalize == nil {
+			return text, nil
+		}
+
+		normalized, err := definition.normalize(text)
+		if err != nil {
+			return nil, fmt.Errorf("%w: \"%s\" %s", ErrInvalidSetting, key, err)
+		}
+		return normalized, nil
+	}
+}
+
+func normalizeLocale(value string) (string, error) {
+	tag, err := language.Parse(value)
+	if err != nil {
+		return "", errors.New("no es una etiqueta de idioma válida")
+	}
+
+	return tag.String(), nil
+}
+
+func normalizeTimezone(value string) (string, error) {
+	// LoadLocation acepta "" y "Local", que dependen del servidor
+	if value == "" || value == "Local" {
+		return "", errors.New("no es una zona horaria válida")
+	}
+
+	location, err := time.LoadLocation(value)
+	if err != nil {
+		return "", errors.New("no es una zona horaria válida")
+	}
+
+	return location.String(), nil
+}
+
+func NewUserSettingsService(db *mongo.Database) IUserSettingsService {
+	return &UserSettingsService{db: db}
+}
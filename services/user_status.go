@@ -0,0 +1,303 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/maramal/user-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrInvalidStatusTransition = errors.New("el cambio de estado no está permitido")
+	ErrUserNotActive           = errors.New("el usuario no está activo")
+)
+
+var userStatuses = []string{
+	models.UserStatusInvited,
+	models.UserStatusPendingVerification,
+	models.UserStatusActive,
+	models.UserStatusSuspended,
+	models.UserStatusLocked,
+	models.UserStatusDeactivated,
+	models.UserStatusExpired,
+	models.UserStatusDeleted,
+}
+
+// Estado de destino => estados desde los que se puede llegar a él
+var userStatusTransitions = map[string][]string{
+	models.UserStatusPendingVerification: {models.UserStatusInvited},
+	models.UserStatusActive: {
+		models.UserStatusInvited,
+		models.UserStatusPendingVerification,
+		models.UserStatusSuspended,
+		models.UserStatusLocked,
+		models.UserStatusDeactivated,
+	},
+	models.UserStatusSuspended: {models.UserStatusActive, models.UserStatusLocked},
+	models.UserStatusLocked:    {models.UserStatusActive},
+	models.UserStatusDeactivated: {
+		models.UserStatusInvited,
+		models.UserStatusPendingVerification,
+		models.UserStatusActive,
+		models.UserStatusSuspended,
+		models.UserStatusLocked,
+		models.UserStatusExpired,
+	},
+	// Sólo lo aplica la tarea de vencimiento de cuentas. Los usuarios suspendidos, bloqueados o desactivados
+	// conservan su estado, y no se pueden activar mientras la cuenta esté vencida
+	models.UserStatusExpired: {
+		models.UserStatusInvited,
+		models.UserStatusPendingVerification,
+		models.UserStatusActive,
+	},
+	models.UserStatusDeleted: {
+		models.UserStatusInvited,
+		models.UserStatusPendingVerification,
+		models.UserStatusActive,
+		models.UserStatusSuspended,
+		models.UserStatusLocked,
+		models.UserStatusDeactivated,
+		models.UserStatusExpired,
+	},
+}
+
+// Estados con los que se puede crear un usuario
+var initialUserStatuses = []string{
+	models.UserStatusInvited,
+	models.UserStatusPendingVerification,
+	models.UserStatusActive,
+}
+
+// Estados en los que se bloquean las sesiones del usuario
+var sessionRevokingStatuses = map[string]bool{
+	models.UserStatusSuspended:   true,
+	models.UserStatusLocked:      true,
+	models.UserStatusDeactivated: true,
+	models.UserStatusExpired:     true,
+	models.UserStatusDeleted:     true,
+}
+
+type ChangeUserStatusRequest struct {
+	// Motivo del cambio de estado, que queda registrado en el historial
+	Reason string `json:"reason" binding:"required"`
+}
+
+type GetStatusHistoryResponse struct {
+	Changes []models.UserStatusChange `json:"changes"`
+}
+
+/** Cambia el estado de un usuario. Para eliminarlo se debe usar DeleteUser
+ *
+ * @param id string "El id del usuario"
+ * @param status string "El nuevo estado"
+ * @param reason string "El motivo del cambio"
+ * @param changedBy string "El correo electrónico de quien cambia el estado"
+ * @return UpdateUserResponse "El usuario actualizado"
+ * @return err error "El error de la operación"
+ */
+func (service *UserService) ChangeStatus(userId string, status string, reason string, changedBy string) (response UpdateUserResponse, err error) {
+	id, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		err = ErrUserNotFound
+		return
+	}
+
+	if status == models.UserStatusDeleted {
+		err = fmt.Errorf("%w: los usuarios se eliminan con DELETE", ErrInvalidStatusTransition)
+		return
+	}
+	if status == models.UserStatusExpired {
+		err = fmt.Errorf("%w: las cuentas vencen en su fecha de vencimiento", ErrInvalidStatusTransition)
+		return
+	}
+
+	if status == models.UserStatusActive {
+		if err = checkUserCanActivate(service.db, id); err != nil {
+			return
+		}
+	}
+
+	if _, err = transitionUserStatus(service.db, id, nil, status, reason, changedBy, nil); err != nil {
+		return
+	}
+
+	opts := options.FindOne().SetProjection(bson.M{"password": 0})
+	err = service.db.Collection("users").FindOne(ctx, bson.M{"_id": id}, opts).Decode(&response.User)
+	return
+}
+
+/** Obtiene el historial de estados de un usuario, del cambio más reciente al más antiguo
+ *
+ * @param id string "El id del usuario"
+ * @return GetStatusHistoryResponse "Los cambios de estado"
+ * @return err error "El error de la operación"
+ */
+func (service *UserService) GetStatusHistory(userId string) (response GetStatusHistoryResponse, err error) {
+	collection := service.db.Collection("user_status_changes")
+
+	id, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		err = ErrUserNotFound
+		return
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "changed_at", Value: -1}, {Key: "_id", Value: -1}})
+	cursor, err := collection.Find(ctx, bson.M{"user_id": id}, opts)
+	if err != nil {
+		return
+	}
+
+	response.Changes = []models.UserStatusChange{}
+	err = cursor.All(ctx, &response.Changes)
+	return
+}
+
+/** Verifica que el estado del usuario le permita iniciar sesión
+ *
+ * @param user models.User "El usuario"
+ * @return error "ErrUserNotActive si el usuario no está activo"
+ */
+func CheckUserCanLogin(user models.User) error {
+	if user.Status != models.UserStatusActive {
+		return fmt.Errorf("%w: su estado es \"%s\"", ErrUserNotActive, user.Status)
+	}
+	// La tarea que pasa las cuentas vencidas al estado expired se ejecuta cada hora
+	if userExpired(user) {
+		return fmt.Errorf("%w: la cuenta venció el %s", ErrUserNotActive, user.ExpiresAt.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// Indica si pasó la fecha de vencimiento de la cuenta del usuario
+func userExpired(user models.User) bool {
+	return user.ExpiresAt != nil && !time.Now().Before(*user.ExpiresAt)
+}
+
+// Verifica que un usuario se pueda activar: si su cuenta venció, la tarea de vencimiento lo volvería a pasar al
+// estado expired
+func checkUserCanActivate(db *mongo.Database, id primitive.ObjectID) error {
+	var user models.User
+	opts := options.FindOne().SetProjection(bson.M{"expires_at": 1})
+	err := db.Collection("users").FindOne(ctx, bson.M{"_id": id}, opts).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if userExpired(user) {
+		return fmt.Errorf("%w: la cuenta venció; se debe extender o quitar el vencimiento", ErrInvalidStatusTransition)
+	}
+
+	return nil
+}
+
+/** Cambia el estado de un usuario si la transición está permitida, la registra en el historial y
+ * aplica sus efectos (por ejemplo bloquear las sesiones al suspenderlo)
+ *
+ * @param db *mongo.Database "La base de datos"
+ * @param id primitive.ObjectID "El id del usuario"
+ * @param ifVersion *int64 "La versión esperada del usuario. Si es nil no se verifica"
+ * @param to string "El nuevo estado"
+ * @param reason string "El motivo del cambio"
+ * @param changedBy string "El correo electrónico de quien cambia el estado"
+ * @param extra bson.M "Otros campos a modificar junto con el estado"
+ * @return models.User "El usuario antes del cambio"
+ * @return err error "El error de la operación"
+ */
+func transitionUserStatus(db *mongo.Database, id primitive.ObjectID, ifVersion *int64, to, reason, changedBy string, extra bson.M) (before models.User, err error) {
+	collection := db.Collection("users")
+
+	sources, ok := userStatusTransitions[to]
+	if !ok {
+		err = fmt.Errorf("%w: el estado \"%s\" no existe", ErrInvalidStatusTransition, to)
+		return
+	}
+
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		err = fmt.Errorf("%w: el motivo del cambio de estado es requerido", ErrInvalidUserData)
+		return
+	}
+
+	now := time.Now()
+	set := bson.M{"status": to, "status_reason": reason, "status_changed_at": now, "updated_at": now}
+	for key, value := range extra {
+		set[key] = value
+	}
+
+	filter := withVersion(bson.M{"_id": id, "deleted_at": nil, "status": bson.M{"$in": sources}}, ifVersion)
+	update := bson.M{"$set": set, "$inc": bson.M{"version": 1}}
+	opts := options.FindOneAndUpdate().SetProjection(bson.M{"password": 0})
+
+	err = collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&before)
+	if err == mongo.ErrNoDocuments {
+		err = statusTransitionError(db, id, ifVersion, to)
+	}
+	if err != nil {
+		return
+	}
+
+	change := models.UserStatusChange{
+		UserID:    id,
+		From:      before.Status,
+		To:        to,
+		Reason:    reason,
+		ChangedBy: changedBy,
+		ChangedAt: now,
+	}
+	if _, err = db.Collection("user_status_changes").InsertOne(ctx, change); err != nil {
+		return
+	}
+
+	if err = recordUserVersion(db, id, UserVersionStatus, changedBy); err != nil {
+		return
+	}
+
+	if sessionRevokingStatuses[to] {
+		err = blockUserSessions(db, before.Email)
+	}
+
+	return
+}
+
+// Determina por qué no se pudo cambiar el estado: el usuario no existe, fue modificado o la transición no está permitida
+func statusTransitionError(db *mongo.Database, id primitive.ObjectID, ifVersion *int64, to string) error {
+	var user models.User
+	err := db.Collection("users").FindOne(ctx, bson.M{"_id": id, "deleted_at": nil}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if ifVersion != nil && *ifVersion != user.Version {
+		return ErrVersionMismatch
+	}
+
+	return fmt.Errorf("%w: no se puede pasar de \"%s\" a \"%s\"", ErrInvalidStatusTransition, user.Status, to)
+}
+
+// Obtiene el estado que tenía un usuario antes de pasar al estado indicado (por ejemplo, antes de ser eliminado)
+func statusBefore(db *mongo.Database, id primitive.ObjectID, status string) (string, error) {
+	var change models.UserStatusChange
+
+	filter := bson.M{"user_id": id, "to": status}
+	opts := options.FindOne().SetSort(bson.D{{Key: "changed_at", Value: -1}, {Key: "_id", Value: -1}})
+
+	err := db.Collection("user_status_changes").FindOne(ctx, filter, opts).Decode(&change)
+	if err == mongo.ErrNoDocuments || (err == nil && change.From == "") {
+		return models.UserStatusActive, nil
+	}
+
+	return change.From, err
+}
@@ -0,0 +1,315 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/maramal/user-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Acciones con las que se registran las versiones de los usuarios
+const (
+	UserVersionCreate     = "create"
+	UserVersionUpdate     = "update"
+	UserVersionPassword   = "password"
+	UserVersionStatus     = "status"
+	UserVersionRestore    = "restore"
+	UserVersionImport     = "import"
+	UserVersionBulk       = "bulk"
+	UserVersionMetadata   = "metadata"
+	UserVersionExpiration = "expiration"
+	UserVersionAvatar     = "avatar"
+	UserVersionPhone      = "phone"
+	UserVersionSettings   = "settings"
+	UserVersionEmail      = "email"
+	// Restauración de una versión anterior (ver RestoreUserVersion)
+	UserVersionRollback = "rollback"
+)
+
+var ErrUserVersionNotFound = errors.New("no se encontró la versión del usuario")
+
+// Campos que no se comparan entre versiones porque cambian con cada modificación o con cada ingreso
+var unversionedUserFields = []string{"version", "updated_at", "last_login_at", "login_count"}
+
+// Diferencia de un campo entre dos versiones. Los campos de objetos se indican con su ruta, por ejemplo custom.area
+type UserFieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+type UserHistoryEntry struct {
+	Version   int64     `json:"version"`
+	Action    string    `json:"action"`
+	ChangedBy string    `json:"changed_by,omitempty"`
+	ChangedAt time.Time `json:"changed_at"`
+	// Campos que cambiaron respecto de la versión anterior registrada
+	Changes []UserFieldChange `json:"changes"`
+}
+
+type GetUserHistoryResponse struct {
+	Versions []UserHistoryEntry `json:"versions"`
+}
+
+type GetUserVersionResponse struct {
+	Version models.UserVersion `json:"version"`
+}
+
+/** Obtiene las versiones de un usuario, de la más reciente a la más antigua, con los campos que
+ * cambiaron en cada una
+ *
+ * @param userId string "El id del usuario"
+ * @return GetUserHistoryResponse "Las versiones"
+ * @return err error "El error de la operación"
+ */
+func (service *UserService) GetUserHistory(userId string) (response GetUserHistoryResponse, err error) {
+	id, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		err = ErrUserNotFound
+		return
+	}
+
+	var versions []models.UserVersion
+	opts := options.Find().SetSort(bson.M{"version": 1})
+	if err = findAll(service.db.Collection("user_versions"), bson.M{"user_id": id}, opts, &versions); err != nil {
+		return
+	}
+
+	response.Versions = []UserHistoryEntry{}
+	previous := map[string]interface{}{}
+	for _, version := range versions {
+		var current map[string]interface{}
+		if current, err = userVersionFields(version.Snapshot); err != nil {
+			return
+		}
+
+		entry := UserHistoryEntry{
+			Version:   version.Version,
+			Action:    version.Action,
+			ChangedBy: version.ChangedBy,
+			ChangedAt: version.ChangedAt,
+			Changes:   []UserFieldChange{},
+		}
+		diffUserFields("", previous, current, &entry.Changes)
+		sort.Slice(entry.Changes, func(i, j int) bool { return entry.Changes[i].Field < entry.Changes[j].Field })
+
+		// De la más reciente a la más antigua
+		response.Versions = append([]UserHistoryEntry{entry}, response.Versions...)
+		previous = current
+	}
+
+	return
+}
+
+/** Obtiene una versión de un usuario, con el usuario tal como quedó en esa versión
+ *
+ * @param userId string "El id del usuario"
+ * @param version int64 "El número de versión"
+ * @return GetUserVersionResponse "La versión"
+ * @return err error "ErrUserVersionNotFound si la versión no está registrada"
+ */
+func (service *UserService) GetUserVersion(userId string, version int64) (response GetUserVersionResponse, err error) {
+	id, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		err = ErrUserNotFound
+		return
+	}
+
+	err = service.db.Collection("user_versions").FindOne(ctx, bson.M{"user_id": id, "version": version}).Decode(&response.Version)
+	if err == mongo.ErrNoDocuments {
+		err = ErrUserVersionNotFound
+	}
+	return
+}
+
+/** Restaura los datos de perfil de un usuario a los de una versión anterior. La restauración crea una
+ * versión nueva. El correo electrónico, la contraseña, el estado, el teléfono, la imagen de perfil y el
+ * vencimiento no se restauran, porque tienen sus propios procesos. Si cambia el tipo se bloquean las
+ * sesiones del usuario
+ *
+ * @param userId string "El id del usuario"
+ * @param version int64 "La versión a restaurar"
+ * @param restoredBy string "El correo electrónico de quien restaura la versión"
+ * @param ifVersion *int64 "La versión actual esperada del usuario (If-Match). Si es nil no se verifica"
+ * @return UpdateUserResponse "El usuario restaurado"
+ * @return err error "El error de la operación"
+ */
+func (service *UserService) RestoreUserVersion(userId string, version int64, restoredBy string, ifVersion *int64) (response UpdateUserResponse, err error) {
+	collection := service.db.Collection("users")
+
+	saved, err := service.GetUserVersion(userId, version)
+	if err != nil {
+		return
+	}
+	snapshot := saved.Version.Snapshot
+
+	var user models.User
+	filter := bson.M{"_id": saved.Version.UserID, "deleted_at": nil}
+	err = collection.FindOne(ctx, filter).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		err = ErrUserNotFound
+	}
+	if err != nil {
+		return
+	}
+	if ifVersion != nil && *ifVersion != user.Version {
+		err = ErrVersionMismatch
+		return
+	}
+
+	if _, ok := rolePermissions[snapshot.Type]; !ok {
+		err = fmt.Errorf("%w: el tipo de usuario \"%s\" de la versión %d ya no existe", ErrInvalidUserData, snapshot.Type, version)
+		return
+	}
+
+	set := bson.M{"first_name": snapshot.FirstName, "last_name": snapshot.LastName, "type": snapshot.Type}
+	unset := bson.M{}
+
+	restored := map[string]map[string]interface{}{
+		"custom":        snapshot.Custom,
+		"settings":      snapshot.Settings,
+		"app_metadata":  snapshot.AppMetadata,
+		"user_metadata": snapshot.UserMetadata,
+	}
+	for field, value := range restored {
+		if value, err = normalizeCustomAttributes(value); err != nil {
+			return
+		}
+		if field == "custom" && len(value) > 0 {
+			// El esquema pudo cambiar desde esa versión
+			if value, err = validateCustomAttributes(service.db, value); err != nil {
+				return
+			}
+		}
+
+		if len(value) == 0 {
+			unset[field] = ""
+		} else {
+			set[field] = value
+		}
+		restored[field] = value
+	}
+
+	user.FirstName, user.LastName, user.Custom = snapshot.FirstName, snapshot.LastName, restored["custom"]
+	if set["search_terms"], err = userSearchTerms(service.db, user); err != nil {
+		return
+	}
+	setUserSortNames(set, user)
+	set["updated_at"] = time.Now()
+
+	update := bson.M{"$set": set, "$inc": bson.M{"version": 1}}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	opts := options.FindOneAndUpdate().
+		SetReturnDocument(options.After).
+		SetProjection(bson.M{"password": 0})
+
+	err = collection.FindOneAndUpdate(ctx, withVersion(filter, ifVersion), update, opts).Decode(&response.User)
+	if err == mongo.ErrNoDocuments {
+		err = service.notMatchedError(user.ID, ifVersion)
+	}
+	if err != nil {
+		return
+	}
+
+	if err = recordUserVersion(service.db, user.ID, UserVersionRollback, restoredBy); err != nil {
+		return
+	}
+
+	// Las sesiones abiertas conservan los permisos del tipo anterior
+	if snapshot.Type != user.Type {
+		err = blockUserSessions(service.db, user.Email)
+	}
+
+	return
+}
+
+/** Guarda una copia del usuario tal como está, identificada por su versión. Se llama después de cada
+ * modificación; si la versión ya estaba registrada no se vuelve a guardar
+ *
+ * @param db *mongo.Database "La base de datos"
+ * @param id primitive.ObjectID "El id del usuario"
+ * @param action string "La acción que modificó al usuario"
+ * @param changedBy string "El correo electrónico de quien hizo el cambio, si se conoce"
+ * @return error "El error de la operación"
+ */
+func recordUserVersion(db *mongo.Database, id primitive.ObjectID, action string, changedBy string) error {
+	var user models.User
+	opts := options.FindOne().SetProjection(bson.M{"password": 0, "search_terms": 0, "sort_first_name": 0, "sort_last_name": 0})
+
+	err := db.Collection("users").FindOne(ctx, bson.M{"_id": id}, opts).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	version := models.UserVersion{
+		UserID:    id,
+		Version:   user.Version,
+		Action:    action,
+		ChangedBy: changedBy,
+		ChangedAt: time.Now(),
+		Snapshot:  user,
+	}
+
+	// Si dos cambios se registran a la vez, el índice único conserva una sola copia de cada versión
+	_, err = db.Collection("user_versions").InsertOne(ctx, version)
+	if mongo.IsDuplicateKeyError(err) {
+		err = nil
+	}
+	return err
+}
+
+// Convierte un usuario guardado en un objeto JSON para compararlo con otras versiones
+func userVersionFields(user models.User) (fields map[string]interface{}, err error) {
+	data, err := json.Marshal(user)
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(data, &fields); err != nil {
+		return
+	}
+
+	for _, field := range unversionedUserFields {
+		delete(fields, field)
+	}
+	return
+}
+
+// Agrega a changes los campos que difieren entre before y after. Los objetos se comparan campo por campo
+func diffUserFields(prefix string, before, after map[string]interface{}, changes *[]UserFieldChange) {
+	keys := map[string]bool{}
+	for key := range before {
+		keys[key] = true
+	}
+	for key := range after {
+		keys[key] = true
+	}
+
+	for key := range keys {
+		field := prefix + key
+		from, to := before[key], after[key]
+
+		fromObject, fromIsObject := from.(map[string]interface{})
+		toObject, toIsObject := to.(map[string]interface{})
+		if (fromIsObject || from == nil) && (toIsObject || to == nil) && (fromIsObject || toIsObject) {
+			diffUserFields(field+".", fromObject, toObject, changes)
+			continue
+		}
+
+		if !reflect.DeepEqual(from, to) {
+			*changes = append(*changes, UserFieldChange{Field: field, From: from, To: to})
+		}
+	}
+}
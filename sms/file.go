@@ -0,0 +1,62 @@
+package sms
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileSender agrega los mensajes a un archivo, uno por línea en JSON, en lugar de enviarlos. Sirve para
+// desarrollo y pruebas automatizadas que necesitan leer los códigos enviados
+type FileSender struct {
+	path string
+	mu   sync.Mutex
+}
+
+type fileMessage struct {
+	To     string    `json:"to"`
+	Body   string    `json:"body"`
+	SentAt time.Time `json:"sent_at"`
+}
+
+func NewFileSender(path string) (*FileSender, error) {
+	if path == "" {
+		return nil, errors.New("falta la ruta del archivo de SMS (SMS_FILE)")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+
+	return &FileSender{path: path}, nil
+}
+
+/** Agrega un mensaje al archivo
+ *
+ * @param message Message "El mensaje"
+ * @return error "El error al escribir el archivo"
+ */
+func (sender *FileSender) Send(message Message) error {
+	line, err := json.Marshal(fileMessage{To: message.To, Body: message.Body, SentAt: time.Now()})
+	if err != nil {
+		return err
+	}
+
+	sender.mu.Lock()
+	defer sender.mu.Unlock()
+
+	file, err := os.OpenFile(sender.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+
+	if _, err = file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
@@ -0,0 +1,22 @@
+package sms
+
+import (
+	"log"
+)
+
+// LogSender escribe los mensajes en el log en lugar de enviarlos. Sirve para desarrollo
+type LogSender struct{}
+
+func NewLogSender() *LogSender {
+	return &LogSender{}
+}
+
+/** Escribe un mensaje en el log
+ *
+ * @param message Message "El mensaje"
+ * @return error "Siempre nil"
+ */
+func (sender *LogSender) Send(message Message) error {
+	log.Printf("SMS para %s: %s", message.To, message.Body)
+	return nil
+}
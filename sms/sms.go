@@ -0,0 +1,37 @@
+package sms
+
+import (
+	"fmt"
+
+	"github.com/maramal/user-service/utils"
+)
+
+// Message es un mensaje de texto
+type Message struct {
+	// Teléfono de destino en formato E.164
+	To   string
+	Body string
+}
+
+// ISender envía mensajes de texto (SMS)
+type ISender interface {
+	// Envía un mensaje. Devuelve error si no se pudo entregar al proveedor
+	Send(message Message) error
+}
+
+/** Crea el servicio de SMS indicado en la configuración (SMS_BACKEND)
+ *
+ * @param config utils.Config "Configuración de la aplicación"
+ * @return ISender "El servicio de SMS"
+ * @return error "Error si la configuración no es válida"
+ */
+func NewSender(config utils.Config) (ISender, error) {
+	switch config.SMSBackend {
+	case "", "log":
+		return NewLogSender(), nil
+	case "file":
+		return NewFileSender(config.SMSFile)
+	default:
+		return nil, fmt.Errorf("el servicio de SMS \"%s\" no existe", config.SMSBackend)
+	}
+}
@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"fmt"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStorage guarda los archivos en un directorio del sistema de archivos
+type LocalStorage struct {
+	dir string
+}
+
+func NewLocalStorage(dir string) *LocalStorage {
+	return &LocalStorage{dir: dir}
+}
+
+/** Guarda un archivo. Se escribe en un archivo temporal y luego se renombra, para que nunca se lea
+ * un archivo incompleto
+ *
+ * @param key string "La clave del archivo"
+ * @param data []byte "El contenido"
+ * @param contentType string "El tipo de contenido. Se deduce de la extensión al leer el archivo"
+ * @return error "Error"
+ */
+func (storage *LocalStorage) Put(key string, data []byte, contentType string) error {
+	filePath, err := storage.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(filePath), 0o700); err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(filePath), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err = file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), filePath)
+}
+
+/** Obtiene un archivo
+ *
+ * @param key string "La clave del archivo"
+ * @return []byte "El contenido"
+ * @return string "El tipo de contenido"
+ * @return error "ErrObjectNotFound si no existe"
+ */
+func (storage *LocalStorage) Get(key string) ([]byte, string, error) {
+	filePath, err := storage.path(key)
+	if err != nil {
+		return nil, "", err
+	}
+
+	data, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return nil, "", ErrObjectNotFound
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	return data, contentType, nil
+}
+
+/** Elimina un archivo
+ *
+ * @param key string "La clave del archivo"
+ * @return error "Error"
+ */
+func (storage *LocalStorage) Delete(key string) error {
+	filePath, err := storage.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err = os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// Obtiene la ruta del archivo de una clave, impidiendo que salga del directorio
+func (storage *LocalStorage) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if clean == "/" || strings.Contains(key, "..") {
+		return "", fmt.Errorf("la clave \"%s\" no es válida", key)
+	}
+
+	return filepath.Join(storage.dir, filepath.FromSlash(clean)), nil
+}
@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const s3RequestTimeout = 30 * time.Second
+
+type S3Config struct {
+	// URL del servicio, por ejemplo https://s3.us-east-1.amazonaws.com o http://localhost:9000 (MinIO)
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// Si es verdadero el bucket va en la ruta (http://host/bucket/clave) en lugar del host
+	// (http://bucket.host/clave). Los servicios compatibles como MinIO suelen requerirlo
+	PathStyle bool
+}
+
+// S3Storage guarda los archivos en un bucket de S3 o de un servicio compatible. Las solicitudes se
+// firman con AWS Signature Version 4
+type S3Storage struct {
+	config   S3Config
+	endpoint *url.URL
+	client   *http.Client
+}
+
+func NewS3Storage(config S3Config) (*S3Storage, error) {
+	if config.Endpoint == "" || config.Bucket == "" || config.AccessKey == "" || config.SecretKey == "" {
+		return nil, errors.New("el almacenamiento s3 requiere S3_ENDPOINT, S3_BUCKET, S3_ACCESS_KEY y S3_SECRET_KEY")
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("S3_ENDPOINT no es una URL válida: %s", config.Endpoint)
+	}
+
+	return &S3Storage{
+		config:   config,
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: s3RequestTimeout},
+	}, nil
+}
+
+/** Guarda un archivo en el bucket
+ *
+ * @param key string "La clave del archivo"
+ * @param data []byte "El contenido"
+ * @param contentType string "El tipo de contenido"
+ * @return error "Error"
+ */
+func (storage *S3Storage) Put(key string, data []byte, contentType string) error {
+	resp, err := storage.do(http.MethodPut, key, data, map[string]string{"Content-Type": contentType})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return s3Error(resp)
+	}
+
+	return nil
+}
+
+/** Obtiene un archivo del bucket
+ *
+ * @param key string "La clave del archivo"
+ * @return []byte "El contenido"
+ * @return string "El tipo de contenido"
+ * @return error "ErrObjectNotFound si no existe"
+ */
+func (storage *S3Storage) Get(key string) ([]byte, string, error) {
+	resp, err := storage.do(http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		data, err := io.ReadAll(resp.Body)
+		return data, resp.Header.Get("Content-Type"), err
+	case http.StatusNotFound:
+		return nil, "", ErrObjectNotFound
+	default:
+		return nil, "", s3Error(resp)
+	}
+}
+
+/** Elimina un archivo del bucket
+ *
+ * @param key string "La clave del archivo"
+ * @return error "Error"
+ */
+func (storage *S3Storage) Delete(key string) error {
+	resp, err := storage.do(http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return s3Error(resp)
+	}
+
+	return nil
+}
+
+// Envía una solicitud firmada sobre un objeto del bucket
+func (storage *S3Storage) do(method, key string, body []byte, headers map[string]string) (*http.Response, error) {
+	target := *storage.endpoint
+	objectPath := "/" + strings.TrimPrefix(key, "/")
+	if storage.config.PathStyle {
+		objectPath = "/" + storage.config.Bucket + objectPath
+	} else {
+		target.Host = storage.config.Bucket + "." + target.Host
+	}
+	target.Path = strings.TrimSuffix(target.Path, "/") + objectPath
+	target.RawPath = s3EscapePath(target.Path)
+
+	req, err := http.NewRequest(method, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	storage.sign(req, body, time.Now().UTC())
+	return storage.client.Do(req)
+}
+
+// Firma la solicitud con AWS Signature Version 4
+func (storage *S3Storage) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + storage.config.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+storage.config.SecretKey), date)
+	key = hmacSHA256(key, storage.config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		storage.config.AccessKey, scope, signedHeaders, signature,
+	))
+}
+
+// Codifica cada segmento de la ruta según las reglas de S3, que sólo dejan sin codificar A-Z, a-z, 0-9, -, _, . y ~
+func s3EscapePath(value string) string {
+	var builder strings.Builder
+	for _, b := range []byte(value) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9',
+			b == '-', b == '_', b == '.', b == '~', b == '/':
+			builder.WriteByte(b)
+		default:
+			fmt.Fprintf(&builder, "%%%02X", b)
+		}
+	}
+
+	return builder.String()
+}
+
+func s3Error(resp *http.Response) error {
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("error del almacenamiento s3 (%d): %s", resp.StatusCode, strings.TrimSpace(string(message)))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
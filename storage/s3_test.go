@@ -0,0 +1,245 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const (
+	testS3Bucket    = "avatars"
+	testS3Region    = "sa-east-1"
+	testS3AccessKey = "AKIDEXAMPLE"
+	testS3SecretKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+)
+
+type fakeS3Object struct {
+	data        []byte
+	contentType string
+}
+
+// Servidor que imita a S3: verifica la firma de cada solicitud y guarda los objetos en memoria
+type fakeS3 struct {
+	t       *testing.T
+	mu      sync.Mutex
+	objects map[string]fakeS3Object
+	// Host y ruta de cada solicitud recibida
+	hosts []string
+	paths []string
+}
+
+func (fake *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		fake.t.Errorf("no se pudo leer el cuerpo: %s", err)
+	}
+
+	if err := verifyS3Signature(r, body); err != nil {
+		fake.t.Errorf("%s %s: %s", r.Method, r.URL.EscapedPath(), err)
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	path := r.URL.EscapedPath()
+	fake.hosts = append(fake.hosts, r.Host)
+	fake.paths = append(fake.paths, path)
+
+	switch r.Method {
+	case http.MethodPut:
+		fake.objects[path] = fakeS3Object{data: body, contentType: r.Header.Get("Content-Type")}
+	case http.MethodGet:
+		object, ok := fake.objects[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", object.contentType)
+		w.Write(object.data)
+	case http.MethodDelete:
+		delete(fake.objects, path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// Verifica la firma AWS Signature Version 4 de la solicitud como lo hace el servidor: a partir de lo recibido
+func verifyS3Signature(r *http.Request, body []byte) error {
+	authorization := r.Header.Get("Authorization")
+	prefix := "AWS4-HMAC-SHA256 Credential="
+	if !strings.HasPrefix(authorization, prefix) {
+		return errors.New("la cabecera Authorization no es AWS4-HMAC-SHA256: " + authorization)
+	}
+
+	parts := strings.Split(strings.TrimPrefix(authorization, prefix), ", ")
+	if len(parts) != 3 || !strings.HasPrefix(parts[1], "SignedHeaders=") || !strings.HasPrefix(parts[2], "Signature=") {
+		return errors.New("formato de Authorization inválido: " + authorization)
+	}
+
+	credential := strings.Split(parts[0], "/")
+	if len(credential) != 5 || credential[0] != testS3AccessKey || credential[2] != testS3Region ||
+		credential[3] != "s3" || credential[4] != "aws4_request" {
+		return errors.New("credencial inválida: " + parts[0])
+	}
+
+	amzDate := r.Header.Get("X-Amz-Date")
+	if !strings.HasPrefix(amzDate, credential[1]) {
+		return errors.New("la fecha de la credencial no coincide con X-Amz-Date")
+	}
+
+	bodyHash := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(bodyHash[:])
+	if r.Header.Get("X-Amz-Content-Sha256") != payloadHash {
+		return errors.New("X-Amz-Content-Sha256 no coincide con el cuerpo")
+	}
+
+	signedHeaders := strings.TrimPrefix(parts[1], "SignedHeaders=")
+	var canonicalHeaders strings.Builder
+	for _, name := range strings.Split(signedHeaders, ";") {
+		value := r.Header.Get(name)
+		if name == "host" {
+			value = r.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		r.URL.EscapedPath(),
+		r.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+
+	scope := strings.Join(credential[1:], "/")
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + testS3SecretKey)
+	for _, value := range []string{credential[1], testS3Region, "s3", "aws4_request", stringToSign} {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(value))
+		key = mac.Sum(nil)
+	}
+
+	if expected := hex.EncodeToString(key); strings.TrimPrefix(parts[2], "Signature=") != expected {
+		return errors.New("la firma no es válida")
+	}
+
+	return nil
+}
+
+// Crea el almacenamiento apuntando al servidor falso. Con el bucket en el host, todas las conexiones se
+// dirigen al servidor porque bucket.127.0.0.1 no se puede resolver
+func newTestS3Storage(t *testing.T, server *httptest.Server, pathStyle bool) *S3Storage {
+	storage, err := NewS3Storage(S3Config{
+		Endpoint:  server.URL,
+		Region:    testS3Region,
+		Bucket:    testS3Bucket,
+		AccessKey: testS3AccessKey,
+		SecretKey: testS3SecretKey,
+		PathStyle: pathStyle,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	address := server.Listener.Addr().String()
+	storage.client.Transport = &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, address)
+		},
+	}
+
+	return storage
+}
+
+func TestS3Storage(t *testing.T) {
+	tests := []struct {
+		name      string
+		pathStyle bool
+		host      func(serverHost string) string
+		path      string
+	}{
+		{
+			name:      "path-style",
+			pathStyle: true,
+			host:      func(serverHost string) string { return serverHost },
+			path:      "/avatars/users/62a0c0d1/imagen%20perfil.png",
+		},
+		{
+			name:      "virtual-host",
+			pathStyle: false,
+			host:      func(serverHost string) string { return testS3Bucket + "." + serverHost },
+			path:      "/users/62a0c0d1/imagen%20perfil.png",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := &fakeS3{t: t, objects: map[string]fakeS3Object{}}
+			server := httptest.NewServer(fake)
+			defer server.Close()
+
+			storage := newTestS3Storage(t, server, test.pathStyle)
+			key := "users/62a0c0d1/imagen perfil.png"
+			data := []byte("\x89PNG contenido")
+
+			if err := storage.Put(key, data, "image/png"); err != nil {
+				t.Fatalf("Put: %s", err)
+			}
+
+			got, contentType, err := storage.Get(key)
+			if err != nil {
+				t.Fatalf("Get: %s", err)
+			}
+			if string(got) != string(data) || contentType != "image/png" {
+				t.Errorf("Get devolvió %q (%s), se esperaba %q (image/png)", got, contentType, data)
+			}
+
+			if err := storage.Delete(key); err != nil {
+				t.Fatalf("Delete: %s", err)
+			}
+			if _, _, err := storage.Get(key); !errors.Is(err, ErrObjectNotFound) {
+				t.Errorf("Get después de Delete devolvió %v, se esperaba ErrObjectNotFound", err)
+			}
+
+			serverHost := strings.TrimPrefix(server.URL, "http://")
+			for i := range fake.paths {
+				if fake.hosts[i] != test.host(serverHost) || fake.paths[i] != test.path {
+					t.Errorf("solicitud %d a %s%s, se esperaba %s%s", i, fake.hosts[i], fake.paths[i], test.host(serverHost), test.path)
+				}
+			}
+			if len(fake.paths) != 4 {
+				t.Errorf("el servidor recibió %d solicitudes firmadas, se esperaban 4", len(fake.paths))
+			}
+		})
+	}
+}
+
+func TestS3StorageRejectedSignature(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, "SignatureDoesNotMatch")
+	}))
+	defer server.Close()
+
+	storage := newTestS3Storage(t, server, true)
+	err := storage.Put("clave", []byte("datos"), "text/plain")
+	if err == nil || !strings.Contains(err.Error(), "403") || !strings.Contains(err.Error(), "SignatureDoesNotMatch") {
+		t.Errorf("Put devolvió %v, se esperaba el error 403 del servidor", err)
+	}
+}
@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/maramal/user-service/utils"
+)
+
+var ErrObjectNotFound = errors.New("no se encontró el archivo")
+
+// IStorage guarda archivos identificados por una clave, por ejemplo "avatars/<id>/256.jpg"
+type IStorage interface {
+	// Guarda un archivo, reemplazándolo si ya existe
+	Put(key string, data []byte, contentType string) error
+
+	// Obtiene un archivo y su tipo de contenido. Devuelve ErrObjectNotFound si no existe
+	Get(key string) ([]byte, string, error)
+
+	// Elimina un archivo. No devuelve error si no existe
+	Delete(key string) error
+}
+
+/** Crea el almacenamiento indicado en la configuración (STORAGE_BACKEND)
+ *
+ * @param config utils.Config "Configuración de la aplicación"
+ * @return IStorage "El almacenamiento"
+ * @return error "Error si la configuración no es válida"
+ */
+func NewStorage(config utils.Config) (IStorage, error) {
+	switch config.StorageBackend {
+	case "", "local":
+		dir := config.StorageDir
+		if dir == "" {
+			dir = filepath.Join(os.TempDir(), "user-storage")
+		}
+
+		return NewLocalStorage(dir), nil
+	case "s3":
+		return NewS3Storage(S3Config{
+			Endpoint:  config.S3Endpoint,
+			Region:    config.S3Region,
+			Bucket:    config.S3Bucket,
+			AccessKey: config.S3AccessKey,
+			SecretKey: config.S3SecretKey,
+			PathStyle: config.S3PathStyle,
+		})
+	default:
+		return nil, fmt.Errorf("el almacenamiento \"%s\" no existe", config.StorageBackend)
+	}
+}
@@ -30,18 +30,14 @@ func NewJWTMaker(secretKey string) (*JWTMaker, error) {
 
 /** Crea un nuevo token para un usuario y duración específicos
  *
- * @param fname string "Nombre"
- * @param lname string "Apellido"
- * @param email string "Email del usuario"
- * @param utype string "Tipo de usuario"
- * @param pimage string "Imagen de perfil"
+ * @param params PayloadParams "Datos del usuario y alcances del token"
  * @param duration time.Duration "Duración del token"
  * @return string "Token"
  * @return *Payload "Payload del token"
  * @return error "Error"
  */
-func (maker *JWTMaker) CreateToken(fname, lname, email, utype, pimage string, duration time.Duration) (string, *Payload, error) {
-	payload, err := NewPayload(fname, lname, email, utype, pimage, duration)
+func (maker *JWTMaker) CreateToken(params PayloadParams, duration time.Duration) (string, *Payload, error) {
+	payload, err := NewPayload(params, duration)
 	if err != nil {
 		return "", payload, err
 	}
@@ -11,23 +11,44 @@ var (
 )
 
 type Payload struct {
-	FirstName    string    `json:"first_name"`
-	LastName     string    `json:"last_name"`
-	Email        string    `json:"email"`
-	UserType     string    `json:"user_type"`
-	ProfileImage string    `json:"profile_image"`
-	IssuedAt     time.Time `json:"issued_at"`
-	ExpiredAt    time.Time `json:"expired_at"`
+	SessionID    string                 `json:"session_id"`
+	FirstName    string                 `json:"first_name"`
+	LastName     string                 `json:"last_name"`
+	Email        string                 `json:"email"`
+	UserType     string                 `json:"user_type"`
+	ProfileImage string                 `json:"profile_image"`
+	Scopes       []string               `json:"scopes"`
+	Claims       map[string]interface{} `json:"claims,omitempty"`
+	Settings     map[string]interface{} `json:"settings,omitempty"`
+	IssuedAt     time.Time              `json:"issued_at"`
+	ExpiredAt    time.Time              `json:"expired_at"`
+}
+
+// Datos del usuario con los que se crea el payload de un token
+type PayloadParams struct {
+	SessionID    string
+	FirstName    string
+	LastName     string
+	Email        string
+	UserType     string
+	ProfileImage string
+	Scopes       []string
+	Claims       map[string]interface{}
+	Settings     map[string]interface{}
 }
 
 // Crea un nuevo token para un usuario y duración específicos
-func NewPayload(fname, lname, email, utype, pimage string, duration time.Duration) (*Payload, error) {
+func NewPayload(params PayloadParams, duration time.Duration) (*Payload, error) {
 	payload := &Payload{
-		FirstName:    fname,
-		LastName:     lname,
-		Email:        email,
-		UserType:     utype,
-		ProfileImage: pimage,
+		SessionID:    params.SessionID,
+		FirstName:    params.FirstName,
+		LastName:     params.LastName,
+		Email:        params.Email,
+		UserType:     params.UserType,
+		ProfileImage: params.ProfileImage,
+		Scopes:       params.Scopes,
+		Claims:       params.Claims,
+		Settings:     params.Settings,
 		IssuedAt:     time.Now(),
 		ExpiredAt:    time.Now().Add(duration),
 	}
@@ -42,3 +63,22 @@ func (payload *Payload) Valid() error {
 	}
 	return nil
 }
+
+// Verifica si el token incluye todos los alcances indicados
+func (payload *Payload) HasScopes(scopes ...string) bool {
+	for _, scope := range scopes {
+		found := false
+		for _, granted := range payload.Scopes {
+			if granted == scope {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
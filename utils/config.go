@@ -15,6 +15,37 @@ type Config struct {
 	RefreshTokenDuration time.Duration `mapstructure:"REFRESH_TOKEN_DURATION"`
 	APMAppName           string        `mapstructure:"APM_APPNAME"`
 	APMLicense           string        `mapstructure:"APM_LICENSE"`
+	AuthzCacheTTL        time.Duration `mapstructure:"AUTHZ_CACHE_TTL"`
+	DeletedUserRetention time.Duration `mapstructure:"DELETED_USER_RETENTION"`
+	ExportsDir           string        `mapstructure:"EXPORTS_DIR"`
+	BulkMaxUsers         int           `mapstructure:"BULK_MAX_USERS"`
+	RequireIfMatch       bool          `mapstructure:"REQUIRE_IF_MATCH"`
+	StorageBackend       string        `mapstructure:"STORAGE_BACKEND"`
+	StorageDir           string        `mapstructure:"STORAGE_DIR"`
+	S3Endpoint           string        `mapstructure:"S3_ENDPOINT"`
+	S3Region             string        `mapstructure:"S3_REGION"`
+	S3Bucket             string        `mapstructure:"S3_BUCKET"`
+	S3AccessKey          string        `mapstructure:"S3_ACCESS_KEY"`
+	S3SecretKey          string        `mapstructure:"S3_SECRET_KEY"`
+	S3PathStyle          bool          `mapstructure:"S3_PATH_STYLE"`
+	AvatarMaxSize        int           `mapstructure:"AVATAR_MAX_SIZE"`
+	AppURL               string        `mapstructure:"APP_URL"`
+	MailBackend          string        `mapstructure:"MAIL_BACKEND"`
+	MailFrom             string        `mapstructure:"MAIL_FROM"`
+	SMTPHost             string        `mapstructure:"SMTP_HOST"`
+	SMTPPort             int           `mapstructure:"SMTP_PORT"`
+	SMTPUsername         string        `mapstructure:"SMTP_USERNAME"`
+	SMTPPassword         string        `mapstructure:"SMTP_PASSWORD"`
+	EmailChangeTTL       time.Duration `mapstructure:"EMAIL_CHANGE_TTL"`
+	InvitationTTL        time.Duration `mapstructure:"INVITATION_TTL"`
+	ExpirationReminder   time.Duration `mapstructure:"EXPIRATION_REMINDER"`
+	MetadataMaxSize      int           `mapstructure:"METADATA_MAX_SIZE"`
+	SMSBackend           string        `mapstructure:"SMS_BACKEND"`
+	SMSFile              string        `mapstructure:"SMS_FILE"`
+	SMSCodeTTL           time.Duration `mapstructure:"SMS_CODE_TTL"`
+	SMSResendInterval    time.Duration `mapstructure:"SMS_RESEND_INTERVAL"`
+	SMSMaxPerHour        int           `mapstructure:"SMS_MAX_PER_HOUR"`
+	PhoneUnique          bool          `mapstructure:"PHONE_UNIQUE"`
 }
 
 /** Lee la configuración del archivo o de las variables de entorno
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"errors"
+	"net/mail"
+	"strings"
+
+	"golang.org/x/net/idna"
+	"golang.org/x/text/unicode/norm"
+)
+
+var ErrInvalidEmail = errors.New("el correo electrónico no es válido")
+
+/**
+ * Normaliza un correo electrónico para que cada dirección tenga una sola representación: se pasa a
+ * Unicode NFC y a minúsculas, y el dominio internacionalizado (IDN) se convierte a ASCII (punycode),
+ * por ejemplo "Juan@Ejemplo.España" se convierte en "juan@ejemplo.xn--espaa-rta"
+ *
+ * @param email string "El correo electrónico"
+ * @return string "El correo electrónico normalizado"
+ * @return error "ErrInvalidEmail si el correo electrónico no tiene un formato válido"
+ */
+func NormalizeEmail(email string) (string, error) {
+	email = norm.NFC.String(strings.TrimSpace(email))
+
+	at := strings.LastIndex(email, "@")
+	if at <= 0 || strings.ContainsAny(email, " \t\r\n") {
+		return "", ErrInvalidEmail
+	}
+
+	domain, err := NormalizeEmailDomain(email[at+1:])
+	if err != nil {
+		return "", err
+	}
+
+	normalized := strings.ToLower(email[:at]) + "@" + domain
+	if _, err := mail.ParseAddress(normalized); err != nil {
+		return "", ErrInvalidEmail
+	}
+
+	return normalized, nil
+}
+
+/**
+ * Normaliza el dominio de un correo electrónico: lo pasa a minúsculas y, si es internacionalizado, a punycode
+ *
+ * @param domain string "El dominio"
+ * @return string "El dominio normalizado"
+ * @return error "ErrInvalidEmail si el dominio no es válido"
+ */
+func NormalizeEmailDomain(domain string) (string, error) {
+	ascii, err := idna.Lookup.ToASCII(norm.NFC.String(strings.TrimSpace(domain)))
+	if err != nil || ascii == "" {
+		return "", ErrInvalidEmail
+	}
+
+	return strings.ToLower(ascii), nil
+}
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		expected string
+		invalid  bool
+	}{
+		{name: "ya normalizado", email: "juan@mail.com", expected: "juan@mail.com"},
+		{name: "mayúsculas", email: "Juan.Perez@Mail.COM", expected: "juan.perez@mail.com"},
+		{name: "espacios alrededor", email: "  juan@mail.com\n", expected: "juan@mail.com"},
+		{name: "NFD a NFC", email: "jose\u0301@mail.com", expected: "josé@mail.com"},
+		{name: "NFC sin cambios", email: "josé@mail.com", expected: "josé@mail.com"},
+		{name: "mayúsculas acentuadas", email: "JOSÉ@mail.com", expected: "josé@mail.com"},
+		{name: "dominio internacionalizado", email: "Juan@Ejemplo.España", expected: "juan@ejemplo.xn--espaa-rta"},
+		{name: "dominio internacionalizado en NFD", email: "juan@ejemplo.espan\u0303a", expected: "juan@ejemplo.xn--espaa-rta"},
+		{name: "dominio en punycode", email: "juan@ejemplo.XN--ESPAA-RTA", expected: "juan@ejemplo.xn--espaa-rta"},
+		{name: "dominio con diéresis", email: "info@Bücher.de", expected: "info@xn--bcher-kva.de"},
+		{name: "arroba en la parte local entre comillas", email: `"juan@casa"@mail.com`, expected: `"juan@casa"@mail.com`},
+		{name: "vacío", email: "", invalid: true},
+		{name: "sin arroba", email: "juan.mail.com", invalid: true},
+		{name: "sin parte local", email: "@mail.com", invalid: true},
+		{name: "sin dominio", email: "juan@", invalid: true},
+		{name: "con espacios", email: "juan perez@mail.com", invalid: true},
+		{name: "con tabulación", email: "juan\t@mail.com", invalid: true},
+		{name: "dominio con caracteres inválidos", email: "juan@mail_com.uy", invalid: true},
+		{name: "con nombre", email: "Juan <juan@mail.com>", invalid: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			normalized, err := NormalizeEmail(test.email)
+			if test.invalid {
+				if !errors.Is(err, ErrInvalidEmail) {
+					t.Fatalf("se esperaba ErrInvalidEmail para %q, se obtuvo %q, %v", test.email, normalized, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("no se esperaba un error para %q: %s", test.email, err)
+			}
+			if normalized != test.expected {
+				t.Errorf("NormalizeEmail(%q) = %q, se esperaba %q", test.email, normalized, test.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,290 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+)
+
+const (
+	// Cantidad máxima de píxeles de una imagen, para no decodificar imágenes que ocupen demasiada memoria
+	maxImagePixels = 40_000_000
+	jpegQuality    = 85
+)
+
+var ErrInvalidImage = errors.New("la imagen no es válida")
+
+/** Decodifica una imagen JPEG, PNG o GIF y aplica la orientación indicada en sus datos EXIF.
+ * La imagen resultante ya no tiene metadatos
+ *
+ * @param data []byte "El contenido de la imagen"
+ * @return *image.NRGBA "La imagen"
+ * @return error "ErrInvalidImage si no se puede decodificar"
+ */
+func DecodeImage(data []byte) (*image.NRGBA, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidImage, err)
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxImagePixels {
+		return nil, fmt.Errorf("%w: la imagen mide %dx%d píxeles", ErrInvalidImage, config.Width, config.Height)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidImage, err)
+	}
+
+	bounds := img.Bounds()
+	rgba := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+
+	return orientImage(rgba, jpegOrientation(data)), nil
+}
+
+/** Reduce una imagen para que su lado mayor no supere el tamaño indicado, manteniendo la proporción
+ *
+ * @param img *image.NRGBA "La imagen"
+ * @param size int "El tamaño máximo del lado mayor"
+ * @return *image.NRGBA "La imagen reducida, o la misma si ya es más chica"
+ */
+func FitImage(img *image.NRGBA, size int) *image.NRGBA {
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	if width <= size && height <= size {
+		return img
+	}
+
+	if width >= height {
+		return resizeImage(img, size, max(1, height*size/width))
+	}
+
+	return resizeImage(img, max(1, width*size/height), size)
+}
+
+/** Genera una miniatura cuadrada: recorta el centro de la imagen y lo reduce al tamaño indicado
+ *
+ * @param img *image.NRGBA "La imagen"
+ * @param size int "El lado de la miniatura"
+ * @return *image.NRGBA "La miniatura"
+ */
+func ThumbnailImage(img *image.NRGBA, size int) *image.NRGBA {
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	side := min(width, height)
+
+	x, y := (width-side)/2, (height-side)/2
+	square := img.SubImage(image.Rect(x, y, x+side, y+side)).(*image.NRGBA)
+	if side <= size {
+		return copyImage(square)
+	}
+
+	return resizeImage(square, size, size)
+}
+
+/** Obtiene el formato de salida de una imagen: PNG si tiene transparencias o JPEG en caso contrario
+ *
+ * @param img *image.NRGBA "La imagen"
+ * @return string "El tipo de contenido"
+ */
+func ImageContentType(img *image.NRGBA) string {
+	if img.Opaque() {
+		return "image/jpeg"
+	}
+
+	return "image/png"
+}
+
+/** Codifica una imagen en el formato indicado
+ *
+ * @param img *image.NRGBA "La imagen"
+ * @param contentType string "image/jpeg o image/png"
+ * @return []byte "La imagen codificada"
+ * @return error "Error"
+ */
+func EncodeImage(img *image.NRGBA, contentType string) ([]byte, error) {
+	var buffer bytes.Buffer
+
+	var err error
+	if contentType == "image/png" {
+		err = png.Encode(&buffer, img)
+	} else {
+		err = jpeg.Encode(&buffer, img, &jpeg.Options{Quality: jpegQuality})
+	}
+
+	return buffer.Bytes(), err
+}
+
+// Reduce la imagen promediando los píxeles de origen que cubre cada píxel de destino
+func resizeImage(src *image.NRGBA, width, height int) *image.NRGBA {
+	bounds := src.Bounds()
+	srcWidth, srcHeight := bounds.Dx(), bounds.Dy()
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		y0 := y * srcHeight / height
+		y1 := max(y0+1, (y+1)*srcHeight/height)
+
+		for x := 0; x < width; x++ {
+			x0 := x * srcWidth / width
+			x1 := max(x0+1, (x+1)*srcWidth/width)
+
+			// Los colores se promedian premultiplicados por el alfa para que los píxeles
+			// transparentes no oscurezcan los bordes
+			var r, g, b, a, count uint64
+			for sy := y0; sy < y1; sy++ {
+				offset := src.PixOffset(bounds.Min.X+x0, bounds.Min.Y+sy)
+				for sx := x0; sx < x1; sx++ {
+					alpha := uint64(src.Pix[offset+3])
+					r += uint64(src.Pix[offset]) * alpha
+					g += uint64(src.Pix[offset+1]) * alpha
+					b += uint64(src.Pix[offset+2]) * alpha
+					a += alpha
+					count++
+					offset += 4
+				}
+			}
+
+			i := dst.PixOffset(x, y)
+			if a > 0 {
+				dst.Pix[i] = uint8(r / a)
+				dst.Pix[i+1] = uint8(g / a)
+				dst.Pix[i+2] = uint8(b / a)
+			}
+			dst.Pix[i+3] = uint8(a / count)
+		}
+	}
+
+	return dst
+}
+
+func copyImage(src *image.NRGBA) *image.NRGBA {
+	dst := image.NewNRGBA(image.Rect(0, 0, src.Bounds().Dx(), src.Bounds().Dy()))
+	draw.Draw(dst, dst.Bounds(), src, src.Bounds().Min, draw.Src)
+	return dst
+}
+
+// Gira o refleja la imagen según el valor EXIF Orientation (1 a 8) para que se vea derecha sin metadatos
+func orientImage(src *image.NRGBA, orientation int) *image.NRGBA {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+
+	width, height := src.Bounds().Dx(), src.Bounds().Dy()
+	dstWidth, dstHeight := width, height
+	if orientation >= 5 {
+		dstWidth, dstHeight = height, width
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+	for y := 0; y < dstHeight; y++ {
+		for x := 0; x < dstWidth; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2:
+				sx, sy = width-1-x, y
+			case 3:
+				sx, sy = width-1-x, height-1-y
+			case 4:
+				sx, sy = x, height-1-y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, height-1-x
+			case 7:
+				sx, sy = width-1-y, height-1-x
+			case 8:
+				sx, sy = width-1-y, x
+			}
+
+			copy(dst.Pix[dst.PixOffset(x, y):dst.PixOffset(x, y)+4], src.Pix[src.PixOffset(sx, sy):src.PixOffset(sx, sy)+4])
+		}
+	}
+
+	return dst
+}
+
+// Obtiene el valor EXIF Orientation de una imagen JPEG. Devuelve 1 (sin rotación) si no lo tiene
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	for offset := 2; offset+4 <= len(data); {
+		if data[offset] != 0xFF {
+			return 1
+		}
+
+		marker := data[offset+1]
+		length := int(binary.BigEndian.Uint16(data[offset+2:]))
+		// SOS: a partir de aquí empiezan los datos de la imagen
+		if marker == 0xDA || length < 2 || offset+2+length > len(data) {
+			return 1
+		}
+
+		segment := data[offset+4 : offset+2+length]
+		if marker == 0xE1 && len(segment) > 6 && string(segment[:6]) == "Exif\x00\x00" {
+			return exifOrientation(segment[6:])
+		}
+
+		offset += 2 + length
+	}
+
+	return 1
+}
+
+// Busca la etiqueta Orientation (0x0112) en el primer directorio de un bloque TIFF
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+
+	entries := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < entries; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			return int(order.Uint16(tiff[entry+8:]))
+		}
+	}
+
+	return 1
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+
+	return b
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+)
+
+// Crea un GIF de un solo píxel cuyo tamaño declarado es el indicado. Así se prueba el límite sin generar
+// una imagen que ocupe la memoria que el límite intenta evitar
+func gifWithScreenSize(t *testing.T, width, height int) []byte {
+	frame := image.NewPaletted(image.Rect(0, 0, 1, 1), color.Palette{color.Black, color.White})
+
+	var buffer bytes.Buffer
+	err := gif.EncodeAll(&buffer, &gif.GIF{
+		Image:  []*image.Paletted{frame},
+		Delay:  []int{0},
+		Config: image.Config{ColorModel: frame.Palette, Width: width, Height: height},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buffer.Bytes()
+}
+
+func TestDecodeImagePixelLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		width   int
+		height  int
+		invalid bool
+	}{
+		{name: "dentro del límite", width: 4000, height: 4000},
+		{name: "justo en el límite", width: 8000, height: maxImagePixels / 8000},
+		{name: "una fila más que el límite", width: 8000, height: maxImagePixels/8000 + 1, invalid: true},
+		{name: "muy grande", width: 65535, height: 65535, invalid: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			img, err := DecodeImage(gifWithScreenSize(t, test.width, test.height))
+			if test.invalid {
+				if !errors.Is(err, ErrInvalidImage) {
+					t.Fatalf("se esperaba ErrInvalidImage para %dx%d, se obtuvo %v", test.width, test.height, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("no se esperaba un error para %dx%d: %s", test.width, test.height, err)
+			}
+			if img == nil {
+				t.Fatal("no se obtuvo la imagen")
+			}
+		})
+	}
+}
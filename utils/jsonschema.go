@@ -0,0 +1,346 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Palabras clave de JSON Schema que se pueden usar en un esquema. Las anotaciones se aceptan pero no se validan
+var jsonSchemaKeywords = map[string]bool{
+	"$schema": true, "$id": true, "title": true, "description": true, "default": true, "examples": true,
+	"type": true, "enum": true, "const": true,
+	"properties": true, "required": true, "additionalProperties": true,
+	"items": true, "minItems": true, "maxItems": true, "uniqueItems": true,
+	"minLength": true, "maxLength": true, "pattern": true, "format": true,
+	"minimum": true, "maximum": true, "exclusiveMinimum": true, "exclusiveMaximum": true,
+	// Extensión propia: los valores de texto de la propiedad se incluyen en la búsqueda de usuarios
+	"x-searchable": true,
+}
+
+var jsonSchemaTypes = map[string]bool{
+	"object": true, "array": true, "string": true, "number": true, "integer": true, "boolean": true, "null": true,
+}
+
+// JSONSchema valida valores JSON con un subconjunto de JSON Schema (draft 7)
+type JSONSchema struct {
+	root     map[string]interface{}
+	patterns map[string]*regexp.Regexp
+}
+
+/** Interpreta un esquema y verifica que sólo use palabras clave soportadas
+ *
+ * @param data []byte "El esquema en formato JSON"
+ * @return *JSONSchema "El esquema"
+ * @return error "El error si el esquema no es válido"
+ */
+func ParseJSONSchema(data []byte) (*JSONSchema, error) {
+	var root map[string]interface{}
+	if err := json.Unmarshal(data, &root); err != nil || root == nil {
+		return nil, fmt.Errorf("el esquema debe ser un objeto JSON")
+	}
+
+	schema := &JSONSchema{root: root, patterns: map[string]*regexp.Regexp{}}
+	if err := schema.check(root, ""); err != nil {
+		return nil, err
+	}
+
+	return schema, nil
+}
+
+/** Valida un valor con el esquema
+ *
+ * @param value interface{} "El valor, con los tipos que produce encoding/json"
+ * @return []string "Los errores, con la ruta de cada valor inválido. Vacío si el valor es válido"
+ */
+func (schema *JSONSchema) Validate(value interface{}) []string {
+	var errs []string
+	schema.validate(schema.root, value, "", &errs)
+	return errs
+}
+
+// Indica si el esquema declara una propiedad de primer nivel
+func (schema *JSONSchema) HasProperty(name string) bool {
+	properties, _ := schema.root["properties"].(map[string]interface{})
+	_, ok := properties[name]
+	return ok
+}
+
+// Devuelve las propiedades de primer nivel marcadas con x-searchable
+func (schema *JSONSchema) SearchableProperties() []string {
+	properties, _ := schema.root["properties"].(map[string]interface{})
+
+	var result []string
+	for name, property := range properties {
+		if definition, ok := property.(map[string]interface{}); ok && definition["x-searchable"] == true {
+			result = append(result, name)
+		}
+	}
+
+	sort.Strings(result)
+	return result
+}
+
+// Verifica recursivamente las palabras clave del esquema y compila las expresiones regulares
+func (schema *JSONSchema) check(node map[string]interface{}, path string) error {
+	for keyword, value := range node {
+		if !jsonSchemaKeywords[keyword] {
+			return fmt.Errorf("%s: la palabra clave \"%s\" no está soportada", schemaPath(path), keyword)
+		}
+
+		switch keyword {
+		case "type":
+			for _, t := range schemaTypes(value) {
+				if !jsonSchemaTypes[t] {
+					return fmt.Errorf("%s: el tipo \"%v\" no existe", schemaPath(path), t)
+				}
+			}
+		case "properties":
+			properties, ok := value.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%s: properties debe ser un objeto", schemaPath(path))
+			}
+			for name, property := range properties {
+				child, ok := property.(map[string]interface{})
+				if !ok {
+					return fmt.Errorf("%s: la propiedad \"%s\" debe ser un esquema", schemaPath(path), name)
+				}
+				if err := schema.check(child, path+"/properties/"+name); err != nil {
+					return err
+				}
+			}
+		case "items", "additionalProperties":
+			if _, ok := value.(bool); ok && keyword == "additionalProperties" {
+				continue
+			}
+			child, ok := value.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%s: %s debe ser un esquema", schemaPath(path), keyword)
+			}
+			if err := schema.check(child, path+"/"+keyword); err != nil {
+				return err
+			}
+		case "required":
+			list, ok := value.([]interface{})
+			if !ok {
+				return fmt.Errorf("%s: required debe ser una lista", schemaPath(path))
+			}
+			for _, name := range list {
+				if _, ok := name.(string); !ok {
+					return fmt.Errorf("%s: required debe contener nombres de propiedades", schemaPath(path))
+				}
+			}
+		case "enum":
+			if _, ok := value.([]interface{}); !ok {
+				return fmt.Errorf("%s: enum debe ser una lista", schemaPath(path))
+			}
+		case "pattern":
+			text, ok := value.(string)
+			if !ok {
+				return fmt.Errorf("%s: pattern debe ser un texto", schemaPath(path))
+			}
+			re, err := regexp.Compile(text)
+			if err != nil {
+				return fmt.Errorf("%s: pattern no es una expresión regular válida: %s", schemaPath(path), err)
+			}
+			schema.patterns[text] = re
+		case "minLength", "maxLength", "minItems", "maxItems", "minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum":
+			if _, ok := value.(float64); !ok {
+				return fmt.Errorf("%s: %s debe ser un número", schemaPath(path), keyword)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (schema *JSONSchema) validate(node map[string]interface{}, value interface{}, path string, errs *[]string) {
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, fmt.Sprintf("%s: %s", schemaPath(path), fmt.Sprintf(format, args...)))
+	}
+
+	if types := schemaTypes(node["type"]); len(types) > 0 && !matchesSchemaType(types, value) {
+		fail("debe ser de tipo %s", strings.Join(types, " o "))
+		return
+	}
+
+	if options, ok := node["enum"].([]interface{}); ok {
+		found := false
+		for _, option := range options {
+			if reflect.DeepEqual(option, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("no es uno de los valores permitidos")
+		}
+	}
+
+	if expected, ok := node["const"]; ok && !reflect.DeepEqual(expected, value) {
+		fail("debe ser igual a %v", expected)
+	}
+
+	switch v := value.(type) {
+	case string:
+		length := float64(utf8.RuneCountInString(v))
+		if min, ok := node["minLength"].(float64); ok && length < min {
+			fail("debe tener al menos %v caracteres", min)
+		}
+		if max, ok := node["maxLength"].(float64); ok && length > max {
+			fail("debe tener como máximo %v caracteres", max)
+		}
+		if pattern, ok := node["pattern"].(string); ok && !schema.patterns[pattern].MatchString(v) {
+			fail("no cumple el formato %s", pattern)
+		}
+		if format, ok := node["format"].(string); ok && !matchesSchemaFormat(format, v) {
+			fail("no es un valor %s válido", format)
+		}
+	case float64:
+		if min, ok := node["minimum"].(float64); ok && v < min {
+			fail("debe ser mayor o igual a %v", min)
+		}
+		if max, ok := node["maximum"].(float64); ok && v > max {
+			fail("debe ser menor o igual a %v", max)
+		}
+		if min, ok := node["exclusiveMinimum"].(float64); ok && v <= min {
+			fail("debe ser mayor a %v", min)
+		}
+		if max, ok := node["exclusiveMaximum"].(float64); ok && v >= max {
+			fail("debe ser menor a %v", max)
+		}
+	case []interface{}:
+		if min, ok := node["minItems"].(float64); ok && float64(len(v)) < min {
+			fail("debe tener al menos %v elementos", min)
+		}
+		if max, ok := node["maxItems"].(float64); ok && float64(len(v)) > max {
+			fail("debe tener como máximo %v elementos", max)
+		}
+		if node["uniqueItems"] == true {
+			for i := range v {
+				for j := i + 1; j < len(v); j++ {
+					if reflect.DeepEqual(v[i], v[j]) {
+						fail("los elementos %d y %d están repetidos", i, j)
+					}
+				}
+			}
+		}
+		if items, ok := node["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				schema.validate(items, item, fmt.Sprintf("%s/%d", path, i), errs)
+			}
+		}
+	case map[string]interface{}:
+		if required, ok := node["required"].([]interface{}); ok {
+			for _, name := range required {
+				if _, present := v[name.(string)]; !present {
+					fail("falta la propiedad requerida \"%s\"", name)
+				}
+			}
+		}
+
+		properties, _ := node["properties"].(map[string]interface{})
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			if property, ok := properties[name].(map[string]interface{}); ok {
+				schema.validate(property, v[name], path+"/"+name, errs)
+				continue
+			}
+
+			switch additional := node["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					fail("la propiedad \"%s\" no está permitida", name)
+				}
+			case map[string]interface{}:
+				schema.validate(additional, v[name], path+"/"+name, errs)
+			}
+		}
+	}
+}
+
+// Devuelve los tipos de la palabra clave type, que puede ser un texto o una lista
+func schemaTypes(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		types := make([]string, 0, len(v))
+		for _, t := range v {
+			types = append(types, fmt.Sprint(t))
+		}
+		return types
+	default:
+		return nil
+	}
+}
+
+func matchesSchemaType(types []string, value interface{}) bool {
+	for _, t := range types {
+		switch v := value.(type) {
+		case nil:
+			if t == "null" {
+				return true
+			}
+		case bool:
+			if t == "boolean" {
+				return true
+			}
+		case string:
+			if t == "string" {
+				return true
+			}
+		case float64:
+			if t == "number" || (t == "integer" && v == math.Trunc(v)) {
+				return true
+			}
+		case []interface{}:
+			if t == "array" {
+				return true
+			}
+		case map[string]interface{}:
+			if t == "object" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Valida los formatos más comunes. Los formatos desconocidos se aceptan, como indica la especificación
+func matchesSchemaFormat(format string, value string) bool {
+	switch format {
+	case "email":
+		_, err := mail.ParseAddress(value)
+		return err == nil && !strings.Contains(value, " ")
+	case "date":
+		_, err := time.Parse("2006-01-02", value)
+		return err == nil
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, value)
+		return err == nil
+	default:
+		return true
+	}
+}
+
+func schemaPath(path string) string {
+	if path == "" {
+		return "/"
+	}
+
+	return path
+}
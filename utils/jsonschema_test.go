@@ -0,0 +1,151 @@
+package utils
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseJSONSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		invalid bool
+	}{
+		{name: "esquema vacío", schema: `{}`},
+		{name: "anotaciones", schema: `{"$schema": "http://json-schema.org/draft-07/schema#", "title": "Usuario", "description": "Atributos", "default": {}, "examples": [{}]}`},
+		{name: "extensión x-searchable", schema: `{"type": "object", "properties": {"department": {"type": "string", "x-searchable": true}}}`},
+		{name: "lista de tipos", schema: `{"type": ["string", "null"]}`},
+		{name: "no es un objeto", schema: `[]`, invalid: true},
+		{name: "null", schema: `null`, invalid: true},
+		{name: "JSON inválido", schema: `{"type":`, invalid: true},
+		{name: "palabra clave desconocida", schema: `{"type": "object", "patternProperties": {}}`, invalid: true},
+		{name: "palabra clave desconocida anidada", schema: `{"properties": {"tags": {"items": {"$ref": "#/definitions/tag"}}}}`, invalid: true},
+		{name: "tipo inexistente", schema: `{"type": "date"}`, invalid: true},
+		{name: "properties no es un objeto", schema: `{"properties": []}`, invalid: true},
+		{name: "propiedad que no es un esquema", schema: `{"properties": {"age": "integer"}}`, invalid: true},
+		{name: "items no es un esquema", schema: `{"items": true}`, invalid: true},
+		{name: "required no es una lista", schema: `{"required": "email"}`, invalid: true},
+		{name: "required con valores que no son nombres", schema: `{"required": [1]}`, invalid: true},
+		{name: "enum no es una lista", schema: `{"enum": "a"}`, invalid: true},
+		{name: "pattern inválido", schema: `{"pattern": "("}`, invalid: true},
+		{name: "minLength no es un número", schema: `{"minLength": "3"}`, invalid: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			schema, err := ParseJSONSchema([]byte(test.schema))
+			if test.invalid {
+				if err == nil {
+					t.Fatalf("se esperaba un error para %s", test.schema)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("no se esperaba un error para %s: %s", test.schema, err)
+			}
+			if schema == nil {
+				t.Fatal("no se obtuvo el esquema")
+			}
+		})
+	}
+}
+
+func TestJSONSchemaValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		value   string
+		invalid bool
+	}{
+		{name: "type string", schema: `{"type": "string"}`, value: `"ventas"`},
+		{name: "type string con un número", schema: `{"type": "string"}`, value: `1`, invalid: true},
+		{name: "type integer", schema: `{"type": "integer"}`, value: `3`},
+		{name: "type integer con decimales", schema: `{"type": "integer"}`, value: `3.5`, invalid: true},
+		{name: "type number", schema: `{"type": "number"}`, value: `3.5`},
+		{name: "type boolean", schema: `{"type": "boolean"}`, value: `"true"`, invalid: true},
+		{name: "type null", schema: `{"type": "null"}`, value: `null`},
+		{name: "type array", schema: `{"type": "array"}`, value: `{}`, invalid: true},
+		{name: "type object", schema: `{"type": "object"}`, value: `{}`},
+		{name: "lista de tipos", schema: `{"type": ["string", "null"]}`, value: `null`},
+		{name: "lista de tipos sin coincidencia", schema: `{"type": ["string", "null"]}`, value: `false`, invalid: true},
+		{name: "enum", schema: `{"enum": ["ventas", "soporte", 3]}`, value: `3`},
+		{name: "enum sin coincidencia", schema: `{"enum": ["ventas", "soporte"]}`, value: `"Ventas"`, invalid: true},
+		{name: "const", schema: `{"const": {"a": [1, 2]}}`, value: `{"a": [1, 2]}`},
+		{name: "const distinto", schema: `{"const": "uy"}`, value: `"ar"`, invalid: true},
+		{name: "properties", schema: `{"properties": {"age": {"type": "integer"}}}`, value: `{"age": 30, "other": "x"}`},
+		{name: "properties con un valor inválido", schema: `{"properties": {"age": {"type": "integer"}}}`, value: `{"age": "30"}`, invalid: true},
+		{name: "required", schema: `{"required": ["department"]}`, value: `{"department": "ventas"}`},
+		{name: "required ausente", schema: `{"required": ["department"]}`, value: `{"age": 30}`, invalid: true},
+		{name: "additionalProperties false", schema: `{"properties": {"age": {}}, "additionalProperties": false}`, value: `{"age": 30}`},
+		{name: "additionalProperties false con otra propiedad", schema: `{"properties": {"age": {}}, "additionalProperties": false}`, value: `{"age": 30, "other": 1}`, invalid: true},
+		{name: "additionalProperties con esquema", schema: `{"additionalProperties": {"type": "string"}}`, value: `{"a": "x", "b": "y"}`},
+		{name: "additionalProperties con esquema inválido", schema: `{"additionalProperties": {"type": "string"}}`, value: `{"a": "x", "b": 2}`, invalid: true},
+		{name: "items", schema: `{"items": {"type": "string"}}`, value: `["a", "b"]`},
+		{name: "items con un elemento inválido", schema: `{"items": {"type": "string"}}`, value: `["a", 2]`, invalid: true},
+		{name: "minItems", schema: `{"minItems": 2}`, value: `[1, 2]`},
+		{name: "minItems sin alcanzar", schema: `{"minItems": 2}`, value: `[1]`, invalid: true},
+		{name: "maxItems", schema: `{"maxItems": 2}`, value: `[1, 2]`},
+		{name: "maxItems superado", schema: `{"maxItems": 2}`, value: `[1, 2, 3]`, invalid: true},
+		{name: "uniqueItems", schema: `{"uniqueItems": true}`, value: `[1, "1", {"a": 1}, {"a": 2}]`},
+		{name: "uniqueItems repetidos", schema: `{"uniqueItems": true}`, value: `[{"a": 1}, {"a": 1}]`, invalid: true},
+		{name: "minLength cuenta caracteres", schema: `{"minLength": 3}`, value: `"ñandú"`},
+		{name: "minLength sin alcanzar", schema: `{"minLength": 3}`, value: `"ab"`, invalid: true},
+		{name: "maxLength cuenta caracteres", schema: `{"maxLength": 5}`, value: `"ñandú"`},
+		{name: "maxLength superado", schema: `{"maxLength": 4}`, value: `"ñandú"`, invalid: true},
+		{name: "pattern", schema: `{"pattern": "^[A-Z]{2}-\\d+$"}`, value: `"UY-123"`},
+		{name: "pattern sin coincidencia", schema: `{"pattern": "^[A-Z]{2}-\\d+$"}`, value: `"uy-123"`, invalid: true},
+		{name: "format email", schema: `{"format": "email"}`, value: `"juan@mail.com"`},
+		{name: "format email inválido", schema: `{"format": "email"}`, value: `"juan mail.com"`, invalid: true},
+		{name: "format email con nombre", schema: `{"format": "email"}`, value: `"Juan <juan@mail.com>"`, invalid: true},
+		{name: "format date", schema: `{"format": "date"}`, value: `"2022-06-08"`},
+		{name: "format date inválido", schema: `{"format": "date"}`, value: `"2022-02-30"`, invalid: true},
+		{name: "format date-time", schema: `{"format": "date-time"}`, value: `"2022-06-08T10:30:00-03:00"`},
+		{name: "format date-time sin zona horaria", schema: `{"format": "date-time"}`, value: `"2022-06-08T10:30:00"`, invalid: true},
+		{name: "format desconocido", schema: `{"format": "uuid"}`, value: `"cualquier cosa"`},
+		{name: "minimum", schema: `{"minimum": 18}`, value: `18`},
+		{name: "minimum sin alcanzar", schema: `{"minimum": 18}`, value: `17.9`, invalid: true},
+		{name: "maximum", schema: `{"maximum": 99}`, value: `99`},
+		{name: "maximum superado", schema: `{"maximum": 99}`, value: `100`, invalid: true},
+		{name: "exclusiveMinimum", schema: `{"exclusiveMinimum": 0}`, value: `0.1`},
+		{name: "exclusiveMinimum igual al límite", schema: `{"exclusiveMinimum": 0}`, value: `0`, invalid: true},
+		{name: "exclusiveMaximum", schema: `{"exclusiveMaximum": 100}`, value: `99.9`},
+		{name: "exclusiveMaximum igual al límite", schema: `{"exclusiveMaximum": 100}`, value: `100`, invalid: true},
+		{name: "palabras clave de texto no se aplican a números", schema: `{"minLength": 3, "pattern": "^a"}`, value: `1`},
+		{name: "propiedad anidada inválida", schema: `{"properties": {"address": {"properties": {"zip": {"type": "string"}}}}}`, value: `{"address": {"zip": 11300}}`, invalid: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			schema, err := ParseJSONSchema([]byte(test.schema))
+			if err != nil {
+				t.Fatalf("no se pudo interpretar el esquema %s: %s", test.schema, err)
+			}
+
+			var value interface{}
+			if err := json.Unmarshal([]byte(test.value), &value); err != nil {
+				t.Fatal(err)
+			}
+
+			errs := schema.Validate(value)
+			if test.invalid && len(errs) == 0 {
+				t.Errorf("se esperaba que %s no cumpliera %s", test.value, test.schema)
+			}
+			if !test.invalid && len(errs) > 0 {
+				t.Errorf("no se esperaban errores para %s con %s: %v", test.value, test.schema, errs)
+			}
+		})
+	}
+}
+
+func TestJSONSchemaValidateErrorPath(t *testing.T) {
+	schema, err := ParseJSONSchema([]byte(`{"properties": {"tags": {"items": {"type": "string"}}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := schema.Validate(map[string]interface{}{"tags": []interface{}{"a", float64(2)}})
+	if len(errs) != 1 || errs[0] != "/tags/1: debe ser de tipo string" {
+		t.Errorf("se obtuvo %v, se esperaba el error en /tags/1", errs)
+	}
+}
@@ -0,0 +1,31 @@
+package utils
+
+/**
+ * Aplica un documento JSON Merge Patch (RFC 7396) sobre un valor. Los valores null del
+ * documento eliminan la propiedad y los objetos se combinan recursivamente
+ *
+ * @param target interface{} "El valor original, con los tipos que produce encoding/json"
+ * @param patch interface{} "El documento con los cambios"
+ * @return interface{} "El valor resultante"
+ */
+func MergePatch(target interface{}, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = MergePatch(targetObject[key], value)
+	}
+
+	return targetObject
+}
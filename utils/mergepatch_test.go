@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// Casos del apéndice A de RFC 7396
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		target   string
+		patch    string
+		expected string
+	}{
+		{name: "cambia una propiedad", target: `{"a":"b"}`, patch: `{"a":"c"}`, expected: `{"a":"c"}`},
+		{name: "agrega una propiedad", target: `{"a":"b"}`, patch: `{"b":"c"}`, expected: `{"a":"b","b":"c"}`},
+		{name: "null elimina la propiedad", target: `{"a":"b"}`, patch: `{"a":null}`, expected: `{}`},
+		{name: "elimina una de varias propiedades", target: `{"a":"b","b":"c"}`, patch: `{"a":null}`, expected: `{"b":"c"}`},
+		{name: "las listas se reemplazan", target: `{"a":["b"]}`, patch: `{"a":"c"}`, expected: `{"a":"c"}`},
+		{name: "reemplaza una lista", target: `{"a":"c"}`, patch: `{"a":["b"]}`, expected: `{"a":["b"]}`},
+		{name: "combina objetos anidados", target: `{"a":{"b":"c"}}`, patch: `{"a":{"b":"d","c":null}}`, expected: `{"a":{"b":"d"}}`},
+		{name: "una lista de objetos se reemplaza completa", target: `{"a":[{"b":"c"}]}`, patch: `{"a":[1]}`, expected: `{"a":[1]}`},
+		{name: "lista sobre lista", target: `["a","b"]`, patch: `["c","d"]`, expected: `["c","d"]`},
+		{name: "lista sobre objeto", target: `{"a":"b"}`, patch: `["c"]`, expected: `["c"]`},
+		{name: "null sobre objeto", target: `{"a":"foo"}`, patch: `null`, expected: `null`},
+		{name: "texto sobre objeto", target: `{"a":"foo"}`, patch: `"bar"`, expected: `"bar"`},
+		{name: "un null existente se conserva", target: `{"e":null}`, patch: `{"a":1}`, expected: `{"e":null,"a":1}`},
+		{name: "objeto sobre una lista", target: `[1,2]`, patch: `{"a":"b","c":null}`, expected: `{"a":"b"}`},
+		{name: "crea objetos anidados", target: `{}`, patch: `{"a":{"bb":{"ccc":null}}}`, expected: `{"a":{"bb":{}}}`},
+		{name: "patch vacío", target: `{"a":"b"}`, patch: `{}`, expected: `{"a":"b"}`},
+		{name: "target null", target: `null`, patch: `{"a":1}`, expected: `{"a":1}`},
+	}
+
+	decode := func(t *testing.T, data string) interface{} {
+		var value interface{}
+		if err := json.Unmarshal([]byte(data), &value); err != nil {
+			t.Fatal(err)
+		}
+		return value
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := MergePatch(decode(t, test.target), decode(t, test.patch))
+			if expected := decode(t, test.expected); !reflect.DeepEqual(result, expected) {
+				t.Errorf("MergePatch(%s, %s) = %v, se esperaba %s", test.target, test.patch, result, test.expected)
+			}
+		})
+	}
+}
@@ -17,7 +17,7 @@ func ReadTags() {
 		Email:             "john@doe.com",
 		Password:          "password",
 		Type:              "user",
-		Status:            "active",
+		Status:            models.UserStatusActive,
 		ProfileImage:      "",
 		PasswordChangedAt: time.Now(),
 		CreatedAt:         time.Now(),
@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrInvalidPhone = errors.New("el teléfono no es válido, debe incluir el código de país, por ejemplo +59899123456")
+
+/**
+ * Normaliza un teléfono al formato E.164 ("+" seguido de 8 a 15 dígitos, sin separadores). Se aceptan
+ * espacios, guiones, puntos y paréntesis como separadores, y el prefijo internacional "00" en lugar de "+",
+ * por ejemplo "00598 (99) 123-456" se convierte en "+59899123456"
+ *
+ * @param phone string "El teléfono"
+ * @return string "El teléfono normalizado"
+ * @return error "ErrInvalidPhone si el teléfono no tiene un formato válido"
+ */
+func NormalizePhone(phone string) (string, error) {
+	phone = strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '.', '(', ')':
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(phone))
+
+	if strings.HasPrefix(phone, "00") {
+		phone = "+" + phone[2:]
+	}
+
+	digits := strings.TrimPrefix(phone, "+")
+	if digits == phone || len(digits) < 8 || len(digits) > 15 || digits[0] == '0' {
+		return "", ErrInvalidPhone
+	}
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return "", ErrInvalidPhone
+		}
+	}
+
+	return "+" + digits, nil
+}
+
+/**
+ * Oculta un teléfono salvo sus últimos cuatro dígitos, para mostrarlo sin revelarlo
+ *
+ * @param phone string "El teléfono en formato E.164"
+ * @return string "El teléfono oculto, por ejemplo +*******3456"
+ */
+func MaskPhone(phone string) string {
+	if len(phone) <= 5 {
+		return phone
+	}
+
+	return "+" + strings.Repeat("*", len(phone)-5) + phone[len(phone)-4:]
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizePhone(t *testing.T) {
+	tests := []struct {
+		name     string
+		phone    string
+		expected string
+		invalid  bool
+	}{
+		{name: "E.164", phone: "+59899123456", expected: "+59899123456"},
+		{name: "con separadores", phone: "+598 (99) 123-456", expected: "+59899123456"},
+		{name: "con puntos", phone: "+1.202.555.0143", expected: "+12025550143"},
+		{name: "prefijo 00", phone: "00598 (99) 123-456", expected: "+59899123456"},
+		{name: "espacios alrededor", phone: "  +59899123456 ", expected: "+59899123456"},
+		{name: "mínimo de 8 dígitos", phone: "+12345678", expected: "+12345678"},
+		{name: "máximo de 15 dígitos", phone: "+123456789012345", expected: "+123456789012345"},
+		{name: "vacío", phone: "", invalid: true},
+		{name: "sin código de país", phone: "099123456", invalid: true},
+		{name: "sin prefijo internacional", phone: "59899123456", invalid: true},
+		{name: "código de país que empieza con 0", phone: "+059899123456", invalid: true},
+		{name: "menos de 8 dígitos", phone: "+1234567", invalid: true},
+		{name: "más de 15 dígitos", phone: "+1234567890123456", invalid: true},
+		{name: "con letras", phone: "+598991234AB", invalid: true},
+		{name: "con una barra", phone: "+598/99123456", invalid: true},
+		{name: "dos prefijos", phone: "++59899123456", invalid: true},
+		{name: "sólo el prefijo", phone: "+", invalid: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			normalized, err := NormalizePhone(test.phone)
+			if test.invalid {
+				if !errors.Is(err, ErrInvalidPhone) {
+					t.Fatalf("se esperaba ErrInvalidPhone para %q, se obtuvo %q, %v", test.phone, normalized, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("no se esperaba un error para %q: %s", test.phone, err)
+			}
+			if normalized != test.expected {
+				t.Errorf("NormalizePhone(%q) = %q, se esperaba %q", test.phone, normalized, test.expected)
+			}
+		})
+	}
+}
+
+func TestMaskPhone(t *testing.T) {
+	tests := []struct {
+		phone    string
+		expected string
+	}{
+		{phone: "+59899123456", expected: "+*******3456"},
+		{phone: "+12345678", expected: "+****5678"},
+		{phone: "+1234", expected: "+1234"},
+		{phone: "", expected: ""},
+	}
+
+	for _, test := range tests {
+		if masked := MaskPhone(test.phone); masked != test.expected {
+			t.Errorf("MaskPhone(%q) = %q, se esperaba %q", test.phone, masked, test.expected)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+/**
+ * Normaliza un texto para búsquedas: lo pasa a minúsculas y le quita los acentos,
+ * por ejemplo "Martín" se convierte en "martin"
+ *
+ * @param text string "El texto a normalizar"
+ * @return string "El texto normalizado"
+ */
+func NormalizeSearchText(text string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	result, _, err := transform.String(t, text)
+	if err != nil {
+		result = text
+	}
+
+	return strings.ToLower(strings.TrimSpace(result))
+}
+
+/**
+ * Separa un texto normalizado en palabras
+ *
+ * @param text string "El texto a separar"
+ * @return []string "Las palabras del texto"
+ */
+func SearchWords(text string) []string {
+	return strings.FieldsFunc(NormalizeSearchText(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+/**
+ * Obtiene los términos de búsqueda de un usuario a partir de su nombre, apellido y correo electrónico
+ *
+ * @param firstName string "El nombre del usuario"
+ * @param lastName string "El apellido del usuario"
+ * @param email string "El correo electrónico del usuario"
+ * @param extra ...string "Otros textos por los que se puede buscar al usuario"
+ * @return []string "Los términos de búsqueda, sin repetir"
+ */
+func UserSearchTerms(firstName, lastName, email string, extra ...string) []string {
+	var terms []string
+	seen := map[string]bool{}
+	add := func(values ...string) {
+		for _, value := range values {
+			if value != "" && !seen[value] {
+				seen[value] = true
+				terms = append(terms, value)
+			}
+		}
+	}
+
+	add(SearchWords(firstName)...)
+	add(SearchWords(lastName)...)
+
+	// El correo completo permite buscar por prefijo, por ejemplo "juan.pe"
+	normalizedEmail := NormalizeSearchText(email)
+	add(normalizedEmail)
+	if at := strings.LastIndex(normalizedEmail, "@"); at >= 0 {
+		add(normalizedEmail[at+1:])
+	}
+	add(SearchWords(normalizedEmail)...)
+
+	for _, text := range extra {
+		add(SearchWords(text)...)
+	}
+
+	return terms
+}
@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"math/big"
+)
+
+/** Genera un token aleatorio de un solo uso, apto para enviar en un enlace
+ *
+ * @return string "El token (32 bytes aleatorios en base64 para URL)"
+ * @return error "El error si no se pudieron generar los bytes aleatorios"
+ */
+func NewSecureToken() (string, error) {
+	data := make([]byte, 32)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+/** Obtiene el hash con el que se guarda un token en la base de datos, para que no se pueda usar si se filtra
+ *
+ * @param token string "El token"
+ * @return string "El hash SHA-256 en hexadecimal"
+ */
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+/** Genera un código numérico aleatorio de un solo uso, apto para enviar por SMS
+ *
+ * @param digits int "La cantidad de dígitos del código"
+ * @return string "El código, con ceros a la izquierda si hace falta"
+ * @return error "El error si no se pudieron generar los números aleatorios"
+ */
+func NewNumericCode(digits int) (string, error) {
+	code := make([]byte, digits)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		code[i] = byte('0' + n.Int64())
+	}
+
+	return string(code), nil
+}
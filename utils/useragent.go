@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"strings"
+)
+
+// Navegadores reconocidos, en el orden en que se buscan: varios incluyen también "Chrome/" o "Safari/"
+var userAgentBrowsers = []struct {
+	name   string
+	tokens []string
+}{
+	{"Edge", []string{"Edg/", "EdgA/", "EdgiOS/", "Edge/"}},
+	{"Opera", []string{"OPR/", "Opera/"}},
+	{"Samsung Internet", []string{"SamsungBrowser/"}},
+	{"Chrome", []string{"CriOS/", "Chrome/"}},
+	{"Firefox", []string{"FxiOS/", "Firefox/"}},
+	{"Safari", []string{"Version/"}},
+	{"Internet Explorer", []string{"MSIE ", "Trident/7.0; rv:"}},
+	{"curl", []string{"curl/"}},
+	{"Wget", []string{"Wget/"}},
+	{"Postman", []string{"PostmanRuntime/"}},
+	{"Python", []string{"python-requests/"}},
+	{"Go", []string{"Go-http-client/"}},
+	{"okhttp", []string{"okhttp/"}},
+}
+
+// Sistemas operativos reconocidos, en el orden en que se buscan: Android incluye también "Linux"
+var userAgentSystems = []struct {
+	name   string
+	tokens []string
+}{
+	{"iOS", []string{"iPhone", "iPad", "iPod"}},
+	{"Android", []string{"Android"}},
+	{"Windows", []string{"Windows"}},
+	{"macOS", []string{"Macintosh", "Mac OS X"}},
+	{"ChromeOS", []string{"CrOS"}},
+	{"Linux", []string{"Linux"}},
+}
+
+var userAgentBotTokens = []string{"bot", "crawler", "spider", "slurp"}
+
+/**
+ * Obtiene el navegador, con su versión principal, el sistema operativo y el tipo de dispositivo de un user agent,
+ * por ejemplo "Chrome 120", "Windows" y "desktop". Lo que no se reconoce queda vacío
+ *
+ * @param userAgent string "El user agent"
+ * @return browser string "El navegador"
+ * @return system string "El sistema operativo"
+ * @return deviceType string "desktop, mobile, tablet o bot"
+ */
+func ParseUserAgent(userAgent string) (browser string, system string, deviceType string) {
+	if userAgent == "" {
+		return
+	}
+
+	lower := strings.ToLower(userAgent)
+	for _, token := range userAgentBotTokens {
+		if strings.Contains(lower, token) {
+			deviceType = "bot"
+			break
+		}
+	}
+
+	for _, candidate := range userAgentBrowsers {
+		if version, ok := userAgentVersion(userAgent, candidate.tokens); ok {
+			browser = strings.TrimSpace(candidate.name + " " + version)
+			break
+		}
+	}
+
+	for _, candidate := range userAgentSystems {
+		if containsAny(userAgent, candidate.tokens) {
+			system = candidate.name
+			break
+		}
+	}
+
+	if deviceType != "" {
+		return
+	}
+
+	switch {
+	case containsAny(userAgent, []string{"iPad", "Tablet"}), system == "Android" && !strings.Contains(userAgent, "Mobile"):
+		deviceType = "tablet"
+	case containsAny(userAgent, []string{"Mobi", "iPhone", "iPod"}):
+		deviceType = "mobile"
+	case system != "":
+		deviceType = "desktop"
+	}
+
+	return
+}
+
+// Busca el primer token en el user agent y devuelve la versión principal que le sigue
+func userAgentVersion(userAgent string, tokens []string) (version string, ok bool) {
+	for _, token := range tokens {
+		index := strings.Index(userAgent, token)
+		if index < 0 {
+			continue
+		}
+
+		version = userAgent[index+len(token):]
+		if end := strings.IndexAny(version, ". ;)"); end >= 0 {
+			version = version[:end]
+		}
+		return version, true
+	}
+
+	return "", false
+}
+
+func containsAny(value string, tokens []string) bool {
+	for _, token := range tokens {
+		if strings.Contains(value, token) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestParseUserAgent(t *testing.T) {
+	tests := []struct {
+		name       string
+		userAgent  string
+		browser    string
+		system     string
+		deviceType string
+	}{
+		{
+			name:       "Chrome en Windows",
+			userAgent:  "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			browser:    "Chrome 120",
+			system:     "Windows",
+			deviceType: "desktop",
+		},
+		{
+			name:       "Edge en Windows",
+			userAgent:  "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.2210.91",
+			browser:    "Edge 120",
+			system:     "Windows",
+			deviceType: "desktop",
+		},
+		{
+			name:       "Opera en macOS",
+			userAgent:  "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Safari/537.36 OPR/105.0.0.0",
+			browser:    "Opera 105",
+			system:     "macOS",
+			deviceType: "desktop",
+		},
+		{
+			name:       "Samsung Internet en Android",
+			userAgent:  "Mozilla/5.0 (Linux; Android 13; SM-S911B) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/23.0 Chrome/115.0.0.0 Mobile Safari/537.36",
+			browser:    "Samsung Internet 23",
+			system:     "Android",
+			deviceType: "mobile",
+		},
+		{
+			name:       "Chrome en iPhone",
+			userAgent:  "Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/120.0.6099.119 Mobile/15E148 Safari/604.1",
+			browser:    "Chrome 120",
+			system:     "iOS",
+			deviceType: "mobile",
+		},
+		{
+			name:       "Safari en iPad",
+			userAgent:  "Mozilla/5.0 (iPad; CPU OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Mobile/15E148 Safari/604.1",
+			browser:    "Safari 17",
+			system:     "iOS",
+			deviceType: "tablet",
+		},
+		{
+			name:       "Chrome en una tableta Android",
+			userAgent:  "Mozilla/5.0 (Linux; Android 13; SM-X710) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			browser:    "Chrome 120",
+			system:     "Android",
+			deviceType: "tablet",
+		},
+		{
+			name:       "Firefox en Linux",
+			userAgent:  "Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0",
+			browser:    "Firefox 121",
+			system:     "Linux",
+			deviceType: "desktop",
+		},
+		{
+			name:       "Googlebot",
+			userAgent:  "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			deviceType: "bot",
+		},
+		{
+			name:       "Googlebot para móviles",
+			userAgent:  "Mozilla/5.0 (Linux; Android 6.0.1; Nexus 5X Build/MMB29P) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			browser:    "Chrome 120",
+			system:     "Android",
+			deviceType: "bot",
+		},
+		{
+			name:      "curl",
+			userAgent: "curl/8.4.0",
+			browser:   "curl 8",
+		},
+		{
+			name:      "vacío",
+			userAgent: "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			browser, system, deviceType := ParseUserAgent(test.userAgent)
+			if browser != test.browser || system != test.system || deviceType != test.deviceType {
+				t.Errorf("se obtuvo (%q, %q, %q), se esperaba (%q, %q, %q)",
+					browser, system, deviceType, test.browser, test.system, test.deviceType)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// Partes fijas de un libro XLSX con una sola hoja
+var xlsxStaticParts = []struct {
+	Name    string
+	Content string
+}{
+	{
+		Name: "[Content_Types].xml",
+		Content: `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+			`</Types>`,
+	},
+	{
+		Name: "_rels/.rels",
+		Content: `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`,
+	},
+	{
+		Name: "xl/workbook.xml",
+		Content: `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="Hoja1" sheetId="1" r:id="rId1"/></sheets>` +
+			`</workbook>`,
+	},
+	{
+		Name: "xl/_rels/workbook.xml.rels",
+		Content: `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+			`</Relationships>`,
+	},
+}
+
+// XLSXWriter escribe una planilla XLSX fila por fila, sin mantener las filas en memoria
+type XLSXWriter struct {
+	zip   *zip.Writer
+	sheet io.Writer
+}
+
+/**
+ * Crea un XLSXWriter que escribe el libro en w
+ *
+ * @param w io.Writer "El destino del archivo"
+ * @return *XLSXWriter "El escritor de la planilla"
+ * @return error "El error al escribir el comienzo del archivo"
+ */
+func NewXLSXWriter(w io.Writer) (*XLSXWriter, error) {
+	archive := zip.NewWriter(w)
+
+	for _, part := range xlsxStaticParts {
+		file, err := archive.Create(part.Name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = io.WriteString(file, part.Content); err != nil {
+			return nil, err
+		}
+	}
+
+	sheet, err := archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = io.WriteString(sheet, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>`+
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	if err != nil {
+		return nil, err
+	}
+
+	return &XLSXWriter{zip: archive, sheet: sheet}, nil
+}
+
+/**
+ * Agrega una fila con los valores como texto
+ *
+ * @param values []string "Los valores de cada celda"
+ * @return error "El error de escritura"
+ */
+func (writer *XLSXWriter) Write(values []string) error {
+	var row bytes.Buffer
+	row.WriteString("<row>")
+	for _, value := range values {
+		row.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">`)
+		if err := xml.EscapeText(&row, []byte(value)); err != nil {
+			return err
+		}
+		row.WriteString("</t></is></c>")
+	}
+	row.WriteString("</row>")
+
+	_, err := writer.sheet.Write(row.Bytes())
+	return err
+}
+
+/**
+ * Cierra la hoja y el archivo. Debe llamarse después de escribir la última fila
+ *
+ * @return error "El error de escritura"
+ */
+func (writer *XLSXWriter) Close() error {
+	if _, err := io.WriteString(writer.sheet, "</sheetData></worksheet>"); err != nil {
+		return fmt.Errorf("no se pudo cerrar la hoja: %w", err)
+	}
+
+	return writer.zip.Close()
+}